@@ -13,6 +13,7 @@ type Config struct {
 	Db   Database            `yaml:"database"`
 	Lnds map[string]*LndInfo `yaml:"lnds"`
 	Btc  BitcoinInfo         `yaml:"btc"`
+	Lsp  LspInfo             `yaml:"lsp"`
 }
 
 type Database struct {
@@ -22,10 +23,16 @@ type Database struct {
 	Password string
 }
 
+// LndInfo configures a lnd node. Cert is a file path or the PEM encoded certificate, ServerName
+// overrides the host name it is verified against. Macaroons are file paths or hex encoded,
+// Macaroon is the admin macaroon. Each call uses the least privileged configured macaroon granting its permissions.
 type LndInfo struct {
-	Address  string `yaml:"address"`
-	Cert     string `yaml:"cert"`
-	Macaroon string `yaml:"macaroon"`
+	Address          string `yaml:"address"`
+	Cert             string `yaml:"cert"`
+	ServerName       string `yaml:"server_name"`
+	Macaroon         string `yaml:"macaroon"`
+	InvoiceMacaroon  string `yaml:"invoice_macaroon"`
+	ReadonlyMacaroon string `yaml:"readonly_macaroon"`
 }
 
 type BitcoinInfo struct {
@@ -35,6 +42,18 @@ type BitcoinInfo struct {
 	Mainnet  bool   `yaml:"mainnet"`
 }
 
+// LspInfo configures the swap provider. Provider names the implementation, boltz by default, URL its
+// API, the public one of Network if empty. Network is mainnet, testnet or regtest, swaps are stored in
+// SwapDir and Timeout is in seconds. Lnd names the node of Lnds whose funds are swapped.
+type LspInfo struct {
+	Provider string `yaml:"provider"`
+	Lnd      string `yaml:"lnd"`
+	URL      string `yaml:"url"`
+	Network  string `yaml:"network"`
+	SwapDir  string `yaml:"swap_dir"`
+	Timeout  int    `yaml:"timeout"`
+}
+
 var Cfg *Config
 
 var cso sync.Once
@@ -4,12 +4,15 @@ import (
 	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/jualy007/GoTF/config"
 	"github.com/jualy007/GoTF/log"
+	"net"
 	"net/rpc"
 	"net/rpc/jsonrpc"
 	"strconv"
+	"strings"
 )
 
 type Adapter struct {
@@ -87,6 +90,22 @@ func New(host string, port int, user, passwd string, useSSL bool, timeoutParam .
 	return &Bitcoind{rpcClient}, nil
 }
 
+// NewFromConfig return a new bitcoind for the node configured as [http(s)://]host:port
+func NewFromConfig(info config.BitcoinInfo, timeoutParam ...int) (*Bitcoind, error) {
+	address := info.Address
+	useSSL := strings.HasPrefix(address, "https://")
+	address = strings.TrimPrefix(strings.TrimPrefix(address, "https://"), "http://")
+	host, portStr, err := net.SplitHostPort(strings.TrimSuffix(address, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("Bad bitcoind address %q: %v", info.Address, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, fmt.Errorf("Bad bitcoind port %q: %v", portStr, err)
+	}
+	return New(host, port, info.User, info.Password, useSSL, timeoutParam...)
+}
+
 // BackupWallet Safely copies wallet.dat to destination,
 // which can be a directory or a path with filename on the remote server
 func (b *Bitcoind) BackupWallet(destination string) error {
@@ -328,6 +347,17 @@ func (b *Bitcoind) GetInfo() (i Info, err error) {
 	return
 }
 
+// GetBlockchainInfo returns an object containing various state info regarding blockchain processing.
+// https://bitcoincore.org/en/doc/0.16.0/rpc/blockchain/getblockchaininfo/
+func (b *Bitcoind) GetBlockchainInfo() (i BlockchainInfo, err error) {
+	r, err := b.client.call("getblockchaininfo", nil)
+	if err = handleError(err, &r); err != nil {
+		return
+	}
+	err = json.Unmarshal(r.Result, &i)
+	return
+}
+
 // GetMiningInfo returns an object containing mining-related information
 func (b *Bitcoind) GetMiningInfo() (miningInfo MiningInfo, err error) {
 	r, err := b.client.call("getmininginfo", nil)
@@ -765,6 +795,16 @@ func (b *Bitcoind) SendManyReplaceable(fromAccount string, amounts map[string]fl
 	return
 }
 
+// SendRawTransaction submits a hex encoded signed transaction to the node and returns its id
+func (b *Bitcoind) SendRawTransaction(txHex string) (txID string, err error) {
+	r, err := b.client.call("sendrawtransaction", []interface{}{txHex})
+	if err = handleError(err, &r); err != nil {
+		return
+	}
+	err = json.Unmarshal(r.Result, &txID)
+	return
+}
+
 // SendToAddress send an amount to a given address
 func (b *Bitcoind) SendToAddress(toAddress string, amount float64, comment, commentTo string) (txID string, err error) {
 	r, err := b.client.call("sendtoaddress", []interface{}{toAddress, amount, comment, commentTo})
@@ -788,6 +828,17 @@ func (b *Bitcoind) SetGenerate(generate bool, genProcLimit int32) error {
 	return handleError(err, &r)
 }
 
+// GenerateToAddress mines nblocks blocks to address immediately, regtest only.
+// It returns the hashes of the mined blocks.
+func (b *Bitcoind) GenerateToAddress(nblocks int, address string) (blockHashes []string, err error) {
+	r, err := b.client.call("generatetoaddress", []interface{}{nblocks, address})
+	if err = handleError(err, &r); err != nil {
+		return
+	}
+	err = json.Unmarshal(r.Result, &blockHashes)
+	return
+}
+
 // SetTxFee set the transaction fee per kB
 func (b *Bitcoind) SetTxFee(amount float64) error {
 	r, err := b.client.call("settxfee", []interface{}{amount})
@@ -841,6 +892,29 @@ func (b *Bitcoind) ValidateAddress(address string) (va ValidateAddressResponse,
 	return
 }
 
+// DeriveAddresses derives one or more addresses corresponding to an output descriptor.
+// For ranged descriptors [rng] must be given as the end or the begin and end (inclusive) of the range.
+// https://bitcoincore.org/en/doc/0.21.0/rpc/util/deriveaddresses/
+func (b *Bitcoind) DeriveAddresses(descriptor string, rng ...uint32) (addresses []string, err error) {
+	params := []interface{}{descriptor}
+	switch len(rng) {
+	case 0:
+	case 1:
+		params = append(params, rng[0])
+	case 2:
+		params = append(params, rng)
+	default:
+		err = errors.New("Bad parameters for DeriveAddresses: you can set 0, 1 or 2 range values")
+		return
+	}
+	r, err := b.client.call("deriveaddresses", params)
+	if err = handleError(err, &r); err != nil {
+		return
+	}
+	err = json.Unmarshal(r.Result, &addresses)
+	return
+}
+
 // WalletLock Removes the wallet encryption key from memory, locking the wallet.
 // After calling this method, you will need to call walletpassphrase again before being
 // able to call any methods which require the wallet to be unlocked.
@@ -0,0 +1,135 @@
+// Package btctest serves a fake bitcoind JSON-RPC API over httptest for the tests of the btc clients.
+package btctest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/jualy007/GoTF/blockchain/btc"
+	"github.com/jualy007/GoTF/config"
+)
+
+// Credentials of the RPC API of fake servers.
+const (
+	User     = "user"
+	Password = "password"
+)
+
+// Error codes of bitcoind used by fake servers.
+const (
+	RPCMiscError      btc.RPCErrorCode = -1
+	RPCMethodNotFound btc.RPCErrorCode = -32601
+)
+
+// Handler answers a call with its params. Returning a *btc.RPCError or a btc.RPCError answers
+// with that error, any other error with RPCMiscError.
+type Handler func(params []json.RawMessage) (interface{}, error)
+
+// Server is a fake bitcoind answering the methods given a Handler or a Result, and
+// RPCMethodNotFound otherwise.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	handlers map[string]Handler
+	calls    map[string]int
+}
+
+// NewServer starts a fake bitcoind without methods.
+func NewServer() *Server {
+	s := &Server{handlers: make(map[string]Handler), calls: make(map[string]int)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Handle answers the calls of method with handler.
+func (s *Server) Handle(method string, handler Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[method] = handler
+}
+
+// Result answers every call of method with result.
+func (s *Server) Result(method string, result interface{}) {
+	s.Handle(method, func([]json.RawMessage) (interface{}, error) {
+		return result, nil
+	})
+}
+
+// Error answers every call of method with the RPC error code and message.
+func (s *Server) Error(method string, code btc.RPCErrorCode, message string) {
+	s.Handle(method, func([]json.RawMessage) (interface{}, error) {
+		return nil, &btc.RPCError{Code: code, Message: message}
+	})
+}
+
+// Calls returns the number of calls of method.
+func (s *Server) Calls(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[method]
+}
+
+// BitcoinInfo returns the configuration of the server.
+func (s *Server) BitcoinInfo() config.BitcoinInfo {
+	return config.BitcoinInfo{Address: s.URL, User: User, Password: Password}
+}
+
+// Bitcoind returns a client of the server.
+func (s *Server) Bitcoind() (*btc.Bitcoind, error) {
+	return btc.NewFromConfig(s.BitcoinInfo(), 5)
+}
+
+type request struct {
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+	ID     int64             `json:"id"`
+}
+
+type response struct {
+	Result interface{}   `json:"result"`
+	Error  *btc.RPCError `json:"error"`
+	ID     int64         `json:"id"`
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	// bitcoind answers a bad authentication with an empty 401.
+	if user, password, ok := r.BasicAuth(); !ok || user != User || password != Password {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var req request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.calls[req.Method]++
+	handler, ok := s.handlers[req.Method]
+	s.mu.Unlock()
+
+	resp := response{ID: req.ID}
+	status := http.StatusOK
+	if !ok {
+		resp.Error = &btc.RPCError{Code: RPCMethodNotFound, Message: "Method not found"}
+		status = http.StatusNotFound
+	} else if result, err := handler(req.Params); err != nil {
+		switch e := err.(type) {
+		case *btc.RPCError:
+			resp.Error = e
+		case btc.RPCError:
+			resp.Error = &e
+		default:
+			resp.Error = &btc.RPCError{Code: RPCMiscError, Message: err.Error()}
+		}
+		status = http.StatusInternalServerError
+	} else {
+		resp.Result = result
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
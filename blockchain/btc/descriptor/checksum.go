@@ -0,0 +1,106 @@
+package descriptor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// inputCharset is the set of characters allowed in a descriptor, ordered so that
+// the low 5 bits of the position carry most of the information (see Bitcoin Core descriptor.cpp).
+const inputCharset = "0123456789()[],'/*abcdefgh@:$%{}" +
+	"IJKLMNOPQRSTUVWXYZ&+-.;<=>?!^_|~" +
+	"ijklmnopqrstuvwxyzABCDEFGH`#\"\\ "
+
+// checksumCharset is the bech32 character set used to render the checksum.
+const checksumCharset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+const checksumLength = 8
+
+func polyMod(c uint64, val int) uint64 {
+	c0 := c >> 35
+	c = ((c & 0x7ffffffff) << 5) ^ uint64(val)
+	if c0&1 != 0 {
+		c ^= 0xf5dee51989
+	}
+	if c0&2 != 0 {
+		c ^= 0xa9fdca3312
+	}
+	if c0&4 != 0 {
+		c ^= 0x1bab10e32d
+	}
+	if c0&8 != 0 {
+		c ^= 0x3706b1677a
+	}
+	if c0&16 != 0 {
+		c ^= 0x644d626ffd
+	}
+	return c
+}
+
+// Checksum computes the 8 character checksum of a descriptor (without any '#' suffix).
+func Checksum(desc string) (string, error) {
+	c := uint64(1)
+	cls := 0
+	clsCount := 0
+	for _, ch := range desc {
+		pos := strings.IndexRune(inputCharset, ch)
+		if pos < 0 {
+			return "", fmt.Errorf("invalid character %q in descriptor", ch)
+		}
+		//Emit a symbol for the position inside the group, for every character.
+		c = polyMod(c, pos&31)
+		//Accumulate the group numbers
+		cls = cls*3 + (pos >> 5)
+		clsCount++
+		if clsCount == 3 {
+			//Emit an extra symbol representing the group numbers, for every 3 characters.
+			c = polyMod(c, cls)
+			cls = 0
+			clsCount = 0
+		}
+	}
+	if clsCount > 0 {
+		c = polyMod(c, cls)
+	}
+	//Shift further to determine the checksum.
+	for j := 0; j < checksumLength; j++ {
+		c = polyMod(c, 0)
+	}
+	//Prevent appending zeroes from not affecting the checksum.
+	c ^= 1
+
+	var sb strings.Builder
+	for j := 0; j < checksumLength; j++ {
+		sb.WriteByte(checksumCharset[(c>>uint(5*(7-j)))&31])
+	}
+	return sb.String(), nil
+}
+
+// AddChecksum returns desc with its '#checksum' suffix appended.
+func AddChecksum(desc string) (string, error) {
+	checksum, err := Checksum(desc)
+	if err != nil {
+		return "", err
+	}
+	return desc + "#" + checksum, nil
+}
+
+// splitChecksum separates desc from an optional '#checksum' suffix and verifies the checksum if present.
+func splitChecksum(desc string) (string, error) {
+	idx := strings.IndexByte(desc, '#')
+	if idx < 0 {
+		return desc, nil
+	}
+	body, checksum := desc[:idx], desc[idx+1:]
+	if len(checksum) != checksumLength {
+		return "", fmt.Errorf("expected %d character checksum, not %d characters", checksumLength, len(checksum))
+	}
+	expected, err := Checksum(body)
+	if err != nil {
+		return "", err
+	}
+	if checksum != expected {
+		return "", fmt.Errorf("provided checksum '%s' does not match computed checksum '%s'", checksum, expected)
+	}
+	return body, nil
+}
@@ -0,0 +1,511 @@
+// Package descriptor parses Bitcoin output script descriptors and derives the
+// scripts and addresses they describe.
+//
+// Supported expressions are pk, pkh, wpkh, sh, wsh, multi, sortedmulti, tr (key path only), addr and raw.
+// See https://github.com/bitcoin/bitcoin/blob/master/doc/descriptors.md for the language.
+package descriptor
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/hdkeychain"
+
+	"github.com/jualy007/GoTF/blockchain/btc"
+)
+
+// ErrNoAddress is returned when a descriptor has no corresponding address, e.g. pk() or bare multi().
+var ErrNoAddress = errors.New("descriptor does not have a corresponding address")
+
+type scriptContext int
+
+const (
+	ctxTop scriptContext = iota
+	ctxSh
+	ctxWitness
+	ctxTr
+)
+
+func (ctx scriptContext) String() string {
+	switch ctx {
+	case ctxSh:
+		return "sh()"
+	case ctxWitness:
+		return "wpkh()/wsh()"
+	case ctxTr:
+		return "tr()"
+	default:
+		return "top level"
+	}
+}
+
+// maxMultiKeys is the maximum number of keys allowed in multi() per context:
+// bare multisig is only standard up to 3 keys, P2SH is bounded by the 520 byte redeem script limit.
+var maxMultiKeys = map[scriptContext]int{
+	ctxTop:     3,
+	ctxSh:      15,
+	ctxWitness: 20,
+}
+
+// Descriptor is a parsed output script descriptor.
+type Descriptor struct {
+	desc string
+	net  *chaincfg.Params
+	root node
+}
+
+// node is one expression of the descriptor tree.
+type node interface {
+	script(index uint32) ([]byte, error)
+	address(index uint32, net *chaincfg.Params) (btcutil.Address, error)
+	isRange() bool
+}
+
+// Parse parses desc for the network net. A '#checksum' suffix is verified when present.
+func Parse(desc string, net *chaincfg.Params) (*Descriptor, error) {
+	body, err := splitChecksum(strings.TrimSpace(desc))
+	if err != nil {
+		return nil, err
+	}
+	root, err := parseExpr(body, ctxTop, net)
+	if err != nil {
+		return nil, err
+	}
+	return &Descriptor{desc: body, net: net, root: root}, nil
+}
+
+// String returns the descriptor with its checksum appended.
+func (d *Descriptor) String() string {
+	desc, err := AddChecksum(d.desc)
+	if err != nil {
+		return d.desc
+	}
+	return desc
+}
+
+// IsRange reports whether the descriptor contains a ranged (/*) key.
+func (d *Descriptor) IsRange() bool {
+	return d.root.isRange()
+}
+
+// Script returns the scriptPubKey at position index. The index is ignored for non-ranged descriptors.
+func (d *Descriptor) Script(index uint32) ([]byte, error) {
+	return d.root.script(index)
+}
+
+// Address returns the address at position index. The index is ignored for non-ranged descriptors.
+func (d *Descriptor) Address(index uint32) (string, error) {
+	addr, err := d.root.address(index, d.net)
+	if err != nil {
+		return "", err
+	}
+	return addr.EncodeAddress(), nil
+}
+
+// Scripts returns the scriptPubKeys for the inclusive range [start, end].
+func (d *Descriptor) Scripts(start, end uint32) ([][]byte, error) {
+	if err := d.checkRange(start, end); err != nil {
+		return nil, err
+	}
+	scripts := make([][]byte, 0, end-start+1)
+	for i := start; ; i++ {
+		script, err := d.Script(i)
+		if err != nil {
+			return nil, fmt.Errorf("script at index %d: %w", i, err)
+		}
+		scripts = append(scripts, script)
+		if i == end {
+			break
+		}
+	}
+	return scripts, nil
+}
+
+// DeriveAddresses returns the addresses for the inclusive range [start, end],
+// mirroring bitcoind's deriveaddresses.
+func (d *Descriptor) DeriveAddresses(start, end uint32) ([]string, error) {
+	if err := d.checkRange(start, end); err != nil {
+		return nil, err
+	}
+	addresses := make([]string, 0, end-start+1)
+	for i := start; ; i++ {
+		addr, err := d.Address(i)
+		if err != nil {
+			return nil, fmt.Errorf("address at index %d: %w", i, err)
+		}
+		addresses = append(addresses, addr)
+		if i == end {
+			break
+		}
+	}
+	return addresses, nil
+}
+
+func (d *Descriptor) checkRange(start, end uint32) error {
+	if !d.IsRange() && (start != 0 || end != 0) {
+		return errors.New("range should not be specified for an un-ranged descriptor")
+	}
+	if start > end {
+		return fmt.Errorf("range specified as [begin,end] must not have begin after end: [%d,%d]", start, end)
+	}
+	if end >= hdkeychain.HardenedKeyStart {
+		return fmt.Errorf("range end must be less than 2^31: [%d,%d]", start, end)
+	}
+	return nil
+}
+
+// parseExpr parses one SCRIPT expression valid in ctx.
+func parseExpr(expr string, ctx scriptContext, net *chaincfg.Params) (node, error) {
+	name, args, err := splitCall(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	switch name {
+	case "pk", "pkh", "wpkh":
+		if name == "wpkh" {
+			if ctx != ctxTop && ctx != ctxSh {
+				return nil, fmt.Errorf("can only have wpkh() at top level or inside sh()")
+			}
+			ctx = ctxWitness
+		}
+		key, err := parseKey(args, ctx, net)
+		if err != nil {
+			return nil, fmt.Errorf("%s(): %w", name, err)
+		}
+		return &keyNode{name: name, key: key, ctx: ctx}, nil
+
+	case "sh":
+		if ctx != ctxTop {
+			return nil, fmt.Errorf("can only have sh() at top level")
+		}
+		sub, err := parseExpr(args, ctxSh, net)
+		if err != nil {
+			return nil, err
+		}
+		return &shNode{sub: sub}, nil
+
+	case "wsh":
+		if ctx != ctxTop && ctx != ctxSh {
+			return nil, fmt.Errorf("can only have wsh() at top level or inside sh()")
+		}
+		sub, err := parseExpr(args, ctxWitness, net)
+		if err != nil {
+			return nil, err
+		}
+		return &wshNode{sub: sub}, nil
+
+	case "multi", "sortedmulti":
+		if ctx == ctxTr {
+			return nil, fmt.Errorf("%s() is not allowed in tr()", name)
+		}
+		parts := splitArgs(args)
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("%s(): expected a threshold and at least one key", name)
+		}
+		threshold, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("multi threshold '%s' is not valid", parts[0])
+		}
+		keys := make([]*keyExpr, 0, len(parts)-1)
+		for _, part := range parts[1:] {
+			key, err := parseKey(part, ctx, net)
+			if err != nil {
+				return nil, fmt.Errorf("%s(): %w", name, err)
+			}
+			keys = append(keys, key)
+		}
+		if threshold < 1 || threshold > len(keys) {
+			return nil, fmt.Errorf("multisig threshold cannot be %d, must be at least 1 and at most %d", threshold, len(keys))
+		}
+		if max := maxMultiKeys[ctx]; len(keys) > max {
+			return nil, fmt.Errorf("cannot have %d keys in %s multisig; must have between 1 and %d keys, inclusive", len(keys), ctx, max)
+		}
+		return &multiNode{threshold: threshold, keys: keys, sorted: name == "sortedmulti"}, nil
+
+	case "tr":
+		if ctx != ctxTop {
+			return nil, fmt.Errorf("can only have tr() at top level")
+		}
+		if len(splitArgs(args)) != 1 {
+			return nil, fmt.Errorf("tr(): script trees are not supported, only tr(KEY)")
+		}
+		key, err := parseKey(args, ctxTr, net)
+		if err != nil {
+			return nil, fmt.Errorf("tr(): %w", err)
+		}
+		return &trNode{key: key}, nil
+
+	case "addr":
+		if ctx != ctxTop {
+			return nil, fmt.Errorf("can only have addr() at top level")
+		}
+		addr, err := btc.DecodeAddress(args, net)
+		if err != nil {
+			return nil, fmt.Errorf("address '%s' is not valid: %w", args, err)
+		}
+		if !addr.IsForNet(net) {
+			return nil, fmt.Errorf("address '%s' is not for network %s", args, net.Name)
+		}
+		return &addrNode{addr: addr}, nil
+
+	case "raw":
+		if ctx != ctxTop {
+			return nil, fmt.Errorf("can only have raw() at top level")
+		}
+		script, err := hex.DecodeString(args)
+		if err != nil {
+			return nil, fmt.Errorf("raw script is not hex: %w", err)
+		}
+		return &rawNode{raw: script}, nil
+	}
+
+	return nil, fmt.Errorf("'%s' is not a valid descriptor function", name)
+}
+
+// splitCall splits "name(args)" into its name and argument string.
+func splitCall(expr string) (string, string, error) {
+	open := strings.IndexByte(expr, '(')
+	if open <= 0 || !strings.HasSuffix(expr, ")") {
+		return "", "", fmt.Errorf("'%s' is not a valid descriptor expression", expr)
+	}
+	return expr[:open], expr[open+1 : len(expr)-1], nil
+}
+
+// splitArgs splits a comma separated argument list, ignoring commas nested in brackets.
+func splitArgs(args string) []string {
+	var parts []string
+	depth := 0
+	last := 0
+	for i, ch := range args {
+		switch ch {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, args[last:i])
+				last = i + 1
+			}
+		}
+	}
+	return append(parts, args[last:])
+}
+
+// keyNode is pk(KEY), pkh(KEY) or wpkh(KEY).
+type keyNode struct {
+	name string
+	key  *keyExpr
+	ctx  scriptContext
+}
+
+func (n *keyNode) isRange() bool {
+	return n.key.isRange()
+}
+
+func (n *keyNode) script(index uint32) ([]byte, error) {
+	pubKey, err := n.key.serialize(index, n.ctx)
+	if err != nil {
+		return nil, err
+	}
+	switch n.name {
+	case "pk":
+		return txscript.NewScriptBuilder().AddData(pubKey).AddOp(txscript.OP_CHECKSIG).Script()
+	case "pkh":
+		return btc.NewP2PKHScriptPubKey(btcutil.Hash160(pubKey))
+	default:
+		return txscript.NewScriptBuilder().AddOp(txscript.OP_0).AddData(btcutil.Hash160(pubKey)).Script()
+	}
+}
+
+func (n *keyNode) address(index uint32, net *chaincfg.Params) (btcutil.Address, error) {
+	if n.name == "pk" {
+		return nil, ErrNoAddress
+	}
+	pubKey, err := n.key.serialize(index, n.ctx)
+	if err != nil {
+		return nil, err
+	}
+	if n.name == "pkh" {
+		return btcutil.NewAddressPubKeyHash(btcutil.Hash160(pubKey), net)
+	}
+	return btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(pubKey), net)
+}
+
+// shNode is sh(SCRIPT).
+type shNode struct {
+	sub node
+}
+
+func (n *shNode) isRange() bool {
+	return n.sub.isRange()
+}
+
+func (n *shNode) script(index uint32) ([]byte, error) {
+	redeemScript, err := n.sub.script(index)
+	if err != nil {
+		return nil, err
+	}
+	return btc.NewP2SHScriptPubKey(btcutil.Hash160(redeemScript))
+}
+
+func (n *shNode) address(index uint32, net *chaincfg.Params) (btcutil.Address, error) {
+	redeemScript, err := n.sub.script(index)
+	if err != nil {
+		return nil, err
+	}
+	return btcutil.NewAddressScriptHash(redeemScript, net)
+}
+
+// wshNode is wsh(SCRIPT).
+type wshNode struct {
+	sub node
+}
+
+func (n *wshNode) isRange() bool {
+	return n.sub.isRange()
+}
+
+func (n *wshNode) script(index uint32) ([]byte, error) {
+	witnessScript, err := n.sub.script(index)
+	if err != nil {
+		return nil, err
+	}
+	witnessProg := sha256.Sum256(witnessScript)
+	return txscript.NewScriptBuilder().AddOp(txscript.OP_0).AddData(witnessProg[:]).Script()
+}
+
+func (n *wshNode) address(index uint32, net *chaincfg.Params) (btcutil.Address, error) {
+	witnessScript, err := n.sub.script(index)
+	if err != nil {
+		return nil, err
+	}
+	witnessProg := sha256.Sum256(witnessScript)
+	return btcutil.NewAddressWitnessScriptHash(witnessProg[:], net)
+}
+
+// multiNode is multi(k,KEY,...) or sortedmulti(k,KEY,...).
+type multiNode struct {
+	threshold int
+	keys      []*keyExpr
+	sorted    bool
+}
+
+func (n *multiNode) isRange() bool {
+	for _, key := range n.keys {
+		if key.isRange() {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *multiNode) script(index uint32) ([]byte, error) {
+	pubKeys := make([][]byte, len(n.keys))
+	for i, key := range n.keys {
+		pubKey, err := key.serialize(index, ctxTop)
+		if err != nil {
+			return nil, err
+		}
+		pubKeys[i] = pubKey
+	}
+	if n.sorted {
+		//BIP67: sort public keys lexicographically by their serialization
+		sort.Slice(pubKeys, func(i, j int) bool {
+			return bytes.Compare(pubKeys[i], pubKeys[j]) < 0
+		})
+	}
+	builder := txscript.NewScriptBuilder().AddInt64(int64(n.threshold))
+	for _, pubKey := range pubKeys {
+		builder.AddData(pubKey)
+	}
+	return builder.AddInt64(int64(len(pubKeys))).AddOp(txscript.OP_CHECKMULTISIG).Script()
+}
+
+func (n *multiNode) address(index uint32, net *chaincfg.Params) (btcutil.Address, error) {
+	return nil, ErrNoAddress
+}
+
+// trNode is tr(KEY), a key path only taproot output.
+type trNode struct {
+	key *keyExpr
+}
+
+func (n *trNode) isRange() bool {
+	return n.key.isRange()
+}
+
+func (n *trNode) outputKey(index uint32) ([]byte, error) {
+	internalKey, err := n.key.serialize(index, ctxTr)
+	if err != nil {
+		return nil, err
+	}
+	return btc.TaprootOutputKey(internalKey, nil)
+}
+
+func (n *trNode) script(index uint32) ([]byte, error) {
+	outputKey, err := n.outputKey(index)
+	if err != nil {
+		return nil, err
+	}
+	return btc.NewP2TRScriptPubKey(outputKey)
+}
+
+func (n *trNode) address(index uint32, net *chaincfg.Params) (btcutil.Address, error) {
+	outputKey, err := n.outputKey(index)
+	if err != nil {
+		return nil, err
+	}
+	return btc.NewAddressTaproot(outputKey, net)
+}
+
+// addrNode is addr(ADDR).
+type addrNode struct {
+	addr btcutil.Address
+}
+
+func (n *addrNode) isRange() bool {
+	return false
+}
+
+func (n *addrNode) script(uint32) ([]byte, error) {
+	return btc.PayToAddrScript(n.addr)
+}
+
+func (n *addrNode) address(uint32, *chaincfg.Params) (btcutil.Address, error) {
+	return n.addr, nil
+}
+
+// rawNode is raw(HEX).
+type rawNode struct {
+	raw []byte
+}
+
+func (n *rawNode) isRange() bool {
+	return false
+}
+
+func (n *rawNode) script(uint32) ([]byte, error) {
+	return n.raw, nil
+}
+
+func (n *rawNode) address(_ uint32, net *chaincfg.Params) (btcutil.Address, error) {
+	if len(n.raw) == 34 && n.raw[0] == txscript.OP_1 && n.raw[1] == txscript.OP_DATA_32 {
+		return btc.NewAddressTaproot(n.raw[2:], net)
+	}
+	class, addresses, _, err := txscript.ExtractPkScriptAddrs(n.raw, net)
+	if err != nil || len(addresses) != 1 || class == txscript.PubKeyTy || class == txscript.MultiSigTy {
+		return nil, ErrNoAddress
+	}
+	return addresses[0], nil
+}
@@ -0,0 +1,322 @@
+package descriptor_test
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+
+	"github.com/jualy007/GoTF/blockchain/btc"
+	"github.com/jualy007/GoTF/blockchain/btc/btctest"
+	"github.com/jualy007/GoTF/blockchain/btc/descriptor"
+)
+
+// The keys of the Bitcoin Core descriptor tests and of the "abandon ... about" mnemonic of BIP86.
+const (
+	coreXprv1 = "xprvA1RpRA33e1JQ7ifknakTFpgNXPmW2YvmhqLQYMmrj4xJXXWYpDPS3xz7iAxn8L39njGVyuoseXzU6rcxFLJ8HFsTjSyQbLYnMpCqE2VbFWc"
+	coreXprv2 = "xprv9uPDJpEQgRQfDcW7BkF7eTya6RPxXeJCqCJGHuCJ4GiRVLzkTXBAJMu2qaMWPrS7AANYqdq6vcBcBUdJCVVFceUvJFjaPdGZ2y9WACViL4L"
+	coreXpub1 = "xpub6ERApfZwUNrhLCkDtcHTcxd75RbzS1ed54G1LkBUHQVHQKqhMkhgbmJbZRkrgZw4koxb5JaHWkY4ALHY2grBGRjaDMzQLcgJvLJuZZvRcEL"
+	coreXpub2 = "xpub68NZiKmJWnxxS6aaHmn81bvJeTESw724CRDs6HbuccFQN9Ku14VQrADWgqbhhTHBaohPX4CjNLf9fq9MYo6oDaPPLPxSb7gwQN3ih19Zm4Y"
+
+	bip86Root    = "xprv9s21ZrQH143K3GJpoapnV8SFfukcVBSfeCficPSGfubmSFDxo1kuHnLisriDvSnRRuL2Qrg5ggqHKNVpxR86QEC8w35uxmGoggxtQTPvfUu"
+	bip86Account = "xpub6BgBgsespWvERF3LHQu6CnqdvfEvtMcQjYrcRzx53QJjSxarj2afYWcLteoGVky7D3UKDP9QyrLprQ3VCECoY49yfdDEHGCtMMj92pReUsQ"
+)
+
+func TestChecksum(t *testing.T) {
+	tests := []struct {
+		desc     string
+		checksum string
+	}{
+		{"pkh([d34db33f/44'/0'/0']" + coreXpub1 + "/1/*)", "ml40v0wf"},
+		{"sh(multi(2,[00000000/111'/222]" + coreXprv1 + "," + coreXprv2 + "/0))", "ggrsrxfy"},
+		{"sh(multi(2,[00000000/111'/222]" + coreXpub1 + "," + coreXpub2 + "/0))", "tjg09x5t"},
+	}
+	for _, test := range tests {
+		checksum, err := descriptor.Checksum(test.desc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if checksum != test.checksum {
+			t.Errorf("Checksum(%v) = %v, want %v", test.desc, checksum, test.checksum)
+		}
+		if _, err := descriptor.Parse(test.desc+"#"+test.checksum, &chaincfg.MainNetParams); err != nil {
+			t.Errorf("Parse with checksum %v: %v", test.checksum, err)
+		}
+	}
+
+	if _, err := descriptor.Checksum("pkh(é)"); err == nil {
+		t.Error("Checksum accepted a character outside the descriptor charset")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	sh := "sh(multi(2,[00000000/111'/222]" + coreXpub1 + "," + coreXpub2 + "/0))"
+	tests := []struct {
+		name string
+		desc string
+	}{
+		{"empty checksum", sh + "#"},
+		{"short checksum", sh + "#tjg09x5"},
+		{"long checksum", sh + "#tjg09x5tt"},
+		{"wrong checksum", sh + "#tjg09x5f"},
+		{"checksum of the private descriptor", sh + "#ggrsrxfy"},
+		{"unknown function", "foo(" + coreXpub1 + ")"},
+		{"not a call", coreXpub1},
+		{"sh in sh", "sh(sh(pk(" + coreXpub1 + ")))"},
+		{"wpkh in wsh", "wsh(wpkh(" + coreXpub1 + "))"},
+		{"uncompressed key in wpkh", "wpkh(04a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd5b8dec5235a0fa8722476c7709c02559e3aa73aa03918ba2d492eea75abea235)"},
+		{"invalid pubkey prefix", "pk(05a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd)"},
+		{"short pubkey", "pk(03a34b99)"},
+		{"hardened derivation of a public key", "pkh(" + coreXpub1 + "/1'/*)"},
+		{"star in the middle of a path", "pkh(" + coreXpub1 + "/*/1)"},
+		{"path element out of range", "pkh(" + coreXpub1 + "/2147483648)"},
+		{"unterminated origin", "pkh([d34db33f/44'" + coreXpub1 + ")"},
+		{"short fingerprint", "pkh([d34db3/44']" + coreXpub1 + ")"},
+		{"threshold above the key count", "sh(multi(3," + coreXpub1 + "," + coreXpub2 + "))"},
+		{"zero threshold", "sh(multi(0," + coreXpub1 + "))"},
+		{"bare multi above 3 keys", "multi(1," + strings.Repeat(coreXpub1+",", 3) + coreXpub2 + ")"},
+		{"multi in tr", "tr(multi(1," + coreXpub1 + "))"},
+		{"tr script tree", "tr(" + coreXpub1 + ",pk(" + coreXpub2 + "))"},
+		{"tr in sh", "sh(tr(" + coreXpub1 + "))"},
+		{"testnet address", "addr(tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx)"},
+		{"raw not hex", "raw(zz)"},
+		{"testnet private key", "pkh(cVt4o7BGAig1UXywgGSmARhxMdzP5qvQsxKkSsc1XEkw3tDTQFpy)"},
+	}
+	for _, test := range tests {
+		if _, err := descriptor.Parse(test.desc, &chaincfg.MainNetParams); err == nil {
+			t.Errorf("%v: Parse(%v) succeeded", test.name, test.desc)
+		}
+	}
+}
+
+func TestScripts(t *testing.T) {
+	tests := []struct {
+		desc    string
+		script  string
+		address string
+	}{
+		{"wpkh(L4rK1yDtCWekvXuE6oXD9jCYfFNV2cWRpVuPLBcCU2z8TrisoyY1)", "00149a1c78a507689f6f54b847ad1cef1e614ee23f1e", ""},
+		{"wpkh(03a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd)", "00149a1c78a507689f6f54b847ad1cef1e614ee23f1e", ""},
+		{"pkh(03a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd)", "76a9149a1c78a507689f6f54b847ad1cef1e614ee23f1e88ac", "1F3sAm6ZtwLAUnj7d38pGFxtP3RVEvtsbV"},
+		{"addr(bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0)", "512079be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798", "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0"},
+		{"raw(512079be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798)", "512079be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798", "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0"},
+	}
+	for _, test := range tests {
+		d, err := descriptor.Parse(test.desc, &chaincfg.MainNetParams)
+		if err != nil {
+			t.Fatalf("Parse(%v): %v", test.desc, err)
+		}
+		script, err := d.Script(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hex.EncodeToString(script) != test.script {
+			t.Errorf("%v script %x, want %v", test.desc, script, test.script)
+		}
+		if test.address == "" {
+			continue
+		}
+		if address, err := d.Address(0); err != nil || address != test.address {
+			t.Errorf("%v address %v, %v, want %v", test.desc, address, err, test.address)
+		}
+	}
+
+	d, err := descriptor.Parse("sh(sortedmulti(1,"+coreXpub1+","+coreXpub2+"))", &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Address(0); err != nil {
+		t.Errorf("sh(sortedmulti()) address: %v", err)
+	}
+	bare, err := descriptor.Parse("multi(1,"+coreXpub1+")", &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bare.Address(0); err != descriptor.ErrNoAddress {
+		t.Errorf("bare multi() address: %v, want ErrNoAddress", err)
+	}
+}
+
+// TestBIP86 derives the addresses of the BIP86 test vectors from the root and from the account key.
+func TestBIP86(t *testing.T) {
+	receive := []string{
+		"bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr",
+		"bc1p4qhjn9zdvkux4e44uhx8tc55attvtyu358kutcqkudyccelu0was9fqzwh",
+	}
+	change := "bc1p3qkhfews2uk44qtvauqyr2ttdsw7svhkl9nkm9s9c3x4ax5h60wqwruhk7"
+
+	for _, desc := range []string{"tr(" + bip86Root + "/86'/0'/0'/0/*)", "tr([73c5da0a/86'/0'/0']" + bip86Account + "/0/*)"} {
+		d, err := descriptor.Parse(desc, &chaincfg.MainNetParams)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !d.IsRange() {
+			t.Errorf("%v is not ranged", desc)
+		}
+		addresses, err := d.DeriveAddresses(0, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(addresses) != 2 || addresses[0] != receive[0] || addresses[1] != receive[1] {
+			t.Errorf("%v addresses %v, want %v", desc, addresses, receive)
+		}
+	}
+
+	d, err := descriptor.Parse("tr("+bip86Account+"/1/*)", &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if address, err := d.Address(0); err != nil || address != change {
+		t.Errorf("change address %v, %v, want %v", address, err, change)
+	}
+
+	internalKey, _ := hex.DecodeString("cc8a4bc64d897bddc5fbc2f670f7a8ba0b386779106cf1223c6fc5d7cd6fc115")
+	outputKey, err := btc.TaprootOutputKey(internalKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(outputKey) != "a60869f0dbcf1dc659c9cecbaf8050135ea9e8cdc487053f1dc6880949dc684c" {
+		t.Errorf("TaprootOutputKey = %x", outputKey)
+	}
+}
+
+func TestRange(t *testing.T) {
+	hardened, err := descriptor.Parse("tr("+bip86Root+"/86'/0'/0'/0/*')", &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hardened.DeriveAddresses(0, 2); err != nil {
+		t.Errorf("hardened range: %v", err)
+	}
+	for _, end := range []uint32{1 << 31, 1<<32 - 1} {
+		if _, err := hardened.DeriveAddresses(end-1, end); err == nil {
+			t.Errorf("hardened range ending at %d derived", end)
+		}
+		if _, err := hardened.Address(end); err == nil {
+			t.Errorf("hardened index %d derived", end)
+		}
+	}
+	if _, err := hardened.DeriveAddresses(3, 2); err == nil {
+		t.Error("range with begin after end derived")
+	}
+
+	unranged, err := descriptor.Parse("tr("+bip86Account+"/0/0)", &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := unranged.DeriveAddresses(0, 1); err == nil {
+		t.Error("range of an unranged descriptor derived")
+	}
+	if addresses, err := unranged.DeriveAddresses(0, 0); err != nil || len(addresses) != 1 {
+		t.Errorf("unranged addresses %v, %v", addresses, err)
+	}
+}
+
+// TestSegWitAddresses checks the BIP350 valid and invalid address vectors.
+func TestSegWitAddresses(t *testing.T) {
+	valid := []struct {
+		address string
+		version byte
+		program string
+	}{
+		{"BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4", 0, "751e76e8199196d454941c45d1b3a323f1433bd6"},
+		{"bc1pw508d6qejxtdg4y5r3zarvary0c5xw7kw508d6qejxtdg4y5r3zarvary0c5xw7kt5nd6y", 1, "751e76e8199196d454941c45d1b3a323f1433bd6751e76e8199196d454941c45d1b3a323f1433bd6"},
+		{"BC1SW50QGDZ25J", 16, "751e"},
+		{"bc1zw508d6qejxtdg4y5r3zarvaryvaxxpcs", 2, "751e76e8199196d454941c45d1b3a323"},
+		{"tb1pqqqqp399et2xygdj5xreqhjjvcmzhxw4aywxecjdzew6hylgvsesf3hn0c", 1, "000000c4a5cad46221b2a187905e5266362b99d5e91c6ce24d165dab93e86433"},
+		{"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0", 1, "79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"},
+	}
+	for _, test := range valid {
+		version, program, err := btc.DecodeSegWitAddress(test.address)
+		if err != nil {
+			t.Errorf("DecodeSegWitAddress(%v): %v", test.address, err)
+			continue
+		}
+		if version != test.version || hex.EncodeToString(program) != test.program {
+			t.Errorf("DecodeSegWitAddress(%v) = %d %x", test.address, version, program)
+		}
+		hrp := strings.ToLower(test.address[:strings.LastIndexByte(test.address, '1')])
+		encoded, err := btc.EncodeSegWitAddress(hrp, version, program)
+		if err != nil || encoded != strings.ToLower(test.address) {
+			t.Errorf("EncodeSegWitAddress(%d, %x) = %v, %v", version, program, encoded, err)
+		}
+	}
+
+	invalid := []string{
+		"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqh2y7hd",
+		"tb1z0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqglt7rf",
+		"BC1S0XLXVLHEMJA6C4DQV22UAPCTQUPFHLXM9H8Z3K2E72Q4K9HCZ7VQ54WELL",
+		"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kemeawh",
+		"tb1q0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vq24jc47",
+		"bc1p38j9r5y49hruaue7wxjce0updqjuyyx0kh56v8s25huc6995vvpql3jow4",
+		"BC130XLXVLHEMJA6C4DQV22UAPCTQUPFHLXM9H8Z3K2E72Q4K9HCZ7VQ7ZWS8R",
+		"bc1pw5dgrnzv",
+		"bc1zw508d6qejxtdg4y5r3zarvaryvqyzf3du",
+		"tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3pq0sL5k7",
+		"bc1gmk9yu",
+	}
+	for _, address := range invalid {
+		if version, program, err := btc.DecodeSegWitAddress(address); err == nil {
+			t.Errorf("DecodeSegWitAddress(%v) = %d %x, want an error", address, version, program)
+		}
+	}
+
+	address, err := btc.DecodeAddress("bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0", &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := address.(*btc.AddressTaproot); !ok || !address.IsForNet(&chaincfg.MainNetParams) || address.IsForNet(&chaincfg.TestNet3Params) {
+		t.Errorf("DecodeAddress = %T %v", address, address)
+	}
+}
+
+// TestCrossCheck cross-checks against a fake bitcoind answering the BIP86 vectors, see
+// integration_test.go for a real one.
+func TestCrossCheck(t *testing.T) {
+	server := btctest.NewServer()
+	defer server.Close()
+	node, err := server.Bitcoind()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := descriptor.Parse("tr("+bip86Account+"/0/*)", &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addresses := []string{
+		"bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr",
+		"bc1p4qhjn9zdvkux4e44uhx8tc55attvtyu358kutcqkudyccelu0was9fqzwh",
+	}
+	server.Handle("deriveaddresses", func(params []json.RawMessage) (interface{}, error) {
+		var desc string
+		var rng []uint32
+		if len(params) != 2 || json.Unmarshal(params[0], &desc) != nil || json.Unmarshal(params[1], &rng) != nil {
+			return nil, errors.New("bad params")
+		}
+		if desc != d.String() || len(rng) != 2 || rng[0] != 0 || rng[1] != 1 {
+			return nil, errors.New("unexpected descriptor or range")
+		}
+		return addresses, nil
+	})
+	if err := descriptor.CrossCheck(node, d, 0, 1); err != nil {
+		t.Errorf("CrossCheck: %v", err)
+	}
+
+	addresses[1] = "bc1p3qkhfews2uk44qtvauqyr2ttdsw7svhkl9nkm9s9c3x4ax5h60wqwruhk7"
+	if err := descriptor.CrossCheck(node, d, 0, 1); err == nil {
+		t.Error("CrossCheck passed with a wrong address from bitcoind")
+	}
+	addresses = addresses[:1]
+	if err := descriptor.CrossCheck(node, d, 0, 1); err == nil {
+		t.Error("CrossCheck passed with a missing address from bitcoind")
+	}
+	server.Error("deriveaddresses", -5, "Invalid descriptor")
+	var rpcErr *btc.RPCError
+	if err := descriptor.CrossCheck(node, d, 0, 1); !errors.As(err, &rpcErr) || rpcErr.Code != -5 {
+		t.Errorf("CrossCheck with a bitcoind error: %v", err)
+	}
+}
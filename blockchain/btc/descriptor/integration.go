@@ -0,0 +1,37 @@
+package descriptor
+
+import (
+	"fmt"
+
+	"github.com/jualy007/GoTF/blockchain/btc"
+)
+
+// CrossCheck derives the addresses of d for the inclusive range [start, end] locally and
+// compares them with the result of the node's deriveaddresses RPC.
+// Used in integration mode against a bitcoind with descriptor support (v0.18+).
+func CrossCheck(node *btc.Bitcoind, d *Descriptor, start, end uint32) error {
+	local, err := d.DeriveAddresses(start, end)
+	if err != nil {
+		return fmt.Errorf("derive addresses locally: %w", err)
+	}
+
+	var remote []string
+	if d.IsRange() {
+		remote, err = node.DeriveAddresses(d.String(), start, end)
+	} else {
+		remote, err = node.DeriveAddresses(d.String())
+	}
+	if err != nil {
+		return fmt.Errorf("deriveaddresses %v: %w", d, err)
+	}
+
+	if len(local) != len(remote) {
+		return fmt.Errorf("derived %d addresses locally but bitcoind returned %d", len(local), len(remote))
+	}
+	for i := range local {
+		if local[i] != remote[i] {
+			return fmt.Errorf("address mismatch at index %d: %v locally, %v from bitcoind", start+uint32(i), local[i], remote[i])
+		}
+	}
+	return nil
+}
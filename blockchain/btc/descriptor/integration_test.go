@@ -0,0 +1,77 @@
+//go:build integration
+// +build integration
+
+package descriptor_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil/hdkeychain"
+
+	"github.com/jualy007/GoTF/blockchain/btc"
+	"github.com/jualy007/GoTF/blockchain/btc/descriptor"
+	"github.com/jualy007/GoTF/config"
+)
+
+// TestCrossCheckBitcoind compares the derived addresses with deriveaddresses of a regtest bitcoind 22.0+
+// (for tr()), configured by GOTF_BITCOIND, e.g. http://127.0.0.1:18443, GOTF_BITCOIND_USER and
+// GOTF_BITCOIND_PASSWORD:
+//
+//	go test -tags integration ./blockchain/btc/descriptor/
+func TestCrossCheckBitcoind(t *testing.T) {
+	address := os.Getenv("GOTF_BITCOIND")
+	if address == "" {
+		t.Skip("GOTF_BITCOIND not set")
+	}
+	node, err := btc.NewFromConfig(config.BitcoinInfo{
+		Address:  address,
+		User:     os.Getenv("GOTF_BITCOIND_USER"),
+		Password: os.Getenv("GOTF_BITCOIND_PASSWORD"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	net := &chaincfg.RegressionNetParams
+	seed := make([]byte, hdkeychain.RecommendedSeedLen)
+	master, err := hdkeychain.NewMaster(seed, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, err := master.Child(hdkeychain.HardenedKeyStart + 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accountPub, err := account.Neuter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	prv, pub := master.String(), accountPub.String()
+
+	descs := []string{
+		"pkh(" + prv + "/44'/1'/0'/0/*)",
+		"wpkh(" + pub + "/0/*)",
+		"sh(wpkh(" + pub + "/1/*))",
+		"sh(multi(2," + prv + "/0/*," + pub + "/0/*))",
+		"wsh(sortedmulti(2," + prv + "/1'/*'," + pub + "/1/*," + pub + "/2/*))",
+		"sh(wsh(sortedmulti(1," + pub + "/3/*," + prv + "/3/*)))",
+		"tr(" + prv + "/86'/1'/0'/0/*)",
+		"tr(" + pub + "/0/*)",
+		"wpkh(" + pub + "/0/7)",
+	}
+	for _, desc := range descs {
+		d, err := descriptor.Parse(desc, net)
+		if err != nil {
+			t.Fatalf("Parse(%v): %v", desc, err)
+		}
+		var end uint32
+		if d.IsRange() {
+			end = 20
+		}
+		if err := descriptor.CrossCheck(node, d, 0, end); err != nil {
+			t.Errorf("%v: %v", desc, err)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package descriptor
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/hdkeychain"
+)
+
+type rangeType int
+
+const (
+	rangeNone rangeType = iota
+	rangeUnhardened
+	rangeHardened
+)
+
+// keyExpr is a parsed KEY expression: a hex public key, a WIF private key or an
+// extended key with a derivation path, optionally prefixed by key origin information.
+type keyExpr struct {
+	pubKey []byte
+	xOnly  bool
+	extKey *hdkeychain.ExtendedKey
+	path   []uint32
+	ranged rangeType
+}
+
+// parseKey parses a KEY expression valid in ctx.
+func parseKey(expr string, ctx scriptContext, net *chaincfg.Params) (*keyExpr, error) {
+	if strings.HasPrefix(expr, "[") {
+		end := strings.IndexByte(expr, ']')
+		if end < 0 {
+			return nil, fmt.Errorf("key origin start '[' character without corresponding end ']'")
+		}
+		if err := checkOrigin(expr[1:end]); err != nil {
+			return nil, err
+		}
+		expr = expr[end+1:]
+	}
+	if expr == "" {
+		return nil, fmt.Errorf("key expression cannot be empty")
+	}
+
+	elems := strings.Split(expr, "/")
+	key := &keyExpr{}
+
+	if len(elems) == 1 {
+		if raw, err := hex.DecodeString(expr); err == nil {
+			switch {
+			case len(raw) == 32 && ctx == ctxTr:
+				if _, err := btcec.ParsePubKey(append([]byte{0x02}, raw...), btcec.S256()); err != nil {
+					return nil, fmt.Errorf("x-only pubkey '%s' is not valid: %w", expr, err)
+				}
+				key.pubKey = raw
+				key.xOnly = true
+			case len(raw) == 33 || len(raw) == 65:
+				if _, err := btcec.ParsePubKey(raw, btcec.S256()); err != nil {
+					return nil, fmt.Errorf("pubkey '%s' is invalid: %w", expr, err)
+				}
+				if len(raw) == 65 && ctx != ctxTop && ctx != ctxSh {
+					return nil, fmt.Errorf("uncompressed keys are not allowed in %s", ctx)
+				}
+				key.pubKey = raw
+			default:
+				return nil, fmt.Errorf("pubkey '%s' has invalid length %d", expr, len(raw))
+			}
+			return key, nil
+		}
+		if wif, err := btcutil.DecodeWIF(expr); err == nil {
+			if !wif.IsForNet(net) {
+				return nil, fmt.Errorf("private key '%s' is not for network %s", expr, net.Name)
+			}
+			if !wif.CompressPubKey && ctx != ctxTop && ctx != ctxSh {
+				return nil, fmt.Errorf("uncompressed keys are not allowed in %s", ctx)
+			}
+			key.pubKey = wif.SerializePubKey()
+			return key, nil
+		}
+	}
+
+	extKey, err := hdkeychain.NewKeyFromString(elems[0])
+	if err != nil {
+		return nil, fmt.Errorf("key '%s' is not valid: %w", elems[0], err)
+	}
+	key.extKey = extKey
+	for i, elem := range elems[1:] {
+		if elem == "*" || elem == "*'" || elem == "*h" {
+			if i != len(elems)-2 {
+				return nil, fmt.Errorf("'*' may only appear as last element in a derivation path")
+			}
+			key.ranged = rangeUnhardened
+			if elem != "*" {
+				key.ranged = rangeHardened
+			}
+			break
+		}
+		index, err := parsePathElement(elem)
+		if err != nil {
+			return nil, err
+		}
+		key.path = append(key.path, index)
+	}
+	if !extKey.IsPrivate() && key.hardened() {
+		return nil, fmt.Errorf("hardened derivation requires an extended private key: %s", expr)
+	}
+	return key, nil
+}
+
+// checkOrigin validates key origin information of the form fingerprint/path.
+func checkOrigin(origin string) error {
+	elems := strings.Split(origin, "/")
+	if len(elems[0]) != 8 {
+		return fmt.Errorf("fingerprint '%s' is not hex of 4 bytes", elems[0])
+	}
+	if _, err := hex.DecodeString(elems[0]); err != nil {
+		return fmt.Errorf("fingerprint '%s' is not hex: %w", elems[0], err)
+	}
+	for _, elem := range elems[1:] {
+		if _, err := parsePathElement(elem); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parsePathElement(elem string) (uint32, error) {
+	hardened := strings.HasSuffix(elem, "'") || strings.HasSuffix(elem, "h")
+	if hardened {
+		elem = elem[:len(elem)-1]
+	}
+	index, err := strconv.ParseUint(elem, 10, 32)
+	if err != nil || index >= hdkeychain.HardenedKeyStart {
+		return 0, fmt.Errorf("key path value '%s' is out of range", elem)
+	}
+	if hardened {
+		index += hdkeychain.HardenedKeyStart
+	}
+	return uint32(index), nil
+}
+
+func (k *keyExpr) hardened() bool {
+	if k.ranged == rangeHardened {
+		return true
+	}
+	for _, index := range k.path {
+		if index >= hdkeychain.HardenedKeyStart {
+			return true
+		}
+	}
+	return false
+}
+
+func (k *keyExpr) isRange() bool {
+	return k.ranged != rangeNone
+}
+
+// serialize returns the serialized public key at position index of the range,
+// x-only for keys used in taproot context.
+func (k *keyExpr) serialize(index uint32, ctx scriptContext) ([]byte, error) {
+	pubKey := k.pubKey
+	if k.isRange() && index >= hdkeychain.HardenedKeyStart {
+		//index+HardenedKeyStart would overflow, and an unhardened index this large is hardened
+		return nil, fmt.Errorf("range index %d is out of range, must be less than 2^31", index)
+	}
+	if k.extKey != nil {
+		child := k.extKey
+		path := k.path
+		switch k.ranged {
+		case rangeUnhardened:
+			path = append(append([]uint32{}, path...), index)
+		case rangeHardened:
+			path = append(append([]uint32{}, path...), index+hdkeychain.HardenedKeyStart)
+		}
+		var err error
+		for _, i := range path {
+			child, err = child.Child(i)
+			if err != nil {
+				return nil, fmt.Errorf("derive child %d: %w", i, err)
+			}
+		}
+		ecPubKey, err := child.ECPubKey()
+		if err != nil {
+			return nil, fmt.Errorf("extended key public key: %w", err)
+		}
+		pubKey = ecPubKey.SerializeCompressed()
+	}
+	if ctx == ctxTr && !k.xOnly {
+		if len(pubKey) != 33 {
+			return nil, fmt.Errorf("uncompressed keys are not allowed in %s", ctx)
+		}
+		return pubKey[1:], nil
+	}
+	return pubKey, nil
+}
@@ -51,6 +51,23 @@ type Info struct {
 	Errors string `json:"errors"`
 }
 
+// BlockchainInfo - blockchain processing state info
+// https://bitcoincore.org/en/doc/0.16.0/rpc/blockchain/getblockchaininfo/
+type BlockchainInfo struct {
+	Chain                string  `json:"chain"`
+	Blocks               uint64  `json:"blocks"`
+	Headers              uint64  `json:"headers"`
+	BestBlockHash        string  `json:"bestblockhash"`
+	Difficulty           float64 `json:"difficulty"`
+	MedianTime           int64   `json:"mediantime"`
+	VerificationProgress float64 `json:"verificationprogress"`
+	InitialBlockDownload bool    `json:"initialblockdownload"`
+	Chainwork            string  `json:"chainwork"`
+	SizeOnDisk           uint64  `json:"size_on_disk"`
+	Pruned               bool    `json:"pruned"`
+	Warnings             string  `json:"warnings"`
+}
+
 // WalletInfo - wallet state info
 // https://bitcoincore.org/en/doc/0.16.0/rpc/wallet/getwalletinfo/
 type WalletInfo struct {
@@ -1,38 +1,51 @@
 package multisig
 
 import (
-	"encoding/csv"
 	"encoding/hex"
 	"fmt"
-	"log"
 	"strings"
 
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+
 	"github.com/jualy007/GoTF/blockchain/btc"
-	"github.com/prettymuchbryce/hellobitcoin/base58check"
 )
 
 //OutputAddress formats and prints relevant outputs to the user.
-func OutputAddress(flagM int, flagN int, flagPublicKeys string) {
-	P2SHAddress, redeemScriptHex := generateAddress(flagM, flagN, flagPublicKeys)
+func OutputAddress(flagM int, flagN int, flagPublicKeys string, flagType string, flagSort bool) error {
+	publicKeys, err := SplitList(flagPublicKeys)
+	if err != nil {
+		return err
+	}
+	scriptType, err := ParseScriptType(flagType)
+	if err != nil {
+		return err
+	}
+	address, err := GenerateAddress(flagM, flagN, publicKeys, AddressOptions{Type: scriptType, Sort: flagSort})
+	if err != nil {
+		return err
+	}
 
-	if flagM*73+flagN*66 > 496 {
+	if len(address.Warnings) > 0 {
 		fmt.Printf(`
 -----------------------------------------------------------------------------------------------------------------------------------
-WARNING: 
-%d-of-%d multisig transaction is valid but *non-standard* for Bitcoin v0.9.x and earlier.
+WARNING:
+%d-of-%d %v multisig transaction is valid but *non-standard*:
+* %v
 It may take a very long time (possibly never) for transaction spending multisig funds to be included in a block.
-To remain valid, choose smaller m and n values such that m*73+n*66 <= 496, as per standardness rules.
 See http://bitcoin.stackexchange.com/questions/23893/what-are-the-limits-of-m-and-n-in-m-of-n-multisig-addresses for more details.
 ------------------------------------------------------------------------------------------------------------------------------------
 `,
 			flagM,
 			flagN,
+			strings.ToUpper(string(address.Type)),
+			strings.Join(address.Warnings, "\n* "),
 		)
 	}
-	//Output P2SH and redeemScript
+	//Output address and redeemScript
 	fmt.Printf(`
 -----------------------------------------------------------------------------------------------------------------------------------
-Your *P2SH ADDRESS* is:
+Your *%v ADDRESS* is:
 %v
 Give this to sender funding multisig address with Bitcoin.
 -----------------------------------------------------------------------------------------------------------------------------------
@@ -42,42 +55,131 @@ Your *REDEEM SCRIPT* is:
 Keep private and provide this to redeem multisig balance later.
 -----------------------------------------------------------------------------------------------------------------------------------
 `,
-		P2SHAddress,
-		redeemScriptHex,
+		strings.ToUpper(string(address.Type)),
+		address.Address,
+		address.RedeemScript,
 	)
+	if address.WitnessScript != "" && address.WitnessScript != address.RedeemScript {
+		fmt.Printf(`Your *WITNESS SCRIPT* is:
+%v
+-----------------------------------------------------------------------------------------------------------------------------------
+`,
+			address.WitnessScript,
+		)
+	}
+
+	return nil
 }
 
-// generateAddress is the high-level logic for creating P2SH multisig addresses with the 'go-bitcoin-multisig address' subcommand.
-// Takes flagM (number of keys required to spend), flagN (total number of keys)
-// and flagPublicKeys (comma separated list of N public keys) as arguments.
-func generateAddress(flagM int, flagN int, flagPublicKeys string) (string, string) {
-	//Convert public keys argument into slice of public key bytes with necessary tidying
-	flagPublicKeys = strings.Replace(flagPublicKeys, "'", "\"", -1) //Replace single quotes with double since csv package only recognizes double quotes
-	publicKeyStrings, err := csv.NewReader(strings.NewReader(flagPublicKeys)).Read()
-	if err != nil {
-		log.Fatal(err)
+// AddressOptions selects the kind of multisig address GenerateAddress creates.
+// The zero value creates a mainnet P2SH address with keys in the given order.
+type AddressOptions struct {
+	Type ScriptType
+	//Sort orders public keys lexicographically as per BIP67, which only allows compressed keys
+	Sort bool
+	Net  *chaincfg.Params
+}
+
+// MultisigAddress is a multisig address and the scripts needed to spend from it.
+// For P2SH RedeemScript is the multisig script, for P2WSH WitnessScript is, and for P2SH-P2WSH
+// RedeemScript is the P2WSH program while WitnessScript is the multisig script.
+type MultisigAddress struct {
+	Type          ScriptType `json:"type"`
+	Address       string     `json:"address"`
+	RedeemScript  string     `json:"redeemScript,omitempty"`
+	WitnessScript string     `json:"witnessScript,omitempty"`
+	PublicKeys    []string   `json:"publicKeys"`
+	Warnings      []string   `json:"warnings,omitempty"`
+}
+
+// GenerateAddress is the high-level logic for creating multisig addresses with the 'gotf multisig address' subcommand.
+// Takes m (number of keys required to spend), n (total number of keys), publicKeys (list of N hex encoded public keys)
+// and opts (script type, BIP67 key sorting and network) as arguments.
+func GenerateAddress(m int, n int, publicKeyStrings []string, opts AddressOptions) (*MultisigAddress, error) {
+	if opts.Type == "" {
+		opts.Type = P2SH
+	}
+	if opts.Net == nil {
+		opts.Net = &chaincfg.MainNetParams
+	}
+	switch max := opts.Type.maxKeys(); {
+	case n < 1 || n > max:
+		return nil, &MOfNError{m, n, len(publicKeyStrings), fmt.Sprintf("N must be between 1 and %d (inclusive) for %v", max, opts.Type)}
+	case m < 1 || m > n:
+		return nil, &MOfNError{m, n, len(publicKeyStrings), "M must be between 1 and N (inclusive)"}
+	case len(publicKeyStrings) != n:
+		return nil, &MOfNError{m, n, len(publicKeyStrings), fmt.Sprintf("exactly %d public keys are required", n)}
 	}
+	//Convert public keys into slice of public key bytes
 	publicKeys := make([][]byte, len(publicKeyStrings))
 	for i, publicKeyString := range publicKeyStrings {
-		publicKeyString = strings.TrimSpace(publicKeyString)   //Trim whitespace
-		publicKeys[i], err = hex.DecodeString(publicKeyString) //Get private keys as slice of raw bytes
+		publicKey, err := hex.DecodeString(publicKeyString) //Get public keys as slice of raw bytes
 		if err != nil {
-			log.Fatal(err, "\n", "Offending publicKey: \n", publicKeyString)
+			return nil, &PublicKeyError{i, publicKeyString, err}
+		}
+		if err := btc.CheckPublicKeyIsValid(publicKey); err != nil {
+			return nil, &PublicKeyError{i, publicKeyString, err}
+		}
+		if opts.Type.IsSegWit() && !btc.IsCompressedPublicKey(publicKey) {
+			return nil, &PublicKeyError{i, publicKeyString, fmt.Errorf("%v requires compressed public keys", opts.Type)}
 		}
+		if opts.Sort && !btc.IsCompressedPublicKey(publicKey) {
+			return nil, &PublicKeyError{i, publicKeyString, fmt.Errorf("BIP67 sorting requires compressed public keys")}
+		}
+		publicKeys[i] = publicKey
+	}
+	if opts.Sort {
+		btc.SortPublicKeys(publicKeys)
 	}
-	//Create redeemScript from public keys
-	redeemScript, err := btc.NewMOfNRedeemScript(flagM, flagN, publicKeys)
+	//Create multisig script from public keys
+	script, err := btc.NewMultisigScript(m, n, publicKeys)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+
+	address := &MultisigAddress{
+		Type:       opts.Type,
+		PublicKeys: make([]string, n),
+		Warnings:   StandardnessWarnings(opts.Type, m, script),
 	}
-	redeemScriptHash, err := btc.Hash160(redeemScript)
+	for i, publicKey := range publicKeys {
+		address.PublicKeys[i] = hex.EncodeToString(publicKey)
+	}
+
+	if opts.Type == P2SH && len(script) > maxScriptElementSize {
+		return nil, &MOfNError{m, n, n, fmt.Sprintf("redeem script of %d bytes exceeds the %d byte limit", len(script), maxScriptElementSize)}
+	}
+	encoded, redeemScript, err := scriptAddress(opts.Type, script, opts.Net)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+	address.Address = encoded.EncodeAddress()
+	if opts.Type.IsSegWit() {
+		address.WitnessScript = hex.EncodeToString(script)
 	}
-	//Get P2SH address by base58 encoding with P2SH prefix 0x05
-	P2SHAddress := base58check.Encode("05", redeemScriptHash)
-	//Get redeemScript in Hex
-	redeemScriptHex := hex.EncodeToString(redeemScript)
+	if redeemScript != nil {
+		address.RedeemScript = hex.EncodeToString(redeemScript)
+	}
+
+	return address, nil
+}
 
-	return P2SHAddress, redeemScriptHex
+// scriptAddress returns the address paying to the multisig script for the script type, and the
+// P2SH redeem script if the type has one (the multisig script itself for P2SH, the P2WSH program for P2SH-P2WSH).
+func scriptAddress(scriptType ScriptType, script []byte, net *chaincfg.Params) (btcutil.Address, []byte, error) {
+	switch scriptType {
+	case P2SH:
+		address, err := btcutil.NewAddressScriptHash(script, net)
+		return address, script, err
+	case P2WSH:
+		witnessProgram, _ := btc.NewP2WSHScriptPubKey(script)
+		address, err := btcutil.NewAddressWitnessScriptHash(witnessProgram[2:], net)
+		return address, nil, err
+	case P2SHP2WSH:
+		//The P2SH redeem script is the P2WSH scriptPubKey
+		redeemScript, _ := btc.NewP2WSHScriptPubKey(script)
+		address, err := btcutil.NewAddressScriptHash(redeemScript, net)
+		return address, redeemScript, err
+	}
+	return nil, nil, fmt.Errorf("unknown script type %q", scriptType)
 }
@@ -0,0 +1,244 @@
+package multisig_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+
+	"github.com/jualy007/GoTF/blockchain/btc/multisig"
+)
+
+// BIP67 test vectors: https://github.com/bitcoin/bips/blob/master/bip-0067.mediawiki#test-vectors
+var bip67Vectors = []struct {
+	m            int
+	publicKeys   []string
+	sorted       []string
+	redeemScript string
+	address      string
+}{
+	{
+		2,
+		[]string{
+			"02ff12471208c14bd580709cb2358d98975247d8765f92bc25eab3b2763ed605f8",
+			"02fe6f0a5a297eb38c391581c4413e084773ea23954d93f7753db7dc0adc188b2f",
+		},
+		[]string{
+			"02fe6f0a5a297eb38c391581c4413e084773ea23954d93f7753db7dc0adc188b2f",
+			"02ff12471208c14bd580709cb2358d98975247d8765f92bc25eab3b2763ed605f8",
+		},
+		"522102fe6f0a5a297eb38c391581c4413e084773ea23954d93f7753db7dc0adc188b2f2102ff12471208c14bd580709cb2358d98975247d8765f92bc25eab3b2763ed605f852ae",
+		"39bgKC7RFbpoCRbtD5KEdkYKtNyhpsNa3Z",
+	},
+	{
+		2,
+		[]string{
+			"02632b12f4ac5b1d1b72b2a3b508c19172de44f6f46bcee50ba33f3f9291e47ed0",
+			"027735a29bae7780a9755fae7a1c4374c656ac6a69ea9f3697fda61bb99a4f3e77",
+			"02e2cc6bd5f45edd43bebe7cb9b675f0ce9ed3efe613b177588290ad188d11b404",
+		},
+		[]string{
+			"02632b12f4ac5b1d1b72b2a3b508c19172de44f6f46bcee50ba33f3f9291e47ed0",
+			"027735a29bae7780a9755fae7a1c4374c656ac6a69ea9f3697fda61bb99a4f3e77",
+			"02e2cc6bd5f45edd43bebe7cb9b675f0ce9ed3efe613b177588290ad188d11b404",
+		},
+		"522102632b12f4ac5b1d1b72b2a3b508c19172de44f6f46bcee50ba33f3f9291e47ed021027735a29bae7780a9755fae7a1c4374c656ac6a69ea9f3697fda61bb99a4f3e772102e2cc6bd5f45edd43bebe7cb9b675f0ce9ed3efe613b177588290ad188d11b40453ae",
+		"3CKHTjBKxCARLzwABMu9yD85kvtm7WnMfH",
+	},
+	{
+		2,
+		[]string{
+			"030000000000000000000000000000000000004141414141414141414141414141",
+			"020000000000000000000000000000000000004141414141414141414141414141",
+			"020000000000000000000000000000000000004141414141414141414141414140",
+			"030000000000000000000000000000000000004141414141414141414141414140",
+		},
+		[]string{
+			"020000000000000000000000000000000000004141414141414141414141414140",
+			"020000000000000000000000000000000000004141414141414141414141414141",
+			"030000000000000000000000000000000000004141414141414141414141414140",
+			"030000000000000000000000000000000000004141414141414141414141414141",
+		},
+		"522102000000000000000000000000000000000000414141414141414141414141414021020000000000000000000000000000000000004141414141414141414141414141210300000000000000000000000000000000000041414141414141414141414141402103000000000000000000000000000000000000414141414141414141414141414154ae",
+		"32V85igBri9zcfBRVupVvwK18NFtS37FuD",
+	},
+	{
+		2,
+		[]string{
+			"022df8750480ad5b26950b25c7ba79d3e37d75f640f8e5d9bcd5b150a0f85014da",
+			"03e3818b65bcc73a7d64064106a859cc1a5a728c4345ff0b641209fba0d90de6e9",
+			"021f2f6e1e50cb6a953935c3601284925decd3fd21bc445712576873fb8c6ebc18",
+		},
+		[]string{
+			"021f2f6e1e50cb6a953935c3601284925decd3fd21bc445712576873fb8c6ebc18",
+			"022df8750480ad5b26950b25c7ba79d3e37d75f640f8e5d9bcd5b150a0f85014da",
+			"03e3818b65bcc73a7d64064106a859cc1a5a728c4345ff0b641209fba0d90de6e9",
+		},
+		"5221021f2f6e1e50cb6a953935c3601284925decd3fd21bc445712576873fb8c6ebc1821022df8750480ad5b26950b25c7ba79d3e37d75f640f8e5d9bcd5b150a0f85014da2103e3818b65bcc73a7d64064106a859cc1a5a728c4345ff0b641209fba0d90de6e953ae",
+		"3Q4sF6tv9wsdqu2NtARzNCpQgwifm2rAba",
+	},
+}
+
+func TestBIP67(t *testing.T) {
+	for i, vector := range bip67Vectors {
+		n := len(vector.publicKeys)
+		address, err := multisig.GenerateAddress(vector.m, n, vector.publicKeys, multisig.AddressOptions{Sort: true})
+		if err != nil {
+			t.Errorf("vector #%d: %v", i+1, err)
+			continue
+		}
+		if strings.Join(address.PublicKeys, ",") != strings.Join(vector.sorted, ",") {
+			t.Errorf("vector #%d: public keys %v, want %v", i+1, address.PublicKeys, vector.sorted)
+		}
+		if address.RedeemScript != vector.redeemScript || address.Address != vector.address {
+			t.Errorf("vector #%d: %v with redeem script %v, want %v with %v", i+1, address.Address, address.RedeemScript, vector.address, vector.redeemScript)
+		}
+
+		// Without sorting the keys keep their order
+		unsorted, err := multisig.GenerateAddress(vector.m, n, vector.publicKeys, multisig.AddressOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(unsorted.PublicKeys, ",") != strings.Join(vector.publicKeys, ",") {
+			t.Errorf("vector #%d: unsorted public keys %v", i+1, unsorted.PublicKeys)
+		}
+		if sorted := strings.Join(vector.publicKeys, ",") == strings.Join(vector.sorted, ","); (unsorted.Address == vector.address) != sorted {
+			t.Errorf("vector #%d: unsorted address %v", i+1, unsorted.Address)
+		}
+	}
+}
+
+func TestKeyLimits(t *testing.T) {
+	publicKeys := testPublicKeys(21, true)
+	tests := []struct {
+		scriptType multisig.ScriptType
+		n          int
+		ok         bool
+	}{
+		{multisig.P2SH, 15, true},
+		{multisig.P2SH, 16, false},
+		{multisig.P2WSH, 16, true},
+		{multisig.P2WSH, 17, true},
+		{multisig.P2WSH, 20, true},
+		{multisig.P2WSH, 21, false},
+		{multisig.P2SHP2WSH, 20, true},
+		{multisig.P2SHP2WSH, 21, false},
+	}
+	for _, test := range tests {
+		address, err := multisig.GenerateAddress(1, test.n, publicKeys[:test.n], multisig.AddressOptions{Type: test.scriptType})
+		var e *multisig.MOfNError
+		switch {
+		case test.ok && err != nil:
+			t.Errorf("1-of-%d %v: %v", test.n, test.scriptType, err)
+		case test.ok && len(address.Warnings) != 0:
+			t.Errorf("1-of-%d %v: warnings %v", test.n, test.scriptType, address.Warnings)
+		case !test.ok && !errors.As(err, &e):
+			t.Errorf("1-of-%d %v: error %v, want a MOfNError", test.n, test.scriptType, err)
+		}
+	}
+}
+
+func TestWitnessScript(t *testing.T) {
+	publicKeys := testPublicKeys(20, true)
+	for _, mn := range [][2]int{{2, 3}, {16, 17}, {17, 20}} {
+		m, n := mn[0], mn[1]
+		p2wsh, err := multisig.GenerateAddress(m, n, publicKeys[:n], multisig.AddressOptions{Type: multisig.P2WSH})
+		if err != nil {
+			t.Fatal(err)
+		}
+		nested, err := multisig.GenerateAddress(m, n, publicKeys[:n], multisig.AddressOptions{Type: multisig.P2SHP2WSH})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p2wsh.WitnessScript == "" || p2wsh.WitnessScript != nested.WitnessScript || p2wsh.RedeemScript != "" {
+			t.Errorf("%d-of-%d: P2WSH witness script %v, P2SH-P2WSH %v", m, n, p2wsh.WitnessScript, nested.WitnessScript)
+		}
+
+		// <m> <pubkey>... <n> OP_CHECKMULTISIG with a one byte push for numbers above 16
+		script, _ := hex.DecodeString(p2wsh.WitnessScript)
+		var want bytes.Buffer
+		writeNumber := func(i int) {
+			if i > 16 {
+				want.Write([]byte{0x01, byte(i)})
+			} else {
+				want.WriteByte(0x50 + byte(i))
+			}
+		}
+		writeNumber(m)
+		for _, publicKey := range publicKeys[:n] {
+			raw, _ := hex.DecodeString(publicKey)
+			want.WriteByte(byte(len(raw)))
+			want.Write(raw)
+		}
+		writeNumber(n)
+		want.WriteByte(0xae)
+		if !bytes.Equal(script, want.Bytes()) {
+			t.Errorf("%d-of-%d: witness script %x, want %x", m, n, script, want.Bytes())
+		}
+
+		hash := sha256.Sum256(script)
+		witnessAddress, err := btcutil.NewAddressWitnessScriptHash(hash[:], &chaincfg.MainNetParams)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p2wsh.Address != witnessAddress.EncodeAddress() {
+			t.Errorf("%d-of-%d: P2WSH address %v, want %v", m, n, p2wsh.Address, witnessAddress.EncodeAddress())
+		}
+		program := append([]byte{0x00, 0x20}, hash[:]...)
+		nestedAddress, err := btcutil.NewAddressScriptHash(program, &chaincfg.MainNetParams)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if nested.RedeemScript != hex.EncodeToString(program) || nested.Address != nestedAddress.EncodeAddress() {
+			t.Errorf("%d-of-%d: P2SH-P2WSH %v with redeem script %v, want %v with %x", m, n, nested.Address, nested.RedeemScript, nestedAddress.EncodeAddress(), program)
+		}
+	}
+
+	testnet, err := multisig.GenerateAddress(2, 3, publicKeys[:3], multisig.AddressOptions{Type: multisig.P2WSH, Net: &chaincfg.TestNet3Params})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(testnet.Address, "tb1q") {
+		t.Errorf("testnet P2WSH address %v", testnet.Address)
+	}
+}
+
+func TestStandardnessWarnings(t *testing.T) {
+	tests := []struct {
+		name       string
+		scriptType multisig.ScriptType
+		m          int
+		scriptSize int
+		warnings   int
+	}{
+		// 1 + 15*74 + 3 + 513 bytes of scriptSig
+		{"15-of-15 P2SH", multisig.P2SH, 15, 513, 0},
+		{"16 signatures of a P2SH 520 byte script", multisig.P2SH, 16, 520, 1},
+		{"P2WSH 3600 byte script", multisig.P2WSH, 20, 3600, 0},
+		{"P2WSH 3601 byte script", multisig.P2WSH, 20, 3601, 1},
+		{"P2SH-P2WSH 3601 byte script", multisig.P2SHP2WSH, 20, 3601, 1},
+		{"99 witness signatures", multisig.P2WSH, 99, 100, 0},
+		{"100 witness signatures", multisig.P2WSH, 100, 100, 1},
+		{"100 witness signatures of a 3601 byte script", multisig.P2SHP2WSH, 100, 3601, 2},
+	}
+	for _, test := range tests {
+		warnings := multisig.StandardnessWarnings(test.scriptType, test.m, make([]byte, test.scriptSize))
+		if len(warnings) != test.warnings {
+			t.Errorf("%v: warnings %q, want %d", test.name, warnings, test.warnings)
+		}
+	}
+
+	// A 15-of-15 P2SH of compressed keys is the largest standard one
+	address, err := multisig.GenerateAddress(15, 15, testPublicKeys(15, true), multisig.AddressOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if script, _ := hex.DecodeString(address.RedeemScript); len(script) != 513 || len(address.Warnings) != 0 {
+		t.Errorf("15-of-15 P2SH redeem script of %d bytes, warnings %v", len(script), address.Warnings)
+	}
+}
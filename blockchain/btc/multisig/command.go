@@ -0,0 +1,227 @@
+package multisig
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"os"
+
+	"github.com/urfave/cli/v2"
+)
+
+var jsonFlag = &cli.BoolFlag{
+	Name:  "json",
+	Usage: "Print the result as JSON instead of formatted text",
+}
+
+// Command returns the 'multisig' command with its keys, address, fund and spend subcommands.
+func Command() *cli.Command {
+	return &cli.Command{
+		Name:  "multisig",
+		Usage: "Generate keys, multisig addresses and funding/spending/payout transactions",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "keys",
+				Usage: "Generate public/private key pairs",
+				Flags: []cli.Flag{
+					&cli.IntFlag{Name: "count", Value: 1, Usage: "No. of key pairs to generate (1-100)"},
+					&cli.BoolFlag{Name: "concise", Usage: "Hide warnings and helpful messages for conciseness"},
+					jsonFlag,
+				},
+				Action: keysAction,
+			},
+			{
+				Name:  "address",
+				Usage: "Generate a P2SH, P2WSH or P2SH-P2WSH multisig address",
+				Flags: []cli.Flag{
+					&cli.IntFlag{Name: "m", Required: true, Usage: "No. of keys required to spend"},
+					&cli.IntFlag{Name: "n", Required: true, Usage: "Total no. of keys"},
+					&cli.StringFlag{Name: "public-keys", Required: true, Usage: "Comma separated list of N public keys"},
+					&cli.StringFlag{Name: "type", Value: string(P2SH), Usage: "Address type: p2sh, p2wsh or p2sh-p2wsh"},
+					&cli.BoolFlag{Name: "sort", Usage: "Sort public keys as per BIP67 before building the script, requires compressed keys"},
+					jsonFlag,
+				},
+				Action: addressAction,
+			},
+			{
+				Name:  "fund",
+				Usage: "Create a transaction funding a P2SH address",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "private-key", Required: true, Usage: "Private key of the input Bitcoins to fund with"},
+					&cli.StringFlag{Name: "input-tx", Required: true, Usage: "Input transaction hash of the Bitcoins to fund with"},
+					&cli.IntFlag{Name: "amount", Required: true, Usage: "Amount in Satoshis to send, the rest of the input is the fee"},
+					&cli.StringFlag{Name: "destination", Required: true, Usage: "Destination P2SH multisig address"},
+					jsonFlag,
+				},
+				Action: fundAction,
+			},
+			{
+				Name:  "spend",
+				Usage: "Create a transaction spending from a P2SH multisig address",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "private-keys", Required: true, Usage: "Comma separated list of M private keys, in the redeem script order"},
+					&cli.StringFlag{Name: "destination", Required: true, Usage: "Destination address of the spent funds"},
+					&cli.StringFlag{Name: "redeem-script", Required: true, Usage: "Redeem script matching the P2SH address"},
+					&cli.StringFlag{Name: "input-tx", Required: true, Usage: "Input transaction hash of the P2SH funds"},
+					&cli.IntFlag{Name: "amount", Required: true, Usage: "Amount in Satoshis to send, the rest of the input is the fee"},
+					jsonFlag,
+				},
+				Action: spendAction,
+			},
+			{
+				Name:  "payout",
+				Usage: "Create a transaction spending multisig UTXOs to one or more destinations with change",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "private-keys", Required: true, Usage: "Comma separated list of at least M private keys"},
+					&cli.StringFlag{Name: "script", Required: true, Usage: "Multisig script, the redeem script for P2SH or the witness script for P2WSH"},
+					&cli.StringFlag{Name: "type", Value: string(P2SH), Usage: "Type of the multisig inputs: p2sh, p2wsh or p2sh-p2wsh"},
+					&cli.StringFlag{Name: "inputs", Required: true, Usage: "Comma separated list of txid:vout:amount[:type] inputs"},
+					&cli.StringFlag{Name: "outputs", Required: true, Usage: "Comma separated list of address:amount outputs"},
+					&cli.Int64Flag{Name: "fee-rate", Value: 1, Usage: "Fee rate in Satoshis per vbyte"},
+					&cli.StringFlag{Name: "change-address", Usage: "Address receiving the change, defaults to the multisig address"},
+					&cli.StringFlag{Name: "network", Value: "mainnet", Usage: "Network of the addresses: mainnet, testnet or regtest"},
+					jsonFlag,
+				},
+				Action: payoutAction,
+			},
+		},
+	}
+}
+
+func keysAction(cctx *cli.Context) error {
+	if !cctx.Bool("json") {
+		return exitOnError(OutputKeys(cctx.Int("count"), cctx.Bool("concise")))
+	}
+
+	keyPairs, err := GenerateKeys(cctx.Int("count"))
+	if err != nil {
+		return exitOnError(err)
+	}
+	return printJSON(keyPairs)
+}
+
+func addressAction(cctx *cli.Context) error {
+	m, n := cctx.Int("m"), cctx.Int("n")
+	if !cctx.Bool("json") {
+		return exitOnError(OutputAddress(m, n, cctx.String("public-keys"), cctx.String("type"), cctx.Bool("sort")))
+	}
+
+	publicKeys, err := SplitList(cctx.String("public-keys"))
+	if err != nil {
+		return exitOnError(err)
+	}
+	scriptType, err := ParseScriptType(cctx.String("type"))
+	if err != nil {
+		return exitOnError(err)
+	}
+	address, err := GenerateAddress(m, n, publicKeys, AddressOptions{Type: scriptType, Sort: cctx.Bool("sort")})
+	if err != nil {
+		return exitOnError(err)
+	}
+	return printJSON(address)
+}
+
+func fundAction(cctx *cli.Context) error {
+	privateKey, inputTx, amount, destination := cctx.String("private-key"), cctx.String("input-tx"), cctx.Int("amount"), cctx.String("destination")
+	if !cctx.Bool("json") {
+		return exitOnError(OutputFund(privateKey, inputTx, amount, destination))
+	}
+
+	transaction, err := GenerateFund(privateKey, inputTx, amount, destination)
+	if err != nil {
+		return exitOnError(err)
+	}
+	return printJSON(map[string]string{"transaction": transaction})
+}
+
+func spendAction(cctx *cli.Context) error {
+	destination, redeemScript := cctx.String("destination"), cctx.String("redeem-script")
+	inputTx, amount := cctx.String("input-tx"), cctx.Int("amount")
+	if !cctx.Bool("json") {
+		return exitOnError(OutputSpend(cctx.String("private-keys"), destination, redeemScript, inputTx, amount))
+	}
+
+	privateKeys, err := SplitList(cctx.String("private-keys"))
+	if err != nil {
+		return exitOnError(err)
+	}
+	transaction, err := GenerateSpend(privateKeys, destination, redeemScript, inputTx, amount)
+	if err != nil {
+		return exitOnError(err)
+	}
+	return printJSON(map[string]string{"transaction": transaction})
+}
+
+func payoutAction(cctx *cli.Context) error {
+	privateKeys, err := SplitList(cctx.String("private-keys"))
+	if err != nil {
+		return exitOnError(err)
+	}
+	params, err := payoutParams(cctx)
+	if err != nil {
+		return exitOnError(err)
+	}
+	if !cctx.Bool("json") {
+		return exitOnError(OutputPayout(privateKeys, params))
+	}
+
+	payout, transaction, err := GeneratePayout(privateKeys, params)
+	if err != nil {
+		return exitOnError(err)
+	}
+	return printJSON(map[string]interface{}{
+		"transaction": transaction,
+		"fee":         payout.Fee,
+		"changeIndex": payout.ChangeIndex,
+	})
+}
+
+func payoutParams(cctx *cli.Context) (PayoutParams, error) {
+	params := PayoutParams{FeeRate: cctx.Int64("fee-rate"), ChangeAddress: cctx.String("change-address")}
+	var err error
+	if params.Type, err = ParseScriptType(cctx.String("type")); err != nil {
+		return params, err
+	}
+	if params.Net, err = ParseNet(cctx.String("network")); err != nil {
+		return params, err
+	}
+	if params.Script, err = hex.DecodeString(cctx.String("script")); err != nil {
+		return params, &RedeemScriptError{cctx.String("script"), err}
+	}
+	inputs, err := SplitList(cctx.String("inputs"))
+	if err != nil {
+		return params, err
+	}
+	for _, input := range inputs {
+		utxo, err := ParseUTXO(input)
+		if err != nil {
+			return params, err
+		}
+		params.Inputs = append(params.Inputs, utxo)
+	}
+	outputs, err := SplitList(cctx.String("outputs"))
+	if err != nil {
+		return params, err
+	}
+	for _, output := range outputs {
+		payment, err := ParsePayment(output)
+		if err != nil {
+			return params, err
+		}
+		params.Outputs = append(params.Outputs, payment)
+	}
+	return params, nil
+}
+
+// exitOnError wraps err so that the CLI exits with a non-zero status.
+func exitOnError(err error) error {
+	if err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+	return nil
+}
+
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return exitOnError(encoder.Encode(v))
+}
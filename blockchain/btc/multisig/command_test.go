@@ -0,0 +1,309 @@
+package multisig_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/urfave/cli/v2"
+
+	"github.com/jualy007/GoTF/blockchain/btc/multisig"
+)
+
+// testKey returns the i-th deterministic private key of the tests.
+func testKey(i int) *btcec.PrivateKey {
+	seed := sha256.Sum256([]byte(fmt.Sprintf("multisig test key %d", i)))
+	key, _ := btcec.PrivKeyFromBytes(btcec.S256(), seed[:])
+	return key
+}
+
+// testWIF returns the WIF of the i-th test key.
+func testWIF(t *testing.T, i int) string {
+	t.Helper()
+	wif, err := btcutil.NewWIF(testKey(i), &chaincfg.MainNetParams, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wif.String()
+}
+
+// testPublicKeys returns the hex public keys of the first n test keys.
+func testPublicKeys(n int, compressed bool) []string {
+	publicKeys := make([]string, n)
+	for i := range publicKeys {
+		if compressed {
+			publicKeys[i] = hex.EncodeToString(testKey(i).PubKey().SerializeCompressed())
+		} else {
+			publicKeys[i] = hex.EncodeToString(testKey(i).PubKey().SerializeUncompressed())
+		}
+	}
+	return publicKeys
+}
+
+// testTxHash returns a fake input transaction hash.
+func testTxHash(i int) string {
+	hash := sha256.Sum256([]byte(fmt.Sprintf("multisig test tx %d", i)))
+	return hex.EncodeToString(hash[:])
+}
+
+// run runs 'gotf multisig args...' and returns what it printed to stdout and its exit code.
+func run(t *testing.T, args ...string) (string, int) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	printed := make(chan []byte)
+	go func() {
+		out, _ := ioutil.ReadAll(r)
+		printed <- out
+	}()
+
+	app := &cli.App{
+		Name:           "gotf",
+		Commands:       []*cli.Command{multisig.Command()},
+		Writer:         w,
+		ErrWriter:      ioutil.Discard,
+		ExitErrHandler: func(*cli.Context, error) {},
+	}
+	runErr := app.Run(append([]string{"gotf", "multisig"}, args...))
+	w.Close()
+	out := string(<-printed)
+
+	switch e := runErr.(type) {
+	case nil:
+		return out, 0
+	case cli.ExitCoder:
+		if e.Error() == "" {
+			t.Errorf("exit %d without a message", e.ExitCode())
+		}
+		return out, e.ExitCode()
+	}
+	// Flag errors are returned as is, the app exits with 1 on them.
+	return out, 1
+}
+
+// decodeTx decodes a hex encoded transaction.
+func decodeTx(t *testing.T, txHex string) *wire.MsgTx {
+	t.Helper()
+	raw, err := hex.DecodeString(txHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tx wire.MsgTx
+	if err := tx.Deserialize(bytes.NewReader(raw)); err != nil {
+		t.Fatalf("transaction %v: %v", txHex, err)
+	}
+	return &tx
+}
+
+// execute runs the script engine on input i of tx spending prevScript of amount.
+func execute(t *testing.T, tx *wire.MsgTx, i int, prevScript []byte, amount int64) {
+	t.Helper()
+	engine, err := txscript.NewEngine(prevScript, tx, i, txscript.StandardVerifyFlags, nil, txscript.NewTxSigHashes(tx), amount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Execute(); err != nil {
+		t.Errorf("input #%d: %v", i, err)
+	}
+}
+
+func TestKeysCommand(t *testing.T) {
+	out, code := run(t, "keys", "--count", "2", "--json")
+	if code != 0 {
+		t.Fatalf("keys --json exited with %d", code)
+	}
+	var keyPairs []map[string]string
+	if err := json.Unmarshal([]byte(out), &keyPairs); err != nil {
+		t.Fatalf("keys --json printed %q: %v", out, err)
+	}
+	if len(keyPairs) != 2 {
+		t.Fatalf("%d key pairs, want 2", len(keyPairs))
+	}
+	for _, keyPair := range keyPairs {
+		if len(keyPair) != 3 || keyPair["privateKey"] == "" || keyPair["publicKey"] == "" || keyPair["address"] == "" {
+			t.Errorf("key pair %v", keyPair)
+		}
+		if _, err := multisig.DecodePrivateKey(keyPair["privateKey"]); err != nil {
+			t.Errorf("private key %v: %v", keyPair["privateKey"], err)
+		}
+		if _, err := btcutil.DecodeAddress(keyPair["address"], &chaincfg.MainNetParams); err != nil {
+			t.Errorf("address %v: %v", keyPair["address"], err)
+		}
+	}
+
+	out, code = run(t, "keys", "--concise")
+	if code != 0 || !strings.Contains(out, "KEY #1") || strings.Contains(out, "Disclaimer") {
+		t.Errorf("keys --concise exited with %d and printed %q", code, out)
+	}
+	for _, count := range []string{"0", "101"} {
+		if out, code := run(t, "keys", "--count", count, "--json"); code != 1 || out != "" {
+			t.Errorf("keys --count %v exited with %d and printed %q", count, code, out)
+		}
+	}
+}
+
+func TestAddressCommand(t *testing.T) {
+	publicKeys := testPublicKeys(3, true)
+	out, code := run(t, "address", "--m", "2", "--n", "3", "--public-keys", strings.Join(publicKeys, ","), "--type", "p2sh-p2wsh", "--sort", "--json")
+	if code != 0 {
+		t.Fatalf("address --json exited with %d", code)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("address --json printed %q: %v", out, err)
+	}
+	want, err := multisig.GenerateAddress(2, 3, publicKeys, multisig.AddressOptions{Type: multisig.P2SHP2WSH, Sort: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["type"] != "p2sh-p2wsh" || got["address"] != want.Address || got["redeemScript"] != want.RedeemScript || got["witnessScript"] != want.WitnessScript {
+		t.Errorf("address --json = %v, want %+v", got, want)
+	}
+	if keys, ok := got["publicKeys"].([]interface{}); !ok || len(keys) != 3 {
+		t.Errorf("publicKeys %v", got["publicKeys"])
+	}
+
+	out, code = run(t, "address", "--m", "2", "--n", "3", "--public-keys", strings.Join(publicKeys, ","))
+	if code != 0 || !strings.Contains(out, "*P2SH ADDRESS*") {
+		t.Errorf("address exited with %d and printed %q", code, out)
+	}
+
+	bad := []struct {
+		name string
+		args []string
+	}{
+		{"malformed public key", []string{"--m", "1", "--n", "2", "--public-keys", publicKeys[0] + ",zz"}},
+		{"M above N", []string{"--m", "3", "--n", "2", "--public-keys", publicKeys[0] + "," + publicKeys[1]}},
+		{"unknown type", []string{"--m", "1", "--n", "1", "--public-keys", publicKeys[0], "--type", "p2tr"}},
+		{"missing public keys", []string{"--m", "1", "--n", "1"}},
+	}
+	for _, test := range bad {
+		for _, args := range [][]string{test.args, append(test.args, "--json")} {
+			if out, code := run(t, append([]string{"address"}, args...)...); code != 1 || strings.Contains(out, "ADDRESS") {
+				t.Errorf("%v: address %v exited with %d and printed %q", test.name, args, code, out)
+			}
+		}
+	}
+}
+
+func TestFundCommand(t *testing.T) {
+	address, err := multisig.GenerateAddress(2, 3, testPublicKeys(3, false), multisig.AddressOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, code := run(t, "fund", "--private-key", testWIF(t, 9), "--input-tx", testTxHash(1), "--amount", "50000", "--destination", address.Address, "--json")
+	if code != 0 {
+		t.Fatalf("fund --json exited with %d", code)
+	}
+	var got map[string]string
+	if err := json.Unmarshal([]byte(out), &got); err != nil || len(got) != 1 {
+		t.Fatalf("fund --json printed %q: %v", out, err)
+	}
+	tx := decodeTx(t, got["transaction"])
+	if len(tx.TxIn) != 1 || tx.TxIn[0].PreviousOutPoint.Hash.String() != testTxHash(1) || len(tx.TxOut) != 1 || tx.TxOut[0].Value != 50000 {
+		t.Fatalf("funding transaction %v", got["transaction"])
+	}
+	destination, _ := btcutil.DecodeAddress(address.Address, &chaincfg.MainNetParams)
+	if script, _ := txscript.PayToAddrScript(destination); !bytes.Equal(tx.TxOut[0].PkScript, script) {
+		t.Errorf("funding output script %x", tx.TxOut[0].PkScript)
+	}
+	// The input spends a P2PKH output of the uncompressed key.
+	source, _ := btcutil.NewAddressPubKeyHash(btcutil.Hash160(testKey(9).PubKey().SerializeUncompressed()), &chaincfg.MainNetParams)
+	prevScript, _ := txscript.PayToAddrScript(source)
+	execute(t, tx, 0, prevScript, 60000)
+
+	if out, code := run(t, "fund", "--private-key", testWIF(t, 9), "--input-tx", testTxHash(1), "--amount", "50000", "--destination", address.Address); code != 0 || !strings.Contains(out, got["transaction"][:100]) {
+		t.Errorf("fund exited with %d and printed %q", code, out)
+	}
+	bad := [][]string{
+		{"--private-key", "5Kb8kLf9zgWQnogidDA76MzPL6TsZZY36hWXMssSzNydYXYB9KG", "--input-tx", testTxHash(1), "--amount", "50000", "--destination", address.Address},
+		{"--private-key", testWIF(t, 9), "--input-tx", "abcd", "--amount", "50000", "--destination", address.Address},
+		{"--private-key", testWIF(t, 9), "--input-tx", testTxHash(1), "--amount", "0", "--destination", address.Address},
+		{"--private-key", testWIF(t, 9), "--input-tx", testTxHash(1), "--amount", "50000", "--destination", "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH"},
+	}
+	for _, args := range bad {
+		if out, code := run(t, append(append([]string{"fund"}, args...), "--json")...); code != 1 || out != "" {
+			t.Errorf("fund %v exited with %d and printed %q", args, code, out)
+		}
+	}
+}
+
+func TestSpendCommand(t *testing.T) {
+	address, err := multisig.GenerateAddress(2, 3, testPublicKeys(3, false), multisig.AddressOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	destination := "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH"
+	args := []string{"spend", "--private-keys", testWIF(t, 0) + "," + testWIF(t, 2), "--destination", destination,
+		"--redeem-script", address.RedeemScript, "--input-tx", testTxHash(2), "--amount", "40000", "--json"}
+	out, code := run(t, args...)
+	if code != 0 {
+		t.Fatalf("spend --json exited with %d", code)
+	}
+	var got map[string]string
+	if err := json.Unmarshal([]byte(out), &got); err != nil || len(got) != 1 {
+		t.Fatalf("spend --json printed %q: %v", out, err)
+	}
+	tx := decodeTx(t, got["transaction"])
+	if len(tx.TxOut) != 1 || tx.TxOut[0].Value != 40000 {
+		t.Fatalf("spending transaction %v", got["transaction"])
+	}
+	p2sh, _ := btcutil.DecodeAddress(address.Address, &chaincfg.MainNetParams)
+	prevScript, _ := txscript.PayToAddrScript(p2sh)
+	execute(t, tx, 0, prevScript, 50000)
+
+	args[len(args)-1] = "--amount=-1"
+	if out, code := run(t, args...); code != 1 || out != "" {
+		t.Errorf("spend of a negative amount exited with %d and printed %q", code, out)
+	}
+	if out, code := run(t, "spend", "--private-keys", testWIF(t, 0), "--destination", destination, "--redeem-script", "xyz",
+		"--input-tx", testTxHash(2), "--amount", "40000"); code != 1 || out != "" {
+		t.Errorf("spend with a malformed redeem script exited with %d and printed %q", code, out)
+	}
+}
+
+func TestPayoutCommand(t *testing.T) {
+	address, err := multisig.GenerateAddress(2, 3, testPublicKeys(3, true), multisig.AddressOptions{Type: multisig.P2WSH})
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := []string{"payout", "--private-keys", testWIF(t, 1) + "," + testWIF(t, 2), "--script", address.WitnessScript, "--type", "p2wsh",
+		"--inputs", testTxHash(3) + ":0:100000", "--outputs", "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4:30000", "--fee-rate", "2", "--json"}
+	out, code := run(t, args...)
+	if code != 0 {
+		t.Fatalf("payout --json exited with %d", code)
+	}
+	var got struct {
+		Transaction string `json:"transaction"`
+		Fee         int64  `json:"fee"`
+		ChangeIndex int    `json:"changeIndex"`
+	}
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("payout --json printed %q: %v", out, err)
+	}
+	tx := decodeTx(t, got.Transaction)
+	if got.ChangeIndex != 1 || len(tx.TxOut) != 2 || tx.TxOut[1].Value != 100000-30000-got.Fee || got.Fee <= 0 {
+		t.Errorf("payout --json = %+v", got)
+	}
+
+	args[len(args)-2] = "0"
+	if out, code := run(t, args...); code != 1 || out != "" {
+		t.Errorf("payout with a zero fee rate exited with %d and printed %q", code, out)
+	}
+}
@@ -0,0 +1,125 @@
+package multisig
+
+import "fmt"
+
+// KeyCountError is returned when the number of key pairs to generate is out of range.
+type KeyCountError struct {
+	Count int
+}
+
+func (e *KeyCountError) Error() string {
+	return fmt.Sprintf("key count must be between 1 and %d, got %d", maxKeyCount, e.Count)
+}
+
+// MOfNError is returned when m, n and the number of public keys do not describe a valid multisig.
+type MOfNError struct {
+	M, N, Keys int
+	Reason     string
+}
+
+func (e *MOfNError) Error() string {
+	return fmt.Sprintf("invalid %d-of-%d multisig with %d public keys: %s", e.M, e.N, e.Keys, e.Reason)
+}
+
+// PublicKeyError is returned for a malformed public key, Index is its position in the input list.
+type PublicKeyError struct {
+	Index     int
+	PublicKey string
+	Err       error
+}
+
+func (e *PublicKeyError) Error() string {
+	return fmt.Sprintf("invalid public key #%d %q: %v", e.Index+1, e.PublicKey, e.Err)
+}
+
+func (e *PublicKeyError) Unwrap() error {
+	return e.Err
+}
+
+// PrivateKeyError is returned for a malformed WIF private key, Index is its position in the input list.
+type PrivateKeyError struct {
+	Index int
+	Err   error
+}
+
+func (e *PrivateKeyError) Error() string {
+	return fmt.Sprintf("invalid private key #%d: %v", e.Index+1, e.Err)
+}
+
+func (e *PrivateKeyError) Unwrap() error {
+	return e.Err
+}
+
+// AddressError is returned for a malformed or unexpected destination address.
+type AddressError struct {
+	Address string
+	Err     error
+}
+
+func (e *AddressError) Error() string {
+	return fmt.Sprintf("invalid address %q: %v", e.Address, e.Err)
+}
+
+func (e *AddressError) Unwrap() error {
+	return e.Err
+}
+
+// RedeemScriptError is returned for a redeem script that is not valid hex.
+type RedeemScriptError struct {
+	RedeemScript string
+	Err          error
+}
+
+func (e *RedeemScriptError) Error() string {
+	return fmt.Sprintf("invalid redeem script %q: %v", e.RedeemScript, e.Err)
+}
+
+func (e *RedeemScriptError) Unwrap() error {
+	return e.Err
+}
+
+// TxHashError is returned for an input transaction hash that is not 32 bytes of hex.
+type TxHashError struct {
+	TxHash string
+	Err    error
+}
+
+func (e *TxHashError) Error() string {
+	return fmt.Sprintf("invalid input transaction hash %q: %v", e.TxHash, e.Err)
+}
+
+func (e *TxHashError) Unwrap() error {
+	return e.Err
+}
+
+// AmountError is returned for a non-positive amount of satoshis.
+type AmountError struct {
+	Amount int
+}
+
+func (e *AmountError) Error() string {
+	return fmt.Sprintf("amount must be a positive number of satoshis, got %d", e.Amount)
+}
+
+// InsufficientFundsError is returned when the inputs of a payout do not cover its outputs and fee.
+type InsufficientFundsError struct {
+	Available, Required int64
+}
+
+func (e *InsufficientFundsError) Error() string {
+	return fmt.Sprintf("insufficient funds: inputs total %d satoshis, outputs and fee require %d", e.Available, e.Required)
+}
+
+// SignatureError is returned when signatures for a payout input are missing or invalid, Input is its index.
+type SignatureError struct {
+	Input int
+	Err   error
+}
+
+func (e *SignatureError) Error() string {
+	return fmt.Sprintf("input #%d: %v", e.Input, e.Err)
+}
+
+func (e *SignatureError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,351 @@
+package multisig_test
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+
+	"github.com/jualy007/GoTF/blockchain/btc/multisig"
+)
+
+// testP2WSH returns the 2-of-3 P2WSH multisig of the first three test keys.
+func testP2WSH(t *testing.T) *multisig.MultisigAddress {
+	t.Helper()
+	address, err := multisig.GenerateAddress(2, 3, testPublicKeys(3, true), multisig.AddressOptions{Type: multisig.P2WSH, Sort: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return address
+}
+
+// testP2PKH returns the mainnet P2PKH address of the i-th test key.
+func testP2PKH(t *testing.T, i int) string {
+	t.Helper()
+	address, err := btcutil.NewAddressPubKeyHash(btcutil.Hash160(testKey(i).PubKey().SerializeCompressed()), &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return address.EncodeAddress()
+}
+
+func TestTypedErrors(t *testing.T) {
+	compressed, uncompressed := testPublicKeys(16, true), testPublicKeys(15, false)
+	wif := testWIF(t, 0)
+	p2sh, err := multisig.GenerateAddress(2, 3, uncompressed[:3], multisig.AddressOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2wsh := testP2WSH(t)
+	witnessScript, _ := hex.DecodeString(p2wsh.WitnessScript)
+	p2pkh := testP2PKH(t, 5)
+	payout := func(mutate func(*multisig.PayoutParams)) func() error {
+		return func() error {
+			params := multisig.PayoutParams{
+				Type:    multisig.P2WSH,
+				Script:  witnessScript,
+				Inputs:  []multisig.UTXO{{TxHash: testTxHash(0), Amount: 100000}},
+				Outputs: []multisig.Payment{{Address: p2pkh, Amount: 50000}},
+				FeeRate: 1,
+			}
+			mutate(&params)
+			_, err := multisig.NewPayout(params)
+			return err
+		}
+	}
+
+	tests := []struct {
+		name  string
+		call  func() error
+		check func(error) bool
+	}{
+		{
+			"no keys",
+			func() error { _, err := multisig.GenerateKeys(0); return err },
+			func(err error) bool { var e *multisig.KeyCountError; return errors.As(err, &e) && e.Count == 0 },
+		},
+		{
+			"too many keys",
+			func() error { _, err := multisig.GenerateKeys(101); return err },
+			func(err error) bool { var e *multisig.KeyCountError; return errors.As(err, &e) && e.Count == 101 },
+		},
+		{
+			"M above N",
+			func() error {
+				_, err := multisig.GenerateAddress(3, 2, compressed[:2], multisig.AddressOptions{})
+				return err
+			},
+			func(err error) bool { var e *multisig.MOfNError; return errors.As(err, &e) && e.M == 3 && e.N == 2 },
+		},
+		{
+			"N above the P2SH limit",
+			func() error {
+				_, err := multisig.GenerateAddress(1, 16, compressed, multisig.AddressOptions{})
+				return err
+			},
+			func(err error) bool { var e *multisig.MOfNError; return errors.As(err, &e) && e.N == 16 },
+		},
+		{
+			"missing public keys",
+			func() error {
+				_, err := multisig.GenerateAddress(2, 3, compressed[:2], multisig.AddressOptions{})
+				return err
+			},
+			func(err error) bool { var e *multisig.MOfNError; return errors.As(err, &e) && e.Keys == 2 },
+		},
+		{
+			"P2SH redeem script above 520 bytes",
+			func() error {
+				_, err := multisig.GenerateAddress(1, 15, uncompressed, multisig.AddressOptions{})
+				return err
+			},
+			func(err error) bool {
+				var e *multisig.MOfNError
+				return errors.As(err, &e) && strings.Contains(e.Reason, "520")
+			},
+		},
+		{
+			"public key not hex",
+			func() error {
+				_, err := multisig.GenerateAddress(1, 2, []string{compressed[0], "zz"}, multisig.AddressOptions{})
+				return err
+			},
+			func(err error) bool {
+				var e *multisig.PublicKeyError
+				return errors.As(err, &e) && e.Index == 1 && e.PublicKey == "zz"
+			},
+		},
+		{
+			"public key of a bad prefix",
+			func() error {
+				_, err := multisig.GenerateAddress(1, 1, []string{"05" + compressed[0][2:]}, multisig.AddressOptions{})
+				return err
+			},
+			func(err error) bool { var e *multisig.PublicKeyError; return errors.As(err, &e) && e.Index == 0 },
+		},
+		{
+			"truncated public key",
+			func() error {
+				_, err := multisig.GenerateAddress(1, 2, []string{compressed[0], compressed[1][:40]}, multisig.AddressOptions{})
+				return err
+			},
+			func(err error) bool { var e *multisig.PublicKeyError; return errors.As(err, &e) && e.Index == 1 },
+		},
+		{
+			"uncompressed public key for P2WSH",
+			func() error {
+				_, err := multisig.GenerateAddress(1, 2, []string{compressed[0], uncompressed[1]}, multisig.AddressOptions{Type: multisig.P2WSH})
+				return err
+			},
+			func(err error) bool { var e *multisig.PublicKeyError; return errors.As(err, &e) && e.Index == 1 },
+		},
+		{
+			"uncompressed public key sorted",
+			func() error {
+				_, err := multisig.GenerateAddress(1, 1, uncompressed[:1], multisig.AddressOptions{Sort: true})
+				return err
+			},
+			func(err error) bool { var e *multisig.PublicKeyError; return errors.As(err, &e) && e.Index == 0 },
+		},
+		{
+			"empty private key",
+			func() error { _, err := multisig.DecodePrivateKey(""); return err },
+			func(err error) bool { var e *multisig.PrivateKeyError; return errors.As(err, &e) },
+		},
+		{
+			"private key of a bad checksum",
+			func() error { _, err := multisig.DecodePrivateKey(wif[:len(wif)-1] + "G"); return err },
+			func(err error) bool { var e *multisig.PrivateKeyError; return errors.As(err, &e) },
+		},
+		{
+			"address as private key",
+			func() error { _, err := multisig.DecodePrivateKey(p2pkh); return err },
+			func(err error) bool { var e *multisig.PrivateKeyError; return errors.As(err, &e) },
+		},
+		{
+			"fund with a bad private key",
+			func() error {
+				_, err := multisig.GenerateFund("not a key", testTxHash(0), 1000, p2sh.Address)
+				return err
+			},
+			func(err error) bool { var e *multisig.PrivateKeyError; return errors.As(err, &e) && e.Index == 0 },
+		},
+		{
+			"fund with a short input transaction hash",
+			func() error { _, err := multisig.GenerateFund(wif, testTxHash(0)[:62], 1000, p2sh.Address); return err },
+			func(err error) bool {
+				var e *multisig.TxHashError
+				return errors.As(err, &e) && e.TxHash == testTxHash(0)[:62]
+			},
+		},
+		{
+			"fund with no amount",
+			func() error { _, err := multisig.GenerateFund(wif, testTxHash(0), 0, p2sh.Address); return err },
+			func(err error) bool { var e *multisig.AmountError; return errors.As(err, &e) && e.Amount == 0 },
+		},
+		{
+			"fund a P2PKH address",
+			func() error { _, err := multisig.GenerateFund(wif, testTxHash(0), 1000, p2pkh); return err },
+			func(err error) bool { var e *multisig.AddressError; return errors.As(err, &e) && e.Address == p2pkh },
+		},
+		{
+			"spend with a redeem script not hex",
+			func() error {
+				_, err := multisig.GenerateSpend([]string{wif}, p2pkh, "zz", testTxHash(0), 1000)
+				return err
+			},
+			func(err error) bool {
+				var e *multisig.RedeemScriptError
+				return errors.As(err, &e) && e.RedeemScript == "zz"
+			},
+		},
+		{
+			"spend with a bad second private key",
+			func() error {
+				_, err := multisig.GenerateSpend([]string{wif, "not a key"}, p2pkh, p2sh.RedeemScript, testTxHash(0), 1000)
+				return err
+			},
+			func(err error) bool { var e *multisig.PrivateKeyError; return errors.As(err, &e) && e.Index == 1 },
+		},
+		{
+			"spend with an input transaction hash not hex",
+			func() error {
+				_, err := multisig.GenerateSpend([]string{wif}, p2pkh, p2sh.RedeemScript, "zz", 1000)
+				return err
+			},
+			func(err error) bool { var e *multisig.TxHashError; return errors.As(err, &e) },
+		},
+		{
+			"spend a negative amount",
+			func() error {
+				_, err := multisig.GenerateSpend([]string{wif}, p2pkh, p2sh.RedeemScript, testTxHash(0), -1)
+				return err
+			},
+			func(err error) bool { var e *multisig.AmountError; return errors.As(err, &e) && e.Amount == -1 },
+		},
+		{
+			"spend to a P2SH address",
+			func() error {
+				_, err := multisig.GenerateSpend([]string{wif}, p2sh.Address, p2sh.RedeemScript, testTxHash(0), 1000)
+				return err
+			},
+			func(err error) bool {
+				var e *multisig.AddressError
+				return errors.As(err, &e) && e.Address == p2sh.Address
+			},
+		},
+		{
+			"payout of a script not multisig",
+			payout(func(p *multisig.PayoutParams) { p.Script = []byte{0x51} }),
+			func(err error) bool {
+				var e *multisig.RedeemScriptError
+				return errors.As(err, &e) && e.RedeemScript == "51"
+			},
+		},
+		{
+			"payout of a bad input transaction hash",
+			payout(func(p *multisig.PayoutParams) { p.Inputs[0].TxHash = "zz" }),
+			func(err error) bool { var e *multisig.TxHashError; return errors.As(err, &e) && e.TxHash == "zz" },
+		},
+		{
+			"payout of an empty input",
+			payout(func(p *multisig.PayoutParams) { p.Inputs[0].Amount = 0 }),
+			func(err error) bool { var e *multisig.AmountError; return errors.As(err, &e) && e.Amount == 0 },
+		},
+		{
+			"payout of an empty output",
+			payout(func(p *multisig.PayoutParams) { p.Outputs[0].Amount = -5 }),
+			func(err error) bool { var e *multisig.AmountError; return errors.As(err, &e) && e.Amount == -5 },
+		},
+		{
+			"payout to a malformed address",
+			payout(func(p *multisig.PayoutParams) { p.Outputs[0].Address = "bc1qnotanaddress" }),
+			func(err error) bool {
+				var e *multisig.AddressError
+				return errors.As(err, &e) && e.Address == "bc1qnotanaddress"
+			},
+		},
+		{
+			"payout to a testnet address",
+			payout(func(p *multisig.PayoutParams) { p.Net = &chaincfg.TestNet3Params }),
+			func(err error) bool { var e *multisig.AddressError; return errors.As(err, &e) && e.Address == p2pkh },
+		},
+		{
+			"payout above the inputs",
+			payout(func(p *multisig.PayoutParams) { p.Outputs[0].Amount = 100000 }),
+			func(err error) bool {
+				var e *multisig.InsufficientFundsError
+				return errors.As(err, &e) && e.Available == 100000 && e.Required > 100000
+			},
+		},
+	}
+	for _, test := range tests {
+		err := test.call()
+		if err == nil {
+			t.Errorf("%v: no error", test.name)
+		} else if !test.check(err) {
+			t.Errorf("%v: unexpected error %T: %v", test.name, err, err)
+		}
+	}
+}
+
+func TestSignatureErrors(t *testing.T) {
+	p2wsh := testP2WSH(t)
+	witnessScript, _ := hex.DecodeString(p2wsh.WitnessScript)
+	payout, err := multisig.NewPayout(multisig.PayoutParams{
+		Type:    multisig.P2WSH,
+		Script:  witnessScript,
+		Inputs:  []multisig.UTXO{{TxHash: testTxHash(0), Amount: 100000}, {TxHash: testTxHash(1), Amount: 100000}},
+		Outputs: []multisig.Payment{{Address: testP2PKH(t, 5), Amount: 150000}},
+		FeeRate: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func(i int) []multisig.PartialSignature {
+		t.Helper()
+		signatures, err := payout.Sign(testKey(i).Serialize())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signatures
+	}
+	first, second := sign(0), sign(1)
+	if _, err := payout.Sign(testKey(5).Serialize()); err == nil {
+		t.Error("signed with a key not in the script")
+	}
+
+	tests := []struct {
+		name       string
+		signatures []multisig.PartialSignature
+		input      int
+	}{
+		{"one signer", first, 0},
+		{"same signer twice", append(append([]multisig.PartialSignature{}, first...), first...), 0},
+		{"second input signed once", append(append([]multisig.PartialSignature{}, first...), second[0]), 1},
+		{"no such input", append(append([]multisig.PartialSignature{}, first...), multisig.PartialSignature{Input: 2}), 2},
+		{"signature not hex", []multisig.PartialSignature{{Input: 1, PublicKey: first[1].PublicKey, Signature: "zz"}}, 1},
+	}
+	for _, test := range tests {
+		_, err := payout.Finalize(test.signatures)
+		var e *multisig.SignatureError
+		if !errors.As(err, &e) || e.Input != test.input {
+			t.Errorf("%v: Finalize() = %v, want a SignatureError of input #%d", test.name, err, test.input)
+		}
+	}
+
+	// A signature of the other input does not verify
+	swapped := second[1]
+	swapped.Input = 0
+	var e *multisig.SignatureError
+	if err := payout.VerifySignature(swapped); !errors.As(err, &e) || e.Input != 0 {
+		t.Errorf("VerifySignature() = %v, want a SignatureError of input #0", err)
+	}
+
+	if _, err := payout.Finalize(append(first, second...)); err != nil {
+		t.Errorf("Finalize() = %v", err)
+	}
+}
@@ -4,15 +4,16 @@ import (
 	"bytes"
 	"encoding/hex"
 	"fmt"
-	"log"
 
 	"github.com/jualy007/GoTF/blockchain/btc"
-	"github.com/prettymuchbryce/hellobitcoin/base58check"
 )
 
 //OutputFund formats and prints relevant outputs to the user.
-func OutputFund(flagPrivateKey string, flagInputTx string, flagAmount int, flagP2SHDestination string) {
-	finalTransactionHex := generateFund(flagPrivateKey, flagInputTx, flagAmount, flagP2SHDestination)
+func OutputFund(flagPrivateKey string, flagInputTx string, flagAmount int, flagP2SHDestination string) error {
+	finalTransactionHex, err := GenerateFund(flagPrivateKey, flagInputTx, flagAmount, flagP2SHDestination)
+	if err != nil {
+		return err
+	}
 
 	//Output our final transaction
 	fmt.Printf(`
@@ -24,59 +25,73 @@ Broadcast this transaction to fund your P2SH address.
 `,
 		finalTransactionHex,
 	)
+
+	return nil
 }
 
-// generateFund is the high-level logic for funding any P2SH address with the 'go-bitcoin-multisig fund' subcommand.
-// Takes flagPrivateKey (private key of input Bitcoins to fund with), flagInputTx (input transaction hash of
-// Bitcoins to fund with), flagAmount (amount in Satoshis to send, with balance left over from input being used
-// as transaction fee) and flagP2SHDestination (destination P2SH multisig address which is being funded) as arguments.
-func generateFund(flagPrivateKey string, flagInputTx string, flagAmount int, flagP2SHDestination string) string {
+// GenerateFund is the high-level logic for funding any P2SH address with the 'gotf multisig fund' subcommand.
+// Takes privateKeyWIF (private key of input Bitcoins to fund with), inputTx (input transaction hash of
+// Bitcoins to fund with), amount (amount in Satoshis to send, with balance left over from input being used
+// as transaction fee) and p2shDestination (destination P2SH multisig address which is being funded) as arguments.
+func GenerateFund(privateKeyWIF string, inputTx string, amount int, p2shDestination string) (string, error) {
 	//Get private key as decoded raw bytes
-	privateKey := base58check.Decode(flagPrivateKey)
+	privateKey, err := decodePrivateKey(0, privateKeyWIF)
+	if err != nil {
+		return "", err
+	}
+	if err := checkTxHash(inputTx); err != nil {
+		return "", err
+	}
+	if amount <= 0 {
+		return "", &AmountError{amount}
+	}
+	redeemScriptHash, err := decodeAddressHash(p2shDestination, p2shVersion, p2shTestnetVersion)
+	if err != nil {
+		return "", err
+	}
 	//In order to construct the raw transaction we need the input transaction hash,
 	//the P2SH destination address, the number of satoshis to send, and the scriptSig
 	//which is temporarily (prior to signing) the ScriptPubKey of the input transaction.
 	publicKey, err := btc.NewPublicKey(privateKey)
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
 	publicKeyHash, err := btc.Hash160(publicKey)
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
 	tempScriptSig, err := btc.NewP2PKHScriptPubKey(publicKeyHash)
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
-	redeemScriptHash := base58check.Decode(flagP2SHDestination)
 	//Create our scriptPubKey
 	scriptPubKey, err := btc.NewP2SHScriptPubKey(redeemScriptHash)
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
 	//Create unsigned raw transaction
-	rawTransaction, err := btc.NewRawTransaction(flagInputTx, flagAmount, tempScriptSig, scriptPubKey)
+	rawTransaction, err := btc.NewRawTransaction(inputTx, amount, tempScriptSig, scriptPubKey)
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
 	//After completing the raw transaction, we append
 	//SIGHASH_ALL in little-endian format to the end of the raw transaction.
 	hashCodeType, err := hex.DecodeString("01000000")
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
 	var rawTransactionBuffer bytes.Buffer
 	rawTransactionBuffer.Write(rawTransaction)
 	rawTransactionBuffer.Write(hashCodeType)
 	rawTransactionWithHashCodeType := rawTransactionBuffer.Bytes()
 	//Sign the raw transaction, and output it to the console.
-	finalTransaction, err := signP2PKHTransaction(rawTransactionWithHashCodeType, privateKey, scriptPubKey, flagInputTx, flagAmount)
+	finalTransaction, err := signP2PKHTransaction(rawTransactionWithHashCodeType, privateKey, scriptPubKey, inputTx, amount)
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
 	finalTransactionHex := hex.EncodeToString(finalTransaction)
 
-	return finalTransactionHex
+	return finalTransactionHex, nil
 }
 
 // signP2PKHTransaction signs a raw P2PKH transaction, given a private key and the scriptPubKey, inputTx and amount
@@ -3,16 +3,18 @@ package multisig
 import (
 	"encoding/hex"
 	"fmt"
-	"log"
 
 	"github.com/jualy007/GoTF/blockchain/btc"
 	"github.com/prettymuchbryce/hellobitcoin/base58check"
 )
 
+const maxKeyCount = 100
+
 //OutputKeys formats and prints relevant outputs to the user.
-func OutputKeys(flagKeyCount int, flagConcise bool) {
-	if flagKeyCount < 1 || flagKeyCount > 100 {
-		log.Fatal("--count <count> must be between 1 and 100")
+func OutputKeys(flagKeyCount int, flagConcise bool) error {
+	keyPairs, err := GenerateKeys(flagKeyCount)
+	if err != nil {
+		return err
 	}
 
 	if !flagConcise {
@@ -26,9 +28,7 @@ func OutputKeys(flagKeyCount int, flagConcise bool) {
 		fmt.Println("----------------------------------------------------------------------")
 	}
 
-	privateKeyWIFs, publicKeyHexs, publicAddresses := generateKeys(flagKeyCount)
-
-	for i := 0; i <= flagKeyCount-1; i++ {
+	for i, keyPair := range keyPairs {
 
 		//Output private key in WIF format, public key as hex and P2PKH public address
 		fmt.Println("-------------------------------------------------------------")
@@ -37,48 +37,60 @@ func OutputKeys(flagKeyCount int, flagConcise bool) {
 			fmt.Println("")
 		}
 		fmt.Println("Private key: ")
-		fmt.Println(privateKeyWIFs[i])
+		fmt.Println(keyPair.PrivateKey)
 		if !flagConcise {
 			fmt.Println("")
 		}
 		fmt.Println("Public key hex: ")
-		fmt.Println(publicKeyHexs[i])
+		fmt.Println(keyPair.PublicKey)
 		if !flagConcise {
 			fmt.Println("")
 		}
 		fmt.Println("Public Bitcoin address: ")
-		fmt.Println(publicAddresses[i])
+		fmt.Println(keyPair.Address)
 		fmt.Println("-------------------------------------------------------------")
 	}
+
+	return nil
+}
+
+// KeyPair is a generated private key in WIF, its public key in hex and P2PKH address.
+type KeyPair struct {
+	PrivateKey string `json:"privateKey"`
+	PublicKey  string `json:"publicKey"`
+	Address    string `json:"address"`
 }
 
-// generateKeys is the high-level logic for generating public/private key pairs with the 'go-bitcoin-multisig keys' subcommand.
-// Takes flagCount (desired number of key pairs) and flagConcise (true hides warnings and helpful messages for conciseness)
-// as arguments.
-func generateKeys(flagKeyCount int) ([]string, []string, []string) {
-	publicKeyHexs := make([]string, flagKeyCount)
-	publicAddresses := make([]string, flagKeyCount)
-	privateKeyWIFs := make([]string, flagKeyCount)
+// GenerateKeys is the high-level logic for generating public/private key pairs with the 'gotf multisig keys' subcommand.
+// Takes keyCount (desired number of key pairs, between 1 and 100) as argument.
+func GenerateKeys(keyCount int) ([]KeyPair, error) {
+	if keyCount < 1 || keyCount > maxKeyCount {
+		return nil, &KeyCountError{keyCount}
+	}
+	keyPairs := make([]KeyPair, keyCount)
 
-	for i := 0; i <= flagKeyCount-1; i++ {
+	for i := range keyPairs {
 		//Generate private key
-		privateKey := btc.NewPrivateKey()
+		privateKey, err := btc.NewPrivateKey()
+		if err != nil {
+			return nil, err
+		}
 		//Generate public key from private key
 		publicKey, err := btc.NewPublicKey(privateKey)
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
 		//Get hex encoded version of public key
-		publicKeyHexs[i] = hex.EncodeToString(publicKey)
+		keyPairs[i].PublicKey = hex.EncodeToString(publicKey)
 		//Get public address by hashing with SHA256 and RIPEMD160 and base58 encoding with mainnet prefix 00
 		publicKeyHash, err := btc.Hash160(publicKey)
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
-		publicAddresses[i] = base58check.Encode("00", publicKeyHash)
+		keyPairs[i].Address = base58check.Encode("00", publicKeyHash)
 		//Get private key in Wallet Import Format (WIF) by base58 encoding with prefix 80
-		privateKeyWIFs[i] = base58check.Encode("80", privateKey)
+		keyPairs[i].PrivateKey = base58check.Encode("80", privateKey)
 	}
 
-	return privateKeyWIFs, publicKeyHexs, publicAddresses
+	return keyPairs, nil
 }
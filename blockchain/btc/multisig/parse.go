@@ -0,0 +1,145 @@
+package multisig
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil/base58"
+)
+
+// Base58 version bytes for mainnet and testnet P2PKH, P2SH and WIF encodings.
+const (
+	p2pkhVersion        = 0x00
+	p2pkhTestnetVersion = 0x6f
+	p2shVersion         = 0x05
+	p2shTestnetVersion  = 0xc4
+	wifVersion          = 0x80
+	wifTestnetVersion   = 0xef
+)
+
+// SplitList splits a comma separated flag value (single or double quoted items allowed) into trimmed items.
+func SplitList(flag string) ([]string, error) {
+	flag = strings.Replace(flag, "'", "\"", -1) //Replace single quotes with double since csv package only recognizes double quotes
+	items, err := csv.NewReader(strings.NewReader(flag)).Read()
+	if err != nil {
+		return nil, err
+	}
+	for i := range items {
+		items[i] = strings.TrimSpace(items[i]) //Trim whitespace
+	}
+	return items, nil
+}
+
+// DecodePrivateKey decodes a WIF private key into its raw 32 bytes.
+func DecodePrivateKey(wif string) ([]byte, error) {
+	return decodePrivateKey(0, wif)
+}
+
+// decodePrivateKey decodes a WIF private key into its raw 32 bytes.
+func decodePrivateKey(index int, wif string) ([]byte, error) {
+	if wif == "" {
+		return nil, &PrivateKeyError{index, errors.New("private key cannot be empty")}
+	}
+	payload, version, err := base58.CheckDecode(wif)
+	if err != nil {
+		return nil, &PrivateKeyError{index, err}
+	}
+	if version != wifVersion && version != wifTestnetVersion {
+		return nil, &PrivateKeyError{index, fmt.Errorf("unexpected WIF version byte 0x%02x", version)}
+	}
+	//Compressed WIF keys carry a trailing 0x01
+	if len(payload) == 33 && payload[32] == 0x01 {
+		payload = payload[:32]
+	}
+	if len(payload) != 32 {
+		return nil, &PrivateKeyError{index, fmt.Errorf("private key should be 32 bytes, got %d", len(payload))}
+	}
+	return payload, nil
+}
+
+// decodeAddressHash decodes a base58 P2PKH or P2SH address into its 20 byte hash,
+// checking the version byte is one of versions.
+func decodeAddressHash(address string, versions ...byte) ([]byte, error) {
+	payload, version, err := base58.CheckDecode(address)
+	if err != nil {
+		return nil, &AddressError{address, err}
+	}
+	if len(payload) != 20 {
+		return nil, &AddressError{address, fmt.Errorf("address hash should be 20 bytes, got %d", len(payload))}
+	}
+	for _, v := range versions {
+		if version == v {
+			return payload, nil
+		}
+	}
+	return nil, &AddressError{address, fmt.Errorf("unexpected address version byte 0x%02x", version)}
+}
+
+// checkTxHash checks an input transaction hash is 32 bytes of hex.
+func checkTxHash(txHash string) error {
+	raw, err := hex.DecodeString(txHash)
+	if err != nil {
+		return &TxHashError{txHash, err}
+	}
+	if len(raw) != 32 {
+		return &TxHashError{txHash, fmt.Errorf("hash should be 32 bytes, got %d", len(raw))}
+	}
+	return nil
+}
+
+// ParseNet returns the chain parameters for mainnet, testnet or regtest, the empty string defaults to mainnet.
+func ParseNet(name string) (*chaincfg.Params, error) {
+	switch strings.ToLower(name) {
+	case "", "mainnet":
+		return &chaincfg.MainNetParams, nil
+	case "testnet", "testnet3":
+		return &chaincfg.TestNet3Params, nil
+	case "regtest":
+		return &chaincfg.RegressionNetParams, nil
+	}
+	return nil, fmt.Errorf("unknown network %q, expected one of mainnet, testnet, regtest", name)
+}
+
+// ParseUTXO parses a "txid:vout:amount[:type]" input.
+func ParseUTXO(s string) (UTXO, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 && len(parts) != 4 {
+		return UTXO{}, fmt.Errorf("invalid input %q, expected txid:vout:amount[:type]", s)
+	}
+	if err := checkTxHash(parts[0]); err != nil {
+		return UTXO{}, err
+	}
+	vout, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return UTXO{}, fmt.Errorf("invalid output index in input %q: %v", s, err)
+	}
+	amount, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return UTXO{}, fmt.Errorf("invalid amount in input %q: %v", s, err)
+	}
+	utxo := UTXO{TxHash: parts[0], Vout: uint32(vout), Amount: amount}
+	if len(parts) == 4 {
+		if utxo.Type, err = ParseScriptType(parts[3]); err != nil {
+			return UTXO{}, err
+		}
+	}
+	return utxo, nil
+}
+
+// ParsePayment parses an "address:amount" output.
+func ParsePayment(s string) (Payment, error) {
+	i := strings.LastIndex(s, ":")
+	if i < 0 {
+		return Payment{}, fmt.Errorf("invalid output %q, expected address:amount", s)
+	}
+	amount, err := strconv.ParseInt(s[i+1:], 10, 64)
+	if err != nil {
+		return Payment{}, fmt.Errorf("invalid amount in output %q: %v", s, err)
+	}
+	return Payment{Address: s[:i], Amount: amount}, nil
+}
@@ -0,0 +1,493 @@
+package multisig
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/jualy007/GoTF/blockchain/btc"
+)
+
+// dustLimit is the smallest change output created, smaller change is left to the fee.
+const dustLimit = 546
+
+//OutputPayout formats and prints relevant outputs to the user.
+func OutputPayout(privateKeyWIFs []string, params PayoutParams) error {
+	payout, finalTransactionHex, err := GeneratePayout(privateKeyWIFs, params)
+	if err != nil {
+		return err
+	}
+	change := "none"
+	if payout.ChangeIndex >= 0 {
+		change = fmt.Sprintf("%d satoshis to output #%d", payout.Tx.TxOut[payout.ChangeIndex].Value, payout.ChangeIndex)
+	}
+
+	//Output our final transaction
+	fmt.Printf(`
+-----------------------------------------------------------------------------------------------------------------------------------
+Your raw payout transaction is:
+%v
+Fee: %d satoshis
+Change: %v
+Broadcast this transaction to spend your multisig funds.
+-----------------------------------------------------------------------------------------------------------------------------------
+`,
+		finalTransactionHex,
+		payout.Fee,
+		change,
+	)
+
+	return nil
+}
+
+// UTXO is a multisig output to be spent by a payout. Type defaults to the script type of the payout.
+type UTXO struct {
+	TxHash string     `json:"txid"`
+	Vout   uint32     `json:"vout"`
+	Amount int64      `json:"amount"`
+	Type   ScriptType `json:"type,omitempty"`
+}
+
+// Payment is an output of a payout. Address may be a P2PKH, P2SH, P2WPKH, P2WSH or P2TR address.
+type Payment struct {
+	Address string `json:"address"`
+	Amount  int64  `json:"amount"`
+}
+
+// PayoutParams describes a spend of multisig UTXOs to one or more destinations.
+type PayoutParams struct {
+	Type ScriptType
+	//Script is the multisig script: the redeem script for P2SH, the witness script for P2WSH and P2SH-P2WSH
+	Script  []byte
+	Inputs  []UTXO
+	Outputs []Payment
+	//FeeRate is in satoshis per virtual byte
+	FeeRate int64
+	//ChangeAddress receives the change, defaults to the multisig address of Type
+	ChangeAddress string
+	Net           *chaincfg.Params
+}
+
+// Payout is an unsigned multisig payout transaction and what is needed to sign it.
+type Payout struct {
+	PayoutParams
+	Tx          *wire.MsgTx
+	Fee         int64
+	ChangeIndex int //-1 if the transaction has no change output
+
+	m           int
+	publicKeys  [][]byte
+	prevScripts [][]byte //scriptPubKey of each input
+}
+
+// PartialSignature is the signature of one payout input by one of the multisig keys, both hex encoded.
+type PartialSignature struct {
+	Input     int    `json:"input"`
+	PublicKey string `json:"publicKey"`
+	Signature string `json:"signature"`
+}
+
+// GeneratePayout is the high-level logic for spending multisig UTXOs with the 'gotf multisig payout' subcommand.
+// Takes privateKeyWIFs (list of at least M private keys, in any order) and params (inputs, outputs, fee rate and change)
+// as arguments, and returns the payout and the hex encoded signed transaction.
+func GeneratePayout(privateKeyWIFs []string, params PayoutParams) (*Payout, string, error) {
+	payout, err := NewPayout(params)
+	if err != nil {
+		return nil, "", err
+	}
+	var signatures []PartialSignature
+	for i, privateKeyWIF := range privateKeyWIFs {
+		privateKey, err := decodePrivateKey(i, privateKeyWIF)
+		if err != nil {
+			return nil, "", err
+		}
+		partial, err := payout.Sign(privateKey)
+		if err != nil {
+			return nil, "", &PrivateKeyError{i, err}
+		}
+		signatures = append(signatures, partial...)
+	}
+	finalTransaction, err := payout.Finalize(signatures)
+	if err != nil {
+		return nil, "", err
+	}
+	finalTransactionHex, err := SerializeTx(finalTransaction)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return payout, finalTransactionHex, nil
+}
+
+// NewPayout creates the unsigned payout transaction. Change below the dust limit is added to the fee.
+func NewPayout(params PayoutParams) (*Payout, error) {
+	if params.Type == "" {
+		params.Type = P2SH
+	}
+	if params.Net == nil {
+		params.Net = &chaincfg.MainNetParams
+	}
+	m, publicKeys, err := parseMultisigScript(params.Script)
+	if err != nil {
+		return nil, &RedeemScriptError{hex.EncodeToString(params.Script), err}
+	}
+	switch {
+	case len(params.Inputs) == 0:
+		return nil, errors.New("at least one input is required")
+	case len(params.Outputs) == 0:
+		return nil, errors.New("at least one output is required")
+	case params.FeeRate < 1:
+		return nil, fmt.Errorf("fee rate must be at least 1 satoshi per vbyte, got %d", params.FeeRate)
+	}
+
+	payout := &Payout{
+		PayoutParams: params,
+		Tx:           wire.NewMsgTx(wire.TxVersion),
+		ChangeIndex:  -1,
+		m:            m,
+		publicKeys:   publicKeys,
+	}
+	payout.Inputs = make([]UTXO, len(params.Inputs))
+	var available, spent int64
+	for i, input := range params.Inputs {
+		if input.Type == "" {
+			input.Type = params.Type
+		}
+		if input.Type, err = ParseScriptType(string(input.Type)); err != nil {
+			return nil, err
+		}
+		if err := checkScriptType(input.Type, params.Script, publicKeys); err != nil {
+			return nil, &RedeemScriptError{hex.EncodeToString(params.Script), err}
+		}
+		if err := checkTxHash(input.TxHash); err != nil {
+			return nil, err
+		}
+		if input.Amount <= 0 {
+			return nil, &AmountError{int(input.Amount)}
+		}
+		hash, err := chainhash.NewHashFromStr(input.TxHash)
+		if err != nil {
+			return nil, &TxHashError{input.TxHash, err}
+		}
+		address, _, err := scriptAddress(input.Type, params.Script, params.Net)
+		if err != nil {
+			return nil, err
+		}
+		prevScript, err := txscript.PayToAddrScript(address)
+		if err != nil {
+			return nil, err
+		}
+		payout.Inputs[i] = input
+		payout.prevScripts = append(payout.prevScripts, prevScript)
+		payout.Tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(hash, input.Vout), nil, nil))
+		available += input.Amount
+	}
+	for _, output := range params.Outputs {
+		if output.Amount <= 0 {
+			return nil, &AmountError{int(output.Amount)}
+		}
+		scriptPubKey, err := payToAddress(output.Address, params.Net)
+		if err != nil {
+			return nil, err
+		}
+		payout.Tx.AddTxOut(wire.NewTxOut(output.Amount, scriptPubKey))
+		spent += output.Amount
+	}
+
+	//Change goes back to the multisig address unless told otherwise
+	var changeScript []byte
+	if params.ChangeAddress == "" {
+		address, _, err := scriptAddress(params.Type, params.Script, params.Net)
+		if err != nil {
+			return nil, err
+		}
+		changeScript, err = txscript.PayToAddrScript(address)
+		if err != nil {
+			return nil, err
+		}
+	} else if changeScript, err = payToAddress(params.ChangeAddress, params.Net); err != nil {
+		return nil, err
+	}
+	changeOutput := wire.NewTxOut(0, changeScript)
+	feeWithChange := payout.virtualSize(changeOutput) * params.FeeRate
+	if change := available - spent - feeWithChange; change >= dustLimit {
+		changeOutput.Value = change
+		payout.Tx.AddTxOut(changeOutput)
+		payout.ChangeIndex = len(payout.Tx.TxOut) - 1
+		payout.Fee = feeWithChange
+		return payout, nil
+	}
+	fee := payout.virtualSize() * params.FeeRate
+	if available < spent+fee {
+		return nil, &InsufficientFundsError{available, spent + fee}
+	}
+	payout.Fee = available - spent
+
+	return payout, nil
+}
+
+// UnsignedTransaction returns the hex encoded unsigned payout transaction.
+func (p *Payout) UnsignedTransaction() (string, error) {
+	return SerializeTx(p.Tx)
+}
+
+// Sign signs every input of the payout with privateKey, which must belong to one of the multisig public keys.
+func (p *Payout) Sign(privateKey []byte) ([]PartialSignature, error) {
+	key, publicKey := btcec.PrivKeyFromBytes(btcec.S256(), privateKey)
+	var publicKeyHex string
+	for _, k := range p.publicKeys {
+		if bytes.Equal(k, publicKey.SerializeCompressed()) || bytes.Equal(k, publicKey.SerializeUncompressed()) {
+			publicKeyHex = hex.EncodeToString(k)
+			break
+		}
+	}
+	if publicKeyHex == "" {
+		return nil, errors.New("private key does not match any public key of the multisig script")
+	}
+
+	sigHashes := txscript.NewTxSigHashes(p.Tx)
+	signatures := make([]PartialSignature, len(p.Inputs))
+	for i, input := range p.Inputs {
+		var signature []byte
+		var err error
+		if input.Type.IsSegWit() {
+			signature, err = txscript.RawTxInWitnessSignature(p.Tx, sigHashes, i, input.Amount, p.Script, txscript.SigHashAll, key)
+		} else {
+			signature, err = txscript.RawTxInSignature(p.Tx, i, p.Script, txscript.SigHashAll, key)
+		}
+		if err != nil {
+			return nil, &SignatureError{i, err}
+		}
+		signatures[i] = PartialSignature{Input: i, PublicKey: publicKeyHex, Signature: hex.EncodeToString(signature)}
+	}
+	return signatures, nil
+}
+
+// Required returns M, the number of signatures each input needs.
+func (p *Payout) Required() int {
+	return p.m
+}
+
+// VerifySignature checks a partial signature is a valid SIGHASH_ALL signature of its input
+// by one of the multisig public keys.
+func (p *Payout) VerifySignature(s PartialSignature) error {
+	if s.Input < 0 || s.Input >= len(p.Inputs) {
+		return &SignatureError{s.Input, errors.New("no such input")}
+	}
+	publicKeyBytes, err := hex.DecodeString(s.PublicKey)
+	if err != nil {
+		return &SignatureError{s.Input, err}
+	}
+	var found bool
+	for _, k := range p.publicKeys {
+		found = found || bytes.Equal(k, publicKeyBytes)
+	}
+	if !found {
+		return &SignatureError{s.Input, fmt.Errorf("public key %v is not part of the multisig script", s.PublicKey)}
+	}
+	signatureBytes, err := hex.DecodeString(s.Signature)
+	if err != nil {
+		return &SignatureError{s.Input, err}
+	}
+	if len(signatureBytes) < 2 || txscript.SigHashType(signatureBytes[len(signatureBytes)-1]) != txscript.SigHashAll {
+		return &SignatureError{s.Input, errors.New("signature must use SIGHASH_ALL")}
+	}
+	signature, err := btcec.ParseDERSignature(signatureBytes[:len(signatureBytes)-1], btcec.S256())
+	if err != nil {
+		return &SignatureError{s.Input, err}
+	}
+	publicKey, err := btcec.ParsePubKey(publicKeyBytes, btcec.S256())
+	if err != nil {
+		return &SignatureError{s.Input, err}
+	}
+
+	var hash []byte
+	input := p.Inputs[s.Input]
+	if input.Type.IsSegWit() {
+		hash, err = txscript.CalcWitnessSigHash(p.Script, txscript.NewTxSigHashes(p.Tx), txscript.SigHashAll, p.Tx, s.Input, input.Amount)
+	} else {
+		hash, err = txscript.CalcSignatureHash(p.Script, txscript.SigHashAll, p.Tx, s.Input)
+	}
+	if err != nil {
+		return &SignatureError{s.Input, err}
+	}
+	if !signature.Verify(hash, publicKey) {
+		return &SignatureError{s.Input, errors.New("signature does not verify")}
+	}
+	return nil
+}
+
+// Finalize combines partial signatures into the signed transaction. Each input needs signatures from
+// at least M distinct keys, and is verified against the output it spends.
+func (p *Payout) Finalize(signatures []PartialSignature) (*wire.MsgTx, error) {
+	bySigner := make([]map[string][]byte, len(p.Inputs))
+	for i := range bySigner {
+		bySigner[i] = make(map[string][]byte)
+	}
+	for _, s := range signatures {
+		if s.Input < 0 || s.Input >= len(p.Inputs) {
+			return nil, &SignatureError{s.Input, errors.New("no such input")}
+		}
+		signature, err := hex.DecodeString(s.Signature)
+		if err != nil {
+			return nil, &SignatureError{s.Input, err}
+		}
+		bySigner[s.Input][strings.ToLower(s.PublicKey)] = signature
+	}
+
+	tx := p.Tx.Copy()
+	sigHashes := txscript.NewTxSigHashes(tx)
+	for i, input := range p.Inputs {
+		//OP_CHECKMULTISIG needs signatures in the same order as the public keys in the script
+		var sigs [][]byte
+		for _, publicKey := range p.publicKeys {
+			if signature, ok := bySigner[i][hex.EncodeToString(publicKey)]; ok && len(sigs) < p.m {
+				sigs = append(sigs, signature)
+			}
+		}
+		if len(sigs) < p.m {
+			return nil, &SignatureError{i, fmt.Errorf("got %d of %d required signatures", len(sigs), p.m)}
+		}
+
+		var err error
+		switch input.Type {
+		case P2SH:
+			builder := txscript.NewScriptBuilder().AddOp(txscript.OP_0) //OP_0 for Multisig off-by-one error
+			for _, signature := range sigs {
+				builder.AddData(signature)
+			}
+			tx.TxIn[i].SignatureScript, err = builder.AddData(p.Script).Script()
+		case P2WSH, P2SHP2WSH:
+			witness := wire.TxWitness{[]byte{}} //empty item for Multisig off-by-one error
+			witness = append(witness, sigs...)
+			tx.TxIn[i].Witness = append(witness, p.Script)
+			if input.Type == P2SHP2WSH {
+				redeemScript, _ := btc.NewP2WSHScriptPubKey(p.Script)
+				tx.TxIn[i].SignatureScript, err = txscript.NewScriptBuilder().AddData(redeemScript).Script()
+			}
+		}
+		if err != nil {
+			return nil, &SignatureError{i, err}
+		}
+
+		engine, err := txscript.NewEngine(p.prevScripts[i], tx, i, txscript.StandardVerifyFlags, nil, sigHashes, input.Amount)
+		if err != nil {
+			return nil, &SignatureError{i, err}
+		}
+		if err := engine.Execute(); err != nil {
+			return nil, &SignatureError{i, err}
+		}
+	}
+	return tx, nil
+}
+
+// virtualSize estimates the virtual size of the signed payout with extra outputs added,
+// assuming maximum size signatures.
+func (p *Payout) virtualSize(extra ...*wire.TxOut) int64 {
+	baseSize := p.Tx.SerializeSizeStripped()
+	for _, output := range extra {
+		baseSize += output.SerializeSize()
+	}
+	witnessSize := 0
+	signaturesSize := p.m * (1 + maxSignatureSize)
+	multisigWitnessSize := wire.VarIntSerializeSize(uint64(p.m+2)) + 1 + signaturesSize +
+		wire.VarIntSerializeSize(uint64(len(p.Script))) + len(p.Script)
+	for _, input := range p.Inputs {
+		//The unsigned transaction already counts one byte for each empty scriptSig
+		switch input.Type {
+		case P2SH:
+			scriptSigSize := 1 + signaturesSize + pushDataSize(len(p.Script)) + len(p.Script)
+			baseSize += wire.VarIntSerializeSize(uint64(scriptSigSize)) - 1 + scriptSigSize
+			witnessSize++ //empty witness, only counted when another input has one
+		case P2WSH:
+			witnessSize += multisigWitnessSize
+		case P2SHP2WSH:
+			baseSize += 1 + 34 //push of the P2WSH program
+			witnessSize += multisigWitnessSize
+		}
+	}
+	weight := baseSize * 4
+	if witnessSize > len(p.Inputs) {
+		weight += 2 + witnessSize //segwit marker and flag
+	}
+	return int64((weight + 3) / 4)
+}
+
+// checkScriptType checks the multisig script can be spent from an output of the given type.
+func checkScriptType(scriptType ScriptType, script []byte, publicKeys [][]byte) error {
+	if !scriptType.IsSegWit() {
+		if len(script) > maxScriptElementSize {
+			return fmt.Errorf("redeem script of %d bytes exceeds the %d byte limit", len(script), maxScriptElementSize)
+		}
+		return nil
+	}
+	for _, publicKey := range publicKeys {
+		if !btc.IsCompressedPublicKey(publicKey) {
+			return fmt.Errorf("%v requires compressed public keys", scriptType)
+		}
+	}
+	return nil
+}
+
+// parseMultisigScript returns M and the public keys of an M-of-N multisig script.
+func parseMultisigScript(script []byte) (int, [][]byte, error) {
+	if len(script) < 3 || script[len(script)-1] != txscript.OP_CHECKMULTISIG {
+		return 0, nil, errors.New("not a multisig script")
+	}
+	pushes, err := txscript.PushedData(script)
+	if err != nil {
+		return 0, nil, err
+	}
+	var m int
+	switch {
+	case script[0] >= txscript.OP_1 && script[0] <= txscript.OP_16:
+		m = int(script[0]-txscript.OP_1) + 1
+	case script[0] == txscript.OP_DATA_1:
+		m = int(script[1])
+	default:
+		return 0, nil, errors.New("multisig script does not start with M")
+	}
+	var publicKeys [][]byte
+	for _, push := range pushes {
+		if len(push) == 33 || len(push) == 65 {
+			publicKeys = append(publicKeys, push)
+		}
+	}
+	if m < 1 || m > len(publicKeys) {
+		return 0, nil, fmt.Errorf("invalid %d-of-%d multisig script", m, len(publicKeys))
+	}
+	return m, publicKeys, nil
+}
+
+// payToAddress returns the scriptPubKey paying to a P2PKH, P2SH, segwit v0 or taproot address.
+func payToAddress(address string, net *chaincfg.Params) ([]byte, error) {
+	decoded, err := btc.DecodeAddress(address, net)
+	if err != nil {
+		return nil, &AddressError{address, err}
+	}
+	if !decoded.IsForNet(net) {
+		return nil, &AddressError{address, fmt.Errorf("address is not for %v", net.Name)}
+	}
+	scriptPubKey, err := btc.PayToAddrScript(decoded)
+	if err != nil {
+		return nil, &AddressError{address, err}
+	}
+	return scriptPubKey, nil
+}
+
+// SerializeTx returns the hex encoded transaction.
+func SerializeTx(tx *wire.MsgTx) (string, error) {
+	var buffer bytes.Buffer
+	if err := tx.Serialize(&buffer); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buffer.Bytes()), nil
+}
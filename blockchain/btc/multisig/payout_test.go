@@ -0,0 +1,316 @@
+package multisig_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+
+	"github.com/jualy007/GoTF/blockchain/btc"
+	"github.com/jualy007/GoTF/blockchain/btc/multisig"
+)
+
+// testScript returns the m-of-n multisig script of the first n test keys.
+func testScript(t *testing.T, m, n int, compressed bool) []byte {
+	t.Helper()
+	//Uncompressed keys are only allowed in P2SH
+	opts := multisig.AddressOptions{Type: multisig.P2SH}
+	if compressed {
+		opts.Type = multisig.P2WSH
+	}
+	address, err := multisig.GenerateAddress(m, n, testPublicKeys(n, compressed), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := address.RedeemScript
+	if compressed {
+		script = address.WitnessScript
+	}
+	raw, err := hex.DecodeString(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+// outputScript returns the scriptPubKey paying to the multisig script as scriptType.
+func outputScript(t *testing.T, scriptType multisig.ScriptType, script []byte) []byte {
+	t.Helper()
+	hash := sha256.Sum256(script)
+	program := append([]byte{txscript.OP_0, txscript.OP_DATA_32}, hash[:]...)
+	var address btcutil.Address
+	var err error
+	switch scriptType {
+	case multisig.P2SH:
+		address, err = btcutil.NewAddressScriptHash(script, &chaincfg.MainNetParams)
+	case multisig.P2WSH:
+		address, err = btcutil.NewAddressWitnessScriptHash(hash[:], &chaincfg.MainNetParams)
+	case multisig.P2SHP2WSH:
+		address, err = btcutil.NewAddressScriptHash(program, &chaincfg.MainNetParams)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkScript, err := txscript.PayToAddrScript(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkScript
+}
+
+// testDestinations returns a P2PKH, a P2WPKH and a P2TR mainnet address with their scriptPubKeys.
+func testDestinations(t *testing.T) ([]string, [][]byte) {
+	t.Helper()
+	publicKey := testKey(7).PubKey().SerializeCompressed()
+	p2pkh, err := btcutil.NewAddressPubKeyHash(btcutil.Hash160(publicKey), &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2wpkh, err := btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(publicKey), &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2tr, err := btc.NewAddressTaproot(publicKey[1:], &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addresses := []string{p2pkh.EncodeAddress(), p2wpkh.EncodeAddress(), p2tr.EncodeAddress()}
+	scripts := [][]byte{
+		append(append([]byte{txscript.OP_DUP, txscript.OP_HASH160, txscript.OP_DATA_20}, btcutil.Hash160(publicKey)...), txscript.OP_EQUALVERIFY, txscript.OP_CHECKSIG),
+		append([]byte{txscript.OP_0, txscript.OP_DATA_20}, btcutil.Hash160(publicKey)...),
+		append([]byte{txscript.OP_1, txscript.OP_DATA_32}, publicKey[1:]...),
+	}
+	return addresses, scripts
+}
+
+// signPayout signs the payout with the given test keys, checks every partial signature,
+// and finalizes it.
+func signPayout(t *testing.T, payout *multisig.Payout, keys ...int) (*wire.MsgTx, error) {
+	t.Helper()
+	var signatures []multisig.PartialSignature
+	for _, key := range keys {
+		partial, err := payout.Sign(testKey(key).Serialize())
+		if err != nil {
+			t.Fatalf("Sign() with key %d: %v", key, err)
+		}
+		if len(partial) != len(payout.Inputs) {
+			t.Fatalf("%d signatures of %d inputs", len(partial), len(payout.Inputs))
+		}
+		for _, signature := range partial {
+			if err := payout.VerifySignature(signature); err != nil {
+				t.Errorf("VerifySignature() of key %d: %v", key, err)
+			}
+		}
+		signatures = append(signatures, partial...)
+	}
+	return payout.Finalize(signatures)
+}
+
+// checkFee checks the fee, less the dust change left to it, pays the fee rate for the size of tx, at most
+// a couple of bytes per signature above as the estimate assumes maximum size signatures.
+func checkFee(t *testing.T, payout *multisig.Payout, tx *wire.MsgTx, dust int64) {
+	t.Helper()
+	vsize := (blockchain.GetTransactionWeight(btcutil.NewTx(tx)) + 3) / 4
+	slack := int64(2*payout.Required()*len(tx.TxIn) + 1)
+	if fee := payout.Fee - dust; fee%payout.FeeRate != 0 || fee < vsize*payout.FeeRate || fee > (vsize+slack)*payout.FeeRate {
+		t.Errorf("fee %d for %d vbytes at %d satoshis per vbyte", payout.Fee, vsize, payout.FeeRate)
+	}
+	var in, out int64
+	for _, input := range payout.Inputs {
+		in += input.Amount
+	}
+	for _, output := range tx.TxOut {
+		out += output.Value
+	}
+	if in-out != payout.Fee {
+		t.Errorf("inputs of %d and outputs of %d satoshis, fee %d", in, out, payout.Fee)
+	}
+}
+
+func TestPayout(t *testing.T) {
+	destinations, destinationScripts := testDestinations(t)
+	tests := []struct {
+		name       string
+		scriptType multisig.ScriptType
+		m, n       int
+		compressed bool
+		signers    []int
+	}{
+		{"P2SH", multisig.P2SH, 2, 3, true, []int{2, 0}},
+		{"P2SH of uncompressed keys", multisig.P2SH, 2, 3, false, []int{1, 2}},
+		{"P2WSH", multisig.P2WSH, 2, 3, true, []int{2, 1}},
+		{"P2WSH of every key", multisig.P2WSH, 2, 3, true, []int{0, 1, 2}},
+		{"P2SH-P2WSH", multisig.P2SHP2WSH, 3, 5, true, []int{4, 0, 2}},
+		{"P2WSH 17-of-20", multisig.P2WSH, 17, 20, true, []int{19, 18, 17, 16, 15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3}},
+	}
+	for _, test := range tests {
+		script := testScript(t, test.m, test.n, test.compressed)
+		inputs := []multisig.UTXO{
+			{TxHash: testTxHash(0), Vout: 1, Amount: 100000},
+			{TxHash: testTxHash(1), Vout: 0, Amount: 250000},
+		}
+		payout, err := multisig.NewPayout(multisig.PayoutParams{
+			Type:   test.scriptType,
+			Script: script,
+			Inputs: inputs,
+			Outputs: []multisig.Payment{
+				{Address: destinations[0], Amount: 50000},
+				{Address: destinations[1], Amount: 60000},
+				{Address: destinations[2], Amount: 70000},
+			},
+			FeeRate: 7,
+		})
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+			continue
+		}
+		if payout.Required() != test.m {
+			t.Errorf("%v: %d required signatures", test.name, payout.Required())
+		}
+
+		tx, err := signPayout(t, payout, test.signers...)
+		if err != nil {
+			t.Errorf("%v: Finalize() = %v", test.name, err)
+			continue
+		}
+		prevScript := outputScript(t, test.scriptType, script)
+		for i := range tx.TxIn {
+			if tx.TxIn[i].PreviousOutPoint.Hash.String() != inputs[i].TxHash || tx.TxIn[i].PreviousOutPoint.Index != inputs[i].Vout {
+				t.Errorf("%v: input #%d spends %v", test.name, i, tx.TxIn[i].PreviousOutPoint)
+			}
+			execute(t, tx, i, prevScript, inputs[i].Amount)
+		}
+		if len(tx.TxOut) != 4 || payout.ChangeIndex != 3 {
+			t.Fatalf("%v: %d outputs, change at %d", test.name, len(tx.TxOut), payout.ChangeIndex)
+		}
+		for i, amount := range []int64{50000, 60000, 70000} {
+			if tx.TxOut[i].Value != amount || !bytes.Equal(tx.TxOut[i].PkScript, destinationScripts[i]) {
+				t.Errorf("%v: output #%d pays %d to %x", test.name, i, tx.TxOut[i].Value, tx.TxOut[i].PkScript)
+			}
+		}
+		// Change goes back to the multisig address
+		if !bytes.Equal(tx.TxOut[3].PkScript, prevScript) {
+			t.Errorf("%v: change pays to %x, want %x", test.name, tx.TxOut[3].PkScript, prevScript)
+		}
+		checkFee(t, payout, tx, 0)
+
+		// The unsigned transaction is left untouched
+		if unsigned, _ := payout.UnsignedTransaction(); len(payout.Tx.TxIn[0].SignatureScript) != 0 || len(payout.Tx.TxIn[0].Witness) != 0 {
+			t.Errorf("%v: unsigned transaction %v", test.name, unsigned)
+		}
+		if _, err := signPayout(t, payout, test.signers[:test.m-1]...); err == nil {
+			t.Errorf("%v: finalized with %d signers", test.name, test.m-1)
+		}
+	}
+}
+
+func TestPayoutMixedInputs(t *testing.T) {
+	// Compressed keys give the same script for the three types
+	script := testScript(t, 2, 3, true)
+	inputs := []multisig.UTXO{
+		{TxHash: testTxHash(0), Amount: 30000, Type: multisig.P2SH},
+		{TxHash: testTxHash(1), Amount: 40000, Type: multisig.P2WSH},
+		{TxHash: testTxHash(2), Amount: 50000, Type: multisig.P2SHP2WSH},
+		{TxHash: testTxHash(3), Amount: 60000},
+	}
+	destinations, destinationScripts := testDestinations(t)
+	payout, err := multisig.NewPayout(multisig.PayoutParams{
+		Type:          multisig.P2WSH,
+		Script:        script,
+		Inputs:        inputs,
+		Outputs:       []multisig.Payment{{Address: destinations[2], Amount: 100000}},
+		FeeRate:       3,
+		ChangeAddress: destinations[1],
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payout.Inputs[3].Type != multisig.P2WSH {
+		t.Errorf("input #3 of type %v, want the payout type", payout.Inputs[3].Type)
+	}
+	tx, err := signPayout(t, payout, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, input := range payout.Inputs {
+		execute(t, tx, i, outputScript(t, input.Type, script), input.Amount)
+	}
+	if len(tx.TxIn[0].SignatureScript) == 0 || len(tx.TxIn[0].Witness) != 0 || len(tx.TxIn[1].SignatureScript) != 0 || len(tx.TxIn[2].SignatureScript) != 35 {
+		t.Errorf("unexpected inputs %v", tx.TxIn)
+	}
+	if payout.ChangeIndex != 1 || !bytes.Equal(tx.TxOut[1].PkScript, destinationScripts[1]) {
+		t.Errorf("change #%d of %d outputs", payout.ChangeIndex, len(tx.TxOut))
+	}
+	checkFee(t, payout, tx, 0)
+}
+
+func TestPayoutChange(t *testing.T) {
+	script := testScript(t, 2, 3, true)
+	destinations, _ := testDestinations(t)
+	const available, feeRate = 100000, 10
+	newPayout := func(amount int64) (*multisig.Payout, error) {
+		return multisig.NewPayout(multisig.PayoutParams{
+			Type:    multisig.P2WSH,
+			Script:  script,
+			Inputs:  []multisig.UTXO{{TxHash: testTxHash(0), Amount: available}},
+			Outputs: []multisig.Payment{{Address: destinations[1], Amount: amount}},
+			FeeRate: feeRate,
+		})
+	}
+	payout, err := newPayout(10000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	feeWithChange := payout.Fee
+	// A P2WSH change output is 8 bytes of amount and a 35 byte script
+	feeWithoutChange := feeWithChange - 43*feeRate
+
+	tests := []struct {
+		name   string
+		amount int64
+		change int64 //-1 without change
+		fee    int64
+		dust   int64 //left to the fee
+	}{
+		{"change", 10000, available - 10000 - feeWithChange, feeWithChange, 0},
+		{"dust limit change", available - feeWithChange - 546, 546, feeWithChange, 0},
+		{"dust change", available - feeWithChange - 545, -1, feeWithChange + 545, 545 + 43*feeRate},
+		{"no change", available - feeWithoutChange, -1, feeWithoutChange, 0},
+	}
+	for _, test := range tests {
+		payout, err := newPayout(test.amount)
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+			continue
+		}
+		if payout.Fee != test.fee {
+			t.Errorf("%v: fee %d, want %d", test.name, payout.Fee, test.fee)
+		}
+		switch {
+		case test.change < 0 && (payout.ChangeIndex != -1 || len(payout.Tx.TxOut) != 1):
+			t.Errorf("%v: change #%d of %d outputs", test.name, payout.ChangeIndex, len(payout.Tx.TxOut))
+		case test.change >= 0 && (payout.ChangeIndex != 1 || payout.Tx.TxOut[1].Value != test.change):
+			t.Errorf("%v: change #%d of %d outputs, want %d satoshis", test.name, payout.ChangeIndex, len(payout.Tx.TxOut), test.change)
+		}
+		tx, err := signPayout(t, payout, 0, 2)
+		if err != nil {
+			t.Fatalf("%v: %v", test.name, err)
+		}
+		checkFee(t, payout, tx, test.dust)
+	}
+
+	for _, amount := range []int64{available - feeWithoutChange + 1, available, available + 1} {
+		_, err := newPayout(amount)
+		var e *multisig.InsufficientFundsError
+		if !errors.As(err, &e) || e.Available != available || e.Required != amount+feeWithoutChange {
+			t.Errorf("NewPayout() of %d satoshis = %v, want an InsufficientFundsError", amount, err)
+		}
+	}
+}
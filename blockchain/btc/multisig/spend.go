@@ -3,19 +3,22 @@ package multisig
 import (
 	"bytes"
 	"encoding/binary"
-	"encoding/csv"
 	"encoding/hex"
 	"fmt"
-	"log"
-	"strings"
 
 	"github.com/jualy007/GoTF/blockchain/btc"
-	"github.com/prettymuchbryce/hellobitcoin/base58check"
 )
 
 //OutputSpend formats and prints relevant outputs to the user.
-func OutputSpend(flagPrivateKeys string, flagDestination string, flagRedeemScript string, flagInputTx string, flagAmount int) {
-	finalTransactionHex := generateSpend(flagPrivateKeys, flagDestination, flagRedeemScript, flagInputTx, flagAmount)
+func OutputSpend(flagPrivateKeys string, flagDestination string, flagRedeemScript string, flagInputTx string, flagAmount int) error {
+	privateKeys, err := SplitList(flagPrivateKeys)
+	if err != nil {
+		return err
+	}
+	finalTransactionHex, err := GenerateSpend(privateKeys, flagDestination, flagRedeemScript, flagInputTx, flagAmount)
+	if err != nil {
+		return err
+	}
 	//Output final transaction
 	//Output our final transaction
 	fmt.Printf(`
@@ -27,67 +30,72 @@ Broadcast this transaction to spend your multisig P2SH funds.
 `,
 		finalTransactionHex,
 	)
+
+	return nil
 }
 
-// generateSpend is the high-level logic for spending from a P2SH multisig address with the 'go-bitcoin-multisig spend' subcommand.
-// Takes flagPrivateKeys (comma separated list of M private keys), flagDestination (destination address of spent funds),
-// flagRedeemScript (redeemScript that matches P2SH script), flagInputTx (input transaction hash of P2SH input to spend)
-// and flagAmount (amount in Satoshis to send, with balance left over from input being used as transaction fee) as arguments.
-func generateSpend(flagPrivateKeys string, flagDestination string, flagRedeemScript string, flagInputTx string, flagAmount int) string {
+// GenerateSpend is the high-level logic for spending from a P2SH multisig address with the 'gotf multisig spend' subcommand.
+// Takes privateKeyWIFs (list of M private keys), destination (destination P2PKH address of spent funds),
+// redeemScriptHex (redeemScript that matches P2SH script), inputTx (input transaction hash of P2SH input to spend)
+// and amount (amount in Satoshis to send, with balance left over from input being used as transaction fee) as arguments.
+func GenerateSpend(privateKeyWIFs []string, destination string, redeemScriptHex string, inputTx string, amount int) (string, error) {
 	//First we create the raw transaction.
 	//In order to construct the raw transaction we need the input transaction hash,
 	//the destination address, the number of satoshis to send, and the scriptSig
 	//which is temporarily (prior to signing) the redeemScript of the input P2SH transaction.
 
 	//Convert redeemScript hex to raw bytes
-	redeemScript, err := hex.DecodeString(flagRedeemScript)
-	if err != nil {
-		log.Fatal(err)
-	}
-	//Convert private-keys argument into slice of private key bytes with necessary tidying
-	flagPrivateKeys = strings.Replace(flagPrivateKeys, "'", "\"", -1) //Replace single quotes with double since csv package only recognizes double quotes
-	privateKeyStrings, err := csv.NewReader(strings.NewReader(flagPrivateKeys)).Read()
+	redeemScript, err := hex.DecodeString(redeemScriptHex)
 	if err != nil {
-		log.Fatal(err)
+		return "", &RedeemScriptError{redeemScriptHex, err}
 	}
-	privateKeys := make([][]byte, len(privateKeyStrings))
-	for i, privateKeyString := range privateKeyStrings {
-		privateKeyString = strings.TrimSpace(privateKeyString) //Trim whitespace
-		if privateKeyString == "" {
-			log.Fatal("Provided private key cannot be empty.")
+	//Convert private keys into slice of private key bytes
+	privateKeys := make([][]byte, len(privateKeyWIFs))
+	for i, privateKeyWIF := range privateKeyWIFs {
+		privateKeys[i], err = decodePrivateKey(i, privateKeyWIF) //Get private keys as slice of raw bytes
+		if err != nil {
+			return "", err
 		}
-		privateKeys[i] = base58check.Decode(privateKeyString) //Get private keys as slice of raw bytes
+	}
+	if err := checkTxHash(inputTx); err != nil {
+		return "", err
+	}
+	if amount <= 0 {
+		return "", &AmountError{amount}
 	}
 	//Create scriptPubKey with provided destination public key
-	publicKeyHash := base58check.Decode(flagDestination)
+	publicKeyHash, err := decodeAddressHash(destination, p2pkhVersion, p2pkhTestnetVersion)
+	if err != nil {
+		return "", err
+	}
 	scriptPubKey, err := btc.NewP2PKHScriptPubKey(publicKeyHash)
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
 	//Create unsigned raw transaction
 	//scriptSig in unsigned transaction is serialized redeemScript of input P2SH transaction.
-	rawTransaction, err := btc.NewRawTransaction(flagInputTx, flagAmount, redeemScript, scriptPubKey)
+	rawTransaction, err := btc.NewRawTransaction(inputTx, amount, redeemScript, scriptPubKey)
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
 	//After completing the raw transaction, we append
 	//SIGHASH_ALL in little-endian format to the end of the raw transaction.
 	hashCodeType, err := hex.DecodeString("01000000")
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
 	var rawTransactionBuffer bytes.Buffer
 	rawTransactionBuffer.Write(rawTransaction)
 	rawTransactionBuffer.Write(hashCodeType)
 	rawTransactionWithHashCodeType := rawTransactionBuffer.Bytes()
 	//Sign transaction
-	finalTransaction, err := signMultisigTransaction(rawTransactionWithHashCodeType, privateKeys, scriptPubKey, redeemScript, flagInputTx, flagAmount)
+	finalTransaction, err := signMultisigTransaction(rawTransactionWithHashCodeType, privateKeys, scriptPubKey, redeemScript, inputTx, amount)
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
 	finalTransactionHex := hex.EncodeToString(finalTransaction)
 
-	return finalTransactionHex
+	return finalTransactionHex, nil
 }
 
 // signMultisigTransaction signs a raw P2PKH transaction, given slice of private keys and the scriptPubKey, inputTx,
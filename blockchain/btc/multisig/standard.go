@@ -0,0 +1,87 @@
+package multisig
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ScriptType is the kind of output a multisig script is paid to.
+type ScriptType string
+
+const (
+	P2SH      ScriptType = "p2sh"
+	P2WSH     ScriptType = "p2wsh"
+	P2SHP2WSH ScriptType = "p2sh-p2wsh"
+)
+
+// Consensus and policy limits relevant to multisig scripts, see Bitcoin Core script/script.h and policy/policy.h.
+const (
+	maxScriptElementSize       = 520  //consensus: max size of a pushed element, bounds the P2SH redeem script
+	maxP2SHMultisigKeys        = 15   //policy: max sigops in a standard P2SH redeem script
+	maxStandardScriptSigSize   = 1650 //policy
+	maxStandardP2WSHScriptSize = 3600 //policy
+	maxStandardP2WSHStackItems = 100  //policy
+	maxSignatureSize           = 73   //DER signature plus sighash byte
+)
+
+// ParseScriptType parses a script type name, the empty string defaults to P2SH.
+func ParseScriptType(name string) (ScriptType, error) {
+	switch t := ScriptType(strings.ToLower(name)); t {
+	case "":
+		return P2SH, nil
+	case P2SH, P2WSH, P2SHP2WSH:
+		return t, nil
+	}
+	return "", fmt.Errorf("unknown script type %q, expected one of %v, %v, %v", name, P2SH, P2WSH, P2SHP2WSH)
+}
+
+// IsSegWit reports whether spends of the script type keep the multisig script in the witness.
+func (t ScriptType) IsSegWit() bool {
+	return t == P2WSH || t == P2SHP2WSH
+}
+
+// maxKeys returns the maximum N for the script type.
+func (t ScriptType) maxKeys() int {
+	if t.IsSegWit() {
+		return 20
+	}
+	return maxP2SHMultisigKeys
+}
+
+// StandardnessWarnings returns the reasons why spending an m-of-n multisig script of the given
+// type would be non-standard, or nil if it is standard. N is bounded by GenerateAddress already.
+func StandardnessWarnings(scriptType ScriptType, m int, script []byte) []string {
+	var warnings []string
+
+	switch scriptType {
+	case P2SH:
+		//scriptSig: OP_0 <sig>... <redeemScript>
+		scriptSigSize := 1 + m*(1+maxSignatureSize) + pushDataSize(len(script)) + len(script)
+		if scriptSigSize > maxStandardScriptSigSize {
+			warnings = append(warnings, fmt.Sprintf("scriptSig of up to %d bytes exceeds the standard limit of %d bytes", scriptSigSize, maxStandardScriptSigSize))
+		}
+	case P2WSH, P2SHP2WSH:
+		if len(script) > maxStandardP2WSHScriptSize {
+			warnings = append(warnings, fmt.Sprintf("witness script of %d bytes exceeds the standard limit of %d bytes", len(script), maxStandardP2WSHScriptSize))
+		}
+		//witness stack: <empty> <sig>... (the witness script is not counted)
+		if m+1 > maxStandardP2WSHStackItems {
+			warnings = append(warnings, fmt.Sprintf("%d witness stack items exceed the standard limit of %d", m+1, maxStandardP2WSHStackItems))
+		}
+	}
+
+	return warnings
+}
+
+// pushDataSize returns the number of bytes needed to push size bytes of data.
+func pushDataSize(size int) int {
+	switch {
+	case size < 76:
+		return 1
+	case size <= 0xff:
+		return 2
+	case size <= 0xffff:
+		return 3
+	}
+	return 5
+}
@@ -0,0 +1,236 @@
+package btc
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/bech32"
+)
+
+// bech32mConst is the checksum constant defined in BIP350 for witness version 1+ addresses.
+const bech32mConst = 0x2bc830a3
+
+// AddressTaproot is a pay-to-taproot (witness v1) address.
+// btcutil only knows about witness v0, so taproot outputs are encoded here with bech32m.
+type AddressTaproot struct {
+	hrp            string
+	witnessProgram [32]byte
+}
+
+// NewAddressTaproot returns a new AddressTaproot for the 32 byte x-only output key.
+func NewAddressTaproot(outputKey []byte, net *chaincfg.Params) (*AddressTaproot, error) {
+	if len(outputKey) != 32 {
+		return nil, fmt.Errorf("taproot output key must be 32 bytes, got %d", len(outputKey))
+	}
+	addr := &AddressTaproot{hrp: strings.ToLower(net.Bech32HRPSegwit)}
+	copy(addr.witnessProgram[:], outputKey)
+	return addr, nil
+}
+
+// EncodeAddress returns the bech32m string encoding of the address.
+func (a *AddressTaproot) EncodeAddress() string {
+	str, err := EncodeSegWitAddress(a.hrp, 1, a.witnessProgram[:])
+	if err != nil {
+		return ""
+	}
+	return str
+}
+
+// ScriptAddress returns the witness program (the tweaked x-only output key).
+func (a *AddressTaproot) ScriptAddress() []byte {
+	return a.witnessProgram[:]
+}
+
+// IsForNet returns whether or not the address is associated with the passed network.
+func (a *AddressTaproot) IsForNet(net *chaincfg.Params) bool {
+	return a.hrp == net.Bech32HRPSegwit
+}
+
+// String returns a human-readable string for the address.
+func (a *AddressTaproot) String() string {
+	return a.EncodeAddress()
+}
+
+// DecodeAddress decodes addr like btcutil.DecodeAddress, additionally accepting witness v1 (taproot) addresses.
+func DecodeAddress(addr string, net *chaincfg.Params) (btcutil.Address, error) {
+	oneIndex := strings.LastIndexByte(addr, '1')
+	if oneIndex > 1 && strings.EqualFold(addr[:oneIndex], net.Bech32HRPSegwit) {
+		version, program, err := DecodeSegWitAddress(addr)
+		if err == nil && version == 1 {
+			return NewAddressTaproot(program, net)
+		}
+	}
+	return btcutil.DecodeAddress(addr, net)
+}
+
+// PayToAddrScript creates the scriptPubKey paying to addr, including taproot addresses.
+func PayToAddrScript(addr btcutil.Address) ([]byte, error) {
+	if taproot, ok := addr.(*AddressTaproot); ok {
+		return NewP2TRScriptPubKey(taproot.ScriptAddress())
+	}
+	return txscript.PayToAddrScript(addr)
+}
+
+// NewP2TRScriptPubKey creates a scriptPubKey for a P2TR output given the 32 byte x-only output key
+func NewP2TRScriptPubKey(outputKey []byte) ([]byte, error) {
+	if len(outputKey) != 32 {
+		return nil, errors.New("outputKey must be 32 bytes.")
+	}
+	//P2TR scriptPubKey format:
+	//<OP_1> <outputKey>
+	var scriptPubKey bytes.Buffer
+	scriptPubKey.WriteByte(byte(OP_1))
+	scriptPubKey.WriteByte(byte(len(outputKey))) //PUSH
+	scriptPubKey.Write(outputKey)
+	return scriptPubKey.Bytes(), nil
+}
+
+// TaggedHash computes the BIP340 tagged hash sha256(sha256(tag) || sha256(tag) || msg).
+func TaggedHash(tag string, msg ...[]byte) []byte {
+	tagHash := sha256.Sum256([]byte(tag))
+	h := sha256.New()
+	h.Write(tagHash[:])
+	h.Write(tagHash[:])
+	for _, m := range msg {
+		h.Write(m)
+	}
+	return h.Sum(nil)
+}
+
+// TaprootOutputKey tweaks the 32 byte x-only internal key with the optional script tree merkle root
+// as described in BIP341 and returns the 32 byte x-only output key.
+func TaprootOutputKey(internalKey []byte, merkleRoot []byte) ([]byte, error) {
+	if len(internalKey) != 32 {
+		return nil, fmt.Errorf("taproot internal key must be 32 bytes, got %d", len(internalKey))
+	}
+	curve := btcec.S256()
+	//lift_x: the internal key is always the point with even Y
+	point, err := btcec.ParsePubKey(append([]byte{0x02}, internalKey...), curve)
+	if err != nil {
+		return nil, fmt.Errorf("invalid taproot internal key %x: %w", internalKey, err)
+	}
+	tweak := new(big.Int).SetBytes(TaggedHash("TapTweak", internalKey, merkleRoot))
+	if tweak.Cmp(curve.Params().N) >= 0 {
+		return nil, errors.New("taproot tweak exceeds curve order")
+	}
+	tx, ty := curve.ScalarBaseMult(tweak.Bytes())
+	qx, qy := curve.Add(point.X, point.Y, tx, ty)
+	if qx.Sign() == 0 && qy.Sign() == 0 {
+		return nil, errors.New("taproot output key is the point at infinity")
+	}
+	outputKey := make([]byte, 32)
+	qxBytes := qx.Bytes()
+	copy(outputKey[32-len(qxBytes):], qxBytes)
+	return outputKey, nil
+}
+
+// EncodeSegWitAddress encodes a witness program as a segwit address, using bech32 for
+// version 0 and bech32m (BIP350) for version 1 and above.
+func EncodeSegWitAddress(hrp string, version byte, program []byte) (string, error) {
+	converted, err := bech32.ConvertBits(program, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	data := append([]byte{version}, converted...)
+	if version == 0 {
+		return bech32.Encode(hrp, data)
+	}
+	checksum := bech32mChecksum(hrp, data)
+	var sb strings.Builder
+	sb.WriteString(hrp)
+	sb.WriteByte('1')
+	for _, b := range append(data, checksum...) {
+		sb.WriteByte(bech32Charset[b])
+	}
+	return sb.String(), nil
+}
+
+// DecodeSegWitAddress decodes a bech32 (version 0) or bech32m (version 1+) segwit address
+// and returns the witness version and program.
+func DecodeSegWitAddress(addr string) (byte, []byte, error) {
+	if strings.ToLower(addr) != addr && strings.ToUpper(addr) != addr {
+		return 0, nil, errors.New("mixed case segwit address")
+	}
+	addr = strings.ToLower(addr)
+	oneIndex := strings.LastIndexByte(addr, '1')
+	if oneIndex < 1 || oneIndex+7 > len(addr) {
+		return 0, nil, errors.New("invalid segwit address separator position")
+	}
+	hrp := addr[:oneIndex]
+	data := make([]byte, 0, len(addr)-oneIndex-1)
+	for _, c := range addr[oneIndex+1:] {
+		pos := strings.IndexRune(bech32Charset, c)
+		if pos < 0 {
+			return 0, nil, fmt.Errorf("invalid character %q in segwit address", c)
+		}
+		data = append(data, byte(pos))
+	}
+	if len(data) < 7 {
+		return 0, nil, errors.New("segwit address too short")
+	}
+	version := data[0]
+	polymod := bech32Polymod(bech32HrpExpand(hrp), data)
+	switch {
+	case version == 0 && polymod != 1:
+		return 0, nil, errors.New("invalid bech32 checksum")
+	case version != 0 && polymod != bech32mConst:
+		return 0, nil, errors.New("invalid bech32m checksum")
+	}
+	program, err := bech32.ConvertBits(data[1:len(data)-6], 5, 8, false)
+	if err != nil {
+		return 0, nil, err
+	}
+	if version > 16 || len(program) < 2 || len(program) > 40 {
+		return 0, nil, fmt.Errorf("invalid witness version %d or program length %d", version, len(program))
+	}
+	return version, program, nil
+}
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+var bech32Gen = [5]int{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+
+func bech32Polymod(values ...[]byte) int {
+	chk := 1
+	for _, vs := range values {
+		for _, v := range vs {
+			b := chk >> 25
+			chk = (chk&0x1ffffff)<<5 ^ int(v)
+			for i := 0; i < 5; i++ {
+				if (b>>uint(i))&1 == 1 {
+					chk ^= bech32Gen[i]
+				}
+			}
+		}
+	}
+	return chk
+}
+
+func bech32HrpExpand(hrp string) []byte {
+	expanded := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]>>5)
+	}
+	expanded = append(expanded, 0)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]&31)
+	}
+	return expanded
+}
+
+func bech32mChecksum(hrp string, data []byte) []byte {
+	polymod := bech32Polymod(bech32HrpExpand(hrp), data, make([]byte, 6)) ^ bech32mConst
+	checksum := make([]byte, 6)
+	for i := 0; i < 6; i++ {
+		checksum[i] = byte((polymod >> uint(5*(5-i))) & 31)
+	}
+	return checksum
+}
@@ -8,9 +8,9 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
-	"log"
 	"math"
 	mathrand "math/rand"
+	"sort"
 	"time"
 
 	secp256k1 "github.com/toxeus/go-secp256k1"
@@ -93,13 +93,13 @@ func NewRandomBytes(size int) ([]byte, error) {
 
 // NewPrivateKey generates a pseudorandom private key compatible with ECDSA.
 // Cryptographically secure to the limits of crypto/rand package.
-func NewPrivateKey() []byte {
+func NewPrivateKey() ([]byte, error) {
 	bytes, err := NewRandomBytes(32)
 	if err != nil {
-		log.Fatal(err)
-		//Throw an error instead of just returning one quietly since cryptographically secure pseudorandomness is crucial to private key.
+		//Never fall back to weaker randomness since cryptographically secure pseudorandomness is crucial to private key.
+		return nil, fmt.Errorf("Failed to read random bytes for private key: %w", err)
 	}
-	return bytes
+	return bytes, nil
 }
 
 // NewPublicKey generates the public key from the private key.
@@ -107,6 +107,9 @@ func NewPrivateKey() []byte {
 // secp256k1 curve as this is fairly specific to Bitcoin.
 // Using toxeus/go-secp256k1 which wraps the official bitcoin/c-secp256k1 with cgo.
 func NewPublicKey(privateKey []byte) ([]byte, error) {
+	if len(privateKey) < 32 {
+		return nil, fmt.Errorf("Private key should be 32 bytes long. Provided private key is %d bytes long.", len(privateKey))
+	}
 	var privateKey32 [32]byte
 	for i := 0; i < 32; i++ {
 		privateKey32[i] = privateKey[i]
@@ -173,15 +176,18 @@ func NewMOfNRedeemScript(m int, n int, publicKeys [][]byte) ([]byte, error) {
 }
 
 // CheckPublicKeyIsValid runs a couple of checks to make sure a public key looks valid.
+// Both uncompressed (65 bytes, 0x04 prefix) and compressed (33 bytes, 0x02/0x03 prefix) keys are accepted.
 // Returns an error with a helpful message or nil if key is valid.
 func CheckPublicKeyIsValid(publicKey []byte) error {
 	errMessage := ""
 	if publicKey == nil {
 		errMessage += "Public key cannot be empty.\n"
-	} else if len(publicKey) != 65 {
-		errMessage += fmt.Sprintf("Public key should be 65 bytes long. Provided public key is %d bytes long.", len(publicKey))
-	} else if publicKey[0] != byte(4) {
+	} else if len(publicKey) != 65 && len(publicKey) != 33 {
+		errMessage += fmt.Sprintf("Public key should be 65 bytes long (33 if compressed). Provided public key is %d bytes long.", len(publicKey))
+	} else if len(publicKey) == 65 && publicKey[0] != byte(4) {
 		errMessage += fmt.Sprintf("Public key first byte should be 0x04. Provided public key first byte is 0x%v.", hex.EncodeToString([]byte{publicKey[0]}))
+	} else if len(publicKey) == 33 && publicKey[0] != byte(2) && publicKey[0] != byte(3) {
+		errMessage += fmt.Sprintf("Compressed public key first byte should be 0x02 or 0x03. Provided public key first byte is 0x%v.", hex.EncodeToString([]byte{publicKey[0]}))
 	}
 	if errMessage != "" {
 		errMessage += "Invalid public key:\n"
@@ -191,6 +197,76 @@ func CheckPublicKeyIsValid(publicKey []byte) error {
 	return nil
 }
 
+// IsCompressedPublicKey reports whether publicKey is a 33 byte compressed public key.
+func IsCompressedPublicKey(publicKey []byte) bool {
+	return len(publicKey) == 33 && (publicKey[0] == byte(2) || publicKey[0] == byte(3))
+}
+
+// SortPublicKeys sorts public keys lexicographically by their serialization, as per BIP67.
+func SortPublicKeys(publicKeys [][]byte) {
+	sort.Slice(publicKeys, func(i, j int) bool {
+		return bytes.Compare(publicKeys[i], publicKeys[j]) < 0
+	})
+}
+
+// MaxMultisigKeys is the maximum number of public keys OP_CHECKMULTISIG accepts.
+const MaxMultisigKeys = 20
+
+// NewMultisigScript creates a M-of-N multisig script given m, n and n public keys.
+// Unlike NewMOfNRedeemScript, N may go up to 20 so the script can be used as a P2WSH witness script;
+// callers are responsible for the limits of the script type it is used in.
+func NewMultisigScript(m int, n int, publicKeys [][]byte) ([]byte, error) {
+	if n < 1 || n > MaxMultisigKeys {
+		return nil, fmt.Errorf("N must be between 1 and %d (inclusive).", MaxMultisigKeys)
+	}
+	if m < 1 || m > n {
+		return nil, errors.New("M must be between 1 and N (inclusive).")
+	}
+	if len(publicKeys) != n {
+		return nil, fmt.Errorf("Need exactly %d public keys to create %d-of-%d multisig script. %d keys provided.", n, m, n, len(publicKeys))
+	}
+	//Multisig script format:
+	//<m> <A pubkey> <B pubkey> <C pubkey>... <n> OP_CHECKMULTISIG
+	var script bytes.Buffer
+	writeSmallInt(&script, m)
+	for _, publicKey := range publicKeys {
+		err := CheckPublicKeyIsValid(publicKey)
+		if err != nil {
+			return nil, err
+		}
+		script.WriteByte(byte(len(publicKey))) //PUSH
+		script.Write(publicKey)                //<pubkey>
+	}
+	writeSmallInt(&script, n)
+	script.WriteByte(byte(OP_CHECKMULTISIG))
+	return script.Bytes(), nil
+}
+
+// writeSmallInt pushes a number between 1 and 20 to the script, as OP_1..OP_16 or a one byte push above 16.
+func writeSmallInt(script *bytes.Buffer, i int) {
+	if i <= 16 {
+		script.WriteByte(byte(OP_1 + (i - 1)))
+		return
+	}
+	script.WriteByte(1) //PUSH one byte
+	script.WriteByte(byte(i))
+}
+
+// NewP2WSHScriptPubKey creates a scriptPubKey for a P2WSH output given the witness script
+func NewP2WSHScriptPubKey(witnessScript []byte) ([]byte, error) {
+	if witnessScript == nil {
+		return nil, errors.New("witnessScript can't be empty.")
+	}
+	//P2WSH scriptPubKey format:
+	//<OP_0> <SHA256(witnessScript)>
+	witnessProgram := sha256.Sum256(witnessScript)
+	var scriptPubKey bytes.Buffer
+	scriptPubKey.WriteByte(byte(OP_0))
+	scriptPubKey.WriteByte(byte(len(witnessProgram))) //PUSH
+	scriptPubKey.Write(witnessProgram[:])
+	return scriptPubKey.Bytes(), nil
+}
+
 // NewP2SHScriptPubKey creates a scriptPubKey for a P2SH transaction given the redeemScript hash
 func NewP2SHScriptPubKey(redeemScriptHash []byte) ([]byte, error) {
 	if redeemScriptHash == nil {
@@ -299,6 +375,9 @@ func NewRawTransaction(inputTxHash string, satoshis int, scriptSig []byte, scrip
 
 // NewSignature generates a ECDSA signature given the raw transaction and privateKey to sign with
 func NewSignature(rawTransaction []byte, privateKey []byte) ([]byte, error) {
+	if len(privateKey) < 32 {
+		return nil, fmt.Errorf("Private key should be 32 bytes long. Provided private key is %d bytes long.", len(privateKey))
+	}
 	//Start secp256k1
 	secp256k1.Start()
 	var privateKey32 [32]byte
@@ -0,0 +1,140 @@
+package btc
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNewKeys(t *testing.T) {
+	privateKey, err := NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(privateKey) != 32 || bytes.Equal(privateKey, other) {
+		t.Errorf("NewPrivateKey() = %x then %x", privateKey, other)
+	}
+	publicKey, err := NewPublicKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckPublicKeyIsValid(publicKey); err != nil || IsCompressedPublicKey(publicKey) {
+		t.Errorf("NewPublicKey() = %x, %v", publicKey, err)
+	}
+
+	// Short private keys are errors instead of panics
+	for _, short := range [][]byte{nil, {}, privateKey[:31]} {
+		if _, err := NewPublicKey(short); err == nil {
+			t.Errorf("NewPublicKey(%x) did not fail", short)
+		}
+		if _, err := NewSignature([]byte("raw transaction"), short); err == nil {
+			t.Errorf("NewSignature(%x) did not fail", short)
+		}
+	}
+}
+
+func TestCheckPublicKeyIsValid(t *testing.T) {
+	key := func(prefix byte, size int) []byte {
+		publicKey := bytes.Repeat([]byte{0x41}, size)
+		publicKey[0] = prefix
+		return publicKey
+	}
+	tests := []struct {
+		publicKey  []byte
+		valid      bool
+		compressed bool
+	}{
+		{key(0x04, 65), true, false},
+		{key(0x02, 33), true, true},
+		{key(0x03, 33), true, true},
+		{key(0x04, 33), false, false},
+		{key(0x05, 33), false, false},
+		{key(0x02, 65), false, false},
+		{key(0x02, 32), false, false},
+		{key(0x04, 64), false, false},
+		{nil, false, false},
+	}
+	for _, test := range tests {
+		if err := CheckPublicKeyIsValid(test.publicKey); (err == nil) != test.valid {
+			t.Errorf("CheckPublicKeyIsValid(%x) = %v", test.publicKey, err)
+		}
+		if got := IsCompressedPublicKey(test.publicKey); got != test.compressed {
+			t.Errorf("IsCompressedPublicKey(%x) = %v", test.publicKey, got)
+		}
+	}
+}
+
+func TestWriteSmallInt(t *testing.T) {
+	tests := []struct {
+		i    int
+		want []byte
+	}{
+		{1, []byte{OP_1}},
+		{2, []byte{OP_1 + 1}},
+		{16, []byte{OP_1 + 15}},
+		{17, []byte{0x01, 0x11}},
+		{20, []byte{0x01, 0x14}},
+	}
+	for _, test := range tests {
+		var script bytes.Buffer
+		writeSmallInt(&script, test.i)
+		if !bytes.Equal(script.Bytes(), test.want) {
+			t.Errorf("writeSmallInt(%d) = %x, want %x", test.i, script.Bytes(), test.want)
+		}
+	}
+}
+
+func TestNewMultisigScript(t *testing.T) {
+	publicKeys := make([][]byte, MaxMultisigKeys+1)
+	for i := range publicKeys {
+		publicKeys[i] = bytes.Repeat([]byte{byte(i)}, 33)
+		publicKeys[i][0] = 0x02
+	}
+
+	// <m> <pubkey>... <n> OP_CHECKMULTISIG with 34 bytes per compressed key
+	for _, mn := range [][2]int{{1, 1}, {2, 3}, {16, 16}, {1, 17}, {17, 20}, {20, 20}} {
+		m, n := mn[0], mn[1]
+		script, err := NewMultisigScript(m, n, publicKeys[:n])
+		if err != nil {
+			t.Errorf("NewMultisigScript(%d, %d): %v", m, n, err)
+			continue
+		}
+		var want bytes.Buffer
+		writeSmallInt(&want, m)
+		for _, publicKey := range publicKeys[:n] {
+			want.WriteByte(33)
+			want.Write(publicKey)
+		}
+		writeSmallInt(&want, n)
+		want.WriteByte(OP_CHECKMULTISIG)
+		if !bytes.Equal(script, want.Bytes()) {
+			t.Errorf("NewMultisigScript(%d, %d) = %x, want %x", m, n, script, want.Bytes())
+		}
+	}
+
+	uncompressed := bytes.Repeat([]byte{0x41}, 65)
+	uncompressed[0] = 0x04
+	if script, err := NewMultisigScript(1, 2, [][]byte{publicKeys[0], uncompressed}); err != nil || len(script) != 3+34+66 {
+		t.Errorf("NewMultisigScript() of mixed keys = %x, %v", script, err)
+	}
+
+	bad := []struct {
+		m, n       int
+		publicKeys [][]byte
+	}{
+		{1, 0, nil},
+		{1, 21, publicKeys},
+		{0, 2, publicKeys[:2]},
+		{3, 2, publicKeys[:2]},
+		{2, 3, publicKeys[:2]},
+		{1, 1, [][]byte{publicKeys[0][:32]}},
+	}
+	for _, test := range bad {
+		if _, err := NewMultisigScript(test.m, test.n, test.publicKeys); err == nil {
+			t.Errorf("NewMultisigScript(%d, %d) of %d keys did not fail", test.m, test.n, len(test.publicKeys))
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package lighting_test
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jualy007/GoTF/blockchain/lighting"
+	"github.com/jualy007/GoTF/blockchain/lighting/lndtest"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newNode starts a fake node and an adapter connected to it, both closed by the test cleanup.
+func newNode(t *testing.T, name string) (*lndtest.Server, *lighting.Adapter) {
+	t.Helper()
+	s, err := lndtest.NewServer(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adapter, err := s.Adapter()
+	if err != nil {
+		s.Close()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		adapter.Close()
+		s.Close()
+	})
+	return s, adapter
+}
+
+func TestGetInfo(t *testing.T) {
+	s, adapter := newNode(t, "alice")
+	s.SetBlockHeight(700)
+
+	info, err := adapter.GetInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Alias != "alice" || info.IdentityPubkey != s.PubKey() || info.BlockHeight != 700 {
+		t.Errorf("GetInfo = %v", info)
+	}
+}
+
+func TestSendPaymentSync(t *testing.T) {
+	alice, aliceAdapter := newNode(t, "alice")
+	bob, bobAdapter := newNode(t, "bob")
+	alice.AddChannel(bob, 100000, 0)
+	ctx := context.Background()
+
+	invoice, err := bobAdapter.AddInvoice(ctx, &lnrpc.Invoice{Value: 1000, Memo: "coffee"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payReq, err := aliceAdapter.DecodePayReq(ctx, invoice.PaymentRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payReq.Destination != bob.PubKey() || payReq.NumSatoshis != 1000 || payReq.Description != "coffee" {
+		t.Errorf("DecodePayReq = %v", payReq)
+	}
+
+	resp, err := aliceAdapter.SendPaymentSync(ctx, &lnrpc.SendRequest{PaymentRequest: invoice.PaymentRequest})
+	if err != nil {
+		t.Fatal(err)
+	}
+	settled := bob.Invoice(hex.EncodeToString(invoice.RHash))
+	if settled.State != lnrpc.Invoice_SETTLED || hex.EncodeToString(resp.PaymentPreimage) != hex.EncodeToString(settled.RPreimage) {
+		t.Errorf("invoice %v after payment %v", settled, resp)
+	}
+
+	// The invoice is settled, paying it again fails after being sent.
+	_, err = aliceAdapter.SendPaymentSync(ctx, &lnrpc.SendRequest{PaymentRequest: invoice.PaymentRequest})
+	var paymentErr *lighting.PaymentError
+	if !errors.As(err, &paymentErr) {
+		t.Errorf("second payment: %v, want a *PaymentError", err)
+	}
+}
+
+func TestNoRoute(t *testing.T) {
+	alice, aliceAdapter := newNode(t, "alice")
+	bob, bobAdapter := newNode(t, "bob")
+	alice.AddChannel(bob, 100000, 0)
+	ctx := context.Background()
+	invoice, err := bobAdapter.AddInvoice(ctx, &lnrpc.Invoice{Value: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alice.Inject("QueryRoutes", lndtest.NoRoute)
+	if _, err := aliceAdapter.QueryRoute(ctx, invoice.PaymentRequest); err == nil {
+		t.Error("QueryRoute found a route")
+	}
+	alice.Clear("QueryRoutes")
+	routes, err := aliceAdapter.QueryRoute(ctx, invoice.PaymentRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes.Routes) != 1 || len(routes.Routes[0].Hops) != 1 {
+		t.Errorf("QueryRoute = %v", routes)
+	}
+}
+
+func TestMacaroon(t *testing.T) {
+	s, err := lndtest.NewServer("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	info := s.LndInfo()
+	info.Macaroon = hex.EncodeToString(make([]byte, 32))
+	adapter, err := lighting.NewAdapter(info, lndtest.DialOption(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer adapter.Close()
+	ctx := context.Background()
+
+	if _, err := adapter.GetInfo(ctx); err == nil {
+		t.Error("GetInfo succeeded with a wrong macaroon")
+	}
+	err = adapter.SubscribeInvoices(ctx, 0, 0, func(*lnrpc.Invoice) error { return nil })
+	if status.Code(err) == codes.Unimplemented {
+		t.Errorf("SubscribeInvoices skipped the macaroon check: %v", err)
+	}
+	if s.Calls("GetInfo") != 1 || s.Calls("SubscribeInvoices") != 1 {
+		t.Errorf("%v GetInfo and %v SubscribeInvoices calls", s.Calls("GetInfo"), s.Calls("SubscribeInvoices"))
+	}
+}
+
+func TestFaults(t *testing.T) {
+	s, adapter := newNode(t, "alice")
+	ctx := context.Background()
+
+	fault := lndtest.Unavailable
+	fault.Times = 1
+	s.Inject("GetInfo", fault)
+	if _, err := adapter.GetInfo(ctx); status.Code(err) != codes.Unavailable {
+		t.Errorf("GetInfo with an Unavailable fault: %v", err)
+	}
+	if _, err := adapter.GetInfo(ctx); err != nil {
+		t.Errorf("GetInfo after the fault: %v", err)
+	}
+
+	s.Inject("GetInfo", lndtest.Timeout)
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := adapter.GetInfo(timeout); status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("GetInfo with a Timeout fault: %v", err)
+	}
+	if n := s.Calls("GetInfo"); n != 3 {
+		t.Errorf("%v GetInfo calls, want 3", n)
+	}
+}
+
+func TestStreamFaults(t *testing.T) {
+	s, adapter := newNode(t, "alice")
+	ctx := context.Background()
+	subscribe := func() error {
+		return adapter.SubscribeInvoices(ctx, 0, 0, func(*lnrpc.Invoice) error { return nil })
+	}
+
+	s.Inject("SubscribeInvoices", lndtest.Unavailable)
+	if err := subscribe(); status.Code(err) != codes.Unavailable {
+		t.Errorf("SubscribeInvoices with an Unavailable fault: %v", err)
+	}
+	s.Clear("SubscribeInvoices")
+	if err := subscribe(); status.Code(err) != codes.Unimplemented {
+		t.Errorf("SubscribeInvoices without fault: %v", err)
+	}
+	if n := s.Calls("SubscribeInvoices"); n != 2 {
+		t.Errorf("%v SubscribeInvoices calls, want 2", n)
+	}
+}
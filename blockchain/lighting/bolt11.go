@@ -0,0 +1,301 @@
+package lighting
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/zpay32"
+)
+
+// Invoice is a BOLT11 payment request. Hashes, secrets and public keys are hex encoded,
+// an AmountMsat of 0 is an invoice for any amount.
+type Invoice struct {
+	Net             *chaincfg.Params `json:"-"`
+	Destination     string           `json:"destination"`
+	PaymentHash     string           `json:"payment_hash"`
+	PaymentSecret   string           `json:"payment_secret,omitempty"`
+	AmountMsat      int64            `json:"amount_msat"`
+	Description     string           `json:"description,omitempty"`
+	DescriptionHash string           `json:"description_hash,omitempty"`
+	Timestamp       time.Time        `json:"timestamp"`
+	Expiry          int64            `json:"expiry"`
+	CltvExpiry      uint64           `json:"cltv_expiry"`
+	FallbackAddress string           `json:"fallback_address,omitempty"`
+	RouteHints      [][]HopHint      `json:"route_hints,omitempty"`
+	Features        []uint16         `json:"features,omitempty"`
+}
+
+// HopHint is a hop of a route hint, a private channel to or towards the destination.
+type HopHint struct {
+	NodeId                    string `json:"node_id"`
+	ChanId                    uint64 `json:"chan_id"`
+	FeeBaseMsat               uint32 `json:"fee_base_msat"`
+	FeeProportionalMillionths uint32 `json:"fee_proportional_millionths"`
+	CltvExpiryDelta           uint16 `json:"cltv_expiry_delta"`
+}
+
+// InvoiceError is returned for a payment request that can not be decoded or encoded.
+type InvoiceError struct {
+	Err error
+}
+
+func (e *InvoiceError) Error() string {
+	return fmt.Sprintf("invalid invoice: %v", e.Err)
+}
+
+func (e *InvoiceError) Unwrap() error {
+	return e.Err
+}
+
+// invoiceNets are the networks of payment requests by the prefix following "ln",
+// regtest first as its prefix extends the mainnet one.
+var invoiceNets = []struct {
+	prefix string
+	net    *chaincfg.Params
+}{
+	{"bcrt", &chaincfg.RegressionNetParams},
+	{"bc", &chaincfg.MainNetParams},
+	{"tb", &chaincfg.TestNet3Params},
+	{"sb", &chaincfg.SimNetParams},
+}
+
+// InvoiceNet returns the network of a payment request from its human readable part.
+func InvoiceNet(payReq string) (*chaincfg.Params, error) {
+	payReq = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(payReq)), "lightning:")
+	if strings.HasPrefix(payReq, "ln") {
+		for _, n := range invoiceNets {
+			if strings.HasPrefix(payReq[2:], n.prefix) {
+				return n.net, nil
+			}
+		}
+	}
+	return nil, &InvoiceError{errors.New("unknown network prefix")}
+}
+
+// DecodeInvoice decodes and verifies the signature of a payment request. If net is nil
+// it is taken from the payment request, otherwise the payment request must be for net.
+func DecodeInvoice(payReq string, net *chaincfg.Params) (*Invoice, error) {
+	payReq = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(payReq)), "lightning:")
+	invoiceNet, err := InvoiceNet(payReq)
+	if err != nil {
+		return nil, err
+	}
+	if net == nil {
+		net = invoiceNet
+	} else if net.Bech32HRPSegwit != invoiceNet.Bech32HRPSegwit {
+		return nil, &InvoiceError{fmt.Errorf("invoice for network %v, want %v", invoiceNet.Name, net.Name)}
+	}
+	decoded, err := zpay32.Decode(payReq, net)
+	if err != nil {
+		return nil, &InvoiceError{err}
+	}
+
+	invoice := &Invoice{
+		Net:         net,
+		Destination: hex.EncodeToString(decoded.Destination.SerializeCompressed()),
+		PaymentHash: hex.EncodeToString(decoded.PaymentHash[:]),
+		Timestamp:   decoded.Timestamp,
+		Expiry:      int64(decoded.Expiry().Seconds()),
+		CltvExpiry:  decoded.MinFinalCLTVExpiry(),
+	}
+	if decoded.PaymentAddr != nil {
+		invoice.PaymentSecret = hex.EncodeToString(decoded.PaymentAddr[:])
+	}
+	if decoded.MilliSat != nil {
+		invoice.AmountMsat = int64(*decoded.MilliSat)
+	}
+	if decoded.Description != nil {
+		invoice.Description = *decoded.Description
+	}
+	if decoded.DescriptionHash != nil {
+		invoice.DescriptionHash = hex.EncodeToString(decoded.DescriptionHash[:])
+	}
+	if decoded.FallbackAddr != nil {
+		invoice.FallbackAddress = decoded.FallbackAddr.EncodeAddress()
+	}
+	for _, route := range decoded.RouteHints {
+		hints := make([]HopHint, 0, len(route))
+		for _, hop := range route {
+			hints = append(hints, HopHint{
+				NodeId:                    hex.EncodeToString(hop.NodeID.SerializeCompressed()),
+				ChanId:                    hop.ChannelID,
+				FeeBaseMsat:               hop.FeeBaseMSat,
+				FeeProportionalMillionths: hop.FeeProportionalMillionths,
+				CltvExpiryDelta:           hop.CLTVExpiryDelta,
+			})
+		}
+		invoice.RouteHints = append(invoice.RouteHints, hints)
+	}
+	if decoded.Features != nil {
+		for bit := range decoded.Features.Features() {
+			invoice.Features = append(invoice.Features, uint16(bit))
+		}
+		sort.Slice(invoice.Features, func(i, j int) bool { return invoice.Features[i] < invoice.Features[j] })
+	}
+	return invoice, nil
+}
+
+// EncodeInvoice encodes the invoice as a payment request signed by key. Destination is ignored,
+// it is the public key of key. A zero Timestamp is the current time, a nil Net regtest.
+func EncodeInvoice(invoice *Invoice, key *btcec.PrivateKey) (string, error) {
+	net := invoice.Net
+	if net == nil {
+		net = &chaincfg.RegressionNetParams
+	}
+	paymentHash, err := decodeHash(invoice.PaymentHash)
+	if err != nil {
+		return "", &InvoiceError{fmt.Errorf("payment hash: %v", err)}
+	}
+
+	var options []func(*zpay32.Invoice)
+	if invoice.AmountMsat > 0 {
+		options = append(options, zpay32.Amount(lnwire.MilliSatoshi(invoice.AmountMsat)))
+	}
+	if invoice.DescriptionHash != "" {
+		descriptionHash, err := decodeHash(invoice.DescriptionHash)
+		if err != nil {
+			return "", &InvoiceError{fmt.Errorf("description hash: %v", err)}
+		}
+		options = append(options, zpay32.DescriptionHash(descriptionHash))
+	} else {
+		options = append(options, zpay32.Description(invoice.Description))
+	}
+	if invoice.PaymentSecret != "" {
+		secret, err := decodeHash(invoice.PaymentSecret)
+		if err != nil {
+			return "", &InvoiceError{fmt.Errorf("payment secret: %v", err)}
+		}
+		options = append(options, zpay32.PaymentAddr(secret))
+	}
+	if invoice.Expiry > 0 {
+		options = append(options, zpay32.Expiry(time.Duration(invoice.Expiry)*time.Second))
+	}
+	if invoice.CltvExpiry > 0 {
+		options = append(options, zpay32.CLTVExpiry(invoice.CltvExpiry))
+	}
+	if invoice.FallbackAddress != "" {
+		address, err := btcutil.DecodeAddress(invoice.FallbackAddress, net)
+		if err != nil {
+			return "", &InvoiceError{fmt.Errorf("fallback address: %v", err)}
+		}
+		options = append(options, zpay32.FallbackAddr(address))
+	}
+	for _, route := range invoice.RouteHints {
+		hops := make([]zpay32.HopHint, 0, len(route))
+		for _, hop := range route {
+			nodeId, err := hex.DecodeString(hop.NodeId)
+			if err != nil {
+				return "", &InvoiceError{fmt.Errorf("route hint node id: %v", err)}
+			}
+			pubKey, err := btcec.ParsePubKey(nodeId, btcec.S256())
+			if err != nil {
+				return "", &InvoiceError{fmt.Errorf("route hint node id: %v", err)}
+			}
+			hops = append(hops, zpay32.HopHint{
+				NodeID:                    pubKey,
+				ChannelID:                 hop.ChanId,
+				FeeBaseMSat:               hop.FeeBaseMsat,
+				FeeProportionalMillionths: hop.FeeProportionalMillionths,
+				CLTVExpiryDelta:           hop.CltvExpiryDelta,
+			})
+		}
+		options = append(options, zpay32.RouteHint(hops))
+	}
+	if len(invoice.Features) > 0 {
+		raw := lnwire.NewRawFeatureVector()
+		for _, bit := range invoice.Features {
+			raw.Set(lnwire.FeatureBit(bit))
+		}
+		options = append(options, zpay32.Features(lnwire.NewFeatureVector(raw, lnwire.Features)))
+	}
+
+	timestamp := invoice.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	encoded, err := zpay32.NewInvoice(net, paymentHash, timestamp, options...)
+	if err != nil {
+		return "", &InvoiceError{err}
+	}
+	payReq, err := encoded.Encode(zpay32.MessageSigner{SignCompact: func(hash []byte) ([]byte, error) {
+		return btcec.SignCompact(btcec.S256(), key, hash, true)
+	}})
+	if err != nil {
+		return "", &InvoiceError{err}
+	}
+	return payReq, nil
+}
+
+func decodeHash(s string) ([32]byte, error) {
+	var hash [32]byte
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return hash, err
+	}
+	if len(b) != len(hash) {
+		return hash, fmt.Errorf("%d bytes, want %d", len(b), len(hash))
+	}
+	copy(hash[:], b)
+	return hash, nil
+}
+
+// ExpiresAt returns the time the invoice expires.
+func (invoice *Invoice) ExpiresAt() time.Time {
+	return invoice.Timestamp.Add(time.Duration(invoice.Expiry) * time.Second)
+}
+
+// Expired reports whether the invoice is expired at now.
+func (invoice *Invoice) Expired(now time.Time) bool {
+	return !now.Before(invoice.ExpiresAt())
+}
+
+// PayReq returns the invoice as lnd decodes payment requests.
+func (invoice *Invoice) PayReq() *lnrpc.PayReq {
+	payReq := &lnrpc.PayReq{
+		Destination:     invoice.Destination,
+		PaymentHash:     invoice.PaymentHash,
+		NumSatoshis:     invoice.AmountMsat / 1000,
+		NumMsat:         invoice.AmountMsat,
+		Timestamp:       invoice.Timestamp.Unix(),
+		Expiry:          invoice.Expiry,
+		Description:     invoice.Description,
+		DescriptionHash: invoice.DescriptionHash,
+		FallbackAddr:    invoice.FallbackAddress,
+		CltvExpiry:      int64(invoice.CltvExpiry),
+		Features:        make(map[uint32]*lnrpc.Feature),
+	}
+	if invoice.PaymentSecret != "" {
+		payReq.PaymentAddr, _ = hex.DecodeString(invoice.PaymentSecret)
+	}
+	for _, route := range invoice.RouteHints {
+		hint := &lnrpc.RouteHint{}
+		for _, hop := range route {
+			hint.HopHints = append(hint.HopHints, &lnrpc.HopHint{
+				NodeId:                    hop.NodeId,
+				ChanId:                    hop.ChanId,
+				FeeBaseMsat:               hop.FeeBaseMsat,
+				FeeProportionalMillionths: hop.FeeProportionalMillionths,
+				CltvExpiryDelta:           uint32(hop.CltvExpiryDelta),
+			})
+		}
+		payReq.RouteHints = append(payReq.RouteHints, hint)
+	}
+	features := lnwire.NewFeatureVector(nil, lnwire.Features)
+	for _, bit := range invoice.Features {
+		payReq.Features[uint32(bit)] = &lnrpc.Feature{
+			Name:       features.Name(lnwire.FeatureBit(bit)),
+			IsRequired: bit%2 == 0,
+			IsKnown:    features.IsKnown(lnwire.FeatureBit(bit)),
+		}
+	}
+	return payReq
+}
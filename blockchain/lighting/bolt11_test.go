@@ -0,0 +1,184 @@
+package lighting
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// The examples of BOLT11, signed by the key of the specification.
+const (
+	donationPayReq = "lnbc1pvjluezpp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypqdpl2pkx2ctnv5sxxmmwwd5kgetjypeh2ursdae8g6twvus8g6rfwvs8qun0dfjkxaq8rkx3yf5tcsyz3d73gafnh3cax9rn449d9p5uxz9ezhhypd0elx87sjle52x86fux2ypatgddc6k63n7erqz25le42c4u4ecky03ylcqca784w"
+	coffeePayReq   = "lnbc2500u1pvjluezpp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypqdq5xysxxatsyp3k7enxv4jsxqzpuaztrnwngzn3kdzw5hydlzf03qdgm2hdq27cqv3agm2awhz5se903vruatfhq77w3ls4evs3ch9zw97j25emudupq63nyw24cg27h2rspfj9srp"
+	routedPayReq   = "lnbc20m1pvjluezpp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypqhp58yjmdan79s6qqdhdzgynm4zwqd5d7xmw5fk98klysy043l2ahrqsfpp3qjmp7lwpagxun9pygexvgpjdc4jdj85fr9yq20q82gphp2nflc7jtzrcazrra7wwgzxqc8u7754cdlpfrmccae92qgzqvzq2ps8pqqqqqqpqqqqq9qqqvpeuqafqxu92d8lr6fvg0r5gv0heeeqgcrqlnm6jhphu9y00rrhy4grqszsvpcgpy9qqqqqqgqqqqq7qqzqj9n4evl6mr5aj9f58zp6fyjzup6ywn3x6sk8akg5v4tgn2q8g4fhx05wf6juaxu9760yp46454gpg5mtzgerlzezqcqvjnhjh8z3g2qqdhhwkj"
+	featuresPayReq = "lnbc25m1pvjluezpp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypqdq5vdhkven9v5sxyetpdeessp5zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zygs9q5sqqqqqqqqqqqqqqqpqsq67gye39hfg3zd8rgc80k32tvy9xk2xunwm5lzexnvpx6fd77en8qaq424dxgt56cag2dpt359k3ssyhetktkpqh24jqnjyw6uqd08sgptq44qu"
+
+	specDestination = "03e7156ae33b0a208d0744199163177e909e80176e55d97a2f221ede0f934dd9ad"
+	specPaymentHash = "0001020304050607080900010203040506070809000102030405060708090102"
+)
+
+var specTimestamp = time.Unix(1496314658, 0)
+
+func TestDecodeInvoiceVectors(t *testing.T) {
+	descriptionHash := sha256.Sum256([]byte("One piece of chocolate cake, one icecream cone, one pickle, one slice of swiss cheese, one slice of salami, one lollypop, one piece of cherry pie, one sausage, one cupcake, and one slice of watermelon"))
+
+	tests := []struct {
+		name   string
+		payReq string
+		want   Invoice
+	}{
+		{"donation", donationPayReq, Invoice{
+			Description: "Please consider supporting this project",
+			Expiry:      3600,
+			CltvExpiry:  9,
+		}},
+		{"coffee", coffeePayReq, Invoice{
+			AmountMsat:  250000000,
+			Description: "1 cup coffee",
+			Expiry:      60,
+			CltvExpiry:  9,
+		}},
+		{"route hints", routedPayReq, Invoice{
+			AmountMsat:      2000000000,
+			DescriptionHash: hex.EncodeToString(descriptionHash[:]),
+			Expiry:          3600,
+			CltvExpiry:      9,
+			FallbackAddress: "1RustyRX2oai4EYYDpQGWvEL62BBGqN9T",
+			RouteHints: [][]HopHint{{
+				{
+					NodeId:                    "029e03a901b85534ff1e92c43c74431f7ce72046060fcf7a95c37e148f78c77255",
+					ChanId:                    0x0102030405060708,
+					FeeBaseMsat:               1,
+					FeeProportionalMillionths: 20,
+					CltvExpiryDelta:           3,
+				},
+				{
+					NodeId:                    "039e03a901b85534ff1e92c43c74431f7ce72046060fcf7a95c37e148f78c77255",
+					ChanId:                    0x030405060708090a,
+					FeeBaseMsat:               2,
+					FeeProportionalMillionths: 30,
+					CltvExpiryDelta:           4,
+				},
+			}},
+		}},
+		{"features", featuresPayReq, Invoice{
+			PaymentSecret: strings.Repeat("11", 32),
+			AmountMsat:    2500000000,
+			Description:   "coffee beans",
+			Expiry:        3600,
+			CltvExpiry:    9,
+			Features:      []uint16{9, 15, 99},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			want := test.want
+			want.Net = &chaincfg.MainNetParams
+			want.Destination = specDestination
+			want.PaymentHash = specPaymentHash
+			want.Timestamp = specTimestamp
+
+			got, err := DecodeInvoice(test.payReq, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Timestamp.Equal(want.Timestamp) {
+				t.Errorf("Timestamp = %v, want %v", got.Timestamp, want.Timestamp)
+			}
+			got.Timestamp = want.Timestamp
+			if !reflect.DeepEqual(*got, want) {
+				t.Errorf("DecodeInvoice = %+v, want %+v", *got, want)
+			}
+		})
+	}
+}
+
+func TestDecodeInvoiceNet(t *testing.T) {
+	if _, err := DecodeInvoice("LIGHTNING:"+strings.ToUpper(donationPayReq), &chaincfg.MainNetParams); err != nil {
+		t.Errorf("upper case payment request with a lightning: prefix: %v", err)
+	}
+
+	var invoiceErr *InvoiceError
+	if _, err := DecodeInvoice(donationPayReq, &chaincfg.TestNet3Params); !errors.As(err, &invoiceErr) {
+		t.Errorf("mainnet payment request decoded for testnet: %v", err)
+	}
+	tampered := donationPayReq[:len(donationPayReq)-8] + "qqqqqqqq"
+	if _, err := DecodeInvoice(tampered, nil); !errors.As(err, &invoiceErr) {
+		t.Errorf("tampered payment request: %v", err)
+	}
+	if _, err := InvoiceNet("lnxy1"); err == nil {
+		t.Error("InvoiceNet of an unknown prefix succeeded")
+	}
+}
+
+func TestEncodeInvoiceRoundTrip(t *testing.T) {
+	key, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatal(err)
+	}
+	hint, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invoice := &Invoice{
+		Net:             &chaincfg.RegressionNetParams,
+		Destination:     hex.EncodeToString(key.PubKey().SerializeCompressed()),
+		PaymentHash:     specPaymentHash,
+		PaymentSecret:   strings.Repeat("22", 32),
+		AmountMsat:      1234567,
+		Description:     "round trip",
+		Timestamp:       specTimestamp,
+		Expiry:          600,
+		CltvExpiry:      40,
+		FallbackAddress: "bcrt1qw508d6qejxtdg4y5r3zarvary0c5xw7kygt080",
+		RouteHints: [][]HopHint{{{
+			NodeId:                    hex.EncodeToString(hint.PubKey().SerializeCompressed()),
+			ChanId:                    0x0102030405060708,
+			FeeBaseMsat:               1000,
+			FeeProportionalMillionths: 1,
+			CltvExpiryDelta:           144,
+		}}},
+		Features: []uint16{9, 15},
+	}
+	payReq, err := EncodeInvoice(invoice, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(payReq, "lnbcrt12345670p1") {
+		t.Errorf("payment request %v", payReq)
+	}
+	decoded, err := DecodeInvoice(payReq, &chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded.Timestamp = invoice.Timestamp
+	if !reflect.DeepEqual(decoded, invoice) {
+		t.Errorf("DecodeInvoice(EncodeInvoice(%+v)) = %+v", *invoice, *decoded)
+	}
+
+	lndPayReq := decoded.PayReq()
+	if len(lndPayReq.RouteHints) != 1 || lndPayReq.RouteHints[0].HopHints[0].CltvExpiryDelta != 144 {
+		t.Errorf("PayReq route hints = %v", lndPayReq.RouteHints)
+	}
+	if f := lndPayReq.Features[9]; f == nil || f.IsRequired || !f.IsKnown {
+		t.Errorf("PayReq feature 9 = %v", f)
+	}
+}
+
+func TestInvoiceExpired(t *testing.T) {
+	invoice, err := DecodeInvoice(coffeePayReq, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if invoice.Expired(specTimestamp.Add(59*time.Second)) || !invoice.Expired(specTimestamp.Add(time.Minute)) {
+		t.Errorf("invoice expiring at %v", invoice.ExpiresAt())
+	}
+}
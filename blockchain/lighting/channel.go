@@ -0,0 +1,89 @@
+package lighting
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+
+	"golang.org/x/net/context"
+)
+
+// ParseChannelPoint parses a "funding_txid:output_index" channel point.
+func ParseChannelPoint(s string) (*lnrpc.ChannelPoint, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 || len(parts[0]) != 64 {
+		return nil, fmt.Errorf("invalid channel point %q, expected funding_txid:output_index", s)
+	}
+	index, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid channel point %q: %v", s, err)
+	}
+	return &lnrpc.ChannelPoint{
+		FundingTxid: &lnrpc.ChannelPoint_FundingTxidStr{FundingTxidStr: parts[0]},
+		OutputIndex: uint32(index),
+	}, nil
+}
+
+// OpenChannel opens a channel to a connected peer and returns once the funding transaction is published.
+func (adapter *Adapter) OpenChannel(ctx context.Context, req *lnrpc.OpenChannelRequest) (*lnrpc.ChannelPoint, error) {
+	return adapter.lc.OpenChannelSync(ctx, req)
+}
+
+// CloseChannel closes a channel, cooperatively unless req.Force is set, and returns once the
+// closing transaction is broadcast. Confirmation of the close is not waited for.
+func (adapter *Adapter) CloseChannel(ctx context.Context, req *lnrpc.CloseChannelRequest) (*lnrpc.PendingUpdate, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := adapter.lc.CloseChannel(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		update, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		switch u := update.Update.(type) {
+		case *lnrpc.CloseStatusUpdate_ClosePending:
+			return u.ClosePending, nil
+		case *lnrpc.CloseStatusUpdate_ChanClose:
+			return &lnrpc.PendingUpdate{Txid: u.ChanClose.ClosingTxid}, nil
+		}
+	}
+}
+
+// ListChannels returns the open channels, see lnrpc.ListChannelsRequest for filters.
+func (adapter *Adapter) ListChannels(ctx context.Context, req *lnrpc.ListChannelsRequest) (*lnrpc.ListChannelsResponse, error) {
+	return adapter.lc.ListChannels(ctx, req)
+}
+
+// PendingChannels returns the channels being opened or closed.
+func (adapter *Adapter) PendingChannels(ctx context.Context) (*lnrpc.PendingChannelsResponse, error) {
+	return adapter.lc.PendingChannels(ctx, &lnrpc.PendingChannelsRequest{})
+}
+
+// ConnectPeer connects to the node pubkey@host, perm keeps reconnecting if the connection drops.
+// Connecting to an already connected peer is not an error.
+func (adapter *Adapter) ConnectPeer(ctx context.Context, pubkey string, host string, perm bool) error {
+	_, err := adapter.lc.ConnectPeer(ctx, &lnrpc.ConnectPeerRequest{
+		Addr: &lnrpc.LightningAddress{Pubkey: pubkey, Host: host},
+		Perm: perm,
+	})
+	if err != nil && strings.Contains(err.Error(), "already connected") {
+		return nil
+	}
+	return err
+}
+
+// ListPeers returns the connected peers.
+func (adapter *Adapter) ListPeers(ctx context.Context) (*lnrpc.ListPeersResponse, error) {
+	return adapter.lc.ListPeers(ctx, &lnrpc.ListPeersRequest{})
+}
+
+// DescribeGraph returns the channel graph known to the node.
+func (adapter *Adapter) DescribeGraph(ctx context.Context, includeUnannounced bool) (*lnrpc.ChannelGraph, error) {
+	return adapter.lc.DescribeGraph(ctx, &lnrpc.ChannelGraphRequest{IncludeUnannounced: includeUnannounced})
+}
@@ -0,0 +1,75 @@
+package lighting
+
+import (
+	"fmt"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// CertError is returned when the TLS certificate of a node can not be loaded or is not valid.
+type CertError struct {
+	Source string
+	Err    error
+}
+
+func (e *CertError) Error() string {
+	return fmt.Sprintf("tls certificate %v: %v", e.Source, e.Err)
+}
+
+func (e *CertError) Unwrap() error {
+	return e.Err
+}
+
+// MacaroonError is returned when a configured macaroon can not be loaded, or when a call of Method
+// needs Permission and no configured macaroon grants it, Kind being the most privileged one configured.
+// Permission is nil for methods of unknown permissions, which need the admin macaroon.
+type MacaroonError struct {
+	Kind       MacaroonKind
+	Source     string
+	Method     string
+	Permission *lnrpc.MacaroonPermission
+	Err        error
+}
+
+func (e *MacaroonError) Error() string {
+	switch {
+	case e.Method == "":
+		return fmt.Sprintf("%v macaroon %v: %v", e.Kind, e.Source, e.Err)
+	case e.Permission == nil:
+		return fmt.Sprintf("%v needs the %v macaroon, the most privileged configured is %v", e.Method, AdminMacaroon, e.Kind)
+	}
+	return fmt.Sprintf("%v needs permission %v:%v, not granted by the configured %v macaroon", e.Method, e.Permission.Entity, e.Permission.Action, e.Kind)
+}
+
+func (e *MacaroonError) Unwrap() error {
+	return e.Err
+}
+
+// GRPCStatus returns PermissionDenied for missing permissions, like lnd does.
+func (e *MacaroonError) GRPCStatus() *status.Status {
+	if e.Method == "" {
+		return status.New(codes.Unknown, e.Error())
+	}
+	return status.New(codes.PermissionDenied, e.Error())
+}
+
+// TransportError is returned when a node can not be reached, including failed TLS handshakes.
+// It keeps the gRPC status of Err.
+type TransportError struct {
+	Address string
+	Err     error
+}
+
+func (e *TransportError) Error() string {
+	return fmt.Sprintf("lnd %v unreachable: %v", e.Address, e.Err)
+}
+
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+func (e *TransportError) GRPCStatus() *status.Status {
+	return status.Convert(e.Err)
+}
@@ -0,0 +1,22 @@
+package lighting
+
+import (
+	"github.com/lightningnetwork/lnd/lnrpc"
+
+	"golang.org/x/net/context"
+)
+
+// AddInvoice creates an invoice, Value or ValueMsat set the amount and an empty amount allows any.
+func (adapter *Adapter) AddInvoice(ctx context.Context, invoice *lnrpc.Invoice) (*lnrpc.AddInvoiceResponse, error) {
+	return adapter.lc.AddInvoice(ctx, invoice)
+}
+
+// LookupInvoice returns the invoice with the hex encoded payment hash.
+func (adapter *Adapter) LookupInvoice(ctx context.Context, rHash string) (*lnrpc.Invoice, error) {
+	return adapter.lc.LookupInvoice(ctx, &lnrpc.PaymentHash{RHashStr: rHash})
+}
+
+// ListInvoices returns a page of invoices, see lnrpc.ListInvoiceRequest for paging.
+func (adapter *Adapter) ListInvoices(ctx context.Context, req *lnrpc.ListInvoiceRequest) (*lnrpc.ListInvoiceResponse, error) {
+	return adapter.lc.ListInvoices(ctx, req)
+}
@@ -1,50 +1,49 @@
 package lighting
 
 import (
-	"encoding/hex"
-	"io/ioutil"
-	"os"
-
 	"github.com/astaxie/beego/logs"
 	"github.com/jualy007/GoTF/config"
 	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/routerrpc"
 
 	"golang.org/x/net/context"
 
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials"
-	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/connectivity"
 )
 
 type Adapter struct {
-	cc   *grpc.ClientConn
-	lc   lnrpc.LightningClient
-	meta metadata.MD
+	cc *grpc.ClientConn
+	lc lnrpc.LightningClient
+	rc routerrpc.RouterClient
 }
 
-func NewAdapter(info *config.LndInfo) (adapter Adapter, err error) {
-	creds, _ := credentials.NewClientTLSFromFile(info.Cert, "")
-
-	adapter.cc, err = grpc.Dial(info.Address, grpc.WithTransportCredentials(creds))
+func NewAdapter(info *config.LndInfo, opts ...grpc.DialOption) (adapter Adapter, err error) {
+	macaroons, err := loadMacaroons(info)
+	if err != nil {
+		logs.Error("Load Macaroon Failed: %v", err)
+		return adapter, err
+	}
 
+	creds, err := TransportCredentials(info)
 	if err != nil {
-		logs.Error("gRPC Connection %v Failed!!!", info.Address)
+		logs.Error("Load TLS Certificate Failed: %v", err)
 		return adapter, err
 	}
 
-	logs.Info("Start init Lightning Network Client......")
-	adapter.lc = lnrpc.NewLightningClient(adapter.cc)
+	opts = append([]grpc.DialOption{grpc.WithTransportCredentials(creds)}, opts...)
+	opts = append(opts, macaroonInterceptors(macaroons)...)
+	opts = append(opts, transportInterceptors(info.Address)...)
+	adapter.cc, err = grpc.Dial(info.Address, opts...)
 
-	fh, openerr := os.Open(info.Macaroon)
-	if openerr != nil {
-		logs.Error("Open Macaroon File %v Failed!!!", info.Macaroon)
+	if err != nil {
+		logs.Error("gRPC Connection %v Failed!!!", info.Address)
+		return adapter, &TransportError{info.Address, err}
 	}
 
-	defer fh.Close()
-	fd, _ := ioutil.ReadAll(fh)
-
-	macaroon := hex.EncodeToString(fd)
-	adapter.meta = metadata.Pairs("macaroon", macaroon)
+	logs.Info("Start init Lightning Network Client......")
+	adapter.lc = lnrpc.NewLightningClient(adapter.cc)
+	adapter.rc = routerrpc.NewRouterClient(adapter.cc)
 
 	return adapter, nil
 }
@@ -55,41 +54,31 @@ func (adapter *Adapter) Close() {
 	}
 }
 
-func (adapter *Adapter) QueryRoute(payreq string) *lnrpc.QueryRoutesResponse {
-	ctx := metadata.NewOutgoingContext(context.Background(), adapter.meta)
+// State returns the connectivity state of the gRPC connection.
+func (adapter *Adapter) State() connectivity.State {
+	return adapter.cc.GetState()
+}
 
-	payreqStr := adapter.DecodePayReq(payreq)
+// QueryRoute queries a route for the amount and destination of a payment request.
+func (adapter *Adapter) QueryRoute(ctx context.Context, payreq string) (*lnrpc.QueryRoutesResponse, error) {
+	payreqStr, err := adapter.DecodePayReq(ctx, payreq)
+	if err != nil {
+		return nil, err
+	}
 
-	routeinfo, err := adapter.lc.QueryRoutes(ctx, &lnrpc.QueryRoutesRequest{
+	return adapter.lc.QueryRoutes(ctx, &lnrpc.QueryRoutesRequest{
 		Amt:        payreqStr.NumSatoshis,
 		PubKey:     payreqStr.Destination,
 		RouteHints: payreqStr.RouteHints,
 	})
-
-	if err != nil {
-		logs.Error("Query Route Failed For Pubkey %v and Amt %v!!!", payreqStr.Destination, payreqStr.NumSatoshis)
-		return nil
-	}
-
-	return routeinfo
 }
 
-func (adapter *Adapter) GetInfo() (info *lnrpc.GetInfoResponse, err error) {
-	ctx := metadata.NewOutgoingContext(context.Background(), adapter.meta)
+func (adapter *Adapter) GetInfo(ctx context.Context) (info *lnrpc.GetInfoResponse, err error) {
 	return adapter.lc.GetInfo(ctx, &lnrpc.GetInfoRequest{})
 }
 
-func (adapter Adapter) DecodePayReq(payreq string) *lnrpc.PayReq {
-	ctx := metadata.NewOutgoingContext(context.Background(), adapter.meta)
-
-	payreqStr, err := adapter.lc.DecodePayReq(ctx, &lnrpc.PayReqString{
+func (adapter *Adapter) DecodePayReq(ctx context.Context, payreq string) (*lnrpc.PayReq, error) {
+	return adapter.lc.DecodePayReq(ctx, &lnrpc.PayReqString{
 		PayReq: payreq,
 	})
-
-	if err != nil {
-		logs.Error("Decode Lightning Network Payment %v Failed!!!", payreq)
-		return nil
-	}
-
-	return payreqStr
 }
@@ -0,0 +1,140 @@
+package lndtest
+
+import (
+	"context"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Fault changes how a fake server answers calls of a method.
+type Fault struct {
+	// Delay holds calls before answering them. Calls whose deadline passes first fail with codes.DeadlineExceeded.
+	Delay time.Duration
+	// Err fails calls with the error.
+	Err error
+	// NoRoute makes QueryRoutes and SendPaymentSync find no route.
+	NoRoute bool
+	// Failure fails the HTLCs of SendToRoute at the sending node with the failure code.
+	Failure lnrpc.Failure_FailureCode
+	// Times is the number of calls the fault applies to, 0 for every call until it is cleared.
+	Times int
+}
+
+var (
+	// Unavailable fails calls like an unreachable lnd.
+	Unavailable = Fault{Err: status.Error(codes.Unavailable, "lnd is unavailable")}
+	// Timeout holds calls until their deadline passes.
+	Timeout = Fault{Delay: math.MaxInt64}
+	// NoRoute finds no route to any destination.
+	NoRoute = Fault{NoRoute: true}
+	// TemporaryChannelFailure fails SendToRoute HTLCs like a channel without enough balance.
+	TemporaryChannelFailure = Fault{Failure: lnrpc.Failure_TEMPORARY_CHANNEL_FAILURE}
+)
+
+// Inject applies fault to the calls of method, the lnrpc.Lightning or routerrpc.Router method name such as "GetInfo".
+// It replaces an earlier fault of method.
+func (s *Server) Inject(method string, fault Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults[method] = &fault
+}
+
+// Clear removes the fault of method.
+func (s *Server) Clear(method string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.faults, method)
+}
+
+// Calls returns the number of calls of method the server received.
+func (s *Server) Calls(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[method]
+}
+
+// take counts a call of method and returns the fault applying to it.
+func (s *Server) take(method string) Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls[method]++
+	fault := s.faults[method]
+	if fault == nil {
+		return Fault{}
+	}
+	if fault.Times > 0 {
+		if fault.Times--; fault.Times == 0 {
+			delete(s.faults, method)
+		}
+	}
+	return *fault
+}
+
+// intercept checks the macaroon and applies the injected delay and error of every unary call.
+// NoRoute and Failure faults are applied by the handlers.
+func (s *Server) intercept(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := s.apply(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// interceptStream is intercept for streaming calls such as SubscribeInvoices and SendPaymentV2.
+func (s *Server) interceptStream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := s.apply(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+}
+
+// apply counts a call of fullMethod, checks its macaroon and applies the fault of the method. It returns the
+// context of the handler.
+func (s *Server) apply(ctx context.Context, fullMethod string) (context.Context, error) {
+	method := fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+	fault := s.take(method)
+	if err := s.checkMacaroon(ctx); err != nil {
+		return nil, err
+	}
+	if fault.Delay > 0 {
+		timer := time.NewTimer(fault.Delay)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		case <-timer.C:
+		}
+	}
+	if fault.Err != nil {
+		return nil, fault.Err
+	}
+	if fault.NoRoute {
+		ctx = context.WithValue(ctx, noRouteKey{}, true)
+	}
+	if fault.Failure != lnrpc.Failure_RESERVED {
+		ctx = context.WithValue(ctx, failureKey{}, fault.Failure)
+	}
+	return ctx, nil
+}
+
+// serverStream is a stream with the context of its handler.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (ss *serverStream) Context() context.Context {
+	return ss.ctx
+}
+
+type noRouteKey struct{}
+
+type failureKey struct{}
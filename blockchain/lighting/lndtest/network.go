@@ -0,0 +1,177 @@
+package lndtest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"sync/atomic"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnwire"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ChannelConfirmations are the confirmations of its funding transaction a channel opened with
+// OpenChannelSync needs to become active, the default of lnd.
+const ChannelConfirmations = 3
+
+// pendingChannel is a channel opened by a server whose funding transaction is not confirmed enough.
+type pendingChannel struct {
+	remote        *Server
+	local         *lnrpc.Channel
+	remoteSide    *lnrpc.Channel
+	fundingHeight uint32 // 0 while the funding transaction is in the mempool
+}
+
+// ConnectPeer connects to another running fake server, both servers become peers of each other.
+func (s *Server) ConnectPeer(ctx context.Context, req *lnrpc.ConnectPeerRequest) (*lnrpc.ConnectPeerResponse, error) {
+	if req.Addr == nil || req.Addr.Pubkey == "" || req.Addr.Host == "" {
+		return nil, status.Error(codes.InvalidArgument, "need: pubkey@host")
+	}
+	if req.Addr.Pubkey == s.pubKey {
+		return nil, status.Error(codes.Unknown, "cannot make connection to self")
+	}
+	peer, ok := servers.Load(req.Addr.Pubkey)
+	if !ok {
+		return nil, status.Errorf(codes.Unknown, "dial %v: no fake lnd server with public key %v", req.Addr.Host, req.Addr.Pubkey)
+	}
+
+	s.mu.Lock()
+	if _, ok := s.peers[req.Addr.Pubkey]; ok {
+		s.mu.Unlock()
+		return nil, status.Errorf(codes.Unknown, "already connected to peer: %v@%v", req.Addr.Pubkey, req.Addr.Host)
+	}
+	s.peers[req.Addr.Pubkey] = &lnrpc.Peer{PubKey: req.Addr.Pubkey, Address: req.Addr.Host}
+	s.mu.Unlock()
+
+	remote := peer.(*Server)
+	remote.mu.Lock()
+	defer remote.mu.Unlock()
+	remote.peers[s.pubKey] = &lnrpc.Peer{PubKey: s.pubKey, Address: s.Address(), Inbound: true}
+	return &lnrpc.ConnectPeerResponse{}, nil
+}
+
+func (s *Server) ListPeers(ctx context.Context, _ *lnrpc.ListPeersRequest) (*lnrpc.ListPeersResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp := &lnrpc.ListPeersResponse{}
+	for _, p := range s.peers {
+		copied := *p
+		resp.Peers = append(resp.Peers, &copied)
+	}
+	sort.Slice(resp.Peers, func(i, j int) bool { return resp.Peers[i].PubKey < resp.Peers[j].PubKey })
+	return resp, nil
+}
+
+// OpenChannelSync opens a channel to a connected peer. It is pending until Mine confirms its funding
+// transaction ChannelConfirmations times. Wallet balances are not modelled.
+func (s *Server) OpenChannelSync(ctx context.Context, req *lnrpc.OpenChannelRequest) (*lnrpc.ChannelPoint, error) {
+	pubKey := hex.EncodeToString(req.NodePubkey)
+	if req.NodePubkeyString != "" {
+		pubKey = req.NodePubkeyString
+	}
+	s.mu.Lock()
+	_, connected := s.peers[pubKey]
+	s.mu.Unlock()
+	peer, running := servers.Load(pubKey)
+	if !connected || !running {
+		return nil, status.Errorf(codes.Unknown, "peer %v is not online", pubKey)
+	}
+	if req.LocalFundingAmount <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "local funding amount must be positive")
+	}
+	if req.PushSat < 0 || req.PushSat >= req.LocalFundingAmount {
+		return nil, status.Error(codes.InvalidArgument, "amount pushed to remote peer for initial state must be below the local funding amount")
+	}
+
+	var txid chainhash.Hash
+	if _, err := rand.Read(txid[:]); err != nil {
+		return nil, err
+	}
+	local, remote := channelSides(s, peer.(*Server), txid.String()+":0", req.LocalFundingAmount, req.PushSat, req.Private)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending[txid.String()] = &pendingChannel{remote: peer.(*Server), local: local, remoteSide: remote}
+	return &lnrpc.ChannelPoint{FundingTxid: &lnrpc.ChannelPoint_FundingTxidBytes{FundingTxidBytes: txid[:]}}, nil
+}
+
+// Mempool returns the funding transactions of the channels s opened that are not mined yet.
+func (s *Server) Mempool() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var txids []string
+	for txid, p := range s.pending {
+		if p.fundingHeight == 0 {
+			txids = append(txids, txid)
+		}
+	}
+	sort.Strings(txids)
+	return txids
+}
+
+// Mine adds blocks to the chain of the node, the funding transactions in its mempool are mined in the
+// first one. Channels whose funding transaction has ChannelConfirmations become active on both sides.
+func (s *Server) Mine(blocks uint32) {
+	if blocks == 0 {
+		return
+	}
+	s.mu.Lock()
+	var confirmed []*pendingChannel
+	for txid, p := range s.pending {
+		if p.fundingHeight == 0 {
+			p.fundingHeight = s.height + 1
+		}
+		if s.height+blocks+1-p.fundingHeight >= ChannelConfirmations {
+			confirmed = append(confirmed, p)
+			delete(s.pending, txid)
+		}
+	}
+	s.height += blocks
+	s.mu.Unlock()
+
+	for _, p := range confirmed {
+		chanId := lnwire.ShortChannelID{BlockHeight: p.fundingHeight, TxIndex: atomic.AddUint32(&channelCount, 1)}
+		p.local.ChanId, p.remoteSide.ChanId = chanId.ToUint64(), chanId.ToUint64()
+		s.addChannel(p.local)
+		p.remote.addChannel(p.remoteSide)
+	}
+}
+
+// DescribeGraph returns the active public channels of every running fake server as gossiped edges.
+// Private channels of s are included with IncludeUnannounced.
+func (s *Server) DescribeGraph(ctx context.Context, req *lnrpc.ChannelGraphRequest) (*lnrpc.ChannelGraph, error) {
+	graph := &lnrpc.ChannelGraph{}
+	edges := make(map[string]bool)
+	nodes := make(map[string]*lnrpc.LightningNode)
+	servers.Range(func(_, value interface{}) bool {
+		node := value.(*Server)
+		node.mu.Lock()
+		defer node.mu.Unlock()
+		for _, c := range node.channels {
+			if !c.Active || edges[c.ChannelPoint] || c.Private && (node != s || !req.IncludeUnannounced) {
+				continue
+			}
+			edges[c.ChannelPoint] = true
+			graph.Edges = append(graph.Edges, &lnrpc.ChannelEdge{
+				ChannelId: c.ChanId,
+				ChanPoint: c.ChannelPoint,
+				Node1Pub:  node.pubKey,
+				Node2Pub:  c.RemotePubkey,
+				Capacity:  c.Capacity,
+			})
+			nodes[node.pubKey] = &lnrpc.LightningNode{PubKey: node.pubKey, Alias: node.Name}
+			if nodes[c.RemotePubkey] == nil {
+				nodes[c.RemotePubkey] = &lnrpc.LightningNode{PubKey: c.RemotePubkey}
+			}
+		}
+		return true
+	})
+	for _, node := range nodes {
+		graph.Nodes = append(graph.Nodes, node)
+	}
+	return graph, nil
+}
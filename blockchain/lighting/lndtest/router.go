@@ -0,0 +1,72 @@
+package lndtest
+
+import (
+	"context"
+	"encoding/hex"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/routerrpc"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// router serves routerrpc.Router for a Server. It is a separate type as lnrpc.Lightning and
+// routerrpc.Router both have a SendToRoute method.
+type router struct {
+	unimplementedRouter
+
+	s *Server
+}
+
+func routeFailure(code lnrpc.Failure_FailureCode, index int) *routerrpc.SendToRouteResponse {
+	return &routerrpc.SendToRouteResponse{Failure: &lnrpc.Failure{Code: code, FailureSourceIndex: uint32(index)}}
+}
+
+// SendToRoute forwards the HTLC hop by hop over the channels of running fake servers. It fails
+// where a channel is missing or lacks balance, and at the destination unless it has an open
+// invoice for the payment hash and amount, which is then settled.
+func (r *router) SendToRoute(ctx context.Context, req *routerrpc.SendToRouteRequest) (*routerrpc.SendToRouteResponse, error) {
+	if len(req.PaymentHash) != 32 {
+		return nil, status.Error(codes.InvalidArgument, "payment hash must be exactly 32 bytes")
+	}
+	if req.Route == nil || len(req.Route.Hops) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "route has no hops")
+	}
+	if code, ok := ctx.Value(failureKey{}).(lnrpc.Failure_FailureCode); ok {
+		return routeFailure(code, 0), nil
+	}
+
+	hops := req.Route.Hops
+	path := []*Server{r.s}
+	amounts := make([]int64, len(hops))
+	for i, hop := range hops {
+		amtMsat := hop.AmtToForwardMsat + hop.FeeMsat
+		if amtMsat == 0 {
+			amtMsat = (hop.AmtToForward + hop.Fee) * 1000
+		}
+		if code := path[i].outgoing(hop.ChanId, hop.PubKey, amtMsat); code != lnrpc.Failure_RESERVED {
+			return routeFailure(code, i), nil
+		}
+		next, ok := servers.Load(hop.PubKey)
+		if !ok {
+			return routeFailure(lnrpc.Failure_UNKNOWN_NEXT_PEER, i), nil
+		}
+		path = append(path, next.(*Server))
+		amounts[i] = amtMsat
+	}
+
+	final := hops[len(hops)-1]
+	amtMsat := final.AmtToForwardMsat
+	if amtMsat == 0 {
+		amtMsat = final.AmtToForward * 1000
+	}
+	preimage, err := path[len(hops)].settle(hex.EncodeToString(req.PaymentHash), amtMsat)
+	if err != nil {
+		return routeFailure(lnrpc.Failure_INCORRECT_OR_UNKNOWN_PAYMENT_DETAILS, len(hops)), nil
+	}
+	for i, hop := range hops {
+		path[i].transfer(path[i+1], hop.ChanId, amounts[i])
+	}
+	return &routerrpc.SendToRouteResponse{Preimage: preimage}, nil
+}
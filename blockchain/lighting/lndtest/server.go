@@ -0,0 +1,557 @@
+package lndtest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/jualy007/GoTF/blockchain/lighting"
+	"github.com/jualy007/GoTF/config"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/routerrpc"
+	"github.com/lightningnetwork/lnd/lnwire"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// ServerName is the host name of the TLS certificates of fake servers.
+const ServerName = "lndtest"
+
+// Defaults of the fake nodes and their invoices.
+const (
+	DefaultBlockHeight   = 500
+	DefaultInvoiceExpiry = 3600
+	DefaultCltvExpiry    = 40
+
+	bufSize = 1 << 20
+)
+
+// ErrNoRoute is the message lnd gives when it finds no path to a destination.
+const ErrNoRoute = "unable to find a path to destination"
+
+// Net are the chain parameters of the payment requests of fake servers.
+var Net = &chaincfg.RegressionNetParams
+
+// servers are the running fake servers by identity public key, payments between them settle the invoices of the payee.
+var servers sync.Map
+
+// channelCount numbers the channels of fake servers.
+var channelCount uint32
+
+// Server is an in-process lnd stand-in serving lnrpc.Lightning and routerrpc.Router over an in-memory bufconn listener,
+// with TLS and a macaroon, so the real lighting.Adapter code path is used. It models a node with
+// invoices, peers and direct channels to other fake servers, opened on a chain mined with Mine.
+// Methods it does not model return codes.Unimplemented.
+type Server struct {
+	unimplemented
+
+	Name string
+
+	lis        *bufconn.Listener
+	grpcServer *grpc.Server
+	key        *btcec.PrivateKey
+	pubKey     string
+	certPEM    []byte
+	macaroon   string
+
+	mu       sync.Mutex
+	height   uint32
+	addIndex uint64
+	invoices map[string]*lnrpc.Invoice
+	channels []*lnrpc.Channel
+	peers    map[string]*lnrpc.Peer
+	pending  map[string]*pendingChannel
+	faults   map[string]*Fault
+	calls    map[string]int
+}
+
+// NewServer starts a fake server for the node name.
+func NewServer(name string) (*Server, error) {
+	key, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		return nil, err
+	}
+	certPEM, cert, err := newCertificate()
+	if err != nil {
+		return nil, err
+	}
+	macaroon := make([]byte, 32)
+	if _, err := rand.Read(macaroon); err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		Name:     name,
+		lis:      bufconn.Listen(bufSize),
+		key:      key,
+		pubKey:   hex.EncodeToString(key.PubKey().SerializeCompressed()),
+		certPEM:  certPEM,
+		macaroon: hex.EncodeToString(macaroon),
+		height:   DefaultBlockHeight,
+		invoices: make(map[string]*lnrpc.Invoice),
+		peers:    make(map[string]*lnrpc.Peer),
+		pending:  make(map[string]*pendingChannel),
+		faults:   make(map[string]*Fault),
+		calls:    make(map[string]int),
+	}
+	s.grpcServer = grpc.NewServer(
+		grpc.Creds(credentials.NewServerTLSFromCert(cert)),
+		grpc.UnaryInterceptor(s.intercept),
+		grpc.StreamInterceptor(s.interceptStream),
+	)
+	lnrpc.RegisterLightningServer(s.grpcServer, s)
+	routerrpc.RegisterRouterServer(s.grpcServer, &router{s: s})
+	servers.Store(s.pubKey, s)
+	go s.grpcServer.Serve(s.lis)
+	return s, nil
+}
+
+// newCertificate creates a self signed certificate for ServerName.
+func newCertificate() ([]byte, *tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: ServerName},
+		DNSNames:              []string{ServerName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	cert, err := tls.X509KeyPair(certPEM, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	if err != nil {
+		return nil, nil, err
+	}
+	return certPEM, &cert, nil
+}
+
+// Close stops the server, open connections fail with codes.Unavailable.
+func (s *Server) Close() {
+	servers.Delete(s.pubKey)
+	s.grpcServer.Stop()
+}
+
+// Address is the address of the server in its LndInfo, only reachable with DialOption.
+func (s *Server) Address() string {
+	return "bufconn-" + s.Name
+}
+
+// PubKey returns the hex encoded identity public key of the node.
+func (s *Server) PubKey() string {
+	return s.pubKey
+}
+
+// LndInfo returns the configuration of the node, with its certificate inline and its macaroon hex encoded.
+func (s *Server) LndInfo() *config.LndInfo {
+	return &config.LndInfo{
+		Address:    s.Address(),
+		Cert:       string(s.certPEM),
+		ServerName: ServerName,
+		Macaroon:   s.macaroon,
+	}
+}
+
+// DialOption dials the in-memory listeners of servers by their address.
+func DialOption(servers ...*Server) grpc.DialOption {
+	return grpc.WithContextDialer(func(ctx context.Context, address string) (net.Conn, error) {
+		for _, s := range servers {
+			if s.Address() == address {
+				return s.lis.Dial()
+			}
+		}
+		return nil, fmt.Errorf("no fake lnd server at %v", address)
+	})
+}
+
+// Adapter returns a new adapter connected to the server, the caller closes it.
+func (s *Server) Adapter() (*lighting.Adapter, error) {
+	adapter, err := lighting.NewAdapter(s.LndInfo(), DialOption(s))
+	if err != nil {
+		return nil, err
+	}
+	return &adapter, nil
+}
+
+// Pool returns a pool of the servers by name, for models.SetLndPool.
+func Pool(servers ...*Server) *lighting.Pool {
+	nodes := make(map[string]*config.LndInfo, len(servers))
+	for _, s := range servers {
+		nodes[s.Name] = s.LndInfo()
+	}
+	return lighting.NewPool(nodes, DialOption(servers...))
+}
+
+// SetBlockHeight sets the block height the node reports.
+func (s *Server) SetBlockHeight(height uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.height = height
+}
+
+// AddChannel opens an active channel of capacity from s to remote, pushing push to remote.
+// Both servers get their side of the channel. It returns the channel id.
+func (s *Server) AddChannel(remote *Server, capacity int64, push int64) uint64 {
+	chanPoint := make([]byte, 32)
+	rand.Read(chanPoint)
+	chanId := lnwire.ShortChannelID{BlockHeight: s.blockHeight(), TxIndex: atomic.AddUint32(&channelCount, 1)}
+
+	local, other := channelSides(s, remote, fmt.Sprintf("%x:0", chanPoint), capacity, push, false)
+	local.ChanId, other.ChanId = chanId.ToUint64(), chanId.ToUint64()
+	s.addChannel(local)
+	remote.addChannel(other)
+	return chanId.ToUint64()
+}
+
+// channelSides returns the sides of an active channel at chanPoint of capacity from s to remote, pushing push to remote.
+func channelSides(s, remote *Server, chanPoint string, capacity, push int64, private bool) (*lnrpc.Channel, *lnrpc.Channel) {
+	channel := func(peer *Server, balance int64, initiator bool) *lnrpc.Channel {
+		return &lnrpc.Channel{
+			Active:        true,
+			RemotePubkey:  peer.pubKey,
+			ChannelPoint:  chanPoint,
+			Capacity:      capacity,
+			LocalBalance:  balance,
+			RemoteBalance: capacity - balance,
+			Initiator:     initiator,
+			Private:       private,
+		}
+	}
+	return channel(remote, capacity-push, true), channel(s, push, false)
+}
+
+func (s *Server) addChannel(channel *lnrpc.Channel) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.channels = append(s.channels, channel)
+}
+
+func (s *Server) blockHeight() uint32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.height
+}
+
+// Invoice returns the invoice with the hex encoded payment hash, nil if there is none.
+func (s *Server) Invoice(rHash string) *lnrpc.Invoice {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.invoices[rHash]
+}
+
+// checkMacaroon fails calls without the macaroon of the server, like lnd does.
+func (s *Server) checkMacaroon(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if len(md["macaroon"]) != 1 {
+		return status.Errorf(codes.Unknown, "expected 1 macaroon, got %d", len(md["macaroon"]))
+	}
+	if md["macaroon"][0] != s.macaroon {
+		return status.Error(codes.Unknown, "verification failed: signature mismatch after caveat verification")
+	}
+	return nil
+}
+
+func (s *Server) GetInfo(ctx context.Context, _ *lnrpc.GetInfoRequest) (*lnrpc.GetInfoResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	active := 0
+	for _, c := range s.channels {
+		if c.Active {
+			active++
+		}
+	}
+	return &lnrpc.GetInfoResponse{
+		Version:           "0.10.0-beta lndtest",
+		IdentityPubkey:    s.pubKey,
+		Alias:             s.Name,
+		NumActiveChannels: uint32(active),
+		NumPeers:          uint32(len(s.channels)),
+		BlockHeight:       s.height,
+		SyncedToChain:     true,
+		SyncedToGraph:     true,
+		Chains:            []*lnrpc.Chain{{Chain: "bitcoin", Network: "regtest"}},
+		Uris:              []string{s.pubKey + "@" + s.Address()},
+	}, nil
+}
+
+func (s *Server) AddInvoice(ctx context.Context, invoice *lnrpc.Invoice) (*lnrpc.AddInvoiceResponse, error) {
+	preimage := invoice.RPreimage
+	if len(preimage) == 0 {
+		preimage = make([]byte, 32)
+		if _, err := rand.Read(preimage); err != nil {
+			return nil, err
+		}
+	}
+	if len(preimage) != 32 {
+		return nil, status.Error(codes.InvalidArgument, "payment preimage must be exactly 32 bytes")
+	}
+	hash := sha256.Sum256(preimage)
+
+	valueMsat := invoice.ValueMsat
+	if valueMsat == 0 {
+		valueMsat = invoice.Value * 1000
+	}
+	expiry := invoice.Expiry
+	if expiry == 0 {
+		expiry = DefaultInvoiceExpiry
+	}
+	cltvExpiry := invoice.CltvExpiry
+	if cltvExpiry == 0 {
+		cltvExpiry = DefaultCltvExpiry
+	}
+
+	created := time.Now()
+	encoded, err := lighting.EncodeInvoice(&lighting.Invoice{
+		Net:         Net,
+		PaymentHash: hex.EncodeToString(hash[:]),
+		AmountMsat:  valueMsat,
+		Description: invoice.Memo,
+		Timestamp:   created,
+		Expiry:      expiry,
+		CltvExpiry:  cltvExpiry,
+	}, s.key)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rHash := hex.EncodeToString(hash[:])
+	if _, ok := s.invoices[rHash]; ok {
+		return nil, status.Error(codes.AlreadyExists, "invoice with payment hash already exists")
+	}
+	s.addIndex++
+	s.invoices[rHash] = &lnrpc.Invoice{
+		Memo:           invoice.Memo,
+		RPreimage:      preimage,
+		RHash:          hash[:],
+		Value:          valueMsat / 1000,
+		ValueMsat:      valueMsat,
+		CreationDate:   created.Unix(),
+		PaymentRequest: encoded,
+		Expiry:         expiry,
+		CltvExpiry:     cltvExpiry,
+		AddIndex:       s.addIndex,
+		State:          lnrpc.Invoice_OPEN,
+	}
+	return &lnrpc.AddInvoiceResponse{RHash: hash[:], PaymentRequest: encoded, AddIndex: s.addIndex}, nil
+}
+
+func (s *Server) LookupInvoice(ctx context.Context, req *lnrpc.PaymentHash) (*lnrpc.Invoice, error) {
+	rHash := req.RHashStr
+	if rHash == "" {
+		rHash = hex.EncodeToString(req.RHash)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	invoice := s.invoices[rHash]
+	if invoice == nil {
+		return nil, status.Error(codes.Unknown, "unable to locate invoice")
+	}
+	copied := *invoice
+	return &copied, nil
+}
+
+func (s *Server) DecodePayReq(ctx context.Context, req *lnrpc.PayReqString) (*lnrpc.PayReq, error) {
+	invoice, err := lighting.DecodeInvoice(req.PayReq, Net)
+	if err != nil {
+		return nil, status.Error(codes.Unknown, err.Error())
+	}
+	return invoice.PayReq(), nil
+}
+
+// route returns a single hop route paying amtMsat to dest over a direct active channel with enough balance.
+// It returns a nil route if there is none or a NoRoute fault applies to the call.
+func (s *Server) route(ctx context.Context, dest string, amtMsat int64, finalCltvDelta int32) (*lnrpc.Route, *lnrpc.Channel) {
+	if noRoute, _ := ctx.Value(noRouteKey{}).(bool); noRoute {
+		return nil, nil
+	}
+	if finalCltvDelta == 0 {
+		finalCltvDelta = DefaultCltvExpiry
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.channels {
+		if c.Active && c.RemotePubkey == dest && c.LocalBalance*1000 >= amtMsat {
+			expiry := s.height + uint32(finalCltvDelta)
+			return &lnrpc.Route{
+				TotalTimeLock: expiry,
+				TotalAmt:      amtMsat / 1000,
+				TotalAmtMsat:  amtMsat,
+				Hops: []*lnrpc.Hop{{
+					ChanId:           c.ChanId,
+					ChanCapacity:     c.Capacity,
+					AmtToForward:     amtMsat / 1000,
+					AmtToForwardMsat: amtMsat,
+					Expiry:           expiry,
+					PubKey:           dest,
+				}},
+			}, c
+		}
+	}
+	return nil, nil
+}
+
+func (s *Server) QueryRoutes(ctx context.Context, req *lnrpc.QueryRoutesRequest) (*lnrpc.QueryRoutesResponse, error) {
+	amtMsat := req.AmtMsat
+	if amtMsat == 0 {
+		amtMsat = req.Amt * 1000
+	}
+	route, _ := s.route(ctx, req.PubKey, amtMsat, req.FinalCltvDelta)
+	if route == nil {
+		return nil, status.Error(codes.Unknown, ErrNoRoute)
+	}
+	return &lnrpc.QueryRoutesResponse{Routes: []*lnrpc.Route{route}, SuccessProb: 1}, nil
+}
+
+// SendPaymentSync pays over a direct channel. The payment succeeds if the destination is a
+// running fake server with an open invoice for the payment hash and amount.
+func (s *Server) SendPaymentSync(ctx context.Context, req *lnrpc.SendRequest) (*lnrpc.SendResponse, error) {
+	dest, hash := hex.EncodeToString(req.Dest), req.PaymentHash
+	if req.DestString != "" {
+		dest = req.DestString
+	}
+	if req.PaymentHashString != "" {
+		var err error
+		if hash, err = hex.DecodeString(req.PaymentHashString); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	amtMsat := req.AmtMsat
+	if amtMsat == 0 {
+		amtMsat = req.Amt * 1000
+	}
+	finalCltvDelta := req.FinalCltvDelta
+	if req.PaymentRequest != "" {
+		invoice, err := lighting.DecodeInvoice(req.PaymentRequest, Net)
+		if err != nil {
+			return nil, status.Error(codes.Unknown, err.Error())
+		}
+		if hash, err = hex.DecodeString(invoice.PaymentHash); err != nil {
+			return nil, err
+		}
+		dest, finalCltvDelta = invoice.Destination, int32(invoice.CltvExpiry)
+		if invoice.AmountMsat > 0 {
+			amtMsat = invoice.AmountMsat
+		}
+	}
+	if amtMsat <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "amount must be specified when paying a zero amount invoice")
+	}
+
+	route, channel := s.route(ctx, dest, amtMsat, finalCltvDelta)
+	if route == nil {
+		return &lnrpc.SendResponse{PaymentError: ErrNoRoute, PaymentHash: hash}, nil
+	}
+	payee, ok := servers.Load(dest)
+	if !ok {
+		return &lnrpc.SendResponse{PaymentError: "IncorrectOrUnknownPaymentDetails", PaymentHash: hash}, nil
+	}
+	preimage, err := payee.(*Server).settle(hex.EncodeToString(hash), amtMsat)
+	if err != nil {
+		return &lnrpc.SendResponse{PaymentError: err.Error(), PaymentHash: hash}, nil
+	}
+	s.transfer(payee.(*Server), channel.ChanId, amtMsat)
+	return &lnrpc.SendResponse{PaymentPreimage: preimage, PaymentHash: hash, PaymentRoute: route}, nil
+}
+
+// outgoing checks s can send amtMsat to peer over the channel chanId. It returns the failure
+// code of the node if it can not, lnrpc.Failure_RESERVED if it can.
+func (s *Server) outgoing(chanId uint64, peer string, amtMsat int64) lnrpc.Failure_FailureCode {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.channels {
+		if c.ChanId != chanId || c.RemotePubkey != peer {
+			continue
+		}
+		if !c.Active {
+			return lnrpc.Failure_CHANNEL_DISABLED
+		}
+		if c.LocalBalance*1000 < amtMsat {
+			return lnrpc.Failure_TEMPORARY_CHANNEL_FAILURE
+		}
+		return lnrpc.Failure_RESERVED
+	}
+	return lnrpc.Failure_UNKNOWN_NEXT_PEER
+}
+
+// transfer moves amtMsat over the channel chanId from s to peer, on both sides of the channel.
+func (s *Server) transfer(peer *Server, chanId uint64, amtMsat int64) {
+	move := func(node *Server, delta int64) {
+		node.mu.Lock()
+		defer node.mu.Unlock()
+		for _, c := range node.channels {
+			if c.ChanId == chanId {
+				c.LocalBalance += delta
+				c.RemoteBalance -= delta
+			}
+		}
+	}
+	move(s, -amtMsat/1000)
+	move(peer, amtMsat/1000)
+}
+
+// settle settles the open invoice of hash paid with amtMsat, returning its preimage.
+func (s *Server) settle(rHash string, amtMsat int64) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	invoice := s.invoices[rHash]
+	if invoice == nil || invoice.State != lnrpc.Invoice_OPEN || amtMsat < invoice.ValueMsat {
+		return nil, fmt.Errorf("IncorrectOrUnknownPaymentDetails")
+	}
+	invoice.State, invoice.Settled = lnrpc.Invoice_SETTLED, true
+	invoice.AmtPaidMsat, invoice.AmtPaidSat = amtMsat, amtMsat/1000
+	invoice.SettleDate = time.Now().Unix()
+	return invoice.RPreimage, nil
+}
+
+func (s *Server) ListChannels(ctx context.Context, req *lnrpc.ListChannelsRequest) (*lnrpc.ListChannelsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	peer := hex.EncodeToString(req.Peer)
+	resp := &lnrpc.ListChannelsResponse{}
+	for _, c := range s.channels {
+		if req.ActiveOnly && !c.Active || req.InactiveOnly && c.Active ||
+			req.PublicOnly && c.Private || req.PrivateOnly && !c.Private ||
+			peer != "" && c.RemotePubkey != peer {
+			continue
+		}
+		copied := *c
+		resp.Channels = append(resp.Channels, &copied)
+	}
+	return resp, nil
+}
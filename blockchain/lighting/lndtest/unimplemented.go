@@ -0,0 +1,236 @@
+package lndtest
+
+import (
+	"context"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/routerrpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// unimplemented answers every lnrpc.LightningServer method the fake does not model with codes.Unimplemented.
+type unimplemented struct{}
+
+func (unimplemented) WalletBalance(context.Context, *lnrpc.WalletBalanceRequest) (*lnrpc.WalletBalanceResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method WalletBalance not implemented")
+}
+
+func (unimplemented) ChannelBalance(context.Context, *lnrpc.ChannelBalanceRequest) (*lnrpc.ChannelBalanceResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ChannelBalance not implemented")
+}
+
+func (unimplemented) GetTransactions(context.Context, *lnrpc.GetTransactionsRequest) (*lnrpc.TransactionDetails, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetTransactions not implemented")
+}
+
+func (unimplemented) EstimateFee(context.Context, *lnrpc.EstimateFeeRequest) (*lnrpc.EstimateFeeResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method EstimateFee not implemented")
+}
+
+func (unimplemented) SendCoins(context.Context, *lnrpc.SendCoinsRequest) (*lnrpc.SendCoinsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method SendCoins not implemented")
+}
+
+func (unimplemented) ListUnspent(context.Context, *lnrpc.ListUnspentRequest) (*lnrpc.ListUnspentResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListUnspent not implemented")
+}
+
+func (unimplemented) SubscribeTransactions(*lnrpc.GetTransactionsRequest, lnrpc.Lightning_SubscribeTransactionsServer) error {
+	return status.Error(codes.Unimplemented, "method SubscribeTransactions not implemented")
+}
+
+func (unimplemented) SendMany(context.Context, *lnrpc.SendManyRequest) (*lnrpc.SendManyResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method SendMany not implemented")
+}
+
+func (unimplemented) NewAddress(context.Context, *lnrpc.NewAddressRequest) (*lnrpc.NewAddressResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method NewAddress not implemented")
+}
+
+func (unimplemented) SignMessage(context.Context, *lnrpc.SignMessageRequest) (*lnrpc.SignMessageResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method SignMessage not implemented")
+}
+
+func (unimplemented) VerifyMessage(context.Context, *lnrpc.VerifyMessageRequest) (*lnrpc.VerifyMessageResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method VerifyMessage not implemented")
+}
+
+func (unimplemented) DisconnectPeer(context.Context, *lnrpc.DisconnectPeerRequest) (*lnrpc.DisconnectPeerResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method DisconnectPeer not implemented")
+}
+
+func (unimplemented) SubscribePeerEvents(*lnrpc.PeerEventSubscription, lnrpc.Lightning_SubscribePeerEventsServer) error {
+	return status.Error(codes.Unimplemented, "method SubscribePeerEvents not implemented")
+}
+
+func (unimplemented) PendingChannels(context.Context, *lnrpc.PendingChannelsRequest) (*lnrpc.PendingChannelsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method PendingChannels not implemented")
+}
+
+func (unimplemented) SubscribeChannelEvents(*lnrpc.ChannelEventSubscription, lnrpc.Lightning_SubscribeChannelEventsServer) error {
+	return status.Error(codes.Unimplemented, "method SubscribeChannelEvents not implemented")
+}
+
+func (unimplemented) ClosedChannels(context.Context, *lnrpc.ClosedChannelsRequest) (*lnrpc.ClosedChannelsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ClosedChannels not implemented")
+}
+
+func (unimplemented) OpenChannel(*lnrpc.OpenChannelRequest, lnrpc.Lightning_OpenChannelServer) error {
+	return status.Error(codes.Unimplemented, "method OpenChannel not implemented")
+}
+
+func (unimplemented) FundingStateStep(context.Context, *lnrpc.FundingTransitionMsg) (*lnrpc.FundingStateStepResp, error) {
+	return nil, status.Error(codes.Unimplemented, "method FundingStateStep not implemented")
+}
+
+func (unimplemented) ChannelAcceptor(lnrpc.Lightning_ChannelAcceptorServer) error {
+	return status.Error(codes.Unimplemented, "method ChannelAcceptor not implemented")
+}
+
+func (unimplemented) CloseChannel(*lnrpc.CloseChannelRequest, lnrpc.Lightning_CloseChannelServer) error {
+	return status.Error(codes.Unimplemented, "method CloseChannel not implemented")
+}
+
+func (unimplemented) AbandonChannel(context.Context, *lnrpc.AbandonChannelRequest) (*lnrpc.AbandonChannelResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method AbandonChannel not implemented")
+}
+
+func (unimplemented) SendPayment(lnrpc.Lightning_SendPaymentServer) error {
+	return status.Error(codes.Unimplemented, "method SendPayment not implemented")
+}
+
+func (unimplemented) SendToRoute(lnrpc.Lightning_SendToRouteServer) error {
+	return status.Error(codes.Unimplemented, "method SendToRoute not implemented")
+}
+
+func (unimplemented) SendToRouteSync(context.Context, *lnrpc.SendToRouteRequest) (*lnrpc.SendResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method SendToRouteSync not implemented")
+}
+
+func (unimplemented) ListInvoices(context.Context, *lnrpc.ListInvoiceRequest) (*lnrpc.ListInvoiceResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListInvoices not implemented")
+}
+
+func (unimplemented) SubscribeInvoices(*lnrpc.InvoiceSubscription, lnrpc.Lightning_SubscribeInvoicesServer) error {
+	return status.Error(codes.Unimplemented, "method SubscribeInvoices not implemented")
+}
+
+func (unimplemented) ListPayments(context.Context, *lnrpc.ListPaymentsRequest) (*lnrpc.ListPaymentsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListPayments not implemented")
+}
+
+func (unimplemented) DeleteAllPayments(context.Context, *lnrpc.DeleteAllPaymentsRequest) (*lnrpc.DeleteAllPaymentsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method DeleteAllPayments not implemented")
+}
+
+func (unimplemented) GetNodeMetrics(context.Context, *lnrpc.NodeMetricsRequest) (*lnrpc.NodeMetricsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetNodeMetrics not implemented")
+}
+
+func (unimplemented) GetChanInfo(context.Context, *lnrpc.ChanInfoRequest) (*lnrpc.ChannelEdge, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetChanInfo not implemented")
+}
+
+func (unimplemented) GetNodeInfo(context.Context, *lnrpc.NodeInfoRequest) (*lnrpc.NodeInfo, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetNodeInfo not implemented")
+}
+
+func (unimplemented) GetNetworkInfo(context.Context, *lnrpc.NetworkInfoRequest) (*lnrpc.NetworkInfo, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetNetworkInfo not implemented")
+}
+
+func (unimplemented) StopDaemon(context.Context, *lnrpc.StopRequest) (*lnrpc.StopResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method StopDaemon not implemented")
+}
+
+func (unimplemented) SubscribeChannelGraph(*lnrpc.GraphTopologySubscription, lnrpc.Lightning_SubscribeChannelGraphServer) error {
+	return status.Error(codes.Unimplemented, "method SubscribeChannelGraph not implemented")
+}
+
+func (unimplemented) DebugLevel(context.Context, *lnrpc.DebugLevelRequest) (*lnrpc.DebugLevelResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method DebugLevel not implemented")
+}
+
+func (unimplemented) FeeReport(context.Context, *lnrpc.FeeReportRequest) (*lnrpc.FeeReportResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method FeeReport not implemented")
+}
+
+func (unimplemented) UpdateChannelPolicy(context.Context, *lnrpc.PolicyUpdateRequest) (*lnrpc.PolicyUpdateResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method UpdateChannelPolicy not implemented")
+}
+
+func (unimplemented) ForwardingHistory(context.Context, *lnrpc.ForwardingHistoryRequest) (*lnrpc.ForwardingHistoryResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ForwardingHistory not implemented")
+}
+
+func (unimplemented) ExportChannelBackup(context.Context, *lnrpc.ExportChannelBackupRequest) (*lnrpc.ChannelBackup, error) {
+	return nil, status.Error(codes.Unimplemented, "method ExportChannelBackup not implemented")
+}
+
+func (unimplemented) ExportAllChannelBackups(context.Context, *lnrpc.ChanBackupExportRequest) (*lnrpc.ChanBackupSnapshot, error) {
+	return nil, status.Error(codes.Unimplemented, "method ExportAllChannelBackups not implemented")
+}
+
+func (unimplemented) VerifyChanBackup(context.Context, *lnrpc.ChanBackupSnapshot) (*lnrpc.VerifyChanBackupResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method VerifyChanBackup not implemented")
+}
+
+func (unimplemented) RestoreChannelBackups(context.Context, *lnrpc.RestoreChanBackupRequest) (*lnrpc.RestoreBackupResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method RestoreChannelBackups not implemented")
+}
+
+func (unimplemented) SubscribeChannelBackups(*lnrpc.ChannelBackupSubscription, lnrpc.Lightning_SubscribeChannelBackupsServer) error {
+	return status.Error(codes.Unimplemented, "method SubscribeChannelBackups not implemented")
+}
+
+func (unimplemented) BakeMacaroon(context.Context, *lnrpc.BakeMacaroonRequest) (*lnrpc.BakeMacaroonResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method BakeMacaroon not implemented")
+}
+
+// unimplementedRouter answers every routerrpc.RouterServer method the fake does not model with codes.Unimplemented.
+type unimplementedRouter struct{}
+
+func (unimplementedRouter) SendPaymentV2(*routerrpc.SendPaymentRequest, routerrpc.Router_SendPaymentV2Server) error {
+	return status.Error(codes.Unimplemented, "method SendPaymentV2 not implemented")
+}
+
+func (unimplementedRouter) TrackPaymentV2(*routerrpc.TrackPaymentRequest, routerrpc.Router_TrackPaymentV2Server) error {
+	return status.Error(codes.Unimplemented, "method TrackPaymentV2 not implemented")
+}
+
+func (unimplementedRouter) EstimateRouteFee(context.Context, *routerrpc.RouteFeeRequest) (*routerrpc.RouteFeeResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method EstimateRouteFee not implemented")
+}
+
+func (unimplementedRouter) SendToRoute(context.Context, *routerrpc.SendToRouteRequest) (*routerrpc.SendToRouteResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method SendToRoute not implemented")
+}
+
+func (unimplementedRouter) ResetMissionControl(context.Context, *routerrpc.ResetMissionControlRequest) (*routerrpc.ResetMissionControlResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ResetMissionControl not implemented")
+}
+
+func (unimplementedRouter) QueryMissionControl(context.Context, *routerrpc.QueryMissionControlRequest) (*routerrpc.QueryMissionControlResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method QueryMissionControl not implemented")
+}
+
+func (unimplementedRouter) QueryProbability(context.Context, *routerrpc.QueryProbabilityRequest) (*routerrpc.QueryProbabilityResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method QueryProbability not implemented")
+}
+
+func (unimplementedRouter) BuildRoute(context.Context, *routerrpc.BuildRouteRequest) (*routerrpc.BuildRouteResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method BuildRoute not implemented")
+}
+
+func (unimplementedRouter) SubscribeHtlcEvents(*routerrpc.SubscribeHtlcEventsRequest, routerrpc.Router_SubscribeHtlcEventsServer) error {
+	return status.Error(codes.Unimplemented, "method SubscribeHtlcEvents not implemented")
+}
+
+func (unimplementedRouter) SendPayment(*routerrpc.SendPaymentRequest, routerrpc.Router_SendPaymentServer) error {
+	return status.Error(codes.Unimplemented, "method SendPayment not implemented")
+}
+
+func (unimplementedRouter) TrackPayment(*routerrpc.TrackPaymentRequest, routerrpc.Router_TrackPaymentServer) error {
+	return status.Error(codes.Unimplemented, "method TrackPayment not implemented")
+}
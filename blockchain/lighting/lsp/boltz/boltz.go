@@ -5,9 +5,7 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
-	"encoding/json"
 	"fmt"
-	"net/http"
 	"strings"
 
 	"github.com/btcsuite/btcd/btcec"
@@ -22,19 +20,16 @@ import (
 )
 
 const (
-	getPairsEndpoint      = "/getpairs"
-	getfeeestimation      = "/getfeeestimation"
-	createSwapEndpoint    = "/createswap"
-	swapStatusEndpoint    = "/swapstatus"
-	claimWitnessInputSize = 1 + 1 + 8 + 73 + 1 + 32 + 1 + 100
-	NormalSwaps           = "submarine"
-	ReverseSwaps          = "reversesubmarine"
+	getPairsEndpoint             = "/getpairs"
+	getFeeEstimationEndpoint     = "/getfeeestimation"
+	createSwapEndpoint           = "/createswap"
+	swapStatusEndpoint           = "/swapstatus"
+	broadcastTransactionEndpoint = "/broadcasttransaction"
+	claimWitnessInputSize        = 1 + 1 + 8 + 73 + 1 + 32 + 1 + 100
+	NormalSwaps                  = "submarine"
+	ReverseSwaps                 = "reversesubmarine"
 )
 
-var apiURL = "https://boltz.exchange/api"
-
-var chain = &chaincfg.MainNetParams
-
 type BadRequestError string
 
 type StatusInfo struct {
@@ -42,8 +37,8 @@ type StatusInfo struct {
 	Transaction struct {
 		ID  string `json:"id"`
 		Hex string `json:"hex"`
-		ETA int32  `json:"eta", omitempty`
-	} `json:"transaction", omitempty`
+		ETA int    `json:"eta,omitempty"`
+	} `json:"transaction,omitempty"`
 }
 
 type ReverseSwap struct {
@@ -126,116 +121,33 @@ type RSResponse struct {
 	LockupAddress string `json:"lockupAddress"`
 }
 
-type Fees struct {
-	fee map[string]int32
-}
+// Fees are fee rates in sat/vbyte by currency.
+type Fees map[string]int64
 
 func (e *BadRequestError) Error() string {
 	return string(*e)
 }
 
-func TestNet() {
-	apiURL = "https://testnet.boltz.exchange/api"
-	chain = &chaincfg.TestNet3Params
-}
-
-func GetFees() (fees *Fees, err error) {
-	//It is important to mention that if 0-conf wants to be used with normal swaps,
-	//the lockup transaction has to have at least 80% of the recommended sat/vbyte value.
-	resp, err := http.Get(apiURL + getfeeestimation)
-
-	if err != nil {
-		return nil, fmt.Errorf("getfeeestimation get %v: %w", apiURL+getfeeestimation, err)
-	}
-	defer resp.Body.Close()
-
-	err = json.NewDecoder(resp.Body).Decode(&fees)
-	return fees, err
-}
-
-func GetSwapInfo(id string) (*http.Response, error) {
-	buffer := new(bytes.Buffer)
-	json.NewEncoder(buffer).Encode(struct {
-		ID string `json:"id"`
-	}{ID: id})
-
-	resp, err := http.Post(apiURL+swapStatusEndpoint, "application/json", buffer)
-	if err != nil {
-		err = fmt.Errorf("swapstatus post %v: %w", apiURL+swapStatusEndpoint, err)
-		return nil, err
-	}
-
-	defer resp.Body.Close()
-
-	return resp, nil
-}
-
-func GetReverseSwapInfo() (pairinfo *PairsInfo, err error) {
-	resp, err := http.Get(apiURL + getPairsEndpoint)
-	if err != nil {
-		return nil, fmt.Errorf("getpairs get %v: %w", apiURL+getPairsEndpoint, err)
-	}
-
-	defer resp.Body.Close()
-
-	err = json.NewDecoder(resp.Body).Decode(&pairinfo)
-	if err != nil {
-		return nil, fmt.Errorf("json decode (status: %v): %w", resp.Status, err)
-	}
-
-	return pairinfo, err
-}
-
-func createReverseSwap(amt int64, preimage []byte, key *btcec.PrivateKey) (*RSResponse, error) {
+func (c *Client) createReverseSwap(amt int64, preimage []byte, key *btcec.PrivateKey) (*RSResponse, error) {
 	h := sha256.Sum256(preimage)
-	buffer := new(bytes.Buffer)
-	err := json.NewEncoder(buffer).Encode(struct {
-		Type           string `json:"type"`
-		PairID         string `json:"pairId"`
-		OrderSide      string `json:"orderSide"`
-		InvoiceAmount  int64  `json:"invoiceAmount"`
-		PreimageHash   string `json:"preimageHash"`
-		ClaimPublicKey string `json:"claimPublicKey"`
-	}{
-		Type:           "reversesubmarine",
-		PairID:         "BTC/BTC",
-		OrderSide:      "buy",
+	var rs RSResponse
+	err := c.post(createSwapEndpoint, RSRequest{
+		BaseRequest: BaseRequest{
+			SwapType:  ReverseSwaps,
+			PairId:    "BTC/BTC",
+			OrderSide: "buy",
+		},
 		InvoiceAmount:  amt,
 		PreimageHash:   hex.EncodeToString(h[:]),
 		ClaimPublicKey: hex.EncodeToString(key.PubKey().SerializeCompressed()),
-	})
+	}, &rs)
 	if err != nil {
-		return nil, fmt.Errorf("json encode %v, %v, %v: %w", amt, preimage, key, err)
-	}
-
-	resp, err := http.Post(apiURL+createSwapEndpoint, "application/json", buffer)
-	if err != nil {
-		return nil, fmt.Errorf("createswap post %v: %w", apiURL+createSwapEndpoint, err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
-		e := struct {
-			Error string `json:"error"`
-		}{}
-		err = json.NewDecoder(resp.Body).Decode(&e)
-		if err != nil {
-			return nil, fmt.Errorf("json decode (status: %v): %w", resp.Status, err)
-		}
-		badRequestError := BadRequestError(e.Error)
-		return nil, fmt.Errorf("createswap result (status: %v) %w", resp.Status, &badRequestError)
-	}
-
-	var rs boltzReverseSwap
-	err = json.NewDecoder(resp.Body).Decode(&rs)
-	if err != nil {
-		return nil, fmt.Errorf("json decode (status ok): %w", err)
+		return nil, err
 	}
-
 	return &rs, nil
 }
 
-func checkReverseSwap(preimage []byte, key *btcec.PrivateKey, rs *RSResponse) error {
+func (c *Client) checkReverseSwap(preimage []byte, key *btcec.PrivateKey, rs *RSResponse) error {
 	script, err := hex.DecodeString(rs.RedeemScript)
 	if err != nil {
 		return fmt.Errorf("hex.DecodeString %v: %w", rs.RedeemScript, err)
@@ -257,7 +169,7 @@ func checkReverseSwap(preimage []byte, key *btcec.PrivateKey, rs *RSResponse) er
 	if s != dis {
 		return fmt.Errorf("bad script")
 	}
-	a, err := addressWitnessScriptHash(script, chain)
+	a, err := addressWitnessScriptHash(script, c.Chain)
 	if err != nil {
 		return fmt.Errorf("addressWitnessScriptHash %v: %w", script, err)
 	}
@@ -288,7 +200,7 @@ func getPrivate() (*btcec.PrivateKey, error) {
 }
 
 // NewReverseSwap begins the reverse submarine process.
-func NewReverseSwap(amt btcutil.Amount) (*ReverseSwap, error) {
+func (c *Client) NewReverseSwap(amt btcutil.Amount) (*ReverseSwap, error) {
 	preimage := getPreimage()
 
 	key, err := getPrivate()
@@ -296,12 +208,12 @@ func NewReverseSwap(amt btcutil.Amount) (*ReverseSwap, error) {
 		return nil, fmt.Errorf("getPrivate: %w", err)
 	}
 
-	rs, err := createReverseSwap(int64(amt), preimage, key)
+	rs, err := c.createReverseSwap(int64(amt), preimage, key)
 	if err != nil {
 		return nil, fmt.Errorf("createReverseSwap amt:%v, preimage:%x, key:%x; %w", amt, preimage, key, err)
 	}
 
-	err = checkReverseSwap(preimage, key, rs)
+	err = c.checkReverseSwap(preimage, key, rs)
 	if err != nil {
 		return nil, fmt.Errorf("checkReverseSwap preimage:%x, key:%x, %#v; %w", preimage, key, rs, err)
 	}
@@ -310,7 +222,7 @@ func NewReverseSwap(amt btcutil.Amount) (*ReverseSwap, error) {
 }
 
 // CheckTransaction checks that the transaction corresponds to the adresss and amount
-func CheckTransaction(transactionHex, lockupAddress string, amt int64) (string, error) {
+func (c *Client) CheckTransaction(transactionHex, lockupAddress string, amt int64) (string, error) {
 	txSerialized, err := hex.DecodeString(transactionHex)
 	if err != nil {
 		return "", fmt.Errorf("hex.DecodeString(%v): %w", transactionHex, err)
@@ -321,7 +233,7 @@ func CheckTransaction(transactionHex, lockupAddress string, amt int64) (string,
 	}
 	var out *wire.OutPoint
 	for i, txout := range tx.MsgTx().TxOut {
-		class, addresses, requiredsigs, err := txscript.ExtractPkScriptAddrs(txout.PkScript, chain)
+		class, addresses, requiredsigs, err := txscript.ExtractPkScriptAddrs(txout.PkScript, c.Chain)
 		if err != nil {
 			return "", fmt.Errorf("txscript.ExtractPkScriptAddrs(%x) %w", txout.PkScript, err)
 		}
@@ -339,40 +251,9 @@ func CheckTransaction(transactionHex, lockupAddress string, amt int64) (string,
 }
 
 // GetTransaction return the transaction after paying the ln invoice
-func GetTransaction(id, lockupAddress string, amt int64) (status, txid, tx string, eta int, err error) {
-	buffer := new(bytes.Buffer)
-	err = json.NewEncoder(buffer).Encode(struct {
-		ID string `json:"id"`
-	}{ID: id})
-	if err != nil {
-		err = fmt.Errorf("json encode %v: %w", id, err)
-		return
-	}
-	resp, err := http.Post(apiURL+swapStatusEndpoint, "application/json", buffer)
+func (c *Client) GetTransaction(id, lockupAddress string, amt int64) (status, txid, tx string, eta int, err error) {
+	ts, err := c.SwapStatus(id)
 	if err != nil {
-		err = fmt.Errorf("swapstatus post %v: %w", apiURL+swapStatusEndpoint, err)
-		return
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		e := struct {
-			Error string `json:"error"`
-		}{}
-		err = json.NewDecoder(resp.Body).Decode(&e)
-		if err != nil {
-			err = fmt.Errorf("json decode (status: %v): %w", resp.Status, err)
-			return
-		}
-		badRequestError := BadRequestError(e.Error)
-		err = fmt.Errorf("createswap result (status: %v) %w", resp.Status, &badRequestError)
-		return
-	}
-
-	var ts transactionStatus
-	err = json.NewDecoder(resp.Body).Decode(&ts)
-	if err != nil {
-		err = fmt.Errorf("json decode (status ok): %w", err)
 		return
 	}
 	if ts.Status != "transaction.mempool" && ts.Status != "transaction.confirmed" {
@@ -382,7 +263,7 @@ func GetTransaction(id, lockupAddress string, amt int64) (status, txid, tx strin
 
 	if lockupAddress != "" {
 		var calculatedTxid string
-		calculatedTxid, err = CheckTransaction(ts.Transaction.Hex, lockupAddress, amt)
+		calculatedTxid, err = c.CheckTransaction(ts.Transaction.Hex, lockupAddress, amt)
 		if err != nil {
 			err = fmt.Errorf("CheckTransaction(%v, %v, %v): %w)", ts.Transaction.Hex, lockupAddress, amt, err)
 			return
@@ -400,8 +281,8 @@ func GetTransaction(id, lockupAddress string, amt int64) (status, txid, tx strin
 }
 
 //ClaimFees return the fees needed for the claimed transaction for a feePerKw
-func ClaimFee(claimAddress string, feePerKw int64) (int64, error) {
-	addr, err := btcutil.DecodeAddress(claimAddress, chain)
+func (c *Client) ClaimFee(claimAddress string, feePerKw int64) (int64, error) {
+	addr, err := btcutil.DecodeAddress(claimAddress, c.Chain)
 	if err != nil {
 		return 0, fmt.Errorf("btcutil.DecodeAddress(%v) %w", addr, err)
 	}
@@ -462,8 +343,9 @@ func claimTransaction(
 	return rawTx.Bytes(), nil
 }
 
-// ClaimTransaction returns the claim transaction to broadcast after sending it also to boltz
-func ClaimTransaction(
+// ClaimTransaction returns the claim transaction. It is not broadcast, callers save it first so
+// they can rebroadcast it, then broadcast it themselves and to Boltz with BroadcastTransaction.
+func (c *Client) ClaimTransaction(
 	redeemScript, transactionHex string,
 	claimAddress string,
 	preimage, key string,
@@ -482,14 +364,14 @@ func ClaimTransaction(
 	if err != nil {
 		return "", fmt.Errorf("hex.DecodeString(%v): %w", redeemScript, err)
 	}
-	lockupAddress, err := addressWitnessScriptHash(script, chain)
+	lockupAddress, err := addressWitnessScriptHash(script, c.Chain)
 	if err != nil {
 		return "", fmt.Errorf("addressWitnessScriptHash %v: %w", script, err)
 	}
 	var out *wire.OutPoint
 	var amt btcutil.Amount
 	for i, txout := range tx.MsgTx().TxOut {
-		class, addresses, requiredsigs, err := txscript.ExtractPkScriptAddrs(txout.PkScript, chain)
+		class, addresses, requiredsigs, err := txscript.ExtractPkScriptAddrs(txout.PkScript, c.Chain)
 		if err != nil {
 			return "", fmt.Errorf("txscript.ExtractPkScriptAddrs(%x) %w", txout.PkScript, err)
 		}
@@ -500,7 +382,14 @@ func ClaimTransaction(
 		}
 	}
 
-	addr, err := btcutil.DecodeAddress(claimAddress, chain)
+	if out == nil {
+		return "", fmt.Errorf("lockupAddress: %v not found in the transaction: %v", lockupAddress.EncodeAddress(), transactionHex)
+	}
+	if btcutil.Amount(fees) >= amt {
+		return "", fmt.Errorf("fees %v exceed the lockup amount %v", fees, int64(amt))
+	}
+
+	addr, err := btcutil.DecodeAddress(claimAddress, c.Chain)
 	if err != nil {
 		return "", fmt.Errorf("btcutil.DecodeAddress(%v) %w", claimAddress, err)
 	}
@@ -518,8 +407,5 @@ func ClaimTransaction(
 	if err != nil {
 		return "", fmt.Errorf("claimTransaction: %w", err)
 	}
-	ctxHex := hex.EncodeToString(ctx)
-	//Ignore the result of broadcasting the transaction via boltz
-	_, _ = broadcastTransaction(ctxHex)
-	return ctxHex, nil
+	return hex.EncodeToString(ctx), nil
 }
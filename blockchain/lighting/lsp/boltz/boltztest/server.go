@@ -0,0 +1,524 @@
+package boltztest
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/lightningnetwork/lnd/input"
+
+	"github.com/jualy007/GoTF/blockchain/lighting"
+	"github.com/jualy007/GoTF/blockchain/lighting/lsp/boltz"
+)
+
+// Defaults of the fake Boltz server.
+const (
+	DefaultBlockHeight = 500
+	// DefaultTimeout is the number of blocks until the timeout of new swaps.
+	DefaultTimeout = 144
+	// DefaultMinerFee is the on-chain fee in satoshis Boltz adds to submarine and takes off reverse swaps.
+	DefaultMinerFee = 1000
+	// DefaultFeeRate is the fee rate in sat/vbyte of getfeeestimation.
+	DefaultFeeRate = 2
+)
+
+// Net are the chain parameters of the swaps of fake servers.
+var Net = &chaincfg.RegressionNetParams
+
+// Swap is a swap created on a fake server. Key is the claim key of Boltz for a submarine swap
+// and its refund key for a reverse swap.
+type Swap struct {
+	ID                 string
+	Type               string
+	Status             boltz.StatusInfo
+	Invoice            string
+	PaymentHash        []byte
+	RedeemScript       []byte
+	TimeoutBlockHeight int64
+	Address            string
+	// Amount is the expected lockup of a submarine swap, the on-chain amount of a reverse swap.
+	Amount int64
+	Key    *btcec.PrivateKey
+}
+
+// Server is an httptest Boltz API serving getpairs, getfeeestimation, createswap, swapstatus,
+// streamswapstatus and broadcasttransaction. Swaps stay in the status they were created in until
+// SetStatus or Lockup.
+type Server struct {
+	*httptest.Server
+
+	key *btcec.PrivateKey
+
+	mu         sync.Mutex
+	height     int64
+	count      int
+	swaps      map[string]*Swap
+	broadcasts []string
+	// changed is closed and replaced on every status change, dropped to end the open streams.
+	changed chan struct{}
+	dropped chan struct{}
+}
+
+// NewServer starts a fake Boltz server.
+func NewServer() (*Server, error) {
+	key, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		key:     key,
+		height:  DefaultBlockHeight,
+		swaps:   make(map[string]*Swap),
+		changed: make(chan struct{}),
+		dropped: make(chan struct{}),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/getpairs", s.getPairs)
+	mux.HandleFunc("/getfeeestimation", s.getFeeEstimation)
+	mux.HandleFunc("/createswap", s.createSwap)
+	mux.HandleFunc("/swapstatus", s.swapStatus)
+	mux.HandleFunc("/streamswapstatus", s.streamSwapStatus)
+	mux.HandleFunc("/broadcasttransaction", s.broadcastTransaction)
+	s.Server = httptest.NewServer(mux)
+	return s, nil
+}
+
+// Close ends the open streams and shuts the server down.
+func (s *Server) Close() {
+	s.DropStreams()
+	s.Server.Close()
+}
+
+// DropStreams ends the open status streams, as a lost connection does.
+func (s *Server) DropStreams() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	close(s.dropped)
+	s.dropped = make(chan struct{})
+}
+
+// notify wakes the status streams up, s.mu is held.
+func (s *Server) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// Client returns a client of the server.
+func (s *Server) Client() *boltz.Client {
+	return boltz.NewClient(s.URL, Net, s.Server.Client())
+}
+
+// SetBlockHeight sets the height the timeouts of new swaps are relative to.
+func (s *Server) SetBlockHeight(height int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.height = height
+}
+
+// Swap returns a copy of the swap id, nil if there is none.
+func (s *Server) Swap(id string) *Swap {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	swap, ok := s.swaps[id]
+	if !ok {
+		return nil
+	}
+	c := *swap
+	return &c
+}
+
+// SetStatus sets the status swapstatus reports for the swap id, keeping its transaction.
+func (s *Server) SetStatus(id, status string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	swap, ok := s.swaps[id]
+	if !ok {
+		return fmt.Errorf("no swap %v", id)
+	}
+	swap.Status.Status = status
+	s.notify()
+	return nil
+}
+
+// SetTransaction sets the status and the transaction swapstatus reports for the swap id.
+func (s *Server) SetTransaction(id, status, txHex string) error {
+	raw, err := hex.DecodeString(txHex)
+	if err != nil {
+		return err
+	}
+	tx, err := btcutil.NewTxFromBytes(raw)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	swap, ok := s.swaps[id]
+	if !ok {
+		return fmt.Errorf("no swap %v", id)
+	}
+	swap.Status.Status = status
+	swap.Status.Transaction.ID = tx.Hash().String()
+	swap.Status.Transaction.Hex = txHex
+	s.notify()
+	return nil
+}
+
+// Lockup locks the on-chain amount of the reverse swap id up in a transaction from a random
+// outpoint, as Boltz does once the invoice is paid, and reports it in the mempool. It returns
+// the hex encoded transaction.
+func (s *Server) Lockup(id string) (string, error) {
+	swap := s.Swap(id)
+	if swap == nil {
+		return "", fmt.Errorf("no swap %v", id)
+	}
+	if swap.Type != boltz.ReverseSwaps {
+		return "", fmt.Errorf("swap %v is not a reverse swap", id)
+	}
+	address, err := btcutil.DecodeAddress(swap.Address, Net)
+	if err != nil {
+		return "", err
+	}
+	pkScript, err := txscript.PayToAddrScript(address)
+	if err != nil {
+		return "", err
+	}
+	var prevHash chainhash.Hash
+	rand.Read(prevHash[:])
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&prevHash, 0), nil, nil))
+	tx.AddTxOut(wire.NewTxOut(swap.Amount, pkScript))
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		return "", err
+	}
+	txHex := hex.EncodeToString(buf.Bytes())
+	return txHex, s.SetTransaction(id, boltz.StatusTransactionMempool, txHex)
+}
+
+// Broadcasts returns the hex encoded transactions broadcast through the server.
+func (s *Server) Broadcasts() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.broadcasts...)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError answers like Boltz: the message in the error field of a JSON object.
+func writeError(w http.ResponseWriter, status int, format string, a ...interface{}) {
+	writeJSON(w, status, map[string]string{"error": fmt.Sprintf(format, a...)})
+}
+
+func (s *Server) getPairs(w http.ResponseWriter, r *http.Request) {
+	minerFees := map[string]interface{}{
+		"normal":  DefaultMinerFee,
+		"reverse": map[string]int64{"lockup": DefaultMinerFee, "claim": DefaultMinerFee},
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"warnings": []string{},
+		"pairs": map[string]interface{}{
+			"BTC/BTC": map[string]interface{}{
+				"rate": 1,
+				"limits": map[string]interface{}{
+					"maximal":         10000000,
+					"minimal":         10000,
+					"maximalZeroConf": map[string]int64{"baseAsset": 10000000, "quoteAsset": 10000000},
+				},
+				"fees": map[string]interface{}{
+					"percentage": 0,
+					"minerFees":  map[string]interface{}{"baseAsset": minerFees, "quoteAsset": minerFees},
+				},
+			},
+		},
+	})
+}
+
+func (s *Server) getFeeEstimation(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, boltz.Fees{"BTC": DefaultFeeRate})
+}
+
+func (s *Server) createSwap(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		boltz.BaseRequest
+		Invoice         string `json:"invoice"`
+		RefundPublicKey string `json:"refundPublicKey"`
+		InvoiceAmount   int64  `json:"invoiceAmount"`
+		PreimageHash    string `json:"preimageHash"`
+		ClaimPublicKey  string `json:"claimPublicKey"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+	if req.PairId != "BTC/BTC" {
+		writeError(w, http.StatusBadRequest, "could not find pair with id: %v", req.PairId)
+		return
+	}
+	key, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+
+	s.mu.Lock()
+	s.count++
+	id := fmt.Sprintf("swap%d", s.count)
+	timeout := s.height + DefaultTimeout
+	s.mu.Unlock()
+
+	swap := &Swap{ID: id, Type: req.SwapType, TimeoutBlockHeight: timeout, Key: key}
+	var resp interface{}
+	switch req.SwapType {
+	case boltz.NormalSwaps:
+		resp, err = s.submarine(swap, req.Invoice, req.RefundPublicKey)
+	case boltz.ReverseSwaps:
+		resp, err = s.reverse(swap, req.InvoiceAmount, req.PreimageHash, req.ClaimPublicKey)
+	default:
+		err = fmt.Errorf("swap type not supported: %v", req.SwapType)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+
+	s.mu.Lock()
+	s.swaps[id] = swap
+	s.mu.Unlock()
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+func (s *Server) submarine(swap *Swap, payReq, refundPublicKey string) (*boltz.SResponse, error) {
+	invoice, err := lighting.DecodeInvoice(payReq, Net)
+	if err != nil {
+		return nil, err
+	}
+	if invoice.AmountMsat == 0 {
+		return nil, fmt.Errorf("invoice has no amount")
+	}
+	paymentHash, err := hex.DecodeString(invoice.PaymentHash)
+	if err != nil {
+		return nil, err
+	}
+	refundKey, err := parseKey(refundPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	script, err := txscript.NewScriptBuilder().
+		AddOp(txscript.OP_HASH160).AddData(input.Ripemd160H(paymentHash)).AddOp(txscript.OP_EQUAL).
+		AddOp(txscript.OP_IF).
+		AddData(swap.Key.PubKey().SerializeCompressed()).
+		AddOp(txscript.OP_ELSE).
+		AddInt64(swap.TimeoutBlockHeight).AddOp(txscript.OP_CHECKLOCKTIMEVERIFY).AddOp(txscript.OP_DROP).
+		AddData(refundKey).
+		AddOp(txscript.OP_ENDIF).AddOp(txscript.OP_CHECKSIG).
+		Script()
+	if err != nil {
+		return nil, err
+	}
+	address, err := witnessScriptAddress(script)
+	if err != nil {
+		return nil, err
+	}
+
+	swap.Status.Status = boltz.StatusInvoiceSet
+	swap.Invoice, swap.PaymentHash, swap.RedeemScript, swap.Address = payReq, paymentHash, script, address
+	swap.Amount = invoice.AmountMsat/1000 + DefaultMinerFee
+	return &boltz.SResponse{
+		BaseResponse: boltz.BaseResponse{
+			ID:                 swap.ID,
+			RedeemScript:       hex.EncodeToString(script),
+			TimeoutBlockHeight: swap.TimeoutBlockHeight,
+		},
+		AcceptZeroConf: true,
+		Address:        address,
+		ExpectedAmount: swap.Amount,
+		Bip21:          fmt.Sprintf("bitcoin:%v?amount=%v", address, btcutil.Amount(swap.Amount).ToBTC()),
+	}, nil
+}
+
+func (s *Server) reverse(swap *Swap, invoiceAmount int64, preimageHash, claimPublicKey string) (*boltz.RSResponse, error) {
+	if invoiceAmount <= DefaultMinerFee {
+		return nil, fmt.Errorf("invoice amount %v below the miner fee", invoiceAmount)
+	}
+	paymentHash, err := hex.DecodeString(preimageHash)
+	if err != nil || len(paymentHash) != sha256.Size {
+		return nil, fmt.Errorf("invalid preimage hash: %v", preimageHash)
+	}
+	claimKey, err := parseKey(claimPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	script, err := txscript.NewScriptBuilder().
+		AddOp(txscript.OP_SIZE).AddInt64(32).AddOp(txscript.OP_EQUAL).
+		AddOp(txscript.OP_IF).
+		AddOp(txscript.OP_HASH160).AddData(input.Ripemd160H(paymentHash)).AddOp(txscript.OP_EQUALVERIFY).
+		AddData(claimKey).
+		AddOp(txscript.OP_ELSE).
+		AddOp(txscript.OP_DROP).
+		AddInt64(swap.TimeoutBlockHeight).AddOp(txscript.OP_CHECKLOCKTIMEVERIFY).AddOp(txscript.OP_DROP).
+		AddData(swap.Key.PubKey().SerializeCompressed()).
+		AddOp(txscript.OP_ENDIF).AddOp(txscript.OP_CHECKSIG).
+		Script()
+	if err != nil {
+		return nil, err
+	}
+	address, err := witnessScriptAddress(script)
+	if err != nil {
+		return nil, err
+	}
+	invoice, err := lighting.EncodeInvoice(&lighting.Invoice{
+		Net:         Net,
+		PaymentHash: preimageHash,
+		AmountMsat:  invoiceAmount * 1000,
+		Description: "Reverse Swap " + swap.ID,
+		Timestamp:   time.Now(),
+		Expiry:      3600,
+		CltvExpiry:  40,
+	}, s.key)
+	if err != nil {
+		return nil, err
+	}
+
+	swap.Status.Status = boltz.StatusSwapCreated
+	swap.Invoice, swap.PaymentHash, swap.RedeemScript, swap.Address = invoice, paymentHash, script, address
+	swap.Amount = invoiceAmount - DefaultMinerFee
+	return &boltz.RSResponse{
+		BaseResponse: boltz.BaseResponse{
+			ID:                 swap.ID,
+			RedeemScript:       hex.EncodeToString(script),
+			TimeoutBlockHeight: swap.TimeoutBlockHeight,
+		},
+		Invoice:       invoice,
+		OnchainAmount: swap.Amount,
+		LockupAddress: address,
+	}, nil
+}
+
+func parseKey(publicKey string) ([]byte, error) {
+	key, err := hex.DecodeString(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %v", publicKey)
+	}
+	if _, err := btcec.ParsePubKey(key, btcec.S256()); err != nil {
+		return nil, fmt.Errorf("invalid public key: %v", publicKey)
+	}
+	return key, nil
+}
+
+func witnessScriptAddress(script []byte) (string, error) {
+	witnessProg := sha256.Sum256(script)
+	address, err := btcutil.NewAddressWitnessScriptHash(witnessProg[:], Net)
+	if err != nil {
+		return "", err
+	}
+	return address.EncodeAddress(), nil
+}
+
+func (s *Server) swapStatus(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+	s.mu.Lock()
+	swap, ok := s.swaps[req.ID]
+	var status boltz.StatusInfo
+	if ok {
+		status = swap.Status
+	}
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "could not find swap with id: %v", req.ID)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// streamSwapStatus sends the status of the swap as a server-sent event on connection and after every change.
+func (s *Server) streamSwapStatus(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if s.Swap(id) == nil {
+		writeError(w, http.StatusNotFound, "could not find swap with id: %v", id)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	for {
+		s.mu.Lock()
+		status := s.swaps[id].Status
+		changed, dropped := s.changed, s.dropped
+		s.mu.Unlock()
+
+		data, err := json.Marshal(status)
+		if err != nil {
+			return
+		}
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-changed:
+		case <-dropped:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *Server) broadcastTransaction(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Currency       string `json:"currency"`
+		TransactionHex string `json:"transactionHex"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+	if req.Currency != "BTC" {
+		writeError(w, http.StatusBadRequest, "could not find currency: %v", req.Currency)
+		return
+	}
+	raw, err := hex.DecodeString(req.TransactionHex)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "TX decode failed")
+		return
+	}
+	tx, err := btcutil.NewTxFromBytes(raw)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "TX decode failed")
+		return
+	}
+	s.mu.Lock()
+	s.broadcasts = append(s.broadcasts, req.TransactionHex)
+	s.mu.Unlock()
+	writeJSON(w, http.StatusCreated, map[string]string{"transactionId": tx.Hash().String()})
+}
@@ -0,0 +1,132 @@
+package boltz
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// Boltz API endpoints of mainnet and testnet.
+const (
+	MainnetURL = "https://boltz.exchange/api"
+	TestnetURL = "https://testnet.boltz.exchange/api"
+)
+
+// DefaultTimeout limits the calls of a Client created without an HTTP client.
+const DefaultTimeout = 30 * time.Second
+
+// Client calls the Boltz API at URL for swaps on Chain.
+type Client struct {
+	URL        string
+	Chain      *chaincfg.Params
+	HTTPClient *http.Client
+}
+
+// NewClient returns a client of the API at url for swaps on chain. A nil httpClient is replaced
+// by one with DefaultTimeout.
+func NewClient(url string, chain *chaincfg.Params, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: DefaultTimeout}
+	}
+	return &Client{URL: url, Chain: chain, HTTPClient: httpClient}
+}
+
+// get decodes the response of a GET of endpoint into out.
+func (c *Client) get(endpoint string, out interface{}) error {
+	resp, err := c.HTTPClient.Get(c.URL + endpoint)
+	if err != nil {
+		return fmt.Errorf("%v get %v: %w", endpoint[1:], c.URL+endpoint, err)
+	}
+	defer resp.Body.Close()
+	return decodeResponse(endpoint, resp, out)
+}
+
+// post posts in as JSON to endpoint and decodes the response into out.
+func (c *Client) post(endpoint string, in interface{}, out interface{}) error {
+	buffer := new(bytes.Buffer)
+	err := json.NewEncoder(buffer).Encode(in)
+	if err != nil {
+		return fmt.Errorf("json encode %#v: %w", in, err)
+	}
+	resp, err := c.HTTPClient.Post(c.URL+endpoint, "application/json", buffer)
+	if err != nil {
+		return fmt.Errorf("%v post %v: %w", endpoint[1:], c.URL+endpoint, err)
+	}
+	defer resp.Body.Close()
+	return decodeResponse(endpoint, resp, out)
+}
+
+func decodeResponse(endpoint string, resp *http.Response, out interface{}) error {
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return responseError(endpoint[1:], resp)
+	}
+	err := json.NewDecoder(resp.Body).Decode(out)
+	if err != nil {
+		return fmt.Errorf("json decode (status ok): %w", err)
+	}
+	return nil
+}
+
+// responseError returns the error of a failed Boltz call as a BadRequestError.
+func responseError(endpoint string, resp *http.Response) error {
+	e := struct {
+		Error string `json:"error"`
+	}{}
+	err := json.NewDecoder(resp.Body).Decode(&e)
+	if err != nil {
+		return fmt.Errorf("json decode (status: %v): %w", resp.Status, err)
+	}
+	badRequestError := BadRequestError(e.Error)
+	return fmt.Errorf("%v result (status: %v) %w", endpoint, resp.Status, &badRequestError)
+}
+
+// GetPairs returns the rates, limits and fees of the pairs Boltz swaps.
+func (c *Client) GetPairs() (*PairsInfo, error) {
+	var pairs PairsInfo
+	if err := c.get(getPairsEndpoint, &pairs); err != nil {
+		return nil, err
+	}
+	return &pairs, nil
+}
+
+// GetFees returns the fee rates Boltz estimates in sat/vbyte by currency. It is important to mention
+// that if 0-conf wants to be used with normal swaps, the lockup transaction has to have at least 80%
+// of the recommended sat/vbyte value.
+func (c *Client) GetFees() (Fees, error) {
+	var fees Fees
+	if err := c.get(getFeeEstimationEndpoint, &fees); err != nil {
+		return nil, err
+	}
+	return fees, nil
+}
+
+// SwapStatus returns the current status of the swap id.
+func (c *Client) SwapStatus(id string) (*StatusInfo, error) {
+	var status StatusInfo
+	err := c.post(swapStatusEndpoint, struct {
+		ID string `json:"id"`
+	}{ID: id}, &status)
+	if err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// BroadcastTransaction broadcasts the hex encoded bitcoin transaction through Boltz and returns its id.
+func (c *Client) BroadcastTransaction(transactionHex string) (string, error) {
+	var result struct {
+		TransactionID string `json:"transactionId"`
+	}
+	err := c.post(broadcastTransactionEndpoint, struct {
+		Currency       string `json:"currency"`
+		TransactionHex string `json:"transactionHex"`
+	}{"BTC", transactionHex}, &result)
+	if err != nil {
+		return "", err
+	}
+	return result.TransactionID, nil
+}
@@ -0,0 +1,143 @@
+package boltz_test
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcutil"
+
+	"github.com/jualy007/GoTF/blockchain/lighting/lsp/boltz"
+	"github.com/jualy007/GoTF/blockchain/lighting/lsp/boltz/boltztest"
+)
+
+func TestGetPairsAndFees(t *testing.T) {
+	server, err := boltztest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	client := server.Client()
+
+	pairs, err := client.GetPairs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := pairs.Pairs["BTC/BTC"]; !ok {
+		t.Errorf("GetPairs = %+v, want a BTC/BTC pair", pairs)
+	}
+	fees, err := client.GetFees()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fees["BTC"] != boltztest.DefaultFeeRate {
+		t.Errorf("GetFees = %v", fees)
+	}
+	var badRequest *boltz.BadRequestError
+	if _, err := client.SwapStatus("unknown"); !errors.As(err, &badRequest) {
+		t.Errorf("SwapStatus of an unknown swap: %v", err)
+	}
+}
+
+func TestReverseSwapClaim(t *testing.T) {
+	server, err := boltztest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	client := server.Client()
+
+	rs, err := client.NewReverseSwap(100000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rs.OnchainAmount != 100000-boltztest.DefaultMinerFee {
+		t.Errorf("on-chain amount %v", rs.OnchainAmount)
+	}
+	lockup, err := server.Lockup(rs.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err := client.SwapStatus(rs.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Status != boltz.StatusTransactionMempool || status.Transaction.Hex != lockup {
+		t.Fatalf("SwapStatus = %+v after the lockup", status)
+	}
+	if _, err := client.CheckTransaction(lockup, rs.LockupAddress, rs.OnchainAmount); err != nil {
+		t.Fatalf("CheckTransaction: %v", err)
+	}
+	if _, err := client.CheckTransaction(lockup, rs.LockupAddress, rs.OnchainAmount+1); err == nil {
+		t.Error("CheckTransaction accepted a lockup short of the amount")
+	}
+
+	address, err := btcutil.NewAddressWitnessPubKeyHash(make([]byte, 20), boltztest.Net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fee, err := client.ClaimFee(address.EncodeAddress(), boltztest.DefaultFeeRate*250)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claim, err := client.ClaimTransaction(rs.RedeemScript, lockup, address.EncodeAddress(), rs.Preimage, rs.Key, fee)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify(t, lockup, claim)
+	if len(server.Broadcasts()) != 0 {
+		t.Error("ClaimTransaction broadcast the claim")
+	}
+
+	// Fees of the whole lockup leave no output to claim.
+	for _, fees := range []int64{rs.OnchainAmount, rs.OnchainAmount + 1} {
+		if claim, err := client.ClaimTransaction(rs.RedeemScript, lockup, address.EncodeAddress(), rs.Preimage, rs.Key, fees); err == nil {
+			t.Errorf("ClaimTransaction with fees %v of the lockup %v = %v", fees, rs.OnchainAmount, claim)
+		}
+	}
+
+	// Any other preimage does not unlock the lockup.
+	wrong := make([]byte, 32)
+	claim, err = client.ClaimTransaction(rs.RedeemScript, lockup, address.EncodeAddress(), hex.EncodeToString(wrong), rs.Key, fee)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := execute(lockup, claim); err == nil {
+		t.Error("claim with a wrong preimage is valid")
+	}
+}
+
+func TestSubmarineSwapRefund(t *testing.T) {
+	h := newHarness(t)
+	swap := h.submarineSwap()
+	record := h.step(swap.ID, boltz.StateLockupSeen)
+	lockup := h.chain.txs[record.LockupTxid]
+	if created := h.server.Swap(swap.ID); created.Amount != record.Amount || created.Address != record.LockupAddress {
+		t.Fatalf("swap %+v funded as %+v", created, record)
+	}
+
+	address, err := h.wallet.NewAddress(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fee, err := h.client.RefundFee(address, false, boltztest.DefaultFeeRate*250)
+	if err != nil {
+		t.Fatal(err)
+	}
+	refund, err := h.client.RefundTransaction(record.RedeemScript, lockup, address, record.Key, fee)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify(t, lockup, refund)
+
+	// The refund is only valid from the timeout on.
+	raw, _ := hex.DecodeString(refund)
+	tx, err := btcutil.NewTxFromBytes(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(tx.MsgTx().LockTime) != swap.TimeoutBlockHeight {
+		t.Errorf("refund locked until %v, want the timeout %v", tx.MsgTx().LockTime, swap.TimeoutBlockHeight)
+	}
+}
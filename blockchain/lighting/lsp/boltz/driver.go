@@ -0,0 +1,243 @@
+package boltz
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+	"github.com/btcsuite/btcutil"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/routerrpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/jualy007/GoTF/blockchain/btc"
+	"github.com/jualy007/GoTF/blockchain/lighting"
+)
+
+// Defaults of the payments of LndWallet.
+const (
+	DefaultPaymentTimeout  = 60
+	DefaultPaymentFeeLimit = 1000
+)
+
+// BitcoindChain is the Chain of a bitcoind node, with the fee rates Boltz estimates.
+type BitcoindChain struct {
+	client   *Client
+	bitcoind *btc.Bitcoind
+}
+
+func NewBitcoindChain(client *Client, bitcoind *btc.Bitcoind) *BitcoindChain {
+	return &BitcoindChain{client: client, bitcoind: bitcoind}
+}
+
+// BlockHeight returns the block count of the node.
+func (c *BitcoindChain) BlockHeight(ctx context.Context) (int64, error) {
+	count, err := c.bitcoind.GetBlockCount()
+	if err != nil {
+		return 0, fmt.Errorf("getblockcount: %w", err)
+	}
+	return int64(count), nil
+}
+
+// FeePerKw returns the BTC fee rate of GetFees in satoshis per kiloweight.
+func (c *BitcoindChain) FeePerKw(ctx context.Context) (int64, error) {
+	fees, err := c.client.GetFees()
+	if err != nil {
+		return 0, fmt.Errorf("GetFees: %w", err)
+	}
+	satPerVbyte, ok := fees["BTC"]
+	if !ok || satPerVbyte <= 0 {
+		return 0, fmt.Errorf("no BTC fee estimation in %v", fees)
+	}
+	// A kiloweight is 250 virtual bytes.
+	return satPerVbyte * 250, nil
+}
+
+// Transaction returns the hex encoded transaction txid, from the mempool or the wallet of the node
+// unless it runs with txindex.
+func (c *BitcoindChain) Transaction(ctx context.Context, txid string) (string, error) {
+	raw, err := c.bitcoind.GetRawTransaction(txid, false)
+	if err != nil {
+		return "", fmt.Errorf("getrawtransaction %v: %w", txid, err)
+	}
+	txHex, ok := raw.(string)
+	if !ok {
+		return "", fmt.Errorf("getrawtransaction %v: unexpected result %v", txid, raw)
+	}
+	return txHex, nil
+}
+
+// Broadcast sends the transaction with sendrawtransaction.
+func (c *BitcoindChain) Broadcast(ctx context.Context, txHex string) (string, error) {
+	txid, err := c.bitcoind.SendRawTransaction(txHex)
+	if err != nil {
+		return "", fmt.Errorf("sendrawtransaction: %w", err)
+	}
+	return txid, nil
+}
+
+// LndWallet is the Wallet of the lnd of an adapter. Payments pay at most FeeLimitSat of routing
+// fees and locked up amounts are sent at SatPerByte, estimated by lnd if 0.
+type LndWallet struct {
+	adapter     *lighting.Adapter
+	FeeLimitSat int64
+	SatPerByte  int64
+}
+
+func NewLndWallet(adapter *lighting.Adapter) *LndWallet {
+	return &LndWallet{adapter: adapter, FeeLimitSat: DefaultPaymentFeeLimit}
+}
+
+// PayInvoice returns once the payment is in flight. The invoices of reverse swaps are hold invoices
+// Boltz only settles after the claim, the payment is followed in the background until then and
+// a later failure is reported by PaymentFailed.
+func (w *LndWallet) PayInvoice(ctx context.Context, invoice string) error {
+	started := make(chan error, 1)
+	go func() {
+		payment, err := w.adapter.SendPaymentV2(context.Background(), &routerrpc.SendPaymentRequest{
+			PaymentRequest: invoice,
+			TimeoutSeconds: DefaultPaymentTimeout,
+			FeeLimitSat:    w.FeeLimitSat,
+		}, func(*lnrpc.Payment) {
+			select {
+			case started <- nil:
+			default:
+			}
+		})
+		select {
+		case started <- err:
+		default:
+			if err != nil {
+				logs.Warn("Swap payment %v failed: %v", invoice, err)
+			} else {
+				logs.Info("Swap payment %x settled", payment.PaymentHash)
+			}
+		}
+	}()
+
+	select {
+	case err := <-started:
+		// lnd refuses to pay an invoice twice, the earlier payment goes on.
+		if err != nil && (strings.Contains(err.Error(), "invoice is already paid") ||
+			strings.Contains(err.Error(), "payment is in transition")) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// PaymentFailed tracks the payment of the invoice with TrackPaymentV2 and returns its failure
+// reason if it failed. lnd not knowing the payment counts as failed, PayInvoice started it.
+func (w *LndWallet) PaymentFailed(ctx context.Context, invoice string) (string, error) {
+	decoded, err := lighting.DecodeInvoice(invoice, nil)
+	if err != nil {
+		return "", err
+	}
+	paymentHash, err := hex.DecodeString(decoded.PaymentHash)
+	if err != nil {
+		return "", err
+	}
+	var payment *lnrpc.Payment
+	err = w.adapter.TrackPaymentV2(ctx, paymentHash, func(p *lnrpc.Payment) error {
+		// The first update is the current state of the payment.
+		payment = p
+		return errTracked
+	})
+	if status.Code(err) == codes.NotFound {
+		return "payment not found", nil
+	}
+	if err != nil && err != errTracked {
+		return "", fmt.Errorf("TrackPaymentV2 %v: %w", decoded.PaymentHash, err)
+	}
+	if payment.Status == lnrpc.Payment_FAILED {
+		return payment.FailureReason.String(), nil
+	}
+	return "", nil
+}
+
+// errTracked stops tracking a payment after its current state.
+var errTracked = errors.New("payment tracked")
+
+// Send sends amount satoshis to address with SendCoins.
+func (w *LndWallet) Send(ctx context.Context, address string, amount int64) (string, error) {
+	return w.adapter.SendCoins(ctx, address, amount, w.SatPerByte)
+}
+
+// SentTo looks for a transaction of the lnd wallet spending to address.
+func (w *LndWallet) SentTo(ctx context.Context, address string) (string, error) {
+	txs, err := w.adapter.GetTransactions(ctx)
+	if err != nil {
+		return "", err
+	}
+	for _, tx := range txs.Transactions {
+		if tx.Amount >= 0 {
+			continue
+		}
+		for _, dest := range tx.DestAddresses {
+			if dest == address {
+				return tx.TxHash, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// NewAddress returns a new native segwit address of the lnd wallet.
+func (w *LndWallet) NewAddress(ctx context.Context) (string, error) {
+	return w.adapter.NewAddress(ctx, lnrpc.AddressType_WITNESS_PUBKEY_HASH)
+}
+
+// NewLndMachine returns a machine swapping with the lnd of adapter, following the chain and
+// broadcasting through bitcoind.
+func NewLndMachine(client *Client, store SwapStore, adapter *lighting.Adapter, bitcoind *btc.Bitcoind) *Machine {
+	return NewMachine(client, store, NewBitcoindChain(client, bitcoind), NewLndWallet(adapter))
+}
+
+// ReverseSwap swaps amt of lightning balance to the on-chain wallet: it creates and checks a reverse
+// swap, saves it, pays its invoice, waits for the lockup of Boltz and claims it. It returns the
+// claimed record, see Wait.
+func (m *Machine) ReverseSwap(ctx context.Context, amt btcutil.Amount) (*SwapRecord, error) {
+	rs, err := m.client.NewReverseSwap(amt)
+	if err != nil {
+		return nil, fmt.Errorf("NewReverseSwap: %w", err)
+	}
+	record := RecordReverseSwap(rs)
+	if err := m.Add(record); err != nil {
+		return nil, fmt.Errorf("save swap %v: %w", rs.ID, err)
+	}
+	return m.Wait(ctx, record.ID)
+}
+
+// Wait steps the swap id every Interval until it is final, retrying the steps that fail. A swap
+// that ends failed returns its record with a *SwapFailedError. The swap is left pending in the
+// store if ctx is done first.
+func (m *Machine) Wait(ctx context.Context, id string) (*SwapRecord, error) {
+	for {
+		record, err := m.Step(ctx, id)
+		if record == nil {
+			return nil, err
+		}
+		if record.State == StateFailed {
+			return record, &SwapFailedError{id, record.Error}
+		}
+		if record.State.Final() {
+			return record, nil
+		}
+		if err != nil {
+			logs.Warn("Swap %v in state %v: %v", id, record.State, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return record, ctx.Err()
+		case <-time.After(m.Interval):
+		}
+	}
+}
@@ -0,0 +1,419 @@
+package boltz
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+	"github.com/btcsuite/btcutil"
+)
+
+// Statuses of a reverse swap other than the ones shared with submarine swaps.
+const (
+	StatusMinerFeePaid        = "minerfee.paid"
+	StatusInvoiceSettled      = "invoice.settled"
+	StatusInvoiceExpired      = "invoice.expired"
+	StatusTransactionFailed   = "transaction.failed"
+	StatusTransactionRefunded = "transaction.refunded"
+)
+
+// DefaultMachineInterval is the default time between the steps of Machine.Run.
+const DefaultMachineInterval = 30 * time.Second
+
+// Chain is the bitcoin node a Machine follows the block height of and broadcasts through.
+type Chain interface {
+	BlockHeight(ctx context.Context) (int64, error)
+	// FeePerKw returns the fee rate of claim and refund transactions.
+	FeePerKw(ctx context.Context) (int64, error)
+	// Transaction returns the hex encoded transaction txid.
+	Transaction(ctx context.Context, txid string) (string, error)
+	// Broadcast broadcasts the hex encoded transaction and returns its txid.
+	Broadcast(ctx context.Context, txHex string) (string, error)
+}
+
+// Wallet is the node paying and receiving the swaps of a Machine.
+type Wallet interface {
+	// PayInvoice starts paying the invoice of a reverse swap without waiting for it to settle, Boltz
+	// settles it after the claim. Paying an invoice already paid or in flight must not pay it again.
+	PayInvoice(ctx context.Context, invoice string) error
+	// PaymentFailed returns why the payment of the invoice failed for good, empty while it is in
+	// flight or once it settled.
+	PaymentFailed(ctx context.Context, invoice string) (string, error)
+	// Send sends amount satoshis to address on chain and returns the transaction id.
+	Send(ctx context.Context, address string, amount int64) (string, error)
+	// NewAddress returns an address to claim or refund to.
+	NewAddress(ctx context.Context) (string, error)
+	// SentTo returns the id of a transaction of the wallet sending to address, empty if there is none.
+	SentTo(ctx context.Context, address string) (string, error)
+}
+
+// Machine drives the swaps of a store to a final state, saving every transition before acting on it
+// so a restarted Machine resumes where the last one stopped. Timeouts are block heights: a reverse
+// swap Boltz did not lock up before its timeout fails, a submarine swap whose lockup Boltz did not
+// claim before its timeout is refunded.
+type Machine struct {
+	client *Client
+	store  SwapStore
+	chain  Chain
+	wallet Wallet
+
+	// Interval is the time between the steps of Run.
+	Interval time.Duration
+
+	mu sync.Mutex
+	// locks serialize the steps of each swap by id, Run and Step may step the same swap.
+	locks map[string]*sync.Mutex
+}
+
+func NewMachine(client *Client, store SwapStore, chain Chain, wallet Wallet) *Machine {
+	return &Machine{
+		client:   client,
+		store:    store,
+		chain:    chain,
+		wallet:   wallet,
+		Interval: DefaultMachineInterval,
+		locks:    make(map[string]*sync.Mutex),
+	}
+}
+
+// lock locks the swap id and returns the function unlocking it.
+func (m *Machine) lock(id string) func() {
+	m.mu.Lock()
+	l, ok := m.locks[id]
+	if !ok {
+		l = &sync.Mutex{}
+		m.locks[id] = l
+	}
+	m.mu.Unlock()
+	l.Lock()
+	return l.Unlock
+}
+
+// Add saves a new swap, it is driven from the next step on.
+func (m *Machine) Add(record *SwapRecord) error {
+	return m.store.Save(record)
+}
+
+// Run steps the pending swaps every Interval until ctx is done, starting with the swaps left pending
+// by an earlier run.
+func (m *Machine) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.Interval)
+	defer ticker.Stop()
+	for {
+		if err := m.StepAll(ctx); err != nil {
+			logs.Error("Step Swaps Failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// StepAll advances every pending swap as far as possible. Errors of single swaps are recorded
+// in their Error and retried on the next step.
+func (m *Machine) StepAll(ctx context.Context) error {
+	records, err := m.store.List()
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		if record.State.Final() {
+			continue
+		}
+		if _, err := m.Step(ctx, record.ID); err != nil {
+			logs.Warn("Swap %v in state %v: %v", record.ID, record.State, err)
+		}
+	}
+	return nil
+}
+
+// Step advances the swap id as far as possible and returns its record. Steps of the same swap
+// wait for each other, each starts from the record the previous one saved.
+func (m *Machine) Step(ctx context.Context, id string) (*SwapRecord, error) {
+	defer m.lock(id)()
+	record, err := m.store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	return record, m.step(ctx, record)
+}
+
+func (m *Machine) step(ctx context.Context, r *SwapRecord) error {
+	for !r.State.Final() {
+		from := r.State
+		changed, err := m.transition(ctx, r)
+		if err != nil {
+			r.Error = err.Error()
+			if saveErr := m.store.Save(r); saveErr != nil {
+				return saveErr
+			}
+			return err
+		}
+		if !changed {
+			return nil
+		}
+		// A transition clears the error of the steps that failed before it, except the reason fail
+		// gave to a failed swap.
+		if r.State != StateFailed {
+			r.Error = ""
+		}
+		if err := m.store.Save(r); err != nil {
+			return err
+		}
+		logs.Info("Swap %v: %v -> %v", r.ID, from, r.State)
+	}
+	return nil
+}
+
+func (m *Machine) transition(ctx context.Context, r *SwapRecord) (bool, error) {
+	height, err := m.chain.BlockHeight(ctx)
+	if err != nil {
+		return false, fmt.Errorf("BlockHeight: %w", err)
+	}
+	switch r.Type {
+	case ReverseSwaps:
+		return m.reverse(ctx, r, height)
+	case NormalSwaps:
+		return m.submarine(ctx, r, height)
+	}
+	return false, fmt.Errorf("unknown swap type %q", r.Type)
+}
+
+func (m *Machine) status(r *SwapRecord) (*StatusInfo, error) {
+	status, err := m.client.SwapStatus(r.ID)
+	if err != nil {
+		return nil, fmt.Errorf("SwapStatus %v: %w", r.ID, err)
+	}
+	r.BoltzStatus = status.Status
+	return status, nil
+}
+
+func fail(r *SwapRecord, reason string) (bool, error) {
+	r.State, r.Error = StateFailed, reason
+	return true, nil
+}
+
+func (m *Machine) reverse(ctx context.Context, r *SwapRecord, height int64) (bool, error) {
+	switch r.State {
+	case StateCreated:
+		if height >= r.TimeoutBlockHeight {
+			return fail(r, "timeout before the invoice was paid")
+		}
+		if err := m.wallet.PayInvoice(ctx, r.Invoice); err != nil {
+			return false, fmt.Errorf("PayInvoice: %w", err)
+		}
+		r.State = StateInvoicePaid
+		return true, nil
+
+	case StateInvoicePaid:
+		status, err := m.status(r)
+		if err != nil {
+			return false, err
+		}
+		switch status.Status {
+		case StatusTransactionMempool, StatusTransactionConfirmed:
+			txid, err := m.client.CheckTransaction(status.Transaction.Hex, r.LockupAddress, r.Amount)
+			if err != nil {
+				return false, fmt.Errorf("CheckTransaction: %w", err)
+			}
+			r.LockupTxid, r.LockupTx = txid, status.Transaction.Hex
+			r.State = StateLockupSeen
+			return true, nil
+		case StatusSwapExpired, StatusInvoiceExpired, StatusTransactionFailed, StatusTransactionRefunded:
+			return fail(r, "boltz status "+status.Status)
+		}
+		// Boltz only locks up once our payment reaches it, a payment that failed never will.
+		reason, err := m.wallet.PaymentFailed(ctx, r.Invoice)
+		if err != nil {
+			return false, fmt.Errorf("PaymentFailed: %w", err)
+		}
+		if reason != "" {
+			return fail(r, "payment failed: "+reason)
+		}
+		if height >= r.TimeoutBlockHeight {
+			return fail(r, "timeout before the lockup")
+		}
+		return false, nil
+
+	case StateLockupSeen:
+		if r.SpendTx == "" {
+			address, err := m.spendAddress(ctx, r)
+			if err != nil {
+				return false, err
+			}
+			feePerKw, err := m.chain.FeePerKw(ctx)
+			if err != nil {
+				return false, fmt.Errorf("FeePerKw: %w", err)
+			}
+			fee, err := m.client.ClaimFee(address, feePerKw)
+			if err != nil {
+				return false, fmt.Errorf("ClaimFee: %w", err)
+			}
+			tx, err := m.client.ClaimTransaction(r.RedeemScript, r.LockupTx, address, r.Preimage, r.Key, fee)
+			if err != nil {
+				return false, fmt.Errorf("ClaimTransaction: %w", err)
+			}
+			// Saved before broadcasting, a restart rebroadcasts the same claim.
+			r.SpendTx = tx
+			if err := m.store.Save(r); err != nil {
+				return false, err
+			}
+		}
+		txid, err := m.broadcast(ctx, r.SpendTx)
+		if err != nil {
+			return false, err
+		}
+		r.SpendTxid, r.State = txid, StateClaimed
+		return true, nil
+	}
+	return false, nil
+}
+
+func (m *Machine) submarine(ctx context.Context, r *SwapRecord, height int64) (bool, error) {
+	switch r.State {
+	case StateCreated:
+		if r.Funding {
+			// Stopped while sending: lnd records a transaction in its wallet before SendCoins returns,
+			// so the lockup was sent if and only if the wallet has a transaction to the address.
+			txid, err := m.wallet.SentTo(ctx, r.LockupAddress)
+			if err != nil {
+				return false, fmt.Errorf("SentTo %v: %w", r.LockupAddress, err)
+			}
+			if txid != "" {
+				r.Funding = false
+				r.LockupTxid, r.State = txid, StateLockupSeen
+				return true, nil
+			}
+		}
+		if height >= r.TimeoutBlockHeight {
+			return fail(r, "timeout before the lockup was funded")
+		}
+		r.Funding = true
+		if err := m.store.Save(r); err != nil {
+			return false, err
+		}
+		txid, err := m.wallet.Send(ctx, r.LockupAddress, r.Amount)
+		r.Funding = false
+		if err != nil {
+			return false, fmt.Errorf("Send: %w", err)
+		}
+		r.LockupTxid, r.State = txid, StateLockupSeen
+		return true, nil
+
+	case StateLockupSeen, StateInvoicePaid:
+		// Until Boltz claims, the lockup is refunded at the timeout whatever Boltz reports: the
+		// invoice may fail to pay, the swap expire or Boltz never claim a lockup after paying.
+		status, err := m.status(r)
+		if err != nil && height < r.TimeoutBlockHeight {
+			return false, err
+		}
+		if status != nil {
+			switch status.Status {
+			case StatusInvoicePaid:
+				if r.State != StateInvoicePaid {
+					r.State = StateInvoicePaid
+					return true, nil
+				}
+			case StatusTransactionClaimed:
+				r.State = StateClaimed
+				return true, nil
+			}
+		}
+		if height >= r.TimeoutBlockHeight {
+			return m.refund(ctx, r)
+		}
+		return false, nil
+	}
+	return false, nil
+}
+
+// spendAddress returns the address of the claim or refund of the swap, saving a new address of
+// the wallet the first time so retries spend to the same address.
+func (m *Machine) spendAddress(ctx context.Context, r *SwapRecord) (string, error) {
+	if r.SpendAddress != "" {
+		return r.SpendAddress, nil
+	}
+	address, err := m.wallet.NewAddress(ctx)
+	if err != nil {
+		return "", fmt.Errorf("NewAddress: %w", err)
+	}
+	r.SpendAddress = address
+	if err := m.store.Save(r); err != nil {
+		return "", err
+	}
+	return address, nil
+}
+
+// refund spends the lockup of a submarine swap back to the wallet.
+func (m *Machine) refund(ctx context.Context, r *SwapRecord) (bool, error) {
+	if r.SpendTx == "" {
+		if r.LockupTx == "" {
+			tx, err := m.chain.Transaction(ctx, r.LockupTxid)
+			if err != nil {
+				return false, fmt.Errorf("Transaction %v: %w", r.LockupTxid, err)
+			}
+			r.LockupTx = tx
+		}
+		address, err := m.spendAddress(ctx, r)
+		if err != nil {
+			return false, err
+		}
+		feePerKw, err := m.chain.FeePerKw(ctx)
+		if err != nil {
+			return false, fmt.Errorf("FeePerKw: %w", err)
+		}
+		lockup, err := btcutil.DecodeAddress(r.LockupAddress, m.client.Chain)
+		if err != nil {
+			return false, fmt.Errorf("btcutil.DecodeAddress(%v) %w", r.LockupAddress, err)
+		}
+		_, nested := lockup.(*btcutil.AddressScriptHash)
+		fee, err := m.client.RefundFee(address, nested, feePerKw)
+		if err != nil {
+			return false, fmt.Errorf("RefundFee: %w", err)
+		}
+		tx, err := m.client.RefundTransaction(r.RedeemScript, r.LockupTx, address, r.Key, fee)
+		if err != nil {
+			return false, fmt.Errorf("RefundTransaction: %w", err)
+		}
+		// Saved before broadcasting, a restart rebroadcasts the same refund.
+		r.SpendTx = tx
+		if err := m.store.Save(r); err != nil {
+			return false, err
+		}
+	}
+	txid, err := m.broadcast(ctx, r.SpendTx)
+	if err != nil {
+		return false, err
+	}
+	r.SpendTxid, r.State = txid, StateRefunded
+	return true, nil
+}
+
+// broadcast broadcasts the saved transaction through the chain, one the node already has counts as
+// broadcast. It is also sent to Boltz, ignoring the result, in case our node is badly connected.
+func (m *Machine) broadcast(ctx context.Context, txHex string) (string, error) {
+	_, _ = m.client.BroadcastTransaction(txHex)
+	txid, err := m.chain.Broadcast(ctx, txHex)
+	if err == nil {
+		return txid, nil
+	}
+	if !strings.Contains(err.Error(), "already in block chain") && !strings.Contains(err.Error(), "txn-already-known") &&
+		!strings.Contains(err.Error(), "txn-already-in-mempool") {
+		return "", fmt.Errorf("Broadcast: %w", err)
+	}
+	raw, decodeErr := hex.DecodeString(txHex)
+	if decodeErr != nil {
+		return "", fmt.Errorf("hex.DecodeString(%v): %w", txHex, decodeErr)
+	}
+	tx, decodeErr := btcutil.NewTxFromBytes(raw)
+	if decodeErr != nil {
+		return "", fmt.Errorf("btcutil.NewTxFromBytes(%x): %w", raw, decodeErr)
+	}
+	return tx.Hash().String(), nil
+}
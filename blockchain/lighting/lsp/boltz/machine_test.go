@@ -0,0 +1,498 @@
+package boltz_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+
+	"github.com/jualy007/GoTF/blockchain/lighting/lndtest"
+	"github.com/jualy007/GoTF/blockchain/lighting/lsp/boltz"
+	"github.com/jualy007/GoTF/blockchain/lighting/lsp/boltz/boltztest"
+)
+
+// chain is a Chain at a settable height holding the transactions sent by its wallet.
+type chain struct {
+	mu         sync.Mutex
+	height     int64
+	txs        map[string]string
+	broadcasts []string
+	// broadcastErr fails the next broadcast.
+	broadcastErr error
+}
+
+func newChain() *chain {
+	return &chain{height: boltztest.DefaultBlockHeight, txs: make(map[string]string)}
+}
+
+func (c *chain) setHeight(height int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.height = height
+}
+
+func (c *chain) BlockHeight(context.Context) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.height, nil
+}
+
+func (c *chain) FeePerKw(context.Context) (int64, error) {
+	return boltztest.DefaultFeeRate * 250, nil
+}
+
+func (c *chain) Transaction(_ context.Context, txid string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tx, ok := c.txs[txid]
+	if !ok {
+		return "", errors.New("No such mempool or blockchain transaction")
+	}
+	return tx, nil
+}
+
+func (c *chain) Broadcast(_ context.Context, txHex string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.broadcastErr; err != nil {
+		c.broadcastErr = nil
+		return "", err
+	}
+	c.broadcasts = append(c.broadcasts, txHex)
+	return txid(txHex), nil
+}
+
+// wallet is a Wallet sending from random outpoints and paying every invoice, unless failed.
+type wallet struct {
+	chain *chain
+
+	mu        sync.Mutex
+	paid      []string
+	failed    map[string]string
+	sent      map[string]string
+	addresses int
+}
+
+func newWallet(chain *chain) *wallet {
+	return &wallet{chain: chain, failed: make(map[string]string), sent: make(map[string]string)}
+}
+
+// fail fails the payment of invoice for reason.
+func (w *wallet) fail(invoice, reason string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.failed[invoice] = reason
+}
+
+func (w *wallet) payments() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.paid)
+}
+
+func (w *wallet) PayInvoice(_ context.Context, invoice string) error {
+	// Starting a payment takes a round trip to lnd.
+	time.Sleep(10 * time.Millisecond)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.paid = append(w.paid, invoice)
+	return nil
+}
+
+func (w *wallet) PaymentFailed(_ context.Context, invoice string) (string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.failed[invoice], nil
+}
+
+func (w *wallet) Send(_ context.Context, address string, amount int64) (string, error) {
+	addr, err := btcutil.DecodeAddress(address, boltztest.Net)
+	if err != nil {
+		return "", err
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return "", err
+	}
+	var prevHash chainhash.Hash
+	rand.Read(prevHash[:])
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&prevHash, 0), nil, nil))
+	tx.AddTxOut(wire.NewTxOut(amount, pkScript))
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		return "", err
+	}
+
+	id := tx.TxHash().String()
+	w.chain.mu.Lock()
+	w.chain.txs[id] = hex.EncodeToString(buf.Bytes())
+	w.chain.mu.Unlock()
+	w.mu.Lock()
+	w.sent[address] = id
+	w.mu.Unlock()
+	return id, nil
+}
+
+func (w *wallet) NewAddress(context.Context) (string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.addresses++
+	hash := make([]byte, 20)
+	rand.Read(hash)
+	address, err := btcutil.NewAddressWitnessPubKeyHash(hash, boltztest.Net)
+	if err != nil {
+		return "", err
+	}
+	return address.EncodeAddress(), nil
+}
+
+func (w *wallet) SentTo(_ context.Context, address string) (string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.sent[address], nil
+}
+
+func txid(txHex string) string {
+	raw, _ := hex.DecodeString(txHex)
+	tx, err := btcutil.NewTxFromBytes(raw)
+	if err != nil {
+		return ""
+	}
+	return tx.Hash().String()
+}
+
+// verify checks the first input of spendHex spends an output of lockupHex.
+func verify(t *testing.T, lockupHex, spendHex string) {
+	t.Helper()
+	if err := execute(lockupHex, spendHex); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// execute executes the scripts of the first input of spendHex, spending an output of lockupHex.
+func execute(lockupHex, spendHex string) error {
+	raw, err := hex.DecodeString(lockupHex)
+	if err != nil {
+		return err
+	}
+	lockup, err := btcutil.NewTxFromBytes(raw)
+	if err != nil {
+		return err
+	}
+	raw, err = hex.DecodeString(spendHex)
+	if err != nil {
+		return err
+	}
+	spend, err := btcutil.NewTxFromBytes(raw)
+	if err != nil {
+		return err
+	}
+	in := spend.MsgTx().TxIn[0]
+	if in.PreviousOutPoint.Hash != *lockup.Hash() {
+		return fmt.Errorf("spend of %v, not of the lockup %v", in.PreviousOutPoint, lockup.Hash())
+	}
+	prev := lockup.MsgTx().TxOut[in.PreviousOutPoint.Index]
+	vm, err := txscript.NewEngine(prev.PkScript, spend.MsgTx(), 0, txscript.StandardVerifyFlags, nil,
+		txscript.NewTxSigHashes(spend.MsgTx()), prev.Value)
+	if err != nil {
+		return err
+	}
+	if err := vm.Execute(); err != nil {
+		return fmt.Errorf("spend script: %w", err)
+	}
+	return nil
+}
+
+// harness is a machine swapping with a fake Boltz server, storing its swaps in a temporary directory.
+type harness struct {
+	t       *testing.T
+	server  *boltztest.Server
+	client  *boltz.Client
+	dir     string
+	store   *boltz.FileStore
+	chain   *chain
+	wallet  *wallet
+	machine *boltz.Machine
+}
+
+func newHarness(t *testing.T) *harness {
+	t.Helper()
+	server, err := boltztest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+	dir, err := ioutil.TempDir("", "swaps")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	store, err := boltz.NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := newChain()
+	wallet := newWallet(chain)
+	client := server.Client()
+	return &harness{
+		t:       t,
+		server:  server,
+		client:  client,
+		dir:     dir,
+		store:   store,
+		chain:   chain,
+		wallet:  wallet,
+		machine: boltz.NewMachine(client, store, chain, wallet),
+	}
+}
+
+// step steps the swap id and checks it reached state.
+func (h *harness) step(id string, state boltz.SwapState) *boltz.SwapRecord {
+	h.t.Helper()
+	record, err := h.machine.Step(context.Background(), id)
+	if err != nil {
+		h.t.Fatalf("step %v: %v", id, err)
+	}
+	if record.State != state {
+		h.t.Fatalf("swap %v is %v, want %v (%v)", id, record.State, state, record.Error)
+	}
+	return record
+}
+
+// submarineSwap creates a submarine swap paying an invoice of a fake lnd and saves it.
+func (h *harness) submarineSwap() *boltz.SwapRecord {
+	h.t.Helper()
+	lnd, err := lndtest.NewServer("alice")
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	h.t.Cleanup(lnd.Close)
+	adapter, err := lnd.Adapter()
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	h.t.Cleanup(adapter.Close)
+	ss, err := h.client.NewSubmarineSwap(context.Background(), adapter, 50000)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	record := boltz.RecordSubmarineSwap(ss)
+	if err := h.machine.Add(record); err != nil {
+		h.t.Fatal(err)
+	}
+	return record
+}
+
+func TestSubmarineRefundAfterFailedPayment(t *testing.T) {
+	h := newHarness(t)
+	swap := h.submarineSwap()
+	record := h.step(swap.ID, boltz.StateLockupSeen)
+	if record.LockupTxid == "" || record.Funding {
+		t.Fatalf("funded swap %+v", record)
+	}
+
+	// Boltz trying to pay is not the invoice paid.
+	h.server.SetStatus(swap.ID, boltz.StatusInvoicePending)
+	h.step(swap.ID, boltz.StateLockupSeen)
+	h.server.SetStatus(swap.ID, boltz.StatusInvoiceFailedToPay)
+	h.step(swap.ID, boltz.StateLockupSeen)
+
+	h.chain.setHeight(swap.TimeoutBlockHeight)
+	record = h.step(swap.ID, boltz.StateRefunded)
+	verify(t, h.chain.txs[record.LockupTxid], record.SpendTx)
+	if len(h.chain.broadcasts) != 1 || record.SpendTxid != txid(record.SpendTx) {
+		t.Errorf("refund %v broadcast as %v", record.SpendTxid, h.chain.broadcasts)
+	}
+}
+
+func TestSubmarineRefundAfterPaidUnclaimed(t *testing.T) {
+	h := newHarness(t)
+	swap := h.submarineSwap()
+	h.step(swap.ID, boltz.StateLockupSeen)
+	h.server.SetStatus(swap.ID, boltz.StatusInvoicePaid)
+	h.step(swap.ID, boltz.StateInvoicePaid)
+
+	h.chain.setHeight(swap.TimeoutBlockHeight)
+	record := h.step(swap.ID, boltz.StateRefunded)
+	verify(t, h.chain.txs[record.LockupTxid], record.SpendTx)
+}
+
+func TestSubmarineClaimed(t *testing.T) {
+	h := newHarness(t)
+	swap := h.submarineSwap()
+	h.step(swap.ID, boltz.StateLockupSeen)
+	h.server.SetStatus(swap.ID, boltz.StatusInvoicePaid)
+	h.step(swap.ID, boltz.StateInvoicePaid)
+	h.server.SetStatus(swap.ID, boltz.StatusTransactionClaimed)
+	h.step(swap.ID, boltz.StateClaimed)
+}
+
+func TestSubmarineInterruptedFunding(t *testing.T) {
+	h := newHarness(t)
+
+	// Stopped after the wallet sent the lockup.
+	sent := h.submarineSwap()
+	txid, err := h.wallet.Send(context.Background(), sent.LockupAddress, sent.Amount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent.Funding = true
+	if err := h.store.Save(sent); err != nil {
+		t.Fatal(err)
+	}
+	record := h.step(sent.ID, boltz.StateLockupSeen)
+	if record.LockupTxid != txid || record.Funding {
+		t.Errorf("swap %+v, want the lockup %v", record, txid)
+	}
+
+	// Stopped before the wallet sent anything.
+	unsent := h.submarineSwap()
+	unsent.Funding = true
+	if err := h.store.Save(unsent); err != nil {
+		t.Fatal(err)
+	}
+	record = h.step(unsent.ID, boltz.StateLockupSeen)
+	if record.LockupTxid == "" || record.LockupTxid == txid {
+		t.Errorf("swap %+v not funded", record)
+	}
+}
+
+func TestClaimSavedBeforeBroadcast(t *testing.T) {
+	h := newHarness(t)
+	rs, err := h.client.NewReverseSwap(100000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.machine.Add(boltz.RecordReverseSwap(rs)); err != nil {
+		t.Fatal(err)
+	}
+	h.step(rs.ID, boltz.StateInvoicePaid)
+	lockup, err := h.server.Lockup(rs.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.chain.broadcastErr = errors.New("connection refused")
+	if _, err := h.machine.Step(context.Background(), rs.ID); err == nil {
+		t.Fatal("claim broadcast through a failing chain")
+	}
+	saved, err := h.store.Get(rs.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.State != boltz.StateLockupSeen || saved.SpendTx == "" || saved.SpendAddress == "" {
+		t.Fatalf("swap saved as %+v after a failed broadcast", saved)
+	}
+	verify(t, lockup, saved.SpendTx)
+
+	record := h.step(rs.ID, boltz.StateClaimed)
+	if record.SpendTx != saved.SpendTx || h.wallet.addresses != 1 {
+		t.Errorf("claim %v rebroadcast as %v to %v addresses", saved.SpendTx, record.SpendTx, h.wallet.addresses)
+	}
+	for _, tx := range h.server.Broadcasts() {
+		if tx != saved.SpendTx {
+			t.Errorf("Boltz got %v, not the saved claim", tx)
+		}
+	}
+}
+
+func TestReversePaymentFailed(t *testing.T) {
+	h := newHarness(t)
+	rs, err := h.client.NewReverseSwap(100000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.machine.Add(boltz.RecordReverseSwap(rs)); err != nil {
+		t.Fatal(err)
+	}
+	h.step(rs.ID, boltz.StateInvoicePaid)
+
+	h.wallet.fail(rs.Invoice, "FAILURE_REASON_NO_ROUTE")
+	record := h.step(rs.ID, boltz.StateFailed)
+	if record.Error != "payment failed: FAILURE_REASON_NO_ROUTE" {
+		t.Errorf("swap failed with %q", record.Error)
+	}
+}
+
+func TestConcurrentSteps(t *testing.T) {
+	h := newHarness(t)
+	rs, err := h.client.NewReverseSwap(100000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.machine.Add(boltz.RecordReverseSwap(rs)); err != nil {
+		t.Fatal(err)
+	}
+
+	h.machine.Interval = time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go h.machine.Run(ctx)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := h.machine.Step(ctx, rs.ID); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	record, err := h.store.Get(rs.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.State != boltz.StateInvoicePaid || h.wallet.payments() != 1 {
+		t.Errorf("swap %+v after paying %v invoices", record, h.wallet.payments())
+	}
+}
+
+func TestResumeAfterRestart(t *testing.T) {
+	h := newHarness(t)
+	rs, err := h.client.NewReverseSwap(100000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.machine.Add(boltz.RecordReverseSwap(rs)); err != nil {
+		t.Fatal(err)
+	}
+	h.step(rs.ID, boltz.StateInvoicePaid)
+	lockup, err := h.server.Lockup(rs.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A new machine on the same directory, as after a restart, finishes the swap with Run.
+	store, err := boltz.NewFileStore(h.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	machine := boltz.NewMachine(h.client, store, h.chain, h.wallet)
+	machine.Interval = 10 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go machine.Run(ctx)
+	record, err := machine.Wait(ctx, rs.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.State != boltz.StateClaimed || len(h.wallet.paid) != 1 {
+		t.Fatalf("swap %+v after paying %v invoices", record, len(h.wallet.paid))
+	}
+	verify(t, lockup, record.SpendTx)
+}
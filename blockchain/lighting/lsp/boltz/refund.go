@@ -0,0 +1,207 @@
+package boltz
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/lightningnetwork/lnd/lnwallet/chainfee"
+)
+
+const (
+	refundWitnessInputSize = 1 + 1 + 8 + 73 + 1 + 1 + 100
+	// nestedSigScriptSize is the size of the signature script spending a nested segwit lockup.
+	nestedSigScriptSize = 1 + 1 + 1 + 32
+)
+
+// refundPath returns the timeout block height and the refund public key of the
+// OP_CHECKLOCKTIMEVERIFY branch of a submarine or reverse swap redeem script.
+func refundPath(script []byte) (int64, []byte, error) {
+	dis, err := txscript.DisasmString(script)
+	if err != nil {
+		return 0, nil, fmt.Errorf("txscript.DisasmString %x: %w", script, err)
+	}
+	d := strings.Split(dis, " ")
+	cltv := -1
+	for i, op := range d {
+		if op == "OP_CHECKLOCKTIMEVERIFY" {
+			cltv = i
+		}
+	}
+	// ... <timeout> OP_CHECKLOCKTIMEVERIFY OP_DROP <refund key> OP_ENDIF OP_CHECKSIG
+	if cltv < 1 || len(d) != cltv+5 || d[cltv+1] != "OP_DROP" || d[cltv+3] != "OP_ENDIF" || d[cltv+4] != "OP_CHECKSIG" {
+		return 0, nil, fmt.Errorf("bad script: no refund path")
+	}
+	h, err := hex.DecodeString(d[cltv-1])
+	if err != nil || len(h) == 0 || len(h) > 5 {
+		return 0, nil, fmt.Errorf("bad script: timeout %v", d[cltv-1])
+	}
+	b := make([]byte, 8)
+	copy(b, h)
+	key, err := hex.DecodeString(d[cltv+2])
+	if err != nil {
+		return 0, nil, fmt.Errorf("hex.DecodeString %v: %w", d[cltv+2], err)
+	}
+	return int64(binary.LittleEndian.Uint64(b)), key, nil
+}
+
+// RefundFee returns the fees needed for the refund transaction for a feePerKw, nested for a lockup in a
+// nested segwit output.
+func (c *Client) RefundFee(refundAddress string, nested bool, feePerKw int64) (int64, error) {
+	addr, err := btcutil.DecodeAddress(refundAddress, c.Chain)
+	if err != nil {
+		return 0, fmt.Errorf("btcutil.DecodeAddress(%v) %w", refundAddress, err)
+	}
+	refundScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return 0, fmt.Errorf("txscript.PayToAddrScript(%v): %w", addr.String(), err)
+	}
+	refundTx := wire.NewMsgTx(1)
+	txIn := wire.NewTxIn(&wire.OutPoint{}, nil, nil)
+	if nested {
+		txIn.SignatureScript = make([]byte, nestedSigScriptSize)
+	}
+	refundTx.AddTxIn(txIn)
+	refundTx.AddTxOut(&wire.TxOut{PkScript: refundScript})
+
+	weight := 4*refundTx.SerializeSizeStripped() + refundWitnessInputSize*len(refundTx.TxIn)
+	fee := chainfee.SatPerKWeight(feePerKw).FeeForWeight(int64(weight))
+	return int64(fee), nil
+}
+
+func refundTransaction(
+	script []byte,
+	amt btcutil.Amount,
+	txout *wire.OutPoint,
+	sigScript []byte,
+	refundAddress btcutil.Address,
+	timeoutBlockHeight int64,
+	privateKey []byte,
+	fees btcutil.Amount,
+) ([]byte, error) {
+	refundTx := wire.NewMsgTx(1)
+	// The locktime must reach the timeout of the script and the sequence must not be final
+	// for OP_CHECKLOCKTIMEVERIFY to pass.
+	refundTx.LockTime = uint32(timeoutBlockHeight)
+	txIn := wire.NewTxIn(txout, sigScript, nil)
+	txIn.Sequence = 0
+	refundTx.AddTxIn(txIn)
+
+	refundScript, err := txscript.PayToAddrScript(refundAddress)
+	if err != nil {
+		return nil, fmt.Errorf("txscript.PayToAddrScript(%v): %w", refundAddress.String(), err)
+	}
+	refundTx.AddTxOut(&wire.TxOut{PkScript: refundScript, Value: int64(amt - fees)})
+
+	sigHashes := txscript.NewTxSigHashes(refundTx)
+	key, _ := btcec.PrivKeyFromBytes(btcec.S256(), privateKey)
+	scriptSig, err := txscript.RawTxInWitnessSignature(refundTx, sigHashes, 0, int64(amt), script, txscript.SigHashAll, key)
+	if err != nil {
+		return nil, fmt.Errorf("txscript.RawTxInWitnessSignature: %w", err)
+	}
+	// The empty item fails the preimage check of both scripts, selecting the refund branch.
+	refundTx.TxIn[0].Witness = [][]byte{scriptSig, {}, script}
+
+	var rawTx bytes.Buffer
+	err = refundTx.Serialize(&rawTx)
+	if err != nil {
+		return nil, fmt.Errorf("refundTx.Serialize %#v: %w", refundTx, err)
+	}
+	return rawTx.Bytes(), nil
+}
+
+// RefundTransaction returns the transaction spending the lockup output of transactionHex back to
+// refundAddress through the timeout branch of redeemScript, signed with the hex encoded refund key.
+// It works for the scripts of submarine and reverse swaps, with native or nested segwit lockups.
+// The transaction is only valid once the chain reached the timeout block height of the script.
+func (c *Client) RefundTransaction(redeemScript, transactionHex string, refundAddress string, key string, fees int64) (string, error) {
+	txSerialized, err := hex.DecodeString(transactionHex)
+	if err != nil {
+		return "", fmt.Errorf("hex.DecodeString(%v): %w", transactionHex, err)
+	}
+	tx, err := btcutil.NewTxFromBytes(txSerialized)
+	if err != nil {
+		return "", fmt.Errorf("btcutil.NewTxFromBytes(%x): %w", txSerialized, err)
+	}
+	script, err := hex.DecodeString(redeemScript)
+	if err != nil {
+		return "", fmt.Errorf("hex.DecodeString(%v): %w", redeemScript, err)
+	}
+	timeoutBlockHeight, refundKey, err := refundPath(script)
+	if err != nil {
+		return "", fmt.Errorf("refundPath %v: %w", redeemScript, err)
+	}
+	privateKey, err := hex.DecodeString(key)
+	if err != nil {
+		return "", fmt.Errorf("hex.DecodeString(%v): %w", key, err)
+	}
+	_, pubKey := btcec.PrivKeyFromBytes(btcec.S256(), privateKey)
+	if !bytes.Equal(pubKey.SerializeCompressed(), refundKey) {
+		return "", fmt.Errorf("bad key: refund key of the script is %x", refundKey)
+	}
+
+	lockupAddress, err := addressWitnessScriptHash(script, c.Chain)
+	if err != nil {
+		return "", fmt.Errorf("addressWitnessScriptHash %v: %w", script, err)
+	}
+	nestedAddress, err := addressNestedWitnessScriptHash(script, c.Chain)
+	if err != nil {
+		return "", fmt.Errorf("addressNestedWitnessScriptHash %v: %w", script, err)
+	}
+	witnessProg := sha256.Sum256(script)
+	nestedSigScript, err := txscript.NewScriptBuilder().
+		AddData(append([]byte{txscript.OP_0, txscript.OP_DATA_32}, witnessProg[:]...)).Script()
+	if err != nil {
+		return "", fmt.Errorf("nested signature script: %w", err)
+	}
+
+	var out *wire.OutPoint
+	var amt btcutil.Amount
+	var sigScript []byte
+	for i, txout := range tx.MsgTx().TxOut {
+		_, addresses, _, err := txscript.ExtractPkScriptAddrs(txout.PkScript, c.Chain)
+		if err != nil || len(addresses) != 1 {
+			continue
+		}
+		switch addresses[0].EncodeAddress() {
+		case lockupAddress.EncodeAddress():
+			sigScript = nil
+		case nestedAddress.EncodeAddress():
+			sigScript = nestedSigScript
+		default:
+			continue
+		}
+		out = wire.NewOutPoint(tx.Hash(), uint32(i))
+		amt = btcutil.Amount(txout.Value)
+	}
+	if out == nil {
+		return "", fmt.Errorf("lockupAddress: %v or %v not found in the transaction: %v",
+			lockupAddress.EncodeAddress(), nestedAddress.EncodeAddress(), transactionHex)
+	}
+	if btcutil.Amount(fees) >= amt {
+		return "", fmt.Errorf("fees %v exceed the lockup amount %v", fees, int64(amt))
+	}
+
+	addr, err := btcutil.DecodeAddress(refundAddress, c.Chain)
+	if err != nil {
+		return "", fmt.Errorf("btcutil.DecodeAddress(%v) %w", refundAddress, err)
+	}
+
+	rtx, err := refundTransaction(script, amt, out, sigScript, addr, timeoutBlockHeight, privateKey, btcutil.Amount(fees))
+	if err != nil {
+		return "", fmt.Errorf("refundTransaction: %w", err)
+	}
+	return hex.EncodeToString(rtx), nil
+}
+
+// RefundSubmarineSwap returns the transaction refunding the lockup transaction of the swap to refundAddress.
+func (c *Client) RefundSubmarineSwap(s *SubmarineSwap, lockupTransactionHex, refundAddress string, fees int64) (string, error) {
+	return c.RefundTransaction(s.RedeemScript, lockupTransactionHex, refundAddress, s.Key, fees)
+}
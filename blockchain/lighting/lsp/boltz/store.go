@@ -0,0 +1,217 @@
+package boltz
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SwapState is the state of a swap in the store.
+type SwapState string
+
+// A reverse swap goes created -> invoice paid -> lockup seen -> claimed, a submarine swap
+// created -> lockup seen -> invoice paid -> claimed, or refunded after its timeout. Swaps Boltz
+// gave up on before anything was locked end failed.
+const (
+	StateCreated     SwapState = "created"
+	StateInvoicePaid SwapState = "invoice_paid"
+	StateLockupSeen  SwapState = "lockup_seen"
+	StateClaimed     SwapState = "claimed"
+	StateRefunded    SwapState = "refunded"
+	StateFailed      SwapState = "failed"
+)
+
+// Final reports whether the swap is done.
+func (s SwapState) Final() bool {
+	return s == StateClaimed || s == StateRefunded || s == StateFailed
+}
+
+// SwapRecord is everything needed to finish a swap after a restart. Key is the claim key of a reverse
+// swap and the refund key of a submarine swap, hex encoded like Preimage. Amount is the on-chain
+// amount locked up. SpendTx is the claim or refund transaction to SpendAddress, kept to rebroadcast it.
+type SwapRecord struct {
+	ID                 string    `json:"id"`
+	Type               string    `json:"type"`
+	State              SwapState `json:"state"`
+	RedeemScript       string    `json:"redeem_script"`
+	TimeoutBlockHeight int64     `json:"timeout_block_height"`
+	Invoice            string    `json:"invoice"`
+	PaymentHash        string    `json:"payment_hash,omitempty"`
+	Preimage           string    `json:"preimage,omitempty"`
+	Key                string    `json:"key"`
+	LockupAddress      string    `json:"lockup_address"`
+	Amount             int64     `json:"amount"`
+	Funding            bool      `json:"funding,omitempty"`
+	LockupTxid         string    `json:"lockup_txid,omitempty"`
+	LockupTx           string    `json:"lockup_tx,omitempty"`
+	SpendAddress       string    `json:"spend_address,omitempty"`
+	SpendTxid          string    `json:"spend_txid,omitempty"`
+	SpendTx            string    `json:"spend_tx,omitempty"`
+	BoltzStatus        string    `json:"boltz_status,omitempty"`
+	Error              string    `json:"error,omitempty"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// RecordReverseSwap returns the record of a new reverse swap, to be saved before its invoice is paid.
+func RecordReverseSwap(rs *ReverseSwap) *SwapRecord {
+	return &SwapRecord{
+		ID:                 rs.ID,
+		Type:               ReverseSwaps,
+		State:              StateCreated,
+		RedeemScript:       rs.RedeemScript,
+		TimeoutBlockHeight: rs.TimeoutBlockHeight,
+		Invoice:            rs.Invoice,
+		Preimage:           rs.Preimage,
+		Key:                rs.Key,
+		LockupAddress:      rs.LockupAddress,
+		Amount:             rs.OnchainAmount,
+		CreatedAt:          time.Now(),
+	}
+}
+
+// RecordSubmarineSwap returns the record of a new submarine swap, to be saved before it is funded.
+func RecordSubmarineSwap(s *SubmarineSwap) *SwapRecord {
+	record := &SwapRecord{
+		ID:                 s.ID,
+		Type:               NormalSwaps,
+		State:              StateCreated,
+		RedeemScript:       s.RedeemScript,
+		TimeoutBlockHeight: s.TimeoutBlockHeight,
+		Invoice:            s.Invoice,
+		PaymentHash:        s.PaymentHash,
+		Key:                s.Key,
+		LockupAddress:      s.Address,
+		Amount:             s.ExpectedAmount,
+		LockupTxid:         s.LockupTxid,
+		CreatedAt:          time.Now(),
+	}
+	if s.LockupTxid != "" {
+		record.State = StateLockupSeen
+	}
+	return record
+}
+
+// SwapStore persists swap records.
+type SwapStore interface {
+	Save(record *SwapRecord) error
+	Get(id string) (*SwapRecord, error)
+	List() ([]*SwapRecord, error)
+}
+
+// SwapNotFoundError is returned for a swap id that is not in the store.
+type SwapNotFoundError struct {
+	ID string
+}
+
+func (e *SwapNotFoundError) Error() string {
+	return fmt.Sprintf("swap %v not found", e.ID)
+}
+
+// FileStore stores each swap as a JSON file named by its id in a directory. The files hold
+// private keys and preimages and are only readable by the owner.
+type FileStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// NewFileStore returns a store in dir, creating it if needed.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("os.MkdirAll(%v): %w", dir, err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (s *FileStore) path(id string) (string, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || id == "." || id == ".." {
+		return "", fmt.Errorf("invalid swap id %q", id)
+	}
+	return filepath.Join(s.dir, id+".json"), nil
+}
+
+// Save writes the record, replacing the file atomically so a crash leaves the old or the new record.
+func (s *FileStore) Save(record *SwapRecord) error {
+	path, err := s.path(record.ID)
+	if err != nil {
+		return err
+	}
+	record.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return fmt.Errorf("json encode %v: %w", record.ID, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := ioutil.TempFile(s.dir, record.ID+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("ioutil.TempFile(%v): %w", s.dir, err)
+	}
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("write %v: %w", path, err)
+	}
+	return nil
+}
+
+// Get returns the record of the swap id, a *SwapNotFoundError if there is none.
+func (s *FileStore) Get(id string) (*SwapRecord, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return readRecord(path, id)
+}
+
+func readRecord(path, id string) (*SwapRecord, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, &SwapNotFoundError{id}
+	}
+	if err != nil {
+		return nil, err
+	}
+	var record SwapRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("json decode %v: %w", path, err)
+	}
+	return &record, nil
+}
+
+// List returns every record, oldest first.
+func (s *FileStore) List() ([]*SwapRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	records := make([]*SwapRecord, 0, len(paths))
+	for _, path := range paths {
+		record, err := readRecord(path, strings.TrimSuffix(filepath.Base(path), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].CreatedAt.Before(records[j].CreatedAt) })
+	return records, nil
+}
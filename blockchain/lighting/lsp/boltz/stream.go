@@ -0,0 +1,144 @@
+package boltz
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/astaxie/beego/logs"
+)
+
+const streamSwapStatusEndpoint = "/streamswapstatus"
+
+// Delays before reconnecting a status stream, doubling from DefaultStreamRetry up to MaxStreamRetry
+// while the stream keeps failing.
+const (
+	DefaultStreamRetry = time.Second
+	MaxStreamRetry     = 30 * time.Second
+)
+
+// FinalStatus reports whether Boltz reports no status after status, for submarine and reverse swaps.
+func FinalStatus(status string) bool {
+	switch status {
+	case StatusTransactionClaimed, StatusSwapExpired, StatusInvoiceFailedToPay, StatusTransactionLockupFailed,
+		StatusInvoiceSettled, StatusInvoiceExpired, StatusTransactionFailed, StatusTransactionRefunded:
+		return true
+	}
+	return false
+}
+
+// StatusUpdate is a transition of the status of the swap ID from Previous, empty for the first
+// status received. The last update of a stream stopped by an error only has Err set.
+type StatusUpdate struct {
+	ID       string
+	Previous string
+	StatusInfo
+	Err error
+}
+
+// StreamSwapStatus streams the status transitions of the swap id from the server-sent events of Boltz.
+// A stream that fails is reconnected, statuses Boltz repeats on reconnection are not delivered again.
+// The channel is closed after a final status, when ctx is done or after an update with the error
+// Boltz answered the stream with, such as an unknown swap.
+func (c *Client) StreamSwapStatus(ctx context.Context, id string) <-chan StatusUpdate {
+	updates := make(chan StatusUpdate)
+	go func() {
+		defer close(updates)
+		last := ""
+		retry := DefaultStreamRetry
+		for {
+			err := c.streamSwapStatus(ctx, id, func(status *StatusInfo) bool {
+				retry = DefaultStreamRetry
+				if status.Status == last {
+					return true
+				}
+				select {
+				case updates <- StatusUpdate{ID: id, Previous: last, StatusInfo: *status}:
+				case <-ctx.Done():
+					return false
+				}
+				last = status.Status
+				return !FinalStatus(last)
+			})
+			if ctx.Err() != nil || FinalStatus(last) {
+				return
+			}
+			var badRequestError *BadRequestError
+			if errors.As(err, &badRequestError) {
+				select {
+				case updates <- StatusUpdate{ID: id, Previous: last, Err: err}:
+				case <-ctx.Done():
+				}
+				return
+			}
+			logs.Warn("Swap %v status stream: %v, reconnecting in %v", id, err, retry)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(retry):
+			}
+			if retry *= 2; retry > MaxStreamRetry {
+				retry = MaxStreamRetry
+			}
+		}
+	}()
+	return updates
+}
+
+// streamSwapStatus reads the status events of one connection, calling update with each until it
+// returns false. It returns why the stream ended.
+func (c *Client) streamSwapStatus(ctx context.Context, id string, update func(*StatusInfo) bool) error {
+	endpoint := c.URL + streamSwapStatusEndpoint + "?id=" + url.QueryEscape(id)
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("http.NewRequest %v: %w", endpoint, err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	// The stream lasts as long as the swap, it is only limited by ctx.
+	httpClient := *c.HTTPClient
+	httpClient.Timeout = 0
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("%v get %v: %w", streamSwapStatusEndpoint[1:], endpoint, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseError(streamSwapStatusEndpoint[1:], resp)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	// Statuses carry whole transactions.
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if len(data) == 0 {
+				continue
+			}
+			var status StatusInfo
+			err := json.Unmarshal([]byte(strings.Join(data, "\n")), &status)
+			data = data[:0]
+			if err != nil {
+				return fmt.Errorf("json decode event: %w", err)
+			}
+			if !update(&status) {
+				return nil
+			}
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+		// Comments and the other fields of events are ignored.
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read stream: %w", err)
+	}
+	return fmt.Errorf("stream closed")
+}
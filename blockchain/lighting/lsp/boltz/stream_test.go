@@ -0,0 +1,101 @@
+package boltz_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jualy007/GoTF/blockchain/lighting/lsp/boltz"
+	"github.com/jualy007/GoTF/blockchain/lighting/lsp/boltz/boltztest"
+)
+
+// next returns the next update of updates, failing the test if none comes in time.
+func next(t *testing.T, updates <-chan boltz.StatusUpdate) boltz.StatusUpdate {
+	t.Helper()
+	select {
+	case update, ok := <-updates:
+		if !ok {
+			t.Fatal("stream closed")
+		}
+		return update
+	case <-time.After(5 * time.Second):
+		t.Fatal("no status update")
+	}
+	return boltz.StatusUpdate{}
+}
+
+func TestStreamSwapStatus(t *testing.T) {
+	server, err := boltztest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	client := server.Client()
+	rs, err := client.NewReverseSwap(100000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	updates := client.StreamSwapStatus(ctx, rs.ID)
+	if u := next(t, updates); u.Status != boltz.StatusSwapCreated || u.Previous != "" {
+		t.Fatalf("first update %+v", u)
+	}
+	lockup, err := server.Lockup(rs.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u := next(t, updates); u.Status != boltz.StatusTransactionMempool || u.Previous != boltz.StatusSwapCreated || u.Transaction.Hex != lockup {
+		t.Fatalf("lockup update %+v", u)
+	}
+
+	// Boltz repeats the last status on reconnection, only the new status is delivered.
+	server.DropStreams()
+	time.Sleep(boltz.DefaultStreamRetry + 500*time.Millisecond)
+	server.SetStatus(rs.ID, boltz.StatusInvoiceSettled)
+	if u := next(t, updates); u.Status != boltz.StatusInvoiceSettled || u.Previous != boltz.StatusTransactionMempool {
+		t.Fatalf("update after reconnecting %+v", u)
+	}
+	// invoice.settled is final, the stream ends.
+	if u, ok := <-updates; ok {
+		t.Fatalf("update %+v after a final status", u)
+	}
+}
+
+func TestStreamSwapStatusUnknownSwap(t *testing.T) {
+	server, err := boltztest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	updates := server.Client().StreamSwapStatus(ctx, "unknown")
+	if u := next(t, updates); u.Err == nil {
+		t.Fatalf("update %+v of an unknown swap", u)
+	}
+	if u, ok := <-updates; ok {
+		t.Fatalf("update %+v after an error", u)
+	}
+}
+
+func TestStreamSwapStatusCanceled(t *testing.T) {
+	server, err := boltztest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	client := server.Client()
+	rs, err := client.NewReverseSwap(100000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	updates := client.StreamSwapStatus(ctx, rs.ID)
+	next(t, updates)
+	cancel()
+	for range updates {
+	}
+}
@@ -0,0 +1,214 @@
+package boltz
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/lightningnetwork/lnd/lnrpc"
+
+	"github.com/jualy007/GoTF/blockchain/btc"
+	"github.com/jualy007/GoTF/blockchain/lighting"
+)
+
+// Statuses of a submarine swap, in the order Boltz reports them.
+const (
+	StatusSwapCreated          = "swap.created"
+	StatusInvoiceSet           = "invoice.set"
+	StatusTransactionMempool   = "transaction.mempool"
+	StatusTransactionConfirmed = "transaction.confirmed"
+	StatusInvoicePending       = "invoice.pending"
+	StatusInvoicePaid          = "invoice.paid"
+	StatusTransactionClaimed   = "transaction.claimed"
+
+	StatusSwapExpired             = "swap.expired"
+	StatusInvoiceFailedToPay      = "invoice.failedToPay"
+	StatusTransactionLockupFailed = "transaction.lockupFailed"
+)
+
+// SwapFailedError is returned when Boltz reports a swap failed.
+type SwapFailedError struct {
+	ID     string
+	Status string
+}
+
+func (e *SwapFailedError) Error() string {
+	return fmt.Sprintf("swap %v failed: %v", e.ID, e.Status)
+}
+
+// SubmarineSwap is a normal swap: we lock ExpectedAmount at Address on chain, Boltz pays
+// Invoice of our lnd and claims the lockup with its preimage. Key is the hex encoded refund
+// private key, needed to get the lockup back after TimeoutBlockHeight if Boltz does not pay.
+type SubmarineSwap struct {
+	SResponse
+	Invoice     string
+	PaymentHash string
+	Key         string
+	LockupTxid  string
+}
+
+func (c *Client) createSubmarineSwap(invoice string, key *btcec.PrivateKey) (*SResponse, error) {
+	var ss SResponse
+	err := c.post(createSwapEndpoint, SRequest{
+		BaseRequest: BaseRequest{
+			SwapType:  NormalSwaps,
+			PairId:    "BTC/BTC",
+			OrderSide: "sell",
+		},
+		Invoice:         invoice,
+		RefundPublicKey: hex.EncodeToString(key.PubKey().SerializeCompressed()),
+	}, &ss)
+	if err != nil {
+		return nil, err
+	}
+	return &ss, nil
+}
+
+// checkSubmarineSwap checks the redeem script pays Boltz with the preimage of paymentHash or refunds
+// to key after the timeout, that the address is the script's and the amount covers the invoice.
+func (c *Client) checkSubmarineSwap(paymentHash []byte, invoiceAmount int64, key *btcec.PrivateKey, ss *SResponse) error {
+	script, err := hex.DecodeString(ss.RedeemScript)
+	if err != nil {
+		return fmt.Errorf("hex.DecodeString %v: %w", ss.RedeemScript, err)
+	}
+	dis, err := txscript.DisasmString(script)
+	if err != nil {
+		return fmt.Errorf("txscript.DisasmString %x: %w", script, err)
+	}
+	d := strings.Split(dis, " ")
+	if len(d) != 12 {
+		return fmt.Errorf("bad script")
+	}
+	claimKey, err := hex.DecodeString(d[4])
+	if err != nil {
+		return fmt.Errorf("hex.DecodeString %v: %w", d[4], err)
+	}
+	if _, err := btcec.ParsePubKey(claimKey, btcec.S256()); err != nil {
+		return fmt.Errorf("bad claim public key %v: %w", d[4], err)
+	}
+
+	s := fmt.Sprintf(
+		"OP_HASH160 %x OP_EQUAL OP_IF %s OP_ELSE %s OP_CHECKLOCKTIMEVERIFY OP_DROP %x OP_ENDIF OP_CHECKSIG",
+		input.Ripemd160H(paymentHash),
+		d[4],
+		btc.CheckHeight(ss.TimeoutBlockHeight, d[6]),
+		key.PubKey().SerializeCompressed(),
+	)
+	if s != dis {
+		return fmt.Errorf("bad script")
+	}
+
+	// Boltz locks normal swaps in a native or a nested segwit output.
+	a, err := addressWitnessScriptHash(script, c.Chain)
+	if err != nil {
+		return fmt.Errorf("addressWitnessScriptHash %v: %w", script, err)
+	}
+	nested, err := addressNestedWitnessScriptHash(script, c.Chain)
+	if err != nil {
+		return fmt.Errorf("addressNestedWitnessScriptHash %v: %w", script, err)
+	}
+	if ss.Address != a.String() && ss.Address != nested.String() {
+		return fmt.Errorf("bad address: %v instead of %v or %v", ss.Address, a.String(), nested.String())
+	}
+
+	if ss.ExpectedAmount < invoiceAmount {
+		return fmt.Errorf("bad expected amount: %v below the invoice amount %v", ss.ExpectedAmount, invoiceAmount)
+	}
+	return nil
+}
+
+func addressNestedWitnessScriptHash(script []byte, net *chaincfg.Params) (*btcutil.AddressScriptHash, error) {
+	witnessProg := sha256.Sum256(script)
+	redeemScript := append([]byte{txscript.OP_0, txscript.OP_DATA_32}, witnessProg[:]...)
+	return btcutil.NewAddressScriptHash(redeemScript, net)
+}
+
+// NewSubmarineSwap begins the submarine process: it creates an invoice of amt on the lnd of adapter,
+// a swap paying it and checks the swap. The lockup address is funded with Fund.
+func (c *Client) NewSubmarineSwap(ctx context.Context, adapter *lighting.Adapter, amt btcutil.Amount) (*SubmarineSwap, error) {
+	key, err := getPrivate()
+	if err != nil {
+		return nil, fmt.Errorf("getPrivate: %w", err)
+	}
+
+	added, err := adapter.AddInvoice(ctx, &lnrpc.Invoice{Value: int64(amt), Memo: "Boltz submarine swap"})
+	if err != nil {
+		return nil, fmt.Errorf("AddInvoice amt:%v: %w", amt, err)
+	}
+	invoice, err := lighting.DecodeInvoice(added.PaymentRequest, c.Chain)
+	if err != nil {
+		return nil, fmt.Errorf("DecodeInvoice %v: %w", added.PaymentRequest, err)
+	}
+	paymentHash, err := hex.DecodeString(invoice.PaymentHash)
+	if err != nil {
+		return nil, fmt.Errorf("hex.DecodeString(%v): %w", invoice.PaymentHash, err)
+	}
+
+	ss, err := c.createSubmarineSwap(added.PaymentRequest, key)
+	if err != nil {
+		return nil, fmt.Errorf("createSubmarineSwap invoice:%v, key:%x; %w", added.PaymentRequest, key, err)
+	}
+
+	err = c.checkSubmarineSwap(paymentHash, invoice.AmountMsat/1000, key, ss)
+	if err != nil {
+		return nil, fmt.Errorf("checkSubmarineSwap paymentHash:%x, key:%x, %#v; %w", paymentHash, key, ss, err)
+	}
+
+	return &SubmarineSwap{
+		SResponse:   *ss,
+		Invoice:     added.PaymentRequest,
+		PaymentHash: invoice.PaymentHash,
+		Key:         hex.EncodeToString(key.Serialize()),
+	}, nil
+}
+
+// Fund sends the expected amount to the lockup address from the lnd wallet of adapter, at satPerByte
+// or the fee rate lnd estimates if 0. Boltz only accepts the lockup without confirmation if
+// AcceptZeroConf is set and the fee rate is high enough.
+func (s *SubmarineSwap) Fund(ctx context.Context, adapter *lighting.Adapter, satPerByte int64) (string, error) {
+	txid, err := adapter.SendCoins(ctx, s.Address, s.ExpectedAmount, satPerByte)
+	if err != nil {
+		return "", fmt.Errorf("SendCoins %v to %v: %w", s.ExpectedAmount, s.Address, err)
+	}
+	s.LockupTxid = txid
+	return txid, nil
+}
+
+// WaitSubmarineSwap polls the status of the swap id every interval until Boltz paid the invoice,
+// returning the last status. updates, if given, are called with every new status.
+// A failed swap returns the status with a *SwapFailedError.
+func (c *Client) WaitSubmarineSwap(ctx context.Context, id string, interval time.Duration, updates ...func(*StatusInfo)) (*StatusInfo, error) {
+	last := ""
+	for {
+		status, err := c.SwapStatus(id)
+		if err != nil {
+			return nil, err
+		}
+		if status.Status != last {
+			last = status.Status
+			for _, update := range updates {
+				update(status)
+			}
+		}
+		switch status.Status {
+		case StatusInvoicePaid, StatusTransactionClaimed:
+			return status, nil
+		case StatusSwapExpired, StatusInvoiceFailedToPay, StatusTransactionLockupFailed:
+			return status, &SwapFailedError{id, status.Status}
+		}
+
+		select {
+		case <-ctx.Done():
+			return status, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
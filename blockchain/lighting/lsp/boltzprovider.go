@@ -0,0 +1,211 @@
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+
+	"github.com/jualy007/GoTF/blockchain/btc"
+	"github.com/jualy007/GoTF/blockchain/lighting"
+	"github.com/jualy007/GoTF/blockchain/lighting/lsp/boltz"
+	"github.com/jualy007/GoTF/config"
+)
+
+// DefaultSwapDir is the directory of the swaps of a configuration without swap_dir.
+const DefaultSwapDir = "swaps"
+
+func init() {
+	Register("boltz", newBoltzProvider)
+}
+
+// boltzProvider swaps with Boltz, driving the swaps with a boltz.Machine.
+type boltzProvider struct {
+	client  *boltz.Client
+	adapter *lighting.Adapter
+	store   boltz.SwapStore
+	machine *boltz.Machine
+}
+
+func newBoltzProvider(info config.LspInfo, net *chaincfg.Params, adapter *lighting.Adapter, bitcoind *btc.Bitcoind) (SwapProvider, error) {
+	url := info.URL
+	if url == "" {
+		switch net {
+		case &chaincfg.MainNetParams:
+			url = boltz.MainnetURL
+		case &chaincfg.TestNet3Params:
+			url = boltz.TestnetURL
+		default:
+			return nil, fmt.Errorf("no public boltz on %v, set the url", net.Name)
+		}
+	}
+	var httpClient *http.Client
+	if info.Timeout > 0 {
+		httpClient = &http.Client{Timeout: time.Duration(info.Timeout) * time.Second}
+	}
+	dir := info.SwapDir
+	if dir == "" {
+		dir = DefaultSwapDir
+	}
+	store, err := boltz.NewFileStore(dir)
+	if err != nil {
+		return nil, err
+	}
+	client := boltz.NewClient(url, net, httpClient)
+	return &boltzProvider{
+		client:  client,
+		adapter: adapter,
+		store:   store,
+		machine: boltz.NewLndMachine(client, store, adapter, bitcoind),
+	}, nil
+}
+
+func (p *boltzProvider) Name() string {
+	return "boltz"
+}
+
+// Quote returns the fees of the BTC/BTC pair. The miner fee of a swap out is the lockup fee Boltz
+// charges and the claim fee it estimates for us.
+func (p *boltzProvider) Quote(ctx context.Context, direction Direction, amt btcutil.Amount) (*Quote, error) {
+	pairs, err := p.client.GetPairs()
+	if err != nil {
+		return nil, fmt.Errorf("GetPairs: %w", err)
+	}
+	pair, ok := pairs.Pairs["BTC/BTC"]
+	if !ok {
+		return nil, fmt.Errorf("no BTC/BTC pair")
+	}
+	minerFees := pair.Fees.MinerFees.BaseAsset
+	quote := &Quote{
+		Provider:   p.Name(),
+		Direction:  direction,
+		Amount:     int64(amt),
+		MinAmount:  pair.Limits.Minimal,
+		MaxAmount:  pair.Limits.Maximal,
+		ServiceFee: int64(math.Ceil(float64(amt) * pair.Fees.Percentage / 100)),
+	}
+	switch direction {
+	case SwapIn:
+		quote.MinerFee = minerFees.Normal
+	case SwapOut:
+		quote.MinerFee = minerFees.Reverse.Lockup + minerFees.Reverse.Claim
+	default:
+		return nil, fmt.Errorf("unknown direction %q", direction)
+	}
+	return quote, nil
+}
+
+func (p *boltzProvider) SwapIn(ctx context.Context, amt btcutil.Amount) (*Swap, error) {
+	ss, err := p.client.NewSubmarineSwap(ctx, p.adapter, amt)
+	if err != nil {
+		return nil, fmt.Errorf("NewSubmarineSwap: %w", err)
+	}
+	return p.start(ctx, boltz.RecordSubmarineSwap(ss))
+}
+
+func (p *boltzProvider) SwapOut(ctx context.Context, amt btcutil.Amount) (*Swap, error) {
+	rs, err := p.client.NewReverseSwap(amt)
+	if err != nil {
+		return nil, fmt.Errorf("NewReverseSwap: %w", err)
+	}
+	return p.start(ctx, boltz.RecordReverseSwap(rs))
+}
+
+// start saves a new swap and steps it once, funding or paying it.
+func (p *boltzProvider) start(ctx context.Context, record *boltz.SwapRecord) (*Swap, error) {
+	if err := p.machine.Add(record); err != nil {
+		return nil, fmt.Errorf("save swap %v: %w", record.ID, err)
+	}
+	record, err := p.machine.Step(ctx, record.ID)
+	if record == nil {
+		return nil, err
+	}
+	return p.swap(record), err
+}
+
+// Status returns the stored swap with the current status of Boltz.
+func (p *boltzProvider) Status(ctx context.Context, id string) (*Swap, error) {
+	record, err := p.store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	status, err := p.client.SwapStatus(id)
+	if err != nil {
+		return p.swap(record), fmt.Errorf("SwapStatus %v: %w", id, err)
+	}
+	record.BoltzStatus = status.Status
+	return p.swap(record), nil
+}
+
+func (p *boltzProvider) Claim(ctx context.Context, id string) (*Swap, error) {
+	return p.finish(ctx, id, boltz.ReverseSwaps, boltz.StateClaimed)
+}
+
+func (p *boltzProvider) Refund(ctx context.Context, id string) (*Swap, error) {
+	return p.finish(ctx, id, boltz.NormalSwaps, boltz.StateRefunded)
+}
+
+// finish steps the swap id of swapType and checks it reached state.
+func (p *boltzProvider) finish(ctx context.Context, id string, swapType string, state boltz.SwapState) (*Swap, error) {
+	record, err := p.store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if record.Type != swapType {
+		return p.swap(record), fmt.Errorf("swap %v is a %v swap", id, record.Type)
+	}
+	record, err = p.machine.Step(ctx, id)
+	if record == nil {
+		return nil, err
+	}
+	swap := p.swap(record)
+	if err != nil {
+		return swap, err
+	}
+	if record.State != state {
+		return swap, fmt.Errorf("swap %v is %v, not %v", id, record.State, state)
+	}
+	return swap, nil
+}
+
+// List returns the stored swaps, without asking Boltz for their status.
+func (p *boltzProvider) List(ctx context.Context) ([]*Swap, error) {
+	records, err := p.store.List()
+	if err != nil {
+		return nil, err
+	}
+	swaps := make([]*Swap, 0, len(records))
+	for _, record := range records {
+		swaps = append(swaps, p.swap(record))
+	}
+	return swaps, nil
+}
+
+func (p *boltzProvider) Run(ctx context.Context) {
+	p.machine.Run(ctx)
+}
+
+func (p *boltzProvider) swap(record *boltz.SwapRecord) *Swap {
+	direction := SwapOut
+	if record.Type == boltz.NormalSwaps {
+		direction = SwapIn
+	}
+	return &Swap{
+		ID:                 record.ID,
+		Provider:           p.Name(),
+		Direction:          direction,
+		State:              string(record.State),
+		Status:             record.BoltzStatus,
+		Amount:             record.Amount,
+		Invoice:            record.Invoice,
+		Address:            record.LockupAddress,
+		TimeoutBlockHeight: record.TimeoutBlockHeight,
+		LockupTxid:         record.LockupTxid,
+		SpendTxid:          record.SpendTxid,
+		Error:              record.Error,
+	}
+}
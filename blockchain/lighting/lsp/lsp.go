@@ -0,0 +1,127 @@
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+
+	"github.com/jualy007/GoTF/blockchain/btc"
+	"github.com/jualy007/GoTF/blockchain/lighting"
+	"github.com/jualy007/GoTF/config"
+)
+
+// DefaultProvider is the provider of a configuration naming none.
+const DefaultProvider = "boltz"
+
+// Direction is the way funds move in a swap.
+type Direction string
+
+const (
+	// SwapIn moves on-chain funds to the lightning balance, a submarine swap.
+	SwapIn Direction = "in"
+	// SwapOut moves lightning balance on chain, a reverse swap.
+	SwapOut Direction = "out"
+)
+
+// Quote is what a provider charges to swap Amount satoshis. MinerFee includes the fee of our own
+// claim of a swap out.
+type Quote struct {
+	Provider   string    `json:"provider"`
+	Direction  Direction `json:"direction"`
+	Amount     int64     `json:"amount"`
+	MinAmount  int64     `json:"min_amount"`
+	MaxAmount  int64     `json:"max_amount"`
+	ServiceFee int64     `json:"service_fee"`
+	MinerFee   int64     `json:"miner_fee"`
+}
+
+// Swap is a swap of a provider. Amount is locked up on chain at Address until TimeoutBlockHeight,
+// Invoice is paid on the lightning side. State is one of the boltz swap states, Status the last
+// status the provider reported.
+type Swap struct {
+	ID                 string    `json:"id"`
+	Provider           string    `json:"provider"`
+	Direction          Direction `json:"direction"`
+	State              string    `json:"state"`
+	Status             string    `json:"status,omitempty"`
+	Amount             int64     `json:"amount"`
+	Invoice            string    `json:"invoice"`
+	Address            string    `json:"address"`
+	TimeoutBlockHeight int64     `json:"timeout_block_height"`
+	LockupTxid         string    `json:"lockup_txid,omitempty"`
+	SpendTxid          string    `json:"spend_txid,omitempty"`
+	Error              string    `json:"error,omitempty"`
+}
+
+// SwapProvider swaps between the lightning balance and the on-chain wallet of a node. Swaps are
+// persisted by the provider so they can be claimed or refunded by id after a restart.
+type SwapProvider interface {
+	Name() string
+	Quote(ctx context.Context, direction Direction, amt btcutil.Amount) (*Quote, error)
+	// SwapIn creates a swap paying an invoice of amt of the node and funds its lockup.
+	SwapIn(ctx context.Context, amt btcutil.Amount) (*Swap, error)
+	// SwapOut creates a swap of amt and starts paying its invoice.
+	SwapOut(ctx context.Context, amt btcutil.Amount) (*Swap, error)
+	Status(ctx context.Context, id string) (*Swap, error)
+	// List returns every stored swap, finished or not.
+	List(ctx context.Context) ([]*Swap, error)
+	// Claim claims the lockup of a swap out to the node once the provider locked it up.
+	Claim(ctx context.Context, id string) (*Swap, error)
+	// Refund gets the lockup of a swap in back to the node once its timeout is reached.
+	Refund(ctx context.Context, id string) (*Swap, error)
+	// Run advances the pending swaps in the background until ctx is done, claiming and refunding
+	// them without further calls.
+	Run(ctx context.Context)
+}
+
+// Factory creates the provider configured by info on the chain net.
+type Factory func(info config.LspInfo, net *chaincfg.Params, adapter *lighting.Adapter, bitcoind *btc.Bitcoind) (SwapProvider, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = make(map[string]Factory)
+)
+
+// Register makes the provider name available to New. It panics if name is registered twice.
+func Register(name string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	if _, ok := factories[name]; ok {
+		panic("lsp: Register called twice for provider " + name)
+	}
+	factories[name] = factory
+}
+
+// Networks are the chain parameters by LspInfo network.
+var Networks = map[string]*chaincfg.Params{
+	"mainnet": &chaincfg.MainNetParams,
+	"testnet": &chaincfg.TestNet3Params,
+	"regtest": &chaincfg.RegressionNetParams,
+}
+
+// New returns the provider configured by info, swapping with the lnd of adapter and following the
+// chain of bitcoind.
+func New(info config.LspInfo, adapter *lighting.Adapter, bitcoind *btc.Bitcoind) (SwapProvider, error) {
+	network := info.Network
+	if network == "" {
+		network = "mainnet"
+	}
+	net, ok := Networks[network]
+	if !ok {
+		return nil, fmt.Errorf("unknown network %q", info.Network)
+	}
+	provider := info.Provider
+	if provider == "" {
+		provider = DefaultProvider
+	}
+	factoriesMu.RLock()
+	factory, ok := factories[provider]
+	factoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown swap provider %q", info.Provider)
+	}
+	return factory(info, net, adapter, bitcoind)
+}
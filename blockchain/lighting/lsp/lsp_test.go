@@ -0,0 +1,93 @@
+package lsp_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+
+	"github.com/jualy007/GoTF/blockchain/btc"
+	"github.com/jualy007/GoTF/blockchain/lighting"
+	"github.com/jualy007/GoTF/blockchain/lighting/lsp"
+	"github.com/jualy007/GoTF/config"
+)
+
+// fakeProvider is a SwapProvider remembering the configuration it was created with.
+type fakeProvider struct {
+	info config.LspInfo
+	net  *chaincfg.Params
+}
+
+func (p *fakeProvider) Name() string { return "fake" }
+
+func (p *fakeProvider) Quote(ctx context.Context, direction lsp.Direction, amt btcutil.Amount) (*lsp.Quote, error) {
+	return &lsp.Quote{Provider: p.Name(), Direction: direction, Amount: int64(amt)}, nil
+}
+
+func (p *fakeProvider) SwapIn(ctx context.Context, amt btcutil.Amount) (*lsp.Swap, error) {
+	return &lsp.Swap{ID: "in", Provider: p.Name(), Direction: lsp.SwapIn, Amount: int64(amt)}, nil
+}
+
+func (p *fakeProvider) SwapOut(ctx context.Context, amt btcutil.Amount) (*lsp.Swap, error) {
+	return &lsp.Swap{ID: "out", Provider: p.Name(), Direction: lsp.SwapOut, Amount: int64(amt)}, nil
+}
+
+func (p *fakeProvider) Status(ctx context.Context, id string) (*lsp.Swap, error) {
+	return &lsp.Swap{ID: id, Provider: p.Name()}, nil
+}
+
+func (p *fakeProvider) List(ctx context.Context) ([]*lsp.Swap, error) { return nil, nil }
+
+func (p *fakeProvider) Claim(ctx context.Context, id string) (*lsp.Swap, error) {
+	return p.Status(ctx, id)
+}
+
+func (p *fakeProvider) Refund(ctx context.Context, id string) (*lsp.Swap, error) {
+	return p.Status(ctx, id)
+}
+
+func (p *fakeProvider) Run(ctx context.Context) {}
+
+func init() {
+	lsp.Register("fake", func(info config.LspInfo, net *chaincfg.Params, _ *lighting.Adapter, _ *btc.Bitcoind) (lsp.SwapProvider, error) {
+		return &fakeProvider{info: info, net: net}, nil
+	})
+}
+
+func TestNewRegisteredProvider(t *testing.T) {
+	info := config.LspInfo{Provider: "fake", Network: "regtest", URL: "http://127.0.0.1:9001"}
+	provider, err := lsp.New(info, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake, ok := provider.(*fakeProvider)
+	if !ok {
+		t.Fatalf("New = %T, want the fake provider", provider)
+	}
+	if fake.info != info || fake.net != &chaincfg.RegressionNetParams {
+		t.Errorf("fake provider created with %+v on %v", fake.info, fake.net.Name)
+	}
+	swap, err := provider.SwapOut(context.Background(), 50000)
+	if err != nil || swap.Provider != "fake" {
+		t.Errorf("SwapOut = %+v, %v", swap, err)
+	}
+}
+
+func TestNewUnknownProvider(t *testing.T) {
+	if _, err := lsp.New(config.LspInfo{Provider: "loop"}, nil, nil); err == nil {
+		t.Error("New created an unregistered provider")
+	}
+	if _, err := lsp.New(config.LspInfo{Provider: "fake", Network: "signet"}, nil, nil); err == nil {
+		t.Error("New created a provider on an unknown network")
+	}
+}
+
+func TestRegisterTwice(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("registering boltz twice did not panic")
+		}
+	}()
+	lsp.Register("boltz", nil)
+}
@@ -0,0 +1,198 @@
+package lighting
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+
+	"github.com/jualy007/GoTF/config"
+	"github.com/lightningnetwork/lnd/lnrpc"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// MacaroonKind is one of the macaroons lnd creates on startup.
+type MacaroonKind string
+
+const (
+	ReadonlyMacaroon MacaroonKind = "readonly"
+	InvoiceMacaroon  MacaroonKind = "invoice"
+	AdminMacaroon    MacaroonKind = "admin"
+)
+
+// macaroonKinds is the order macaroons are tried in, least privileged first.
+var macaroonKinds = []MacaroonKind{ReadonlyMacaroon, InvoiceMacaroon, AdminMacaroon}
+
+func perm(entity, action string) *lnrpc.MacaroonPermission {
+	return &lnrpc.MacaroonPermission{Entity: entity, Action: action}
+}
+
+// permissions are the permissions lnd requires for the methods the adapter calls.
+// lnd v0.10 has no ListPermissions RPC, they are copied from its rpc servers.
+var permissions = map[string][]*lnrpc.MacaroonPermission{
+	"/lnrpc.Lightning/GetInfo":                {perm("info", "read")},
+	"/lnrpc.Lightning/QueryRoutes":            {perm("info", "read")},
+	"/lnrpc.Lightning/DecodePayReq":           {perm("offchain", "read")},
+	"/lnrpc.Lightning/AddInvoice":             {perm("invoices", "write")},
+	"/lnrpc.Lightning/LookupInvoice":          {perm("invoices", "read")},
+	"/lnrpc.Lightning/ListInvoices":           {perm("invoices", "read")},
+	"/lnrpc.Lightning/SubscribeInvoices":      {perm("invoices", "read")},
+	"/lnrpc.Lightning/SendPaymentSync":        {perm("offchain", "write")},
+	"/lnrpc.Lightning/ListPayments":           {perm("offchain", "read")},
+	"/lnrpc.Lightning/OpenChannelSync":        {perm("onchain", "write"), perm("offchain", "write")},
+	"/lnrpc.Lightning/CloseChannel":           {perm("onchain", "write"), perm("offchain", "write")},
+	"/lnrpc.Lightning/ListChannels":           {perm("offchain", "read")},
+	"/lnrpc.Lightning/PendingChannels":        {perm("offchain", "read")},
+	"/lnrpc.Lightning/SubscribeChannelEvents": {perm("offchain", "read")},
+	"/lnrpc.Lightning/DescribeGraph":          {perm("info", "read")},
+	"/lnrpc.Lightning/ConnectPeer":            {perm("peers", "write")},
+	"/lnrpc.Lightning/ListPeers":              {perm("peers", "read")},
+	"/lnrpc.Lightning/WalletBalance":          {perm("onchain", "read")},
+	"/lnrpc.Lightning/ChannelBalance":         {perm("offchain", "read")},
+	"/lnrpc.Lightning/NewAddress":             {perm("address", "write")},
+	"/lnrpc.Lightning/SendCoins":              {perm("onchain", "write")},
+	"/lnrpc.Lightning/SubscribeTransactions":  {perm("onchain", "read")},
+	"/lnrpc.Lightning/BakeMacaroon":           {perm("macaroon", "generate")},
+	"/routerrpc.Router/SendPaymentV2":         {perm("offchain", "write")},
+	"/routerrpc.Router/TrackPaymentV2":        {perm("offchain", "read")},
+	"/routerrpc.Router/SendToRoute":           {perm("offchain", "write")},
+}
+
+// grants are the permissions of the readonly and invoice macaroons lnd creates, the admin macaroon grants all.
+var grants = map[MacaroonKind][]*lnrpc.MacaroonPermission{
+	ReadonlyMacaroon: {
+		perm("info", "read"), perm("onchain", "read"), perm("offchain", "read"), perm("address", "read"),
+		perm("message", "read"), perm("peers", "read"), perm("invoices", "read"), perm("signer", "read"),
+	},
+	InvoiceMacaroon: {
+		perm("invoices", "read"), perm("invoices", "write"), perm("address", "read"), perm("address", "write"),
+		perm("onchain", "read"),
+	},
+}
+
+// ListPermissions returns the permissions lnd requires for the gRPC methods the adapter calls, by full method name.
+func ListPermissions() map[string][]*lnrpc.MacaroonPermission {
+	list := make(map[string][]*lnrpc.MacaroonPermission, len(permissions))
+	for method, perms := range permissions {
+		list[method] = append([]*lnrpc.MacaroonPermission(nil), perms...)
+	}
+	return list
+}
+
+// LoadMacaroon reads a macaroon from a file, or decodes it if source is not a file but hex encoded.
+func LoadMacaroon(source string) ([]byte, error) {
+	mac, err := ioutil.ReadFile(source)
+	if os.IsNotExist(err) {
+		if decoded, hexErr := hex.DecodeString(source); hexErr == nil && len(decoded) > 0 {
+			return decoded, nil
+		}
+	}
+	return mac, err
+}
+
+// loadMacaroons loads the configured macaroons, hex encoded.
+func loadMacaroons(info *config.LndInfo) (map[MacaroonKind]string, error) {
+	sources := map[MacaroonKind]string{
+		AdminMacaroon:    info.Macaroon,
+		InvoiceMacaroon:  info.InvoiceMacaroon,
+		ReadonlyMacaroon: info.ReadonlyMacaroon,
+	}
+	macaroons := make(map[MacaroonKind]string)
+	for kind, source := range sources {
+		if source == "" {
+			continue
+		}
+		mac, err := LoadMacaroon(source)
+		if err != nil {
+			return nil, &MacaroonError{Kind: kind, Source: source, Err: err}
+		}
+		macaroons[kind] = hex.EncodeToString(mac)
+	}
+	return macaroons, nil
+}
+
+// missingPermission returns the first of perms a macaroon of kind does not grant, nil if it grants them all.
+func missingPermission(kind MacaroonKind, perms []*lnrpc.MacaroonPermission) *lnrpc.MacaroonPermission {
+	if kind == AdminMacaroon {
+		return nil
+	}
+	for _, p := range perms {
+		granted := false
+		for _, g := range grants[kind] {
+			if g.Entity == p.Entity && g.Action == p.Action {
+				granted = true
+			}
+		}
+		if !granted {
+			return p
+		}
+	}
+	return nil
+}
+
+// macaroonFor returns the least privileged configured macaroon granting the permissions of method,
+// or a *MacaroonError naming the permission none grants. Methods with unknown permissions use the
+// admin macaroon. No macaroon is returned if none is configured, for lnd running with --no-macaroons.
+func macaroonFor(macaroons map[MacaroonKind]string, method string) (string, error) {
+	if len(macaroons) == 0 {
+		return "", nil
+	}
+	perms, known := permissions[method]
+	var configured MacaroonKind
+	for _, kind := range macaroonKinds {
+		mac, ok := macaroons[kind]
+		if !ok {
+			continue
+		}
+		if (known || kind == AdminMacaroon) && missingPermission(kind, perms) == nil {
+			return mac, nil
+		}
+		configured = kind
+	}
+	if !known {
+		return "", &MacaroonError{Kind: configured, Method: method}
+	}
+	return "", &MacaroonError{Kind: configured, Method: method, Permission: missingPermission(configured, perms)}
+}
+
+// macaroonInterceptors attach the macaroon chosen by macaroonFor to every call. Calls no configured
+// macaroon grants fail with its *MacaroonError without reaching lnd.
+func macaroonInterceptors(macaroons map[MacaroonKind]string) []grpc.DialOption {
+	attach := func(ctx context.Context, method string) (context.Context, error) {
+		mac, err := macaroonFor(macaroons, method)
+		if err != nil || mac == "" {
+			return ctx, err
+		}
+		return metadata.AppendToOutgoingContext(ctx, "macaroon", mac), nil
+	}
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(func(ctx context.Context, method string, req, reply interface{},
+			cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			ctx, err := attach(ctx, method)
+			if err != nil {
+				return err
+			}
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}),
+		grpc.WithChainStreamInterceptor(func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn,
+			method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			ctx, err := attach(ctx, method)
+			if err != nil {
+				return nil, err
+			}
+			return streamer(ctx, desc, cc, method, opts...)
+		}),
+	}
+}
+
+// BakeMacaroon bakes a new macaroon granting permissions, hex encoded. It requires the admin macaroon.
+func (adapter *Adapter) BakeMacaroon(ctx context.Context, permissions []*lnrpc.MacaroonPermission) (string, error) {
+	resp, err := adapter.lc.BakeMacaroon(ctx, &lnrpc.BakeMacaroonRequest{Permissions: permissions})
+	if err != nil {
+		return "", err
+	}
+	return resp.Macaroon, nil
+}
@@ -0,0 +1,197 @@
+package lighting
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+var testMacaroons = map[MacaroonKind]string{
+	ReadonlyMacaroon: "0201726561646f6e6c79",
+	InvoiceMacaroon:  "0201696e766f696365",
+	AdminMacaroon:    "020161646d696e",
+}
+
+func TestMacaroonFor(t *testing.T) {
+	without := func(kinds ...MacaroonKind) map[MacaroonKind]string {
+		macaroons := make(map[MacaroonKind]string)
+		for kind, mac := range testMacaroons {
+			macaroons[kind] = mac
+		}
+		for _, kind := range kinds {
+			delete(macaroons, kind)
+		}
+		return macaroons
+	}
+	tests := []struct {
+		macaroons  map[MacaroonKind]string
+		method     string
+		want       MacaroonKind
+		configured MacaroonKind
+		missing    *lnrpc.MacaroonPermission
+	}{
+		{testMacaroons, "/lnrpc.Lightning/GetInfo", ReadonlyMacaroon, "", nil},
+		{testMacaroons, "/lnrpc.Lightning/ListInvoices", ReadonlyMacaroon, "", nil},
+		{testMacaroons, "/lnrpc.Lightning/AddInvoice", InvoiceMacaroon, "", nil},
+		{testMacaroons, "/lnrpc.Lightning/NewAddress", InvoiceMacaroon, "", nil},
+		{testMacaroons, "/lnrpc.Lightning/SendPaymentSync", AdminMacaroon, "", nil},
+		{testMacaroons, "/lnrpc.Lightning/OpenChannelSync", AdminMacaroon, "", nil},
+		{testMacaroons, "/lnrpc.Lightning/BakeMacaroon", AdminMacaroon, "", nil},
+		{testMacaroons, "/lnrpc.Lightning/StopDaemon", AdminMacaroon, "", nil},
+		{without(ReadonlyMacaroon), "/lnrpc.Lightning/GetInfo", AdminMacaroon, "", nil},
+		{without(AdminMacaroon), "/lnrpc.Lightning/AddInvoice", InvoiceMacaroon, "", nil},
+		{without(AdminMacaroon), "/lnrpc.Lightning/SendPaymentSync", "", InvoiceMacaroon, perm("offchain", "write")},
+		{without(AdminMacaroon), "/lnrpc.Lightning/OpenChannelSync", "", InvoiceMacaroon, perm("onchain", "write")},
+		{without(AdminMacaroon, InvoiceMacaroon), "/lnrpc.Lightning/AddInvoice", "", ReadonlyMacaroon, perm("invoices", "write")},
+		{without(AdminMacaroon), "/lnrpc.Lightning/StopDaemon", "", InvoiceMacaroon, nil},
+	}
+	for _, test := range tests {
+		mac, err := macaroonFor(test.macaroons, test.method)
+		if test.want != "" {
+			if err != nil || mac != test.macaroons[test.want] {
+				t.Errorf("macaroonFor(%v, %v) = %v, %v, want the %v macaroon", test.macaroons, test.method, mac, err, test.want)
+			}
+			continue
+		}
+		var macErr *MacaroonError
+		if !errors.As(err, &macErr) || mac != "" {
+			t.Errorf("macaroonFor(%v, %v) = %v, %v, want a MacaroonError", test.macaroons, test.method, mac, err)
+			continue
+		}
+		if macErr.Kind != test.configured || macErr.Method != test.method {
+			t.Errorf("macaroonFor(%v, %v) = %+v", test.macaroons, test.method, macErr)
+		}
+		if (macErr.Permission == nil) != (test.missing == nil) ||
+			test.missing != nil && (macErr.Permission.Entity != test.missing.Entity || macErr.Permission.Action != test.missing.Action) {
+			t.Errorf("macaroonFor(%v, %v) misses %v, want %v", test.macaroons, test.method, macErr.Permission, test.missing)
+		}
+	}
+
+	// lnd running with --no-macaroons
+	if mac, err := macaroonFor(nil, "/lnrpc.Lightning/SendPaymentSync"); mac != "" || err != nil {
+		t.Errorf("macaroonFor() without macaroons = %v, %v", mac, err)
+	}
+}
+
+// macaroonServer records the macaroon of every call it serves, by method.
+type macaroonServer struct {
+	mu        sync.Mutex
+	macaroons map[string][]string
+}
+
+func (s *macaroonServer) handle(srv interface{}, stream grpc.ServerStream) error {
+	method, _ := grpc.MethodFromServerStream(stream)
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	s.mu.Lock()
+	s.macaroons[method] = md.Get("macaroon")
+	s.mu.Unlock()
+	// An empty message decodes as the response of any method
+	return stream.SendMsg(&lnrpc.GetInfoResponse{})
+}
+
+func (s *macaroonServer) called(method string) ([]string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	macaroons, ok := s.macaroons[method]
+	return macaroons, ok
+}
+
+// dialMacaroonServer connects to a macaroonServer through the interceptors of macaroons.
+func dialMacaroonServer(t *testing.T, macaroons map[MacaroonKind]string) (lnrpc.LightningClient, *macaroonServer) {
+	t.Helper()
+	server := &macaroonServer{macaroons: make(map[string][]string)}
+	listener := bufconn.Listen(1 << 20)
+	grpcServer := grpc.NewServer(grpc.UnknownServiceHandler(server.handle))
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	opts := append([]grpc.DialOption{
+		grpc.WithInsecure(),
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return listener.Dial()
+		}),
+	}, macaroonInterceptors(macaroons)...)
+	conn, err := grpc.Dial("bufnet", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return lnrpc.NewLightningClient(conn), server
+}
+
+func TestMacaroonInterceptors(t *testing.T) {
+	ctx := context.Background()
+	client, server := dialMacaroonServer(t, testMacaroons)
+
+	calls := []struct {
+		method string
+		call   func() error
+		want   MacaroonKind
+	}{
+		{"/lnrpc.Lightning/GetInfo", func() error {
+			_, err := client.GetInfo(ctx, &lnrpc.GetInfoRequest{})
+			return err
+		}, ReadonlyMacaroon},
+		{"/lnrpc.Lightning/AddInvoice", func() error {
+			_, err := client.AddInvoice(ctx, &lnrpc.Invoice{})
+			return err
+		}, InvoiceMacaroon},
+		{"/lnrpc.Lightning/SendPaymentSync", func() error {
+			_, err := client.SendPaymentSync(ctx, &lnrpc.SendRequest{})
+			return err
+		}, AdminMacaroon},
+		{"/lnrpc.Lightning/SubscribeInvoices", func() error {
+			stream, err := client.SubscribeInvoices(ctx, &lnrpc.InvoiceSubscription{})
+			if err != nil {
+				return err
+			}
+			_, err = stream.Recv()
+			return err
+		}, ReadonlyMacaroon},
+	}
+	for _, call := range calls {
+		if err := call.call(); err != nil {
+			t.Errorf("%v: %v", call.method, err)
+			continue
+		}
+		got, _ := server.called(call.method)
+		if len(got) != 1 || got[0] != testMacaroons[call.want] {
+			t.Errorf("%v sent macaroons %v, want the %v macaroon", call.method, got, call.want)
+		}
+	}
+
+	// Calls the configured macaroons do not grant fail before reaching lnd
+	client, server = dialMacaroonServer(t, map[MacaroonKind]string{
+		ReadonlyMacaroon: testMacaroons[ReadonlyMacaroon],
+		InvoiceMacaroon:  testMacaroons[InvoiceMacaroon],
+	})
+	_, err := client.SendPaymentSync(ctx, &lnrpc.SendRequest{})
+	var macErr *MacaroonError
+	if !errors.As(err, &macErr) || macErr.Permission == nil || macErr.Permission.Entity != "offchain" || macErr.Permission.Action != "write" {
+		t.Errorf("SendPaymentSync with an invoice macaroon: %v, want a MacaroonError of offchain:write", err)
+	}
+	if code := status.Code(err); code != codes.PermissionDenied {
+		t.Errorf("SendPaymentSync with an invoice macaroon: code %v, want %v", code, codes.PermissionDenied)
+	}
+	_, err = client.CloseChannel(ctx, &lnrpc.CloseChannelRequest{})
+	if !errors.As(err, &macErr) || macErr.Method != "/lnrpc.Lightning/CloseChannel" {
+		t.Errorf("CloseChannel with an invoice macaroon: %v, want a MacaroonError", err)
+	}
+	for _, method := range []string{"/lnrpc.Lightning/SendPaymentSync", "/lnrpc.Lightning/CloseChannel"} {
+		if _, ok := server.called(method); ok {
+			t.Errorf("%v reached lnd", method)
+		}
+	}
+	if _, err := client.AddInvoice(ctx, &lnrpc.Invoice{}); err != nil {
+		t.Errorf("AddInvoice with an invoice macaroon: %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package lighting
+
+import (
+	"fmt"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/routerrpc"
+
+	"golang.org/x/net/context"
+)
+
+// PaymentError is returned when lnd accepted a payment but it failed.
+type PaymentError struct {
+	PaymentHash string
+	Reason      string
+}
+
+func (e *PaymentError) Error() string {
+	return fmt.Sprintf("payment %v failed: %v", e.PaymentHash, e.Reason)
+}
+
+// SendPaymentSync pays a payment request, or an amount to a destination, and waits for the result.
+// A payment that fails after being sent returns the response with a *PaymentError.
+func (adapter *Adapter) SendPaymentSync(ctx context.Context, req *lnrpc.SendRequest) (*lnrpc.SendResponse, error) {
+	resp, err := adapter.lc.SendPaymentSync(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.PaymentError != "" {
+		return resp, &PaymentError{fmt.Sprintf("%x", resp.PaymentHash), resp.PaymentError}
+	}
+	return resp, nil
+}
+
+// SendPaymentV2 sends a payment through the router and waits for it to succeed or fail.
+// updates, if given, is called with every in flight state of the payment.
+// A failed payment returns the final payment with a *PaymentError.
+func (adapter *Adapter) SendPaymentV2(ctx context.Context, req *routerrpc.SendPaymentRequest, updates ...func(*lnrpc.Payment)) (*lnrpc.Payment, error) {
+	stream, err := adapter.rc.SendPaymentV2(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return waitPayment(stream, updates...)
+}
+
+// paymentStream is the stream returned by SendPaymentV2 and TrackPaymentV2.
+type paymentStream interface {
+	Recv() (*lnrpc.Payment, error)
+}
+
+// waitPayment receives payment updates until the payment succeeds or fails.
+func waitPayment(stream paymentStream, updates ...func(*lnrpc.Payment)) (*lnrpc.Payment, error) {
+	for {
+		payment, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		switch payment.Status {
+		case lnrpc.Payment_SUCCEEDED:
+			return payment, nil
+		case lnrpc.Payment_FAILED:
+			return payment, &PaymentError{payment.PaymentHash, payment.FailureReason.String()}
+		}
+		for _, update := range updates {
+			update(payment)
+		}
+	}
+}
+
+// ListPayments returns the outgoing payments, see lnrpc.ListPaymentsRequest for paging.
+func (adapter *Adapter) ListPayments(ctx context.Context, req *lnrpc.ListPaymentsRequest) (*lnrpc.ListPaymentsResponse, error) {
+	return adapter.lc.ListPayments(ctx, req)
+}
+
+// QueryRoutes queries routes to req.PubKey, see lnrpc.QueryRoutesRequest for the options.
+func (adapter *Adapter) QueryRoutes(ctx context.Context, req *lnrpc.QueryRoutesRequest) (*lnrpc.QueryRoutesResponse, error) {
+	return adapter.lc.QueryRoutes(ctx, req)
+}
+
+// SendToRoute sends a single HTLC of paymentHash along route and waits for it to settle or fail.
+// A failed HTLC is not an error, it is returned in the Failure of the response. This is the
+// routerrpc SendToRoute of lnd v0.10, which has no SendToRouteV2 returning an HTLCAttempt yet.
+func (adapter *Adapter) SendToRoute(ctx context.Context, paymentHash []byte, route *lnrpc.Route) (*routerrpc.SendToRouteResponse, error) {
+	return adapter.rc.SendToRoute(ctx, &routerrpc.SendToRouteRequest{PaymentHash: paymentHash, Route: route})
+}
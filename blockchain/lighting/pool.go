@@ -0,0 +1,179 @@
+package lighting
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jualy007/GoTF/config"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/connectivity"
+)
+
+// Reconnect backoff of pooled connections. gRPC redials dropped connections itself,
+// the pool applies the same backoff to nodes whose adapter could not be created.
+var poolBackoff = backoff.Config{
+	BaseDelay:  time.Second,
+	Multiplier: 1.6,
+	Jitter:     0.2,
+	MaxDelay:   30 * time.Second,
+}
+
+// UnknownNodeError is returned for a node name that is not configured.
+type UnknownNodeError struct {
+	Name string
+}
+
+func (e *UnknownNodeError) Error() string {
+	return fmt.Sprintf("No configured lnd node %v", e.Name)
+}
+
+// Pool keeps one long-lived gRPC connection per configured lnd node, shared by all callers.
+// Adapters returned by Get must not be closed, Close the pool instead.
+type Pool struct {
+	mu    sync.Mutex
+	nodes map[string]*config.LndInfo
+	opts  []grpc.DialOption
+	conns map[string]*poolConn
+}
+
+type poolConn struct {
+	adapter *Adapter
+	err     error
+	retries int
+	retryAt time.Time
+}
+
+// NewPool returns a pool of the nodes, dialed with the extra dial options opts.
+func NewPool(nodes map[string]*config.LndInfo, opts ...grpc.DialOption) *Pool {
+	return &Pool{nodes: nodes, opts: opts, conns: make(map[string]*poolConn)}
+}
+
+// Names returns the configured node names, sorted.
+func (p *Pool) Names() []string {
+	names := make([]string, 0, len(p.nodes))
+	for name := range p.nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get returns the adapter of the node, dialing it on first use. If creating the adapter
+// failed, the error is returned until the backoff delay passed and it is retried.
+func (p *Pool) Get(name string) (*Adapter, error) {
+	info, ok := p.nodes[name]
+	if !ok {
+		return nil, &UnknownNodeError{name}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	conn := p.conns[name]
+	if conn == nil {
+		conn = &poolConn{}
+		p.conns[name] = conn
+	}
+	if conn.adapter != nil {
+		return conn.adapter, nil
+	}
+	if time.Now().Before(conn.retryAt) {
+		return nil, conn.err
+	}
+
+	opts := append([]grpc.DialOption{grpc.WithConnectParams(grpc.ConnectParams{
+		Backoff:           poolBackoff,
+		MinConnectTimeout: 20 * time.Second,
+	})}, p.opts...)
+	adapter, err := NewAdapter(info, opts...)
+	if err != nil {
+		conn.err = err
+		conn.retryAt = time.Now().Add(backoffDelay(conn.retries))
+		conn.retries++
+		return nil, err
+	}
+	conn.adapter, conn.err, conn.retries = &adapter, nil, 0
+	return conn.adapter, nil
+}
+
+// State returns the connectivity state of the node, dialing it on first use.
+// A node whose adapter could not be created is in TransientFailure.
+func (p *Pool) State(name string) (connectivity.State, error) {
+	adapter, err := p.Get(name)
+	if _, unknown := err.(*UnknownNodeError); unknown {
+		return connectivity.Shutdown, err
+	}
+	if err != nil {
+		return connectivity.TransientFailure, err
+	}
+	return adapter.State(), nil
+}
+
+// Ready reports whether the connection to the node is established.
+func (p *Pool) Ready(name string) bool {
+	state, _ := p.State(name)
+	return state == connectivity.Ready
+}
+
+// Readiness returns the connectivity state of every configured node.
+func (p *Pool) Readiness() map[string]connectivity.State {
+	states := make(map[string]connectivity.State, len(p.nodes))
+	for _, name := range p.Names() {
+		states[name], _ = p.State(name)
+	}
+	return states
+}
+
+// WaitReady blocks until the connection to the node is established or ctx is done.
+func (p *Pool) WaitReady(ctx context.Context, name string) error {
+	for {
+		adapter, err := p.Get(name)
+		if _, unknown := err.(*UnknownNodeError); unknown {
+			return err
+		}
+		if err == nil {
+			state := adapter.State()
+			if state == connectivity.Ready {
+				return nil
+			}
+			if !adapter.cc.WaitForStateChange(ctx, state) {
+				return ctx.Err()
+			}
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(poolBackoff.BaseDelay):
+		}
+	}
+}
+
+// Close closes every connection of the pool.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for name, conn := range p.conns {
+		if conn.adapter != nil {
+			conn.adapter.Close()
+		}
+		delete(p.conns, name)
+	}
+}
+
+// backoffDelay returns the delay before retry number retries, without jitter.
+func backoffDelay(retries int) time.Duration {
+	delay := float64(poolBackoff.BaseDelay)
+	for i := 0; i < retries && delay < float64(poolBackoff.MaxDelay); i++ {
+		delay *= poolBackoff.Multiplier
+	}
+	if delay > float64(poolBackoff.MaxDelay) {
+		delay = float64(poolBackoff.MaxDelay)
+	}
+	return time.Duration(delay)
+}
@@ -0,0 +1,84 @@
+package probe
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"github.com/jualy007/GoTF/blockchain/lighting"
+	"github.com/jualy007/GoTF/config"
+	"github.com/urfave/cli/v2"
+)
+
+// Command returns the 'probe' command probing targets from a node configured in
+// application.yaml of --configdir.
+func Command() *cli.Command {
+	return &cli.Command{
+		Name:  "probe",
+		Usage: "Probe routes to destinations with payments that can not settle and report their reliability",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "node", Required: true, Usage: "Configured lnd node sending the probes"},
+			&cli.StringFlag{Name: "file", Required: true, Usage: "YAML file of the targets"},
+			&cli.IntFlag{Name: "attempts", Value: DefaultAttempts, Usage: "Probes per target"},
+			&cli.DurationFlag{Name: "probe-timeout", Value: DefaultTimeout, Usage: "Time to wait for a single probe"},
+			&cli.DurationFlag{Name: "timeout", Value: 30 * time.Minute, Usage: "Time to wait for all probes"},
+			&cli.StringFlag{Name: "out", Usage: "File to write the JSON report to"},
+			&cli.Float64Flag{Name: "min-success-rate", Usage: "Exit with an error if the overall success rate is lower, 0 to 1"},
+		},
+		Action: probeAction,
+	}
+}
+
+func probeAction(cctx *cli.Context) error {
+	if cctx.String("configdir") == "" {
+		return cli.Exit("Required flag \"configdir\" not set", 1)
+	}
+	cfg := config.GetCfg(context.WithValue(context.Background(), "configfile", path.Join(cctx.String("configdir"), "application.yaml")))
+	if cfg == nil {
+		return cli.Exit("Application configuration not loaded", 1)
+	}
+	if cctx.Int("attempts") <= 0 {
+		return cli.Exit("attempts must be positive", 1)
+	}
+
+	targets, err := LoadTargets(cctx.String("file"))
+	if err != nil {
+		return err
+	}
+	pool := lighting.NewPool(cfg.Lnds)
+	defer pool.Close()
+	adapter, err := pool.Get(cctx.String("node"))
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cctx.Duration("timeout"))
+	defer cancel()
+	prober := NewProber(adapter)
+	prober.Attempts, prober.Timeout = cctx.Int("attempts"), cctx.Duration("probe-timeout")
+	report := prober.Run(ctx, cctx.String("node"), targets)
+
+	if out := cctx.String("out"); out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		if err := report.WriteJSON(f); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	if err := report.WriteTable(os.Stdout); err != nil {
+		return err
+	}
+
+	if min := cctx.Float64("min-success-rate"); report.Summary.SuccessRate < min {
+		return cli.Exit(fmt.Sprintf("Success rate %.2f below %.2f", report.Summary.SuccessRate, min), 1)
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package probe
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/jualy007/GoTF/blockchain/lighting"
+	"github.com/lightningnetwork/lnd/lnrpc"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Defaults of a Prober.
+const (
+	DefaultAttempts = 3
+	DefaultTimeout  = time.Minute
+)
+
+// Target is a destination probed with an amount, for example
+//
+//	targets:
+//	  - {destination: 02b5...e1, amount: 10000}
+//	  - {destination: 03c2...7f, amount: 250000}
+type Target struct {
+	Destination string `yaml:"destination" json:"destination"`
+	Amount      int64  `yaml:"amount" json:"amount"`
+}
+
+type targetFile struct {
+	Targets []Target `yaml:"targets"`
+}
+
+// LoadTargets reads the targets of a YAML file.
+func LoadTargets(path string) ([]Target, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file targetFile
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, err
+	}
+	for i, t := range file.Targets {
+		if len(t.Destination) != 66 {
+			return nil, fmt.Errorf("target %d: invalid destination %q", i, t.Destination)
+		}
+		if t.Amount <= 0 {
+			return nil, fmt.Errorf("target %d: amount must be positive", i)
+		}
+	}
+	return file.Targets, nil
+}
+
+// Outcome classifies the result of a probe.
+type Outcome string
+
+const (
+	// Success is a probe that reached the destination, which failed it for its unknown payment hash.
+	Success Outcome = "success"
+	// TemporaryFailure is a probe failed on the way for liquidity or channel policy, which may succeed later.
+	TemporaryFailure Outcome = "temporary_failure"
+	// PermanentFailure is a probe failed on the way for a reason retrying does not fix.
+	PermanentFailure Outcome = "permanent_failure"
+	// NoRoute is a probe for which lnd found no route.
+	NoRoute Outcome = "no_route"
+	// Error is a probe that failed with an rpc error.
+	Error Outcome = "error"
+)
+
+// temporaryFailures are the failures of forwarding nodes that can go away without changes to the route.
+var temporaryFailures = map[lnrpc.Failure_FailureCode]bool{
+	lnrpc.Failure_TEMPORARY_CHANNEL_FAILURE: true,
+	lnrpc.Failure_TEMPORARY_NODE_FAILURE:    true,
+	lnrpc.Failure_CHANNEL_DISABLED:          true,
+	lnrpc.Failure_FEE_INSUFFICIENT:          true,
+	lnrpc.Failure_INCORRECT_CLTV_EXPIRY:     true,
+	lnrpc.Failure_EXPIRY_TOO_SOON:           true,
+	lnrpc.Failure_AMOUNT_BELOW_MINIMUM:      true,
+}
+
+// Attempt is a single probe. FailureSource is the public key of the node that failed the HTLC,
+// empty for the probing node itself.
+type Attempt struct {
+	Outcome       Outcome `json:"outcome"`
+	FailureCode   string  `json:"failure_code,omitempty"`
+	FailureSource string  `json:"failure_source,omitempty"`
+	Hops          int     `json:"hops"`
+	FeeMsat       int64   `json:"fee_msat"`
+	TimeLock      uint32  `json:"time_lock"`
+	LatencyMs     int64   `json:"latency_ms"`
+	Error         string  `json:"error,omitempty"`
+}
+
+// Prober sends probe payments from a node. Each attempt queries a route with lnd's mission control,
+// which learns from the failures of earlier attempts, and sends an HTLC with a random payment hash
+// along it. The destination can not settle it, so a probe failing at the destination reached it.
+type Prober struct {
+	adapter *lighting.Adapter
+
+	// Attempts is the number of probes per target.
+	Attempts int
+	// Timeout limits a single probe.
+	Timeout time.Duration
+}
+
+func NewProber(adapter *lighting.Adapter) *Prober {
+	return &Prober{adapter: adapter, Attempts: DefaultAttempts, Timeout: DefaultTimeout}
+}
+
+// Probe probes target Attempts times. It stops early if ctx is done.
+func (p *Prober) Probe(ctx context.Context, target Target) []Attempt {
+	attempts := make([]Attempt, 0, p.Attempts)
+	for i := 0; i < p.Attempts && ctx.Err() == nil; i++ {
+		attempts = append(attempts, p.attempt(ctx, target))
+	}
+	return attempts
+}
+
+func (p *Prober) attempt(ctx context.Context, target Target) Attempt {
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+	start := time.Now()
+	attempt := p.send(ctx, target)
+	attempt.LatencyMs = time.Since(start).Milliseconds()
+	return attempt
+}
+
+func (p *Prober) send(ctx context.Context, target Target) Attempt {
+	routes, err := p.adapter.QueryRoutes(ctx, &lnrpc.QueryRoutesRequest{
+		PubKey:            target.Destination,
+		Amt:               target.Amount,
+		UseMissionControl: true,
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "unable to find a path") {
+			return Attempt{Outcome: NoRoute}
+		}
+		return Attempt{Outcome: Error, Error: err.Error()}
+	}
+	if len(routes.Routes) == 0 {
+		return Attempt{Outcome: NoRoute}
+	}
+	route := routes.Routes[0]
+	attempt := Attempt{Hops: len(route.Hops), FeeMsat: route.TotalFeesMsat, TimeLock: route.TotalTimeLock}
+
+	paymentHash := make([]byte, 32)
+	if _, err := rand.Read(paymentHash); err != nil {
+		attempt.Outcome, attempt.Error = Error, err.Error()
+		return attempt
+	}
+	resp, err := p.adapter.SendToRoute(ctx, paymentHash, route)
+	if err != nil {
+		attempt.Outcome, attempt.Error = Error, err.Error()
+		return attempt
+	}
+	if resp.Failure == nil {
+		// Settled although nobody knows the preimage of a random hash, the destination reached anyway.
+		attempt.Outcome = Success
+		return attempt
+	}
+
+	failure := resp.Failure
+	attempt.FailureCode = failure.Code.String()
+	if index := int(failure.FailureSourceIndex); index > 0 && index <= len(route.Hops) {
+		attempt.FailureSource = route.Hops[index-1].PubKey
+	}
+	switch {
+	case failure.Code == lnrpc.Failure_INCORRECT_OR_UNKNOWN_PAYMENT_DETAILS && int(failure.FailureSourceIndex) == len(route.Hops):
+		attempt.Outcome = Success
+	case temporaryFailures[failure.Code]:
+		attempt.Outcome = TemporaryFailure
+	default:
+		attempt.Outcome = PermanentFailure
+	}
+	return attempt
+}
+
+// Run probes every target and returns the report. node names the probing node in the report.
+func (p *Prober) Run(ctx context.Context, node string, targets []Target) *Report {
+	report := &Report{Node: node, Started: time.Now()}
+	for _, target := range targets {
+		if ctx.Err() != nil {
+			break
+		}
+		report.Targets = append(report.Targets, newTargetReport(target, p.Probe(ctx, target)))
+	}
+	report.Finished = time.Now()
+	report.Summary = summarize(report.Targets)
+	return report
+}
@@ -0,0 +1,66 @@
+package probe
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jualy007/GoTF/blockchain/lighting/lndtest"
+	"github.com/lightningnetwork/lnd/lnrpc"
+)
+
+func TestSendOutcomes(t *testing.T) {
+	alice, err := lndtest.NewServer("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer alice.Close()
+	bob, err := lndtest.NewServer("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bob.Close()
+	alice.AddChannel(bob, 100000, 0)
+	adapter, err := alice.Adapter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer adapter.Close()
+	prober := NewProber(adapter)
+	target := Target{Destination: bob.PubKey(), Amount: 1000}
+
+	tests := []struct {
+		name        string
+		method      string
+		fault       lndtest.Fault
+		target      Target
+		outcome     Outcome
+		failureCode string
+	}{
+		{name: "reached", target: target, outcome: Success, failureCode: "INCORRECT_OR_UNKNOWN_PAYMENT_DETAILS"},
+		{name: "temporary channel failure", method: "SendToRoute", fault: lndtest.TemporaryChannelFailure,
+			target: target, outcome: TemporaryFailure, failureCode: "TEMPORARY_CHANNEL_FAILURE"},
+		{name: "permanent failure", method: "SendToRoute", fault: lndtest.Fault{Failure: lnrpc.Failure_UNKNOWN_NEXT_PEER},
+			target: target, outcome: PermanentFailure, failureCode: "UNKNOWN_NEXT_PEER"},
+		{name: "no route", method: "QueryRoutes", fault: lndtest.NoRoute, target: target, outcome: NoRoute},
+		{name: "no liquidity", target: Target{Destination: bob.PubKey(), Amount: 200000}, outcome: NoRoute},
+		{name: "unavailable", method: "QueryRoutes", fault: lndtest.Unavailable, target: target, outcome: Error},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.method != "" {
+				alice.Inject(test.method, test.fault)
+				defer alice.Clear(test.method)
+			}
+			attempt := prober.send(context.Background(), test.target)
+			if attempt.Outcome != test.outcome || attempt.FailureCode != test.failureCode {
+				t.Errorf("send = %+v, want outcome %v and failure code %q", attempt, test.outcome, test.failureCode)
+			}
+			if test.outcome == Success && attempt.FailureSource != bob.PubKey() {
+				t.Errorf("failure source %v, want the destination", attempt.FailureSource)
+			}
+			if test.outcome == TemporaryFailure && attempt.FailureSource != "" {
+				t.Errorf("failure source %v, want the probing node", attempt.FailureSource)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package probe
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// Report is the result of probing targets from a node. Fees and hop counts are averaged over
+// the successful probes, the routes of failed probes were not usable.
+type Report struct {
+	Node     string         `json:"node"`
+	Started  time.Time      `json:"started"`
+	Finished time.Time      `json:"finished"`
+	Targets  []TargetReport `json:"targets"`
+	Summary  Summary        `json:"summary"`
+}
+
+// TargetReport are the probes of a target.
+type TargetReport struct {
+	Target   Target    `json:"target"`
+	Attempts []Attempt `json:"attempts"`
+	Summary  Summary   `json:"summary"`
+}
+
+// Summary aggregates probes. Outcomes counts the probes by outcome, Failures by failure code.
+type Summary struct {
+	Probes      int             `json:"probes"`
+	Successes   int             `json:"successes"`
+	SuccessRate float64         `json:"success_rate"`
+	AvgFeeMsat  float64         `json:"avg_fee_msat"`
+	MaxFeeMsat  int64           `json:"max_fee_msat"`
+	AvgHops     float64         `json:"avg_hops"`
+	MaxHops     int             `json:"max_hops"`
+	Outcomes    map[Outcome]int `json:"outcomes"`
+	Failures    map[string]int  `json:"failures,omitempty"`
+}
+
+func newTargetReport(target Target, attempts []Attempt) TargetReport {
+	return TargetReport{Target: target, Attempts: attempts, Summary: summarizeAttempts(attempts)}
+}
+
+func summarize(targets []TargetReport) Summary {
+	var attempts []Attempt
+	for _, t := range targets {
+		attempts = append(attempts, t.Attempts...)
+	}
+	return summarizeAttempts(attempts)
+}
+
+func summarizeAttempts(attempts []Attempt) Summary {
+	s := Summary{Probes: len(attempts), Outcomes: make(map[Outcome]int)}
+	var fees int64
+	var hops int
+	for _, a := range attempts {
+		s.Outcomes[a.Outcome]++
+		if a.FailureCode != "" && a.Outcome != Success {
+			if s.Failures == nil {
+				s.Failures = make(map[string]int)
+			}
+			s.Failures[a.FailureCode]++
+		}
+		if a.Outcome != Success {
+			continue
+		}
+		s.Successes++
+		fees += a.FeeMsat
+		hops += a.Hops
+		if a.FeeMsat > s.MaxFeeMsat {
+			s.MaxFeeMsat = a.FeeMsat
+		}
+		if a.Hops > s.MaxHops {
+			s.MaxHops = a.Hops
+		}
+	}
+	if s.Probes > 0 {
+		s.SuccessRate = float64(s.Successes) / float64(s.Probes)
+	}
+	if s.Successes > 0 {
+		s.AvgFeeMsat = float64(fees) / float64(s.Successes)
+		s.AvgHops = float64(hops) / float64(s.Successes)
+	}
+	return s
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteTable writes a line per target and the summary as a table.
+func (r *Report) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "DESTINATION\tAMOUNT\tSUCCESS\tAVG FEE MSAT\tAVG HOPS\tFAILURES\n")
+	for _, t := range r.Targets {
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%.0f\t%.1f\t%v\n", t.Target.Destination, t.Target.Amount,
+			rate(t.Summary), t.Summary.AvgFeeMsat, t.Summary.AvgHops, failures(t.Summary))
+	}
+	fmt.Fprintf(tw, "total\t\t%v\t%.0f\t%.1f\t%v\n", rate(r.Summary), r.Summary.AvgFeeMsat, r.Summary.AvgHops, failures(r.Summary))
+	return tw.Flush()
+}
+
+func rate(s Summary) string {
+	return fmt.Sprintf("%d/%d (%.0f%%)", s.Successes, s.Probes, s.SuccessRate*100)
+}
+
+// failures lists the failures and outcomes other than success, most frequent first.
+func failures(s Summary) string {
+	counts := make(map[string]int)
+	for code, n := range s.Failures {
+		counts[code] = n
+	}
+	for outcome, n := range s.Outcomes {
+		if outcome == NoRoute || outcome == Error {
+			counts[string(outcome)] = n
+		}
+	}
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	list := ""
+	for i, name := range names {
+		if i > 0 {
+			list += ", "
+		}
+		list += fmt.Sprintf("%v=%d", name, counts[name])
+	}
+	if list == "" {
+		return "-"
+	}
+	return list
+}
@@ -0,0 +1,87 @@
+package lighting
+
+import (
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/routerrpc"
+
+	"golang.org/x/net/context"
+)
+
+// The Subscribe methods block calling fn with every event until ctx is done, the stream
+// fails or fn returns an error, and return that error.
+
+// SubscribeInvoices streams invoice additions after addIndex and settlements after settleIndex,
+// zero indexes only stream new events.
+func (adapter *Adapter) SubscribeInvoices(ctx context.Context, addIndex uint64, settleIndex uint64, fn func(*lnrpc.Invoice) error) error {
+	stream, err := adapter.lc.SubscribeInvoices(ctx, &lnrpc.InvoiceSubscription{
+		AddIndex:    addIndex,
+		SettleIndex: settleIndex,
+	})
+	if err != nil {
+		return err
+	}
+	for {
+		invoice, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		if err := fn(invoice); err != nil {
+			return err
+		}
+	}
+}
+
+// SubscribeChannelEvents streams channels being opened, closed, activated and deactivated.
+func (adapter *Adapter) SubscribeChannelEvents(ctx context.Context, fn func(*lnrpc.ChannelEventUpdate) error) error {
+	stream, err := adapter.lc.SubscribeChannelEvents(ctx, &lnrpc.ChannelEventSubscription{})
+	if err != nil {
+		return err
+	}
+	for {
+		event, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+}
+
+// SubscribeTransactions streams on-chain transactions of the lnd wallet as they are seen and confirmed.
+func (adapter *Adapter) SubscribeTransactions(ctx context.Context, fn func(*lnrpc.Transaction) error) error {
+	stream, err := adapter.lc.SubscribeTransactions(ctx, &lnrpc.GetTransactionsRequest{})
+	if err != nil {
+		return err
+	}
+	for {
+		tx, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		if err := fn(tx); err != nil {
+			return err
+		}
+	}
+}
+
+// TrackPaymentV2 streams the states of a payment by hash, starting with the current one,
+// and returns nil once the payment succeeded or failed.
+func (adapter *Adapter) TrackPaymentV2(ctx context.Context, paymentHash []byte, fn func(*lnrpc.Payment) error) error {
+	stream, err := adapter.rc.TrackPaymentV2(ctx, &routerrpc.TrackPaymentRequest{PaymentHash: paymentHash})
+	if err != nil {
+		return err
+	}
+	for {
+		payment, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		if err := fn(payment); err != nil {
+			return err
+		}
+		if payment.Status == lnrpc.Payment_SUCCEEDED || payment.Status == lnrpc.Payment_FAILED {
+			return nil
+		}
+	}
+}
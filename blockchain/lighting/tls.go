@@ -0,0 +1,96 @@
+package lighting
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/jualy007/GoTF/config"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+const pemCertificate = "-----BEGIN CERTIFICATE-----"
+
+// TransportCredentials returns the TLS credentials of a node. info.Cert is a file path or the
+// PEM encoded certificate itself, without one the system roots are used. info.ServerName
+// overrides the host name the certificate is verified against.
+func TransportCredentials(info *config.LndInfo) (credentials.TransportCredentials, error) {
+	tlsConfig := &tls.Config{ServerName: info.ServerName}
+	if info.Cert != "" {
+		pool, err := LoadCertPool(info.Cert)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+// LoadCertPool reads the PEM encoded certificates of cert, a file path or the PEM content,
+// and fails if any of them is expired or not yet valid.
+func LoadCertPool(cert string) (*x509.CertPool, error) {
+	source, data := "inline", []byte(cert)
+	if !strings.Contains(cert, pemCertificate) {
+		var err error
+		source = cert
+		if data, err = ioutil.ReadFile(cert); err != nil {
+			return nil, &CertError{source, err}
+		}
+	}
+
+	pool := x509.NewCertPool()
+	count, now := 0, time.Now()
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		c, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, &CertError{source, err}
+		}
+		if now.After(c.NotAfter) {
+			return nil, &CertError{source, fmt.Errorf("expired on %v", c.NotAfter.Format(time.RFC3339))}
+		}
+		if now.Before(c.NotBefore) {
+			return nil, &CertError{source, fmt.Errorf("not valid before %v", c.NotBefore.Format(time.RFC3339))}
+		}
+		pool.AddCert(c)
+		count++
+	}
+	if count == 0 {
+		return nil, &CertError{source, errors.New("no PEM encoded certificate found")}
+	}
+	return pool, nil
+}
+
+// transportInterceptors wrap Unavailable errors of calls to address in a *TransportError.
+func transportInterceptors(address string) []grpc.DialOption {
+	wrap := func(err error) error {
+		if status.Code(err) == codes.Unavailable {
+			return &TransportError{address, err}
+		}
+		return err
+	}
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(func(ctx context.Context, method string, req, reply interface{},
+			cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			return wrap(invoker(ctx, method, req, reply, cc, opts...))
+		}),
+		grpc.WithChainStreamInterceptor(func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn,
+			method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			stream, err := streamer(ctx, desc, cc, method, opts...)
+			return stream, wrap(err)
+		}),
+	}
+}
@@ -0,0 +1,69 @@
+package topology
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path"
+	"time"
+
+	"github.com/jualy007/GoTF/blockchain/btc"
+	"github.com/jualy007/GoTF/blockchain/lighting"
+	"github.com/jualy007/GoTF/config"
+	"github.com/urfave/cli/v2"
+)
+
+// Command returns the 'topology' command setting up a topology on the nodes configured in
+// application.yaml of --configdir.
+func Command() *cli.Command {
+	return &cli.Command{
+		Name:  "topology",
+		Usage: "Set up a network of the configured lnd nodes funded from the regtest bitcoind",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "up",
+				Usage: "Connect peers, fund wallets, open channels and wait until the graph is gossiped",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "file", Required: true, Usage: "YAML topology file"},
+					&cli.DurationFlag{Name: "timeout", Value: 10 * time.Minute, Usage: "Time to wait for the topology to be set up"},
+				},
+				Action: upAction,
+			},
+		},
+	}
+}
+
+func upAction(cctx *cli.Context) error {
+	if cctx.String("configdir") == "" {
+		return cli.Exit("Required flag \"configdir\" not set", 1)
+	}
+	cfg := config.GetCfg(context.WithValue(context.Background(), "configfile", path.Join(cctx.String("configdir"), "application.yaml")))
+	if cfg == nil {
+		return cli.Exit("Application configuration not loaded", 1)
+	}
+
+	t, err := LoadTopology(cctx.String("file"))
+	if err != nil {
+		return err
+	}
+	bitcoind, err := btc.NewFromConfig(cfg.Btc)
+	if err != nil {
+		return err
+	}
+	pool := lighting.NewPool(cfg.Lnds)
+	defer pool.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), cctx.Duration("timeout"))
+	defer cancel()
+	network, err := NewOrchestrator(pool, bitcoind).Up(ctx, t, cfg.Lnds)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct {
+		PubKeys  map[string]string `json:"pub_keys"`
+		Channels []OpenChannel     `json:"channels"`
+	}{network.PubKeys, network.Channels})
+}
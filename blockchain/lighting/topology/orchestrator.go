@@ -0,0 +1,321 @@
+package topology
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcutil"
+	"github.com/jualy007/GoTF/blockchain/btc"
+	"github.com/jualy007/GoTF/blockchain/lighting"
+	"github.com/jualy007/GoTF/config"
+	"github.com/lightningnetwork/lnd/lnrpc"
+)
+
+// matureBlocks are mined at a time when bitcoind has too few spendable coins, coinbases mature after 100 blocks.
+const (
+	matureBlocks   = 101
+	matureAttempts = 10
+)
+
+// Orchestrator sets up topologies on the nodes of a pool, funded and mined by a regtest bitcoind.
+type Orchestrator struct {
+	pool     *lighting.Pool
+	bitcoind *btc.Bitcoind
+
+	// PollInterval is how often a condition that is waited for is checked.
+	PollInterval time.Duration
+}
+
+func NewOrchestrator(pool *lighting.Pool, bitcoind *btc.Bitcoind) *Orchestrator {
+	return &Orchestrator{pool: pool, bitcoind: bitcoind, PollInterval: time.Second}
+}
+
+// Network is a topology that is set up.
+type Network struct {
+	Nodes    map[string]*lighting.Adapter
+	PubKeys  map[string]string
+	Channels []OpenChannel
+}
+
+// OpenChannel is an active channel of the topology.
+type OpenChannel struct {
+	Channel
+	ChannelPoint string `json:"channel_point"`
+	ChanId       uint64 `json:"chan_id"`
+}
+
+// Node returns the adapter of the node, nil if it is not part of the network.
+func (n *Network) Node(name string) *lighting.Adapter {
+	return n.Nodes[name]
+}
+
+// Up connects the nodes of t, funds their wallets, opens the channels and waits until they are
+// active and every public channel is in the graph of every node. It is bounded by ctx only.
+func (o *Orchestrator) Up(ctx context.Context, t *Topology, lnds map[string]*config.LndInfo) (*Network, error) {
+	if err := t.Validate(lnds); err != nil {
+		return nil, err
+	}
+	network := &Network{Nodes: make(map[string]*lighting.Adapter), PubKeys: make(map[string]string)}
+	hosts := make(map[string]string)
+	for _, name := range t.NodeNames() {
+		if err := o.pool.WaitReady(ctx, name); err != nil {
+			return nil, fmt.Errorf("node %v: %w", name, err)
+		}
+		adapter, err := o.pool.Get(name)
+		if err != nil {
+			return nil, fmt.Errorf("node %v: %w", name, err)
+		}
+		info, err := adapter.GetInfo(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("node %v: %w", name, err)
+		}
+		network.Nodes[name], network.PubKeys[name] = adapter, info.IdentityPubkey
+		hosts[name] = t.Nodes[name].Host
+		if hosts[name] == "" && len(info.Uris) > 0 {
+			hosts[name] = info.Uris[0][strings.Index(info.Uris[0], "@")+1:]
+		}
+	}
+
+	miner, err := o.bitcoind.GetNewAddress()
+	if err != nil {
+		return nil, err
+	}
+	if err := o.fund(ctx, t, network, miner); err != nil {
+		return nil, err
+	}
+	if err := o.connect(ctx, t, network, hosts); err != nil {
+		return nil, err
+	}
+	if err := o.open(ctx, t, network, miner); err != nil {
+		return nil, err
+	}
+	if err := o.waitGraph(ctx, network); err != nil {
+		return nil, err
+	}
+	return network, nil
+}
+
+// fund sends every node its funding from bitcoind and waits until it is confirmed.
+func (o *Orchestrator) fund(ctx context.Context, t *Topology, network *Network, miner string) error {
+	var total btcutil.Amount
+	for _, node := range t.Nodes {
+		total += btcutil.Amount(node.Fund)
+	}
+	if total == 0 {
+		return nil
+	}
+	if err := o.ensureBalance(ctx, network, miner, total); err != nil {
+		return err
+	}
+
+	for _, name := range t.NodeNames() {
+		fund := t.Nodes[name].Fund
+		if fund == 0 {
+			continue
+		}
+		address, err := network.Nodes[name].NewAddress(ctx, lnrpc.AddressType_WITNESS_PUBKEY_HASH)
+		if err != nil {
+			return fmt.Errorf("node %v: %w", name, err)
+		}
+		if _, err := o.bitcoind.SendToAddress(address, btcutil.Amount(fund).ToBTC(), "", ""); err != nil {
+			return fmt.Errorf("node %v: %w", name, err)
+		}
+	}
+	if err := o.mine(ctx, network, miner, t.Confirmations); err != nil {
+		return err
+	}
+
+	for _, name := range t.NodeNames() {
+		fund, adapter := t.Nodes[name].Fund, network.Nodes[name]
+		err := o.wait(ctx, "wallet of "+name+" funded", func() (bool, error) {
+			balance, err := adapter.WalletBalance(ctx)
+			return err == nil && balance.ConfirmedBalance >= fund, err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureBalance mines blocks until bitcoind can spend amount, with some room for fees.
+func (o *Orchestrator) ensureBalance(ctx context.Context, network *Network, miner string, amount btcutil.Amount) error {
+	for i := 0; ; i++ {
+		balance, err := o.bitcoind.GetBalance("*", 1)
+		if err != nil {
+			return err
+		}
+		if spendable, _ := btcutil.NewAmount(balance); spendable > amount+btcutil.SatoshiPerBitcoin/100 {
+			return nil
+		}
+		if i == matureAttempts {
+			return fmt.Errorf("bitcoind balance %v is too low to fund %v", balance, amount)
+		}
+		if err := o.mine(ctx, network, miner, matureBlocks); err != nil {
+			return err
+		}
+	}
+}
+
+// connect connects the nodes of every channel as peers.
+func (o *Orchestrator) connect(ctx context.Context, t *Topology, network *Network, hosts map[string]string) error {
+	for _, c := range t.Channels {
+		if hosts[c.To] == "" {
+			return &TopologyError{fmt.Sprintf("node %v has no host and advertises no uri", c.To)}
+		}
+		if err := network.Nodes[c.From].ConnectPeer(ctx, network.PubKeys[c.To], hosts[c.To], false); err != nil {
+			return fmt.Errorf("channel %v: %w", c, err)
+		}
+	}
+	return nil
+}
+
+// open opens every channel and waits until all of them are active. A block is mined after each
+// funding transaction, so nodes opening several channels spend confirmed change.
+func (o *Orchestrator) open(ctx context.Context, t *Topology, network *Network, miner string) error {
+	for _, c := range t.Channels {
+		pubKey, err := hex.DecodeString(network.PubKeys[c.To])
+		if err != nil {
+			return err
+		}
+		point, err := network.Nodes[c.From].OpenChannel(ctx, &lnrpc.OpenChannelRequest{
+			NodePubkey:         pubKey,
+			LocalFundingAmount: c.Capacity,
+			PushSat:            c.Push,
+			Private:            c.Private,
+		})
+		if err != nil {
+			return fmt.Errorf("channel %v: %w", c, err)
+		}
+		txid, err := fundingTxid(point)
+		if err != nil {
+			return err
+		}
+		err = o.wait(ctx, "funding transaction "+txid+" in mempool", func() (bool, error) {
+			mempool, err := o.bitcoind.GetRawMempool()
+			for _, tx := range mempool {
+				if tx == txid {
+					return true, nil
+				}
+			}
+			return false, err
+		})
+		if err != nil {
+			return err
+		}
+		if err := o.mine(ctx, network, miner, 1); err != nil {
+			return err
+		}
+		network.Channels = append(network.Channels, OpenChannel{Channel: c, ChannelPoint: fmt.Sprintf("%v:%v", txid, point.OutputIndex)})
+	}
+	if err := o.mine(ctx, network, miner, t.Confirmations); err != nil {
+		return err
+	}
+
+	for i := range network.Channels {
+		channel := &network.Channels[i]
+		adapter := network.Nodes[channel.From]
+		err := o.wait(ctx, "channel "+channel.String()+" active", func() (bool, error) {
+			channels, err := adapter.ListChannels(ctx, &lnrpc.ListChannelsRequest{ActiveOnly: true})
+			if err != nil {
+				return false, err
+			}
+			for _, active := range channels.Channels {
+				if active.ChannelPoint == channel.ChannelPoint {
+					channel.ChanId = active.ChanId
+					return true, nil
+				}
+			}
+			return false, nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitGraph waits until every public channel is gossiped to every node.
+func (o *Orchestrator) waitGraph(ctx context.Context, network *Network) error {
+	for name, adapter := range network.Nodes {
+		adapter := adapter
+		err := o.wait(ctx, "graph of "+name+" complete", func() (bool, error) {
+			graph, err := adapter.DescribeGraph(ctx, false)
+			if err != nil {
+				return false, err
+			}
+			edges := make(map[string]bool, len(graph.Edges))
+			for _, edge := range graph.Edges {
+				edges[edge.ChanPoint] = true
+			}
+			for _, channel := range network.Channels {
+				if !channel.Private && !edges[channel.ChannelPoint] {
+					return false, nil
+				}
+			}
+			return true, nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mine mines blocks and waits until every node is synced to the new tip.
+func (o *Orchestrator) mine(ctx context.Context, network *Network, miner string, blocks int) error {
+	if _, err := o.bitcoind.GenerateToAddress(blocks, miner); err != nil {
+		return err
+	}
+	height, err := o.bitcoind.GetBlockCount()
+	if err != nil {
+		return err
+	}
+	for name, adapter := range network.Nodes {
+		adapter := adapter
+		err := o.wait(ctx, fmt.Sprintf("%v synced to height %v", name, height), func() (bool, error) {
+			info, err := adapter.GetInfo(ctx)
+			return err == nil && info.SyncedToChain && uint64(info.BlockHeight) >= height, err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// wait polls done until it returns true, an error, or ctx is done.
+func (o *Orchestrator) wait(ctx context.Context, what string, done func() (bool, error)) error {
+	ticker := time.NewTicker(o.PollInterval)
+	defer ticker.Stop()
+	for {
+		ok, err := done()
+		if err != nil {
+			return fmt.Errorf("waiting for %v: %w", what, err)
+		}
+		if ok {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for %v: %w", what, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// fundingTxid returns the funding transaction id of point in the usual byte order.
+func fundingTxid(point *lnrpc.ChannelPoint) (string, error) {
+	if txid := point.GetFundingTxidStr(); txid != "" {
+		return txid, nil
+	}
+	hash, err := chainhash.NewHash(point.GetFundingTxidBytes())
+	if err != nil {
+		return "", err
+	}
+	return hash.String(), nil
+}
@@ -0,0 +1,130 @@
+package topology
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/jualy007/GoTF/config"
+
+	"gopkg.in/yaml.v2"
+)
+
+// DefaultConfirmations are mined after funding wallets and opening channels, enough for lnd to announce channels.
+const DefaultConfirmations = 6
+
+// Topology is a network of configured lnd nodes and the channels between them, for example
+//
+//	nodes:
+//	  alice: {fund: 10000000}
+//	  bob: {fund: 10000000, host: "bob:9735"}
+//	  carol: {host: "carol:9735"}
+//	channels:
+//	  - {from: alice, to: bob, capacity: 1000000, push: 100000}
+//	  - {from: bob, to: carol, capacity: 500000}
+type Topology struct {
+	Nodes         map[string]Node `yaml:"nodes"`
+	Channels      []Channel       `yaml:"channels"`
+	Confirmations int             `yaml:"confirmations"`
+}
+
+// Node is a lnd node of config.Cfg.Lnds. Fund satoshis are sent to its wallet from bitcoind.
+// Host is its p2p address as reachable by its peers, by default the first uri lnd advertises.
+type Node struct {
+	Fund int64  `yaml:"fund"`
+	Host string `yaml:"host"`
+}
+
+// Channel is opened by From to To, pushing Push of its Capacity satoshis to To.
+type Channel struct {
+	From     string `yaml:"from" json:"from"`
+	To       string `yaml:"to" json:"to"`
+	Capacity int64  `yaml:"capacity" json:"capacity"`
+	Push     int64  `yaml:"push" json:"push"`
+	Private  bool   `yaml:"private" json:"private"`
+}
+
+func (c Channel) String() string {
+	return fmt.Sprintf("%v->%v", c.From, c.To)
+}
+
+// TopologyError is returned for a topology that can not be set up with the configured nodes.
+type TopologyError struct {
+	Reason string
+}
+
+func (e *TopologyError) Error() string {
+	return "invalid topology: " + e.Reason
+}
+
+func LoadTopology(path string) (*Topology, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseTopology(data)
+}
+
+func ParseTopology(data []byte) (*Topology, error) {
+	var t Topology
+	if err := yaml.UnmarshalStrict(data, &t); err != nil {
+		return nil, err
+	}
+	if t.Confirmations == 0 {
+		t.Confirmations = DefaultConfirmations
+	}
+	return &t, nil
+}
+
+// Validate checks every node is configured and every channel is between two distinct nodes of the topology,
+// with at most one channel between two nodes.
+func (t *Topology) Validate(lnds map[string]*config.LndInfo) error {
+	if len(t.Nodes) == 0 {
+		return &TopologyError{"no nodes"}
+	}
+	for _, name := range t.NodeNames() {
+		if lnds[name] == nil {
+			return &TopologyError{fmt.Sprintf("node %v is not configured", name)}
+		}
+		if t.Nodes[name].Fund < 0 {
+			return &TopologyError{fmt.Sprintf("node %v funding is negative", name)}
+		}
+	}
+	pairs := make(map[[2]string]bool, len(t.Channels))
+	for _, c := range t.Channels {
+		if _, ok := t.Nodes[c.From]; !ok {
+			return &TopologyError{fmt.Sprintf("channel %v: unknown node %v", c, c.From)}
+		}
+		if _, ok := t.Nodes[c.To]; !ok {
+			return &TopologyError{fmt.Sprintf("channel %v: unknown node %v", c, c.To)}
+		}
+		if c.From == c.To {
+			return &TopologyError{fmt.Sprintf("channel %v: nodes are the same", c)}
+		}
+		pair := [2]string{c.From, c.To}
+		if c.To < c.From {
+			pair = [2]string{c.To, c.From}
+		}
+		if pairs[pair] {
+			return &TopologyError{fmt.Sprintf("channel %v: duplicate channel between %v and %v", c, pair[0], pair[1])}
+		}
+		pairs[pair] = true
+		if c.Capacity <= 0 || c.Push < 0 || c.Push >= c.Capacity {
+			return &TopologyError{fmt.Sprintf("channel %v: push %v must be less than capacity %v", c, c.Push, c.Capacity)}
+		}
+	}
+	if t.Confirmations < 1 {
+		return &TopologyError{"confirmations must be positive"}
+	}
+	return nil
+}
+
+// NodeNames returns the names of the nodes, sorted.
+func (t *Topology) NodeNames() []string {
+	names := make([]string, 0, len(t.Nodes))
+	for name := range t.Nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,413 @@
+package topology
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jualy007/GoTF/blockchain/btc"
+	"github.com/jualy007/GoTF/blockchain/btc/btctest"
+	"github.com/jualy007/GoTF/blockchain/lighting"
+	"github.com/jualy007/GoTF/blockchain/lighting/lndtest"
+	"github.com/jualy007/GoTF/config"
+	"github.com/lightningnetwork/lnd/lnrpc"
+)
+
+func TestParseTopology(t *testing.T) {
+	topology, err := ParseTopology([]byte(`
+nodes:
+  alice: {fund: 10000000}
+  bob: {host: "bob:9735"}
+channels:
+  - {from: alice, to: bob, capacity: 1000000, push: 100000, private: true}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Channel{From: "alice", To: "bob", Capacity: 1000000, Push: 100000, Private: true}
+	if len(topology.Nodes) != 2 || topology.Nodes["alice"].Fund != 10000000 || topology.Nodes["bob"].Host != "bob:9735" ||
+		len(topology.Channels) != 1 || topology.Channels[0] != want {
+		t.Errorf("ParseTopology() = %+v", topology)
+	}
+	if topology.Confirmations != DefaultConfirmations {
+		t.Errorf("ParseTopology() confirmations = %v, want %v", topology.Confirmations, DefaultConfirmations)
+	}
+	if names := topology.NodeNames(); strings.Join(names, ",") != "alice,bob" {
+		t.Errorf("NodeNames() = %v", names)
+	}
+
+	// Unknown fields and wrong types are errors
+	for _, data := range []string{
+		"nodes:\n  alice: {fund: 1, funds: 2}\n",
+		"nodes:\n  alice: {}\nchannels:\n  - {from: alice, to: bob, capacty: 1000}\n",
+		"nodes:\n  alice: {}\nconfirmation: 1\n",
+		"nodes:\n  alice: {fund: lots}\n",
+		"nodes: [alice]\n",
+	} {
+		if _, err := ParseTopology([]byte(data)); err == nil {
+			t.Errorf("ParseTopology(%q) did not fail", data)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	lnds := map[string]*config.LndInfo{"alice": {}, "bob": {}, "carol": {}}
+	nodes := map[string]Node{"alice": {}, "bob": {}, "carol": {}}
+	channel := func(from, to string, capacity, push int64) Channel {
+		return Channel{From: from, To: to, Capacity: capacity, Push: push}
+	}
+	tests := []struct {
+		name     string
+		topology Topology
+		reason   string
+	}{
+		{"valid", Topology{Nodes: nodes, Channels: []Channel{channel("alice", "bob", 1000, 999), channel("bob", "carol", 1000, 0)}, Confirmations: 1}, ""},
+		{"no nodes", Topology{Confirmations: 1}, "no nodes"},
+		{"unconfigured node", Topology{Nodes: map[string]Node{"alice": {}, "dave": {}}, Confirmations: 1}, "node dave is not configured"},
+		{"negative funding", Topology{Nodes: map[string]Node{"alice": {Fund: -1}}, Confirmations: 1}, "node alice funding is negative"},
+		{"unknown from", Topology{Nodes: nodes, Channels: []Channel{channel("dave", "bob", 1000, 0)}, Confirmations: 1}, "unknown node dave"},
+		{"unknown to", Topology{Nodes: nodes, Channels: []Channel{channel("alice", "dave", 1000, 0)}, Confirmations: 1}, "unknown node dave"},
+		{"same nodes", Topology{Nodes: nodes, Channels: []Channel{channel("alice", "alice", 1000, 0)}, Confirmations: 1}, "nodes are the same"},
+		{"duplicate channel", Topology{Nodes: nodes, Channels: []Channel{channel("alice", "bob", 1000, 0), channel("alice", "bob", 2000, 0)}, Confirmations: 1}, "duplicate channel between alice and bob"},
+		{"duplicate reversed channel", Topology{Nodes: nodes, Channels: []Channel{channel("alice", "bob", 1000, 0), channel("bob", "alice", 1000, 0)}, Confirmations: 1}, "duplicate channel between alice and bob"},
+		{"push above capacity", Topology{Nodes: nodes, Channels: []Channel{channel("alice", "bob", 1000, 1001)}, Confirmations: 1}, "push 1001 must be less than capacity 1000"},
+		{"push of the capacity", Topology{Nodes: nodes, Channels: []Channel{channel("alice", "bob", 1000, 1000)}, Confirmations: 1}, "push 1000 must be less than capacity 1000"},
+		{"negative push", Topology{Nodes: nodes, Channels: []Channel{channel("alice", "bob", 1000, -1)}, Confirmations: 1}, "push -1 must be less than capacity 1000"},
+		{"no capacity", Topology{Nodes: nodes, Channels: []Channel{channel("alice", "bob", 0, 0)}, Confirmations: 1}, "must be less than capacity 0"},
+		{"no confirmations", Topology{Nodes: nodes, Confirmations: -1}, "confirmations must be positive"},
+	}
+	for _, test := range tests {
+		err := test.topology.Validate(lnds)
+		if test.reason == "" {
+			if err != nil {
+				t.Errorf("%v: %v", test.name, err)
+			}
+			continue
+		}
+		var topologyErr *TopologyError
+		if !errors.As(err, &topologyErr) || !strings.Contains(topologyErr.Reason, test.reason) {
+			t.Errorf("%v: %v, want a TopologyError of %q", test.name, err, test.reason)
+		}
+	}
+}
+
+// chain is a fake regtest bitcoind mining the funding transactions of fake lnd servers.
+type chain struct {
+	*btctest.Server
+
+	mu     sync.Mutex
+	height int
+	nodes  []*lndtest.Server
+}
+
+func newChain(t *testing.T, nodes ...*lndtest.Server) *chain {
+	c := &chain{Server: btctest.NewServer(), height: lndtest.DefaultBlockHeight, nodes: nodes}
+	t.Cleanup(c.Close)
+	c.Result("getnewaddress", "bcrt1qw508d6qejxtdg4y5r3zarvary0c5xw7kygt080")
+	c.Handle("getblockcount", func([]json.RawMessage) (interface{}, error) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.height, nil
+	})
+	c.Handle("getrawmempool", func([]json.RawMessage) (interface{}, error) {
+		txids := []string{}
+		for _, node := range c.nodes {
+			txids = append(txids, node.Mempool()...)
+		}
+		return txids, nil
+	})
+	c.Handle("generatetoaddress", func(params []json.RawMessage) (interface{}, error) {
+		var blocks int
+		if err := json.Unmarshal(params[0], &blocks); err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		hashes := make([]string, blocks)
+		for i := range hashes {
+			hashes[i] = fmt.Sprintf("%064x", c.height+i+1)
+		}
+		c.height += blocks
+		for _, node := range c.nodes {
+			node.Mine(uint32(blocks))
+		}
+		return hashes, nil
+	})
+	return c
+}
+
+func (c *chain) bitcoind(t *testing.T) *btc.Bitcoind {
+	t.Helper()
+	bitcoind, err := c.Bitcoind()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bitcoind
+}
+
+// newNodes starts fake servers of the names, with a pool of them and their configuration.
+func newNodes(t *testing.T, names ...string) ([]*lndtest.Server, *lighting.Pool, map[string]*config.LndInfo) {
+	t.Helper()
+	servers := make([]*lndtest.Server, len(names))
+	lnds := make(map[string]*config.LndInfo)
+	for i, name := range names {
+		s, err := lndtest.NewServer(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(s.Close)
+		servers[i], lnds[name] = s, s.LndInfo()
+	}
+	pool := lndtest.Pool(servers...)
+	t.Cleanup(pool.Close)
+	return servers, pool, lnds
+}
+
+func newOrchestrator(pool *lighting.Pool, bitcoind *btc.Bitcoind) *Orchestrator {
+	o := NewOrchestrator(pool, bitcoind)
+	o.PollInterval = 10 * time.Millisecond
+	return o
+}
+
+// newNetwork returns a network of the servers, whose adapters come from the pool.
+func newNetwork(t *testing.T, pool *lighting.Pool, servers ...*lndtest.Server) *Network {
+	t.Helper()
+	network := &Network{Nodes: make(map[string]*lighting.Adapter), PubKeys: make(map[string]string)}
+	for _, s := range servers {
+		adapter, err := pool.Get(s.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		network.Nodes[s.Name], network.PubKeys[s.Name] = adapter, s.PubKey()
+	}
+	return network
+}
+
+func TestUp(t *testing.T) {
+	servers, pool, lnds := newNodes(t, "alice", "bob", "carol")
+	alice, bob, carol := servers[0], servers[1], servers[2]
+	c := newChain(t, servers...)
+	o := newOrchestrator(pool, c.bitcoind(t))
+
+	topology, err := ParseTopology([]byte(`
+nodes:
+  alice: {}
+  bob: {}
+  carol: {host: "carol:9735"}
+channels:
+  - {from: alice, to: bob, capacity: 1000000, push: 100000}
+  - {from: bob, to: carol, capacity: 500000, private: true}
+  - {from: alice, to: carol, capacity: 200000}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	network, err := o.Up(ctx, topology, lnds)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range servers {
+		if network.PubKeys[s.Name] != s.PubKey() || network.Node(s.Name) == nil {
+			t.Errorf("Up() node %v = %v", s.Name, network.PubKeys[s.Name])
+		}
+	}
+
+	// Peers are connected at the advertised uri or the configured host
+	peers := func(s *lndtest.Server) map[string]*lnrpc.Peer {
+		resp, err := network.Node(s.Name).ListPeers(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		byPubKey := make(map[string]*lnrpc.Peer)
+		for _, p := range resp.Peers {
+			byPubKey[p.PubKey] = p
+		}
+		return byPubKey
+	}
+	if p := peers(alice)[bob.PubKey()]; p == nil || p.Address != bob.Address() || p.Inbound {
+		t.Errorf("alice peer bob = %+v", p)
+	}
+	if p := peers(bob)[carol.PubKey()]; p == nil || p.Address != "carol:9735" {
+		t.Errorf("bob peer carol = %+v", p)
+	}
+	if p := peers(carol)[bob.PubKey()]; p == nil || !p.Inbound {
+		t.Errorf("carol peer bob = %+v", p)
+	}
+
+	// Every channel is active on both sides with its push and privacy
+	if len(network.Channels) != len(topology.Channels) {
+		t.Fatalf("Up() channels = %+v", network.Channels)
+	}
+	for i, channel := range network.Channels {
+		if channel.Channel != topology.Channels[i] || channel.ChanId == 0 || channel.ChannelPoint == "" {
+			t.Errorf("Up() channel %v = %+v", i, channel)
+		}
+		for _, side := range []struct {
+			name, peer string
+			balance    int64
+		}{
+			{channel.From, channel.To, channel.Capacity - channel.Push},
+			{channel.To, channel.From, channel.Push},
+		} {
+			channels, err := network.Node(side.name).ListChannels(ctx, &lnrpc.ListChannelsRequest{ActiveOnly: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			found := false
+			for _, c := range channels.Channels {
+				if c.ChannelPoint == channel.ChannelPoint {
+					found = true
+					if c.ChanId != channel.ChanId || c.RemotePubkey != network.PubKeys[side.peer] ||
+						c.LocalBalance != side.balance || c.Private != channel.Private {
+						t.Errorf("channel %v of %v = %+v", channel, side.name, c)
+					}
+				}
+			}
+			if !found {
+				t.Errorf("channel %v is not active at %v", channel, side.name)
+			}
+		}
+	}
+
+	// A block is mined after every funding transaction, then the confirmations
+	if calls, want := c.Calls("generatetoaddress"), len(topology.Channels)+1; calls != want {
+		t.Errorf("generatetoaddress called %v times, want %v", calls, want)
+	}
+}
+
+func TestConnect(t *testing.T) {
+	servers, pool, _ := newNodes(t, "alice", "bob")
+	alice, bob := servers[0], servers[1]
+	o := newOrchestrator(pool, newChain(t, servers...).bitcoind(t))
+	network := newNetwork(t, pool, alice, bob)
+	topology := &Topology{Channels: []Channel{{From: "alice", To: "bob", Capacity: 1000}}}
+	ctx := context.Background()
+
+	// A node to connect to needs a host
+	var topologyErr *TopologyError
+	if err := o.connect(ctx, topology, network, map[string]string{}); !errors.As(err, &topologyErr) {
+		t.Errorf("connect() without host: %v, want a TopologyError", err)
+	}
+	if calls := alice.Calls("ConnectPeer"); calls != 0 {
+		t.Errorf("connect() without host called ConnectPeer %v times", calls)
+	}
+
+	hosts := map[string]string{"bob": bob.Address()}
+	if err := o.connect(ctx, topology, network, hosts); err != nil {
+		t.Fatal(err)
+	}
+	// Peers already connected are not an error
+	if err := o.connect(ctx, topology, network, hosts); err != nil {
+		t.Errorf("connect() of connected peers: %v", err)
+	}
+	if calls := alice.Calls("ConnectPeer"); calls != 2 {
+		t.Errorf("ConnectPeer called %v times, want 2", calls)
+	}
+
+	alice.Inject("ConnectPeer", lndtest.Fault{Err: errors.New("connection refused"), Times: 1})
+	if err := o.connect(ctx, topology, network, hosts); err == nil || !strings.Contains(err.Error(), "channel alice->bob") {
+		t.Errorf("connect() of a failing peer: %v", err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	servers, pool, _ := newNodes(t, "alice", "bob")
+	alice, bob := servers[0], servers[1]
+	c := newChain(t, servers...)
+	o := newOrchestrator(pool, c.bitcoind(t))
+	network := newNetwork(t, pool, alice, bob)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := o.connect(ctx, &Topology{Channels: []Channel{{From: "alice", To: "bob"}}}, network, map[string]string{"bob": bob.Address()}); err != nil {
+		t.Fatal(err)
+	}
+
+	topology := &Topology{
+		Channels:      []Channel{{From: "alice", To: "bob", Capacity: 100000}, {From: "bob", To: "alice", Capacity: 50000, Push: 1000}},
+		Confirmations: lndtest.ChannelConfirmations,
+	}
+	if err := o.open(ctx, topology, network, "miner"); err != nil {
+		t.Fatal(err)
+	}
+	if len(network.Channels) != 2 || network.Channels[0].ChanId == 0 || network.Channels[1].ChanId == 0 ||
+		network.Channels[0].ChannelPoint == network.Channels[1].ChannelPoint {
+		t.Errorf("open() = %+v", network.Channels)
+	}
+	for _, s := range servers {
+		if len(s.Mempool()) != 0 {
+			t.Errorf("funding transactions of %v not mined: %v", s.Name, s.Mempool())
+		}
+	}
+	if height, _ := c.bitcoind(t).GetBlockCount(); height != lndtest.DefaultBlockHeight+2+lndtest.ChannelConfirmations {
+		t.Errorf("open() mined to %v", height)
+	}
+
+	// Too few confirmations for the channels to become active
+	topology = &Topology{Channels: []Channel{{From: "alice", To: "bob", Capacity: 100000}}, Confirmations: 1}
+	short, cancelShort := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancelShort()
+	if err := o.open(short, topology, newNetwork(t, pool, alice, bob), "miner"); short.Err() == nil ||
+		err == nil || !strings.Contains(err.Error(), "channel alice->bob active") {
+		t.Errorf("open() with too few confirmations: %v", err)
+	}
+
+	bob.Inject("OpenChannelSync", lndtest.Fault{Err: errors.New("not enough witness outputs to create funding transaction"), Times: 1})
+	topology = &Topology{Channels: []Channel{{From: "bob", To: "alice", Capacity: 100000}}, Confirmations: 1}
+	if err := o.open(ctx, topology, newNetwork(t, pool, alice, bob), "miner"); err == nil || !strings.Contains(err.Error(), "channel bob->alice") {
+		t.Errorf("open() with a failing funding: %v", err)
+	}
+}
+
+func TestWaitGraph(t *testing.T) {
+	servers, pool, _ := newNodes(t, "alice", "bob")
+	alice, bob := servers[0], servers[1]
+	o := newOrchestrator(pool, newChain(t, servers...).bitcoind(t))
+	network := newNetwork(t, pool, alice, bob)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	chanId := alice.AddChannel(bob, 100000, 0)
+	channels, err := network.Node("alice").ListChannels(ctx, &lnrpc.ListChannelsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	network.Channels = []OpenChannel{
+		{Channel: Channel{From: "alice", To: "bob"}, ChannelPoint: channels.Channels[0].ChannelPoint, ChanId: chanId},
+		// Private channels are not gossiped
+		{Channel: Channel{From: "bob", To: "alice", Private: true}, ChannelPoint: strings.Repeat("ab", 32) + ":0"},
+	}
+	if err := o.waitGraph(ctx, network); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range servers {
+		if calls := s.Calls("DescribeGraph"); calls != 1 {
+			t.Errorf("DescribeGraph of %v called %v times, want 1", s.Name, calls)
+		}
+	}
+
+	// A public channel missing from the graph is waited for until ctx is done
+	network.Channels = append(network.Channels, OpenChannel{Channel: Channel{From: "bob", To: "alice"}, ChannelPoint: strings.Repeat("cd", 32) + ":0"})
+	short, cancelShort := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancelShort()
+	if err := o.waitGraph(short, network); short.Err() == nil || err == nil || !strings.Contains(err.Error(), "complete") {
+		t.Errorf("waitGraph() of a missing channel: %v", err)
+	}
+	if calls := alice.Calls("DescribeGraph") + bob.Calls("DescribeGraph"); calls < 4 {
+		t.Errorf("DescribeGraph called %v times while waiting", calls)
+	}
+
+	network.Channels = network.Channels[:1]
+	bob.Inject("DescribeGraph", lndtest.Unavailable)
+	var transportErr *lighting.TransportError
+	if err := o.waitGraph(ctx, network); !errors.As(err, &transportErr) {
+		t.Errorf("waitGraph() of an unavailable node: %v, want a TransportError", err)
+	}
+}
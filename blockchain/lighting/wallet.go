@@ -0,0 +1,41 @@
+package lighting
+
+import (
+	"github.com/lightningnetwork/lnd/lnrpc"
+
+	"golang.org/x/net/context"
+)
+
+// WalletBalance returns the on-chain balance of the lnd wallet.
+func (adapter *Adapter) WalletBalance(ctx context.Context) (*lnrpc.WalletBalanceResponse, error) {
+	return adapter.lc.WalletBalance(ctx, &lnrpc.WalletBalanceRequest{})
+}
+
+// ChannelBalance returns the total balance of the open and pending channels.
+func (adapter *Adapter) ChannelBalance(ctx context.Context) (*lnrpc.ChannelBalanceResponse, error) {
+	return adapter.lc.ChannelBalance(ctx, &lnrpc.ChannelBalanceRequest{})
+}
+
+// NewAddress returns a new on-chain address of the lnd wallet.
+func (adapter *Adapter) NewAddress(ctx context.Context, addressType lnrpc.AddressType) (string, error) {
+	resp, err := adapter.lc.NewAddress(ctx, &lnrpc.NewAddressRequest{Type: addressType})
+	if err != nil {
+		return "", err
+	}
+	return resp.Address, nil
+}
+
+// SendCoins sends amount satoshis from the lnd wallet to address and returns the transaction id.
+// A satPerByte of 0 lets lnd estimate the fee rate.
+func (adapter *Adapter) SendCoins(ctx context.Context, address string, amount int64, satPerByte int64) (string, error) {
+	resp, err := adapter.lc.SendCoins(ctx, &lnrpc.SendCoinsRequest{Addr: address, Amount: amount, SatPerByte: satPerByte})
+	if err != nil {
+		return "", err
+	}
+	return resp.Txid, nil
+}
+
+// GetTransactions returns the transactions of the lnd wallet.
+func (adapter *Adapter) GetTransactions(ctx context.Context) (*lnrpc.TransactionDetails, error) {
+	return adapter.lc.GetTransactions(ctx, &lnrpc.GetTransactionsRequest{})
+}
@@ -6,6 +6,13 @@ type BaseController struct {
 	beego.Controller
 }
 
+// Response is the JSON envelope returned by the API controllers.
+type Response struct {
+	Status int         `json:"status"`
+	Msg    string      `json:"msg"`
+	Data   interface{} `json:"data"`
+}
+
 // @Title Support CORS
 // @Description Support CORS
 // @Success 200 {string} "OK"
@@ -14,3 +21,16 @@ func (this *BaseController) Options() {
 	this.Data["json"] = map[string]interface{}{"status": 200, "message": "ok", "data": ""}
 	this.ServeJSON()
 }
+
+// Success serves data in a 200 envelope.
+func (this *BaseController) Success(data interface{}) {
+	this.Data["json"] = &Response{Status: 200, Data: data}
+	this.ServeJSON()
+}
+
+// Failure serves err in an envelope with the given HTTP status.
+func (this *BaseController) Failure(status int, err error) {
+	this.Ctx.Output.SetStatus(status)
+	this.Data["json"] = &Response{Status: status, Msg: err.Error()}
+	this.ServeJSON()
+}
@@ -0,0 +1,27 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/jualy007/GoTF/blockchain/lighting"
+)
+
+// Operations about BOLT11 payment requests, without a Lnd node
+type Bolt11Controller struct {
+	BaseController
+}
+
+// @Title DecodePayReq
+// @Description Decode and verify a payment request locally, its network is taken from its prefix
+// @Param	pay_req		path 	string	true		"The BOLT11 payment request"
+// @Success 200 {object} lnrpc.PayReq
+// @Failure 400 invalid payment request
+// @router /payreq/:pay_req [get]
+func (this *Bolt11Controller) DecodePayReq() {
+	invoice, err := lighting.DecodeInvoice(this.Ctx.Input.Param(":pay_req"), nil)
+	if err != nil {
+		this.Failure(http.StatusBadRequest, err)
+		return
+	}
+	this.Success(invoice.PayReq())
+}
@@ -1,8 +1,16 @@
 package controllers
 
 import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
 	"github.com/astaxie/beego"
 	"github.com/astaxie/beego/orm"
+	"github.com/jualy007/GoTF/blockchain/btc"
+	"github.com/jualy007/GoTF/models"
 )
 
 type IndexController struct {
@@ -28,3 +36,167 @@ func (c *IndexController) Test() {
 
 	}
 }
+
+// Operations about the bitcoin node
+type BtcController struct {
+	BaseController
+	node *btc.Bitcoind
+}
+
+// bitcoind RPC_INVALID_ADDRESS_OR_KEY, returned for unknown blocks and transactions
+const rpcInvalidAddressOrKey = -5
+
+func (this *BtcController) Prepare() {
+	node, err := models.Bitcoind()
+	if err != nil {
+		this.Failure(http.StatusServiceUnavailable, err)
+		this.StopRun()
+	}
+	this.node = node
+}
+
+// serve serves data, or err with an HTTP status matching the bitcoind error.
+func (this *BtcController) serve(data interface{}, err error) {
+	if err == nil {
+		this.Success(data)
+		return
+	}
+	var rpcErr *btc.RPCError
+	switch {
+	case errors.As(err, &rpcErr) && rpcErr.Code == rpcInvalidAddressOrKey:
+		this.Failure(http.StatusNotFound, err)
+	case errors.As(err, &rpcErr):
+		this.Failure(http.StatusBadRequest, err)
+	default:
+		this.Failure(http.StatusBadGateway, err)
+	}
+}
+
+// @Title ChainInfo
+// @Description get blockchain processing state
+// @Success 200 {object} btc.BlockchainInfo
+// @Failure 502 bitcoind unreachable
+// @router /info [get]
+func (this *BtcController) ChainInfo() {
+	this.serve(this.node.GetBlockchainInfo())
+}
+
+// @Title BlockByHash
+// @Description get a block by hash
+// @Param	hash		path 	string	true		"The block hash"
+// @Success 200 {object} btc.Block
+// @Failure 404 block not found
+// @router /block/:hash [get]
+func (this *BtcController) BlockByHash() {
+	this.serve(this.node.GetBlock(this.Ctx.Input.Param(":hash")))
+}
+
+// @Title BlockByHeight
+// @Description get a block of the best block chain by height
+// @Param	height		path 	int	true		"The block height"
+// @Success 200 {object} btc.Block
+// @Failure 400 height is not a number
+// @Failure 404 block not found
+// @router /block/height/:height [get]
+func (this *BtcController) BlockByHeight() {
+	height, err := strconv.ParseUint(this.Ctx.Input.Param(":height"), 10, 64)
+	if err != nil {
+		this.Failure(http.StatusBadRequest, err)
+		return
+	}
+	this.serve(models.BlockByHeight(this.node, height))
+}
+
+// @Title Transaction
+// @Description get a decoded transaction, needs txindex for confirmed transactions not in the wallet
+// @Param	txid		path 	string	true		"The transaction id"
+// @Success 200 {object} btc.RawTransaction
+// @Failure 404 transaction not found
+// @router /tx/:txid [get]
+func (this *BtcController) Transaction() {
+	this.serve(this.node.GetRawTransaction(this.Ctx.Input.Param(":txid"), true))
+}
+
+// @Title Mempool
+// @Description list the transaction ids in the memory pool
+// @Param	verbose		query 	bool	false		"Return fee and size details per transaction"
+// @Success 200 {object} []string
+// @router /mempool [get]
+func (this *BtcController) Mempool() {
+	if verbose, _ := this.GetBool("verbose"); verbose {
+		this.serve(this.node.GetRawMempoolVerbose())
+		return
+	}
+	this.serve(this.node.GetRawMempool())
+}
+
+// @Title FeeEstimates
+// @Description estimate fee rates in BTC/kB for confirmation targets
+// @Param	targets		query 	string	false		"Comma separated confirmation targets in blocks, default 2,6,12,144"
+// @Success 200 {object} map[int]btc.EstimateSmartFeeResult
+// @Failure 400 invalid target
+// @router /fees [get]
+func (this *BtcController) FeeEstimates() {
+	targets := models.DefaultFeeTargets
+	if param := this.GetString("targets"); param != "" {
+		targets = nil
+		for _, t := range strings.Split(param, ",") {
+			target, err := strconv.Atoi(strings.TrimSpace(t))
+			if err != nil || target < 1 {
+				this.Failure(http.StatusBadRequest, errors.New("targets must be positive numbers of blocks"))
+				return
+			}
+			targets = append(targets, target)
+		}
+	}
+	this.serve(models.FeeEstimates(this.node, targets))
+}
+
+// @Title ValidateAddress
+// @Description validate a bitcoin address
+// @Param	address		path 	string	true		"The address"
+// @Success 200 {object} btc.ValidateAddressResponse
+// @router /address/:address [get]
+func (this *BtcController) ValidateAddress() {
+	this.serve(this.node.ValidateAddress(this.Ctx.Input.Param(":address")))
+}
+
+// @Title Balance
+// @Description get the wallet balance in BTC
+// @Param	minconf		query 	int	false		"Minimum confirmations, default 1"
+// @Success 200 {float64}
+// @router /balance [get]
+func (this *BtcController) Balance() {
+	minconf, err := this.GetUint64("minconf", 1)
+	if err != nil {
+		this.Failure(http.StatusBadRequest, err)
+		return
+	}
+	this.serve(this.node.GetBalance("*", minconf))
+}
+
+// @Title Send
+// @Description send BTC from the wallet to an address
+// @Param	body		body 	models.SendRequest	true		"Address, amount in BTC and comments"
+// @Success 200 {string} transaction id
+// @Failure 400 invalid address, amount or insufficient funds
+// @router /send [post]
+func (this *BtcController) Send() {
+	var req models.SendRequest
+	if err := json.Unmarshal(this.Ctx.Input.RequestBody, &req); err != nil {
+		this.Failure(http.StatusBadRequest, err)
+		return
+	}
+	if req.Address == "" || req.Amount <= 0 {
+		this.Failure(http.StatusBadRequest, errors.New("address and a positive amount are required"))
+		return
+	}
+	txid, err := this.node.SendToAddress(req.Address, req.Amount, req.Comment, req.CommentTo)
+	// bitcoind rejects an invalid destination with RPC_INVALID_ADDRESS_OR_KEY, which is not a missing resource here
+	var rpcErr *btc.RPCError
+	if errors.As(err, &rpcErr) && rpcErr.Code == rpcInvalidAddressOrKey {
+		this.Failure(http.StatusBadRequest, err)
+		return
+	}
+	this.serve(txid, err)
+}
@@ -0,0 +1,312 @@
+package controllers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/astaxie/beego"
+	"github.com/jualy007/GoTF/blockchain/btc"
+	"github.com/jualy007/GoTF/blockchain/btc/btctest"
+	"github.com/jualy007/GoTF/controllers"
+	"github.com/jualy007/GoTF/models"
+)
+
+const (
+	testBlockHash = "0f9188f13cb7b2c71f2a335e3a4fc328bf5beb436012afca590b1a11466e2206"
+	testTxid      = "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b"
+	testAddress   = "bcrt1qw508d6qejxtdg4y5r3zarvary0c5xw7kygt080"
+)
+
+// bitcoind error codes answered by the fake node
+const (
+	rpcInvalidParameter    btc.RPCErrorCode = -8
+	rpcInvalidAddressOrKey btc.RPCErrorCode = -5
+	rpcWalletInsufficient  btc.RPCErrorCode = -6
+)
+
+// newBtcHandler serves the bitcoin routes used by the tests for a fake regtest bitcoind.
+func newBtcHandler(t *testing.T) (*beego.ControllerRegister, *btctest.Server) {
+	t.Helper()
+	server := btctest.NewServer()
+	node, err := server.Bitcoind()
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+	models.SetBitcoind(node)
+	t.Cleanup(func() {
+		models.SetBitcoind(nil)
+		server.Close()
+	})
+
+	server.Result("getblockchaininfo", map[string]interface{}{"chain": "regtest", "blocks": 5, "headers": 5, "bestblockhash": testBlockHash})
+	server.Handle("getblock", func(params []json.RawMessage) (interface{}, error) {
+		var hash string
+		if err := json.Unmarshal(params[0], &hash); err != nil || hash != testBlockHash {
+			return nil, &btc.RPCError{Code: rpcInvalidAddressOrKey, Message: "Block not found"}
+		}
+		return map[string]interface{}{"hash": testBlockHash, "height": 5, "tx": []string{testTxid}}, nil
+	})
+	server.Handle("getblockhash", func(params []json.RawMessage) (interface{}, error) {
+		var height uint64
+		if err := json.Unmarshal(params[0], &height); err != nil || height != 5 {
+			return nil, &btc.RPCError{Code: rpcInvalidParameter, Message: "Block height out of range"}
+		}
+		return testBlockHash, nil
+	})
+	server.Handle("getrawtransaction", func(params []json.RawMessage) (interface{}, error) {
+		var txid string
+		if err := json.Unmarshal(params[0], &txid); err != nil || txid != testTxid {
+			return nil, &btc.RPCError{Code: rpcInvalidAddressOrKey, Message: "No such mempool or blockchain transaction"}
+		}
+		return map[string]interface{}{"txid": testTxid, "hex": "01000000", "version": 1, "blockhash": testBlockHash}, nil
+	})
+	server.Handle("getrawmempool", func(params []json.RawMessage) (interface{}, error) {
+		if len(params) == 1 {
+			return map[string]interface{}{testTxid: map[string]interface{}{"size": 110, "fee": 0.0001}}, nil
+		}
+		return []string{testTxid}, nil
+	})
+	server.Handle("estimatesmartfee", func(params []json.RawMessage) (interface{}, error) {
+		var target int
+		if err := json.Unmarshal(params[0], &target); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"feerate": 0.001 / float64(target), "blocks": target}, nil
+	})
+	server.Handle("validateaddress", func(params []json.RawMessage) (interface{}, error) {
+		var address string
+		if err := json.Unmarshal(params[0], &address); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"isvalid": address == testAddress, "address": address}, nil
+	})
+	server.Handle("getbalance", func(params []json.RawMessage) (interface{}, error) {
+		var minconf uint64
+		if err := json.Unmarshal(params[1], &minconf); err != nil {
+			return nil, err
+		}
+		return 1.5 / float64(minconf+1), nil
+	})
+	server.Handle("sendtoaddress", func(params []json.RawMessage) (interface{}, error) {
+		var address string
+		var amount float64
+		if err := json.Unmarshal(params[0], &address); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(params[1], &amount); err != nil {
+			return nil, err
+		}
+		switch {
+		case address != testAddress:
+			return nil, &btc.RPCError{Code: rpcInvalidAddressOrKey, Message: "Invalid address"}
+		case amount > 1.5:
+			return nil, &btc.RPCError{Code: rpcWalletInsufficient, Message: "Insufficient funds"}
+		}
+		return testTxid, nil
+	})
+
+	beego.BConfig.CopyRequestBody = true
+	handler := beego.NewControllerRegister()
+	handler.Add("/v1/btc/info", &controllers.BtcController{}, "get:ChainInfo")
+	handler.Add("/v1/btc/block/:hash", &controllers.BtcController{}, "get:BlockByHash")
+	handler.Add("/v1/btc/block/height/:height", &controllers.BtcController{}, "get:BlockByHeight")
+	handler.Add("/v1/btc/tx/:txid", &controllers.BtcController{}, "get:Transaction")
+	handler.Add("/v1/btc/mempool", &controllers.BtcController{}, "get:Mempool")
+	handler.Add("/v1/btc/fees", &controllers.BtcController{}, "get:FeeEstimates")
+	handler.Add("/v1/btc/address/:address", &controllers.BtcController{}, "get:ValidateAddress")
+	handler.Add("/v1/btc/balance", &controllers.BtcController{}, "get:Balance")
+	handler.Add("/v1/btc/send", &controllers.BtcController{}, "post:Send")
+	return handler, server
+}
+
+func TestChainInfo(t *testing.T) {
+	handler, server := newBtcHandler(t)
+
+	var info btc.BlockchainInfo
+	if code := serve(t, handler, "GET", "/v1/btc/info", "", &info); code != http.StatusOK {
+		t.Fatalf("GET info: %v", code)
+	}
+	if info.Chain != "regtest" || info.Blocks != 5 || info.BestBlockHash != testBlockHash {
+		t.Errorf("GET info = %+v", info)
+	}
+
+	// bitcoind errors other than RPC_INVALID_ADDRESS_OR_KEY are bad requests
+	server.Error("getblockchaininfo", btctest.RPCMiscError, "Loading block index...")
+	if code := serve(t, handler, "GET", "/v1/btc/info", "", nil); code != http.StatusBadRequest {
+		t.Errorf("GET info of a bitcoind error: %v, want 400", code)
+	}
+
+	// An unreachable node is a bad gateway
+	server.Close()
+	if code := serve(t, handler, "GET", "/v1/btc/info", "", nil); code != http.StatusBadGateway {
+		t.Errorf("GET info of an unreachable node: %v, want 502", code)
+	}
+
+	// No node configured
+	models.SetBitcoind(nil)
+	if code := serve(t, handler, "GET", "/v1/btc/info", "", nil); code != http.StatusServiceUnavailable {
+		t.Errorf("GET info without a node: %v, want 503", code)
+	}
+}
+
+func TestBlocks(t *testing.T) {
+	handler, _ := newBtcHandler(t)
+
+	tests := []struct {
+		url  string
+		code int
+	}{
+		{"/v1/btc/block/" + testBlockHash, http.StatusOK},
+		{"/v1/btc/block/height/5", http.StatusOK},
+		{"/v1/btc/block/" + testTxid, http.StatusNotFound},
+		{"/v1/btc/block/height/6", http.StatusBadRequest},
+		{"/v1/btc/block/height/x", http.StatusBadRequest},
+		{"/v1/btc/block/height/-1", http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		var block btc.Block
+		code := serve(t, handler, "GET", test.url, "", &block)
+		if code != test.code {
+			t.Errorf("GET %v: %v, want %v", test.url, code, test.code)
+		}
+		if code == http.StatusOK && (block.Hash != testBlockHash || block.Height != 5) {
+			t.Errorf("GET %v = %+v", test.url, block)
+		}
+	}
+}
+
+func TestTransaction(t *testing.T) {
+	handler, _ := newBtcHandler(t)
+
+	var tx btc.RawTransaction
+	if code := serve(t, handler, "GET", "/v1/btc/tx/"+testTxid, "", &tx); code != http.StatusOK {
+		t.Fatalf("GET tx: %v", code)
+	}
+	if tx.Txid != testTxid || tx.BlockHash != testBlockHash {
+		t.Errorf("GET tx = %+v", tx)
+	}
+	if code := serve(t, handler, "GET", "/v1/btc/tx/"+testBlockHash, "", nil); code != http.StatusNotFound {
+		t.Errorf("GET unknown tx: %v, want 404", code)
+	}
+}
+
+func TestMempool(t *testing.T) {
+	handler, _ := newBtcHandler(t)
+
+	var txids []string
+	if code := serve(t, handler, "GET", "/v1/btc/mempool", "", &txids); code != http.StatusOK || len(txids) != 1 || txids[0] != testTxid {
+		t.Errorf("GET mempool: %v %v", code, txids)
+	}
+	var txs map[string]btc.VerboseTx
+	if code := serve(t, handler, "GET", "/v1/btc/mempool?verbose=true", "", &txs); code != http.StatusOK || txs[testTxid].Size != 110 || txs[testTxid].Fee != 0.0001 {
+		t.Errorf("GET verbose mempool: %v %+v", code, txs)
+	}
+}
+
+func TestFeeEstimates(t *testing.T) {
+	handler, server := newBtcHandler(t)
+
+	var estimates map[string]btc.EstimateSmartFeeResult
+	if code := serve(t, handler, "GET", "/v1/btc/fees", "", &estimates); code != http.StatusOK {
+		t.Fatalf("GET fees: %v", code)
+	}
+	if len(estimates) != len(models.DefaultFeeTargets) || estimates["144"].Blocks != 144 || estimates["2"].FeeRate != 0.0005 {
+		t.Errorf("GET fees = %+v", estimates)
+	}
+	if n := server.Calls("estimatesmartfee"); n != len(models.DefaultFeeTargets) {
+		t.Errorf("%v estimatesmartfee calls, want %v", n, len(models.DefaultFeeTargets))
+	}
+
+	estimates = nil
+	if code := serve(t, handler, "GET", "/v1/btc/fees?targets=1,%203", "", &estimates); code != http.StatusOK || len(estimates) != 2 || estimates["3"].Blocks != 3 {
+		t.Errorf("GET fees of targets: %v %+v", code, estimates)
+	}
+	for _, targets := range []string{"0", "2,x", "-1"} {
+		if code := serve(t, handler, "GET", "/v1/btc/fees?targets="+targets, "", nil); code != http.StatusBadRequest {
+			t.Errorf("GET fees of targets %v: %v, want 400", targets, code)
+		}
+	}
+}
+
+func TestValidateAddress(t *testing.T) {
+	handler, _ := newBtcHandler(t)
+
+	for address, valid := range map[string]bool{testAddress: true, "bcrt1qinvalid": false} {
+		var va btc.ValidateAddressResponse
+		if code := serve(t, handler, "GET", "/v1/btc/address/"+address, "", &va); code != http.StatusOK || va.IsValid != valid || va.Address != address {
+			t.Errorf("GET address %v: %v %+v", address, code, va)
+		}
+	}
+}
+
+func TestBalanceAndSend(t *testing.T) {
+	handler, server := newBtcHandler(t)
+
+	var balance float64
+	if code := serve(t, handler, "GET", "/v1/btc/balance", "", &balance); code != http.StatusOK || balance != 0.75 {
+		t.Errorf("GET balance: %v %v, want 0.75 at 1 confirmation", code, balance)
+	}
+	if code := serve(t, handler, "GET", "/v1/btc/balance?minconf=0", "", &balance); code != http.StatusOK || balance != 1.5 {
+		t.Errorf("GET balance at 0 confirmations: %v %v", code, balance)
+	}
+	if code := serve(t, handler, "GET", "/v1/btc/balance?minconf=x", "", nil); code != http.StatusBadRequest {
+		t.Errorf("GET balance of a bad minconf: %v, want 400", code)
+	}
+
+	var txid string
+	if code := serve(t, handler, "POST", "/v1/btc/send", `{"address": "`+testAddress+`", "amount": 0.5}`, &txid); code != http.StatusOK || txid != testTxid {
+		t.Errorf("POST send: %v %v", code, txid)
+	}
+
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"invalid address", `{"address": "bcrt1qinvalid", "amount": 0.5}`, http.StatusBadRequest},
+		{"insufficient funds", `{"address": "` + testAddress + `", "amount": 2}`, http.StatusBadRequest},
+		{"no address", `{"amount": 0.5}`, http.StatusBadRequest},
+		{"no amount", `{"address": "` + testAddress + `"}`, http.StatusBadRequest},
+		{"malformed", `{"amount": "x"}`, http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		if code := serve(t, handler, "POST", "/v1/btc/send", test.body, nil); code != test.code {
+			t.Errorf("POST send of %v: %v, want %v", test.name, code, test.code)
+		}
+	}
+	// Requests without an address or a positive amount do not reach bitcoind
+	if n := server.Calls("sendtoaddress"); n != 3 {
+		t.Errorf("%v sendtoaddress calls, want 3", n)
+	}
+}
+
+// TestRPCErrorStatus checks the HTTP status of bitcoind errors through an unknown block.
+func TestRPCErrorStatus(t *testing.T) {
+	handler, server := newBtcHandler(t)
+
+	tests := []struct {
+		code   btc.RPCErrorCode
+		status int
+	}{
+		{rpcInvalidAddressOrKey, http.StatusNotFound},
+		{rpcInvalidParameter, http.StatusBadRequest},
+		{btctest.RPCMiscError, http.StatusBadRequest},
+		{btctest.RPCMethodNotFound, http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		server.Error("getblock", test.code, "error")
+		if code := serve(t, handler, "GET", "/v1/btc/block/"+testBlockHash, "", nil); code != test.status {
+			t.Errorf("GET block of bitcoind error %v: %v, want %v", test.code, code, test.status)
+		}
+	}
+
+	// Errors that are not from bitcoind, as a malformed result, are a bad gateway
+	server.Handle("getblock", func([]json.RawMessage) (interface{}, error) {
+		return "not a block", nil
+	})
+	if code := serve(t, handler, "GET", "/v1/btc/block/"+testBlockHash, "", nil); code != http.StatusBadGateway {
+		t.Errorf("GET malformed block: %v, want 502", code)
+	}
+}
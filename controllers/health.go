@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/jualy007/GoTF/blockchain/lighting"
+	"github.com/jualy007/GoTF/models"
+)
+
+// Health of the configured Lnd nodes
+type LightningHealthController struct {
+	BaseController
+}
+
+// @Title Health
+// @Description Get the latest health check of every Lnd node, 503 if any node is not alive
+// @Success 200 {object} models.NodeHealth
+// @Failure 503 a node is not alive
+// @router /health [get]
+func (this *LightningHealthController) Health() {
+	status := models.LndHealth().Status()
+	for _, h := range status {
+		if !h.Alive {
+			this.unavailable(h.Name, status)
+			return
+		}
+	}
+	this.Success(status)
+}
+
+// @Title NodeHealth
+// @Description Get the latest health check of a Lnd node
+// @Param	lndname		path 	string	true		"The Lnd Name"
+// @Success 200 {object} models.NodeHealth
+// @Failure 404 Lnd not exist for The Lnd Name
+// @Failure 503 the node is not alive
+// @router /health/:lndname [get]
+func (this *LightningHealthController) NodeHealth() {
+	lndname := this.Ctx.Input.Param(":lndname")
+	h, ok := models.LndHealth().Node(lndname)
+	if !ok {
+		this.Failure(http.StatusNotFound, &lighting.UnknownNodeError{Name: lndname})
+		return
+	}
+	if !h.Alive {
+		this.unavailable(h.Name, h)
+		return
+	}
+	this.Success(h)
+}
+
+// unavailable serves data with a 503 for the node that is not alive.
+func (this *LightningHealthController) unavailable(lndname string, data interface{}) {
+	this.Ctx.Output.SetStatus(http.StatusServiceUnavailable)
+	this.Data["json"] = &Response{Status: http.StatusServiceUnavailable, Msg: "Lnd node " + lndname + " is not alive", Data: data}
+	this.ServeJSON()
+}
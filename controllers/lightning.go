@@ -1,104 +1,400 @@
 package controllers
 
 import (
-	"fmt"
-	"github.com/astaxie/beego"
-	"github.com/emirpasic/gods/lists/arraylist"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+
 	"github.com/jualy007/GoTF/blockchain/lighting"
-	"github.com/jualy007/GoTF/config"
 	"github.com/jualy007/GoTF/models"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/routerrpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// Operations about the configured Lnd nodes
 type LightningController struct {
-	beego.Controller
+	BaseController
+	adapter *lighting.Adapter
 }
 
+// defaultPaymentTimeout bounds payments that do not set timeout_seconds.
+const defaultPaymentTimeout = 60
+
 func (this *LightningController) Prepare() {
-	//Update Avaliable Lnd Node
-	models.Healthcheck()
+	adapter, err := models.LndPool().Get(this.Ctx.Input.Param(":lndname"))
+	if err != nil {
+		var unknown *lighting.UnknownNodeError
+		if errors.As(err, &unknown) {
+			this.Failure(http.StatusNotFound, err)
+		} else {
+			this.Failure(http.StatusServiceUnavailable, err)
+		}
+		this.StopRun()
+	}
+	this.adapter = adapter
+}
 
-	if models.Livenode.Size() == 0 {
-		this.Data["status"] = 500
-		this.Data["data"] = nil
-		this.Data["msg"] = "No avaliable Lnd nodes"
+// serve serves data, or err with the HTTP status matching its gRPC status code.
+func (this *LightningController) serve(data interface{}, err error) {
+	if err == nil {
+		this.Success(data)
+		return
+	}
+	var paymentErr *lighting.PaymentError
+	if errors.As(err, &paymentErr) {
+		this.Ctx.Output.SetStatus(http.StatusPaymentRequired)
+		this.Data["json"] = &Response{Status: http.StatusPaymentRequired, Msg: err.Error(), Data: data}
 		this.ServeJSON()
-		this.StopRun()
+		return
+	}
+	this.Failure(grpcHTTPStatus(err), err)
+}
+
+// grpcHTTPStatus maps the gRPC status code of err to an HTTP status.
+// lnd returns most errors with codes.Unknown, these map to 500.
+func grpcHTTPStatus(err error) int {
+	switch status.Code(err) {
+	case codes.InvalidArgument, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.FailedPrecondition:
+		return http.StatusPreconditionFailed
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Canceled:
+		return http.StatusRequestTimeout
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
 	}
+	return http.StatusInternalServerError
+}
+
+// decode unmarshals the request body into v, serving a 400 and returning false if it is invalid.
+func (this *LightningController) decode(v interface{}) bool {
+	if err := json.Unmarshal(this.Ctx.Input.RequestBody, v); err != nil {
+		this.Failure(http.StatusBadRequest, err)
+		return false
+	}
+	return true
 }
 
 // @Title GetInfo
 // @Description Get Lnd Info
-// @Param	LndName		path 	string	true		"The Lnd Name which we want get full info"
-// @Success 200 {object}
-// @Failure 403 : Lnd not exist for The Lnd Name
+// @Param	lndname		path 	string	true		"The Lnd Name which we want get full info"
+// @Success 200 {object} lnrpc.GetInfoResponse
+// @Failure 404 Lnd not exist for The Lnd Name
 // @router /:lndname [get]
 func (this *LightningController) Info() {
-	lndname := this.Ctx.Input.Param(":lndname")
+	this.serve(this.adapter.GetInfo(this.Ctx.Request.Context()))
+}
 
-	if models.Livenode.Contains(lndname) {
-		lndinfo := config.Cfg.Lnds[lndname]
-		adapter, err := lighting.NewAdapter(lndinfo)
+// @Title QueryRoutes
+// @Description Query Routes Info
+// @Param	lndname		path 	string	true		"The Lnd Name"
+// @Param	pay_req		path 	string	true		"The LND Payment Requests"
+// @Success 200 {object} lnrpc.QueryRoutesResponse
+// @Failure 500 Decode Failed
+// @router /:lndname/routes/:pay_req [get]
+func (this *LightningController) QueryRoutes() {
+	this.serve(this.adapter.QueryRoute(this.Ctx.Request.Context(), this.Ctx.Input.Param(":pay_req")))
+}
 
-		if err != nil {
-			this.Data["status"] = 403
-			this.Data["msg"] = err.Error()
-			this.Data["data"] = nil
-		} else {
-			info, err := adapter.GetInfo()
-
-			if err != nil {
-				this.Data["status"] = 403
-				this.Data["msg"] = err.Error()
-				this.Data["data"] = nil
-			} else {
-				this.Data["status"] = 200
-				this.Data["msg"] = ""
-				this.Data["json"] = &info
-			}
-		}
-	} else {
-		this.Data["status"] = 403
-		this.Data["msg"] = fmt.Sprintf("No avaliable lnd node %v", lndname)
-		this.Data["data"] = nil
+// @Title DecodePayReq
+// @Description Decode a payment request
+// @Param	lndname		path 	string	true		"The Lnd Name"
+// @Param	pay_req		path 	string	true		"The LND Payment Requests"
+// @Success 200 {object} lnrpc.PayReq
+// @Failure 500 Decode Failed
+// @router /:lndname/payreq/:pay_req [get]
+func (this *LightningController) DecodePayReq() {
+	this.serve(this.adapter.DecodePayReq(this.Ctx.Request.Context(), this.Ctx.Input.Param(":pay_req")))
+}
+
+// @Title AddInvoice
+// @Description Create an invoice
+// @Param	lndname		path 	string	true		"The Lnd Name"
+// @Param	body		body 	lnrpc.Invoice	true		"value, memo, expiry and private"
+// @Success 200 {object} lnrpc.AddInvoiceResponse
+// @Failure 400 invalid invoice
+// @router /:lndname/invoices [post]
+func (this *LightningController) AddInvoice() {
+	var invoice lnrpc.Invoice
+	if this.decode(&invoice) {
+		this.serve(this.adapter.AddInvoice(this.Ctx.Request.Context(), &invoice))
 	}
+}
 
-	this.ServeJSON()
+// @Title ListInvoices
+// @Description List invoices
+// @Param	lndname		path 	string	true		"The Lnd Name"
+// @Param	pending_only		query 	bool	false		"Only return open invoices"
+// @Param	index_offset		query 	int	false		"Invoice index to start from"
+// @Param	num_max_invoices		query 	int	false		"Page size"
+// @Param	reversed		query 	bool	false		"Page backwards from index_offset"
+// @Success 200 {object} lnrpc.ListInvoiceResponse
+// @router /:lndname/invoices [get]
+func (this *LightningController) ListInvoices() {
+	req := &lnrpc.ListInvoiceRequest{}
+	req.PendingOnly, _ = this.GetBool("pending_only")
+	req.IndexOffset, _ = this.GetUint64("index_offset")
+	req.NumMaxInvoices, _ = this.GetUint64("num_max_invoices")
+	req.Reversed, _ = this.GetBool("reversed")
+	this.serve(this.adapter.ListInvoices(this.Ctx.Request.Context(), req))
 }
 
-// @Title QueryRoutes
-// @Description Query Routes Info
-// @Param	PayRequests		path 	string	true		"The LND Payment Requests"
-// @Success 200 {object}
-// @Failure 403 : Decode Failed
-// @router /routes/:pay_req [get]
-func (this *LightningController) QueryRoutes() {
-	pay_req := this.Ctx.Input.Param(":pay_req")
+// @Title LookupInvoice
+// @Description Get an invoice by payment hash
+// @Param	lndname		path 	string	true		"The Lnd Name"
+// @Param	r_hash		path 	string	true		"The hex encoded payment hash"
+// @Success 200 {object} lnrpc.Invoice
+// @Failure 404 invoice not found
+// @router /:lndname/invoices/:r_hash [get]
+func (this *LightningController) LookupInvoice() {
+	this.serve(this.adapter.LookupInvoice(this.Ctx.Request.Context(), this.Ctx.Input.Param(":r_hash")))
+}
+
+// @Title SendPayment
+// @Description Pay a payment request and wait for the payment to succeed or fail
+// @Param	lndname		path 	string	true		"The Lnd Name"
+// @Param	body		body 	routerrpc.SendPaymentRequest	true		"payment_request, amt, fee_limit_sat and timeout_seconds"
+// @Success 200 {object} lnrpc.Payment
+// @Failure 402 payment failed
+// @router /:lndname/payments [post]
+func (this *LightningController) SendPayment() {
+	var req routerrpc.SendPaymentRequest
+	if !this.decode(&req) {
+		return
+	}
+	if req.TimeoutSeconds == 0 {
+		req.TimeoutSeconds = defaultPaymentTimeout
+	}
+	this.serve(this.adapter.SendPaymentV2(this.Ctx.Request.Context(), &req))
+}
+
+// @Title ListPayments
+// @Description List outgoing payments
+// @Param	lndname		path 	string	true		"The Lnd Name"
+// @Param	include_incomplete		query 	bool	false		"Include in flight and failed payments"
+// @Success 200 {object} lnrpc.ListPaymentsResponse
+// @router /:lndname/payments [get]
+func (this *LightningController) ListPayments() {
+	req := &lnrpc.ListPaymentsRequest{}
+	req.IncludeIncomplete, _ = this.GetBool("include_incomplete")
+	this.serve(this.adapter.ListPayments(this.Ctx.Request.Context(), req))
+}
+
+// @Title ListChannels
+// @Description List open channels
+// @Param	lndname		path 	string	true		"The Lnd Name"
+// @Param	active_only		query 	bool	false		"Only return active channels"
+// @Param	inactive_only		query 	bool	false		"Only return inactive channels"
+// @Success 200 {object} lnrpc.ListChannelsResponse
+// @router /:lndname/channels [get]
+func (this *LightningController) ListChannels() {
+	req := &lnrpc.ListChannelsRequest{}
+	req.ActiveOnly, _ = this.GetBool("active_only")
+	req.InactiveOnly, _ = this.GetBool("inactive_only")
+	this.serve(this.adapter.ListChannels(this.Ctx.Request.Context(), req))
+}
 
-	lndinfo := config.Cfg.Lnds["atoken-lightning-01"]
-	adapter, err := lighting.NewAdapter(lndinfo)
+// @Title PendingChannels
+// @Description List channels being opened or closed
+// @Param	lndname		path 	string	true		"The Lnd Name"
+// @Success 200 {object} lnrpc.PendingChannelsResponse
+// @router /:lndname/channels/pending [get]
+func (this *LightningController) PendingChannels() {
+	this.serve(this.adapter.PendingChannels(this.Ctx.Request.Context()))
+}
+
+// @Title OpenChannel
+// @Description Open a channel to a connected peer, returns once the funding transaction is published
+// @Param	lndname		path 	string	true		"The Lnd Name"
+// @Param	body		body 	lnrpc.OpenChannelRequest	true		"node_pubkey_string, local_funding_amount, push_sat, sat_per_byte and private"
+// @Success 200 {object} lnrpc.ChannelPoint
+// @Failure 400 invalid channel
+// @router /:lndname/channels [post]
+func (this *LightningController) OpenChannel() {
+	var req lnrpc.OpenChannelRequest
+	if this.decode(&req) {
+		this.serve(this.adapter.OpenChannel(this.Ctx.Request.Context(), &req))
+	}
+}
 
+// @Title CloseChannel
+// @Description Close a channel, returns once the closing transaction is broadcast
+// @Param	lndname		path 	string	true		"The Lnd Name"
+// @Param	funding_txid		path 	string	true		"The funding transaction id of the channel"
+// @Param	output_index		path 	int	true		"The funding output index of the channel"
+// @Param	force		query 	bool	false		"Force close the channel"
+// @Param	sat_per_byte		query 	int	false		"Fee rate of the closing transaction"
+// @Success 200 {object} lnrpc.PendingUpdate
+// @Failure 400 invalid channel point
+// @router /:lndname/channels/:funding_txid/:output_index [delete]
+func (this *LightningController) CloseChannel() {
+	channelPoint, err := lighting.ParseChannelPoint(this.Ctx.Input.Param(":funding_txid") + ":" + this.Ctx.Input.Param(":output_index"))
 	if err != nil {
-		this.Data["status"] = 403
-		this.Data["msg"] = err.Error()
-		this.Data["data"] = nil
-	} else {
-		info := adapter.QueryRoute(pay_req)
-		this.Data["status"] = 200
-		this.Data["msg"] = ""
-		this.Data["json"] = &info
+		this.Failure(http.StatusBadRequest, err)
+		return
 	}
+	req := &lnrpc.CloseChannelRequest{ChannelPoint: channelPoint}
+	req.Force, _ = this.GetBool("force")
+	req.SatPerByte, _ = this.GetInt64("sat_per_byte")
+	this.serve(this.adapter.CloseChannel(this.Ctx.Request.Context(), req))
+}
 
-	this.ServeJSON()
+// @Title ListPeers
+// @Description List connected peers
+// @Param	lndname		path 	string	true		"The Lnd Name"
+// @Success 200 {object} lnrpc.ListPeersResponse
+// @router /:lndname/peers [get]
+func (this *LightningController) ListPeers() {
+	this.serve(this.adapter.ListPeers(this.Ctx.Request.Context()))
 }
 
-// @Title SendPayment
-// @Description SendPayment
-// @Param	uid		path 	string	true		"The uid you want to update"
-// @Param	body	body 	models.User	true		"body for user content"
-// @Success 200 {object} models.User
-// @Failure 403 :uid is not int
-// @router /:uid [put]
-func (this *LightningController) SP() {
-
-	adapter, err := lighting.NewAdapter(lndinfo)
+// @Title ConnectPeer
+// @Description Connect to a peer
+// @Param	lndname		path 	string	true		"The Lnd Name"
+// @Param	body		body 	lnrpc.LightningAddress	true		"pubkey and host of the peer"
+// @Success 200 {string} connected
+// @Failure 400 invalid peer address
+// @router /:lndname/peers [post]
+func (this *LightningController) ConnectPeer() {
+	var addr lnrpc.LightningAddress
+	if !this.decode(&addr) {
+		return
+	}
+	perm, _ := this.GetBool("perm")
+	this.serve("connected", this.adapter.ConnectPeer(this.Ctx.Request.Context(), addr.Pubkey, addr.Host, perm))
+}
+
+// @Title Balance
+// @Description Get the on-chain wallet and channel balances
+// @Param	lndname		path 	string	true		"The Lnd Name"
+// @Success 200 {object} controllers.Response
+// @router /:lndname/balance [get]
+func (this *LightningController) Balance() {
+	ctx := this.Ctx.Request.Context()
+	wallet, err := this.adapter.WalletBalance(ctx)
+	if err != nil {
+		this.serve(nil, err)
+		return
+	}
+	channel, err := this.adapter.ChannelBalance(ctx)
+	if err != nil {
+		this.serve(nil, err)
+		return
+	}
+	this.Success(map[string]interface{}{"wallet": wallet, "channel": channel})
+}
+
+// @Title NewAddress
+// @Description Create a new on-chain address of the lnd wallet
+// @Param	lndname		path 	string	true		"The Lnd Name"
+// @Param	type		query 	string	false		"p2wkh (default) or np2wkh"
+// @Success 200 {string} address
+// @Failure 400 invalid address type
+// @router /:lndname/addresses [post]
+func (this *LightningController) NewAddress() {
+	var addressType lnrpc.AddressType
+	switch this.GetString("type", "p2wkh") {
+	case "p2wkh":
+		addressType = lnrpc.AddressType_WITNESS_PUBKEY_HASH
+	case "np2wkh":
+		addressType = lnrpc.AddressType_NESTED_PUBKEY_HASH
+	default:
+		this.Failure(http.StatusBadRequest, errors.New("type must be p2wkh or np2wkh"))
+		return
+	}
+	this.serve(this.adapter.NewAddress(this.Ctx.Request.Context(), addressType))
+}
+
+// stream serves the events of subscribe as Server-Sent Events until the subscription ends or the
+// client disconnects. A subscription failing for another reason ends with an "error" event.
+func (this *LightningController) stream(subscribe func(ctx context.Context, send func(event string, data interface{}) error) error) {
+	ctx := this.Ctx.Request.Context()
+	s := this.StartEventStream()
+	defer s.Close()
+
+	err := subscribe(ctx, s.Send)
+	if err != nil && ctx.Err() == nil {
+		s.Send("error", &Response{Status: grpcHTTPStatus(err), Msg: err.Error()})
+	}
+}
+
+// @Title SubscribeInvoices
+// @Description Stream invoice additions and settlements as Server-Sent "invoice" events
+// @Param	lndname		path 	string	true		"The Lnd Name"
+// @Param	add_index		query 	int	false		"Replay invoices added after this index"
+// @Param	settle_index		query 	int	false		"Replay invoices settled after this index"
+// @Success 200 {object} lnrpc.Invoice
+// @router /:lndname/events/invoices [get]
+func (this *LightningController) SubscribeInvoices() {
+	addIndex, _ := this.GetUint64("add_index")
+	settleIndex, _ := this.GetUint64("settle_index")
+	this.stream(func(ctx context.Context, send func(string, interface{}) error) error {
+		return this.adapter.SubscribeInvoices(ctx, addIndex, settleIndex, func(invoice *lnrpc.Invoice) error {
+			return send("invoice", invoice)
+		})
+	})
+}
+
+// @Title SubscribeChannelEvents
+// @Description Stream channel open, close, active and inactive updates as Server-Sent "channel" events
+// @Param	lndname		path 	string	true		"The Lnd Name"
+// @Success 200 {object} lnrpc.ChannelEventUpdate
+// @router /:lndname/events/channels [get]
+func (this *LightningController) SubscribeChannelEvents() {
+	this.stream(func(ctx context.Context, send func(string, interface{}) error) error {
+		return this.adapter.SubscribeChannelEvents(ctx, func(event *lnrpc.ChannelEventUpdate) error {
+			return send("channel", event)
+		})
+	})
+}
+
+// @Title SubscribeTransactions
+// @Description Stream on-chain wallet transactions as Server-Sent "transaction" events
+// @Param	lndname		path 	string	true		"The Lnd Name"
+// @Success 200 {object} lnrpc.Transaction
+// @router /:lndname/events/transactions [get]
+func (this *LightningController) SubscribeTransactions() {
+	this.stream(func(ctx context.Context, send func(string, interface{}) error) error {
+		return this.adapter.SubscribeTransactions(ctx, func(tx *lnrpc.Transaction) error {
+			return send("transaction", tx)
+		})
+	})
+}
+
+// @Title TrackPayment
+// @Description Stream the states of a payment as Server-Sent "payment" events, ends once it succeeded or failed
+// @Param	lndname		path 	string	true		"The Lnd Name"
+// @Param	payment_hash		path 	string	true		"The hex encoded payment hash"
+// @Success 200 {object} lnrpc.Payment
+// @Failure 400 invalid payment hash
+// @router /:lndname/events/payments/:payment_hash [get]
+func (this *LightningController) TrackPayment() {
+	paymentHash, err := hex.DecodeString(this.Ctx.Input.Param(":payment_hash"))
+	if err != nil || len(paymentHash) != 32 {
+		this.Failure(http.StatusBadRequest, errors.New("payment hash must be 32 bytes of hex"))
+		return
+	}
+	this.stream(func(ctx context.Context, send func(string, interface{}) error) error {
+		return this.adapter.TrackPaymentV2(ctx, paymentHash, func(payment *lnrpc.Payment) error {
+			return send("payment", payment)
+		})
+	})
 }
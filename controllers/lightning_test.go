@@ -0,0 +1,116 @@
+package controllers_test
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/astaxie/beego"
+	"github.com/jualy007/GoTF/blockchain/lighting/lndtest"
+	"github.com/jualy007/GoTF/controllers"
+	"github.com/jualy007/GoTF/models"
+	"github.com/lightningnetwork/lnd/lnrpc"
+)
+
+// newHandler serves the lightning routes used by the tests for fake nodes alice and bob.
+func newHandler(t *testing.T) (*beego.ControllerRegister, *lndtest.Server, *lndtest.Server) {
+	t.Helper()
+	alice, err := lndtest.NewServer("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := lndtest.NewServer("bob")
+	if err != nil {
+		alice.Close()
+		t.Fatal(err)
+	}
+	models.SetLndPool(lndtest.Pool(alice, bob))
+	t.Cleanup(func() {
+		models.SetLndPool(nil)
+		alice.Close()
+		bob.Close()
+	})
+
+	beego.BConfig.CopyRequestBody = true
+	handler := beego.NewControllerRegister()
+	handler.Add("/v1/lightning/:lndname", &controllers.LightningController{}, "get:Info")
+	handler.Add("/v1/lightning/:lndname/invoices", &controllers.LightningController{}, "post:AddInvoice")
+	handler.Add("/v1/lightning/:lndname/invoices/:r_hash", &controllers.LightningController{}, "get:LookupInvoice")
+	handler.Add("/v1/lightning/:lndname/payments", &controllers.LightningController{}, "post:SendPayment")
+	return handler, alice, bob
+}
+
+// serve serves the request and decodes the data of the response envelope into data, if not nil.
+func serve(t *testing.T, handler http.Handler, method, url, body string, data interface{}) int {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(method, url, strings.NewReader(body)))
+	resp := controllers.Response{Data: data}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%v %v: %v in %s", method, url, err, w.Body.Bytes())
+	}
+	if resp.Status != w.Code {
+		t.Errorf("%v %v: envelope status %v, HTTP status %v", method, url, resp.Status, w.Code)
+	}
+	return w.Code
+}
+
+func TestInfo(t *testing.T) {
+	handler, alice, _ := newHandler(t)
+
+	var info lnrpc.GetInfoResponse
+	if code := serve(t, handler, "GET", "/v1/lightning/alice", "", &info); code != http.StatusOK {
+		t.Fatalf("GET alice: %v", code)
+	}
+	if info.Alias != "alice" || info.IdentityPubkey != alice.PubKey() {
+		t.Errorf("GET alice = %v", &info)
+	}
+
+	if code := serve(t, handler, "GET", "/v1/lightning/carol", "", nil); code != http.StatusNotFound {
+		t.Errorf("GET unknown node: %v, want 404", code)
+	}
+
+	alice.Inject("GetInfo", lndtest.Unavailable)
+	if code := serve(t, handler, "GET", "/v1/lightning/alice", "", nil); code != http.StatusServiceUnavailable {
+		t.Errorf("GET unavailable node: %v, want 503", code)
+	}
+}
+
+func TestInvoices(t *testing.T) {
+	handler, _, bob := newHandler(t)
+
+	var added lnrpc.AddInvoiceResponse
+	if code := serve(t, handler, "POST", "/v1/lightning/bob/invoices", `{"value": 1000, "memo": "coffee"}`, &added); code != http.StatusOK {
+		t.Fatalf("POST invoice: %v", code)
+	}
+	var invoice lnrpc.Invoice
+	rHash := hex.EncodeToString(added.RHash)
+	if code := serve(t, handler, "GET", "/v1/lightning/bob/invoices/"+rHash, "", &invoice); code != http.StatusOK {
+		t.Fatalf("GET invoice: %v", code)
+	}
+	if invoice.Value != 1000 || invoice.Memo != "coffee" || invoice.PaymentRequest != added.PaymentRequest {
+		t.Errorf("GET invoice = %v", &invoice)
+	}
+	if bob.Invoice(rHash) == nil {
+		t.Errorf("invoice %v not added to bob", rHash)
+	}
+
+	if code := serve(t, handler, "POST", "/v1/lightning/bob/invoices", `{"value": "x"}`, nil); code != http.StatusBadRequest {
+		t.Errorf("POST invalid invoice: %v, want 400", code)
+	}
+}
+
+func TestSendPaymentStreamFault(t *testing.T) {
+	handler, alice, _ := newHandler(t)
+
+	alice.Inject("SendPaymentV2", lndtest.Unavailable)
+	if code := serve(t, handler, "POST", "/v1/lightning/alice/payments", `{"payment_request": "lnbcrt1"}`, nil); code != http.StatusServiceUnavailable {
+		t.Errorf("POST payment with an Unavailable fault: %v, want 503", code)
+	}
+	if n := alice.Calls("SendPaymentV2"); n != 1 {
+		t.Errorf("%v SendPaymentV2 calls, want 1", n)
+	}
+}
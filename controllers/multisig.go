@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/jualy007/GoTF/blockchain/btc/multisig"
+	"github.com/jualy007/GoTF/models"
+)
+
+// Operations about multisig wallets
+type MultisigController struct {
+	BaseController
+}
+
+// @Title Keys
+// @Description generate public/private key pairs
+// @Param	body		body 	models.KeysRequest	true		"No. of key pairs to generate (1-100)"
+// @Success 200 {object} controllers.Response
+// @Failure 400 invalid key count
+// @router /keys [post]
+func (this *MultisigController) Keys() {
+	var req models.KeysRequest
+	if err := json.Unmarshal(this.Ctx.Input.RequestBody, &req); err != nil {
+		this.Failure(http.StatusBadRequest, err)
+		return
+	}
+	keyPairs, err := multisig.GenerateKeys(req.Count)
+	if err != nil {
+		this.Failure(http.StatusBadRequest, err)
+		return
+	}
+	this.Success(keyPairs)
+}
+
+// @Title Address
+// @Description create a P2SH, P2WSH or P2SH-P2WSH multisig address
+// @Param	body		body 	models.AddressRequest	true		"M, N, public keys, script type, BIP67 sorting and network"
+// @Success 200 {object} controllers.Response
+// @Failure 400 invalid multisig parameters
+// @router /address [post]
+func (this *MultisigController) Address() {
+	var req models.AddressRequest
+	if err := json.Unmarshal(this.Ctx.Input.RequestBody, &req); err != nil {
+		this.Failure(http.StatusBadRequest, err)
+		return
+	}
+	address, err := req.Generate()
+	if err != nil {
+		this.Failure(http.StatusBadRequest, err)
+		return
+	}
+	this.Success(address)
+}
+
+// @Title CreatePayout
+// @Description create an unsigned payout transaction collecting partial signatures
+// @Param	body		body 	models.PayoutRequest	true		"Multisig script, inputs, outputs, fee rate and change address"
+// @Success 200 {object} models.PayoutSession
+// @Failure 400 invalid payout
+// @router /payouts [post]
+func (this *MultisigController) CreatePayout() {
+	var req models.PayoutRequest
+	if err := json.Unmarshal(this.Ctx.Input.RequestBody, &req); err != nil {
+		this.Failure(http.StatusBadRequest, err)
+		return
+	}
+	params, err := req.Params()
+	if err != nil {
+		this.Failure(http.StatusBadRequest, err)
+		return
+	}
+	payout, err := multisig.NewPayout(params)
+	if err != nil {
+		this.Failure(http.StatusBadRequest, err)
+		return
+	}
+	session, err := models.AddPayout(payout)
+	if err != nil {
+		this.Failure(http.StatusInternalServerError, err)
+		return
+	}
+	this.Success(session)
+}
+
+// @Title GetPayout
+// @Description get a payout with its signatures, and the signed transaction once complete
+// @Param	payoutId		path 	string	true		"The payout id"
+// @Success 200 {object} models.PayoutSession
+// @Failure 404 payout not exist
+// @router /payouts/:payoutId [get]
+func (this *MultisigController) GetPayout() {
+	session, err := models.GetPayout(this.Ctx.Input.Param(":payoutId"))
+	if err != nil {
+		this.Failure(http.StatusNotFound, err)
+		return
+	}
+	this.Success(session)
+}
+
+// @Title Sign
+// @Description submit partial signatures, or a private key to sign every input with
+// @Param	payoutId		path 	string	true		"The payout id"
+// @Param	body		body 	models.SignaturesRequest	true		"Partial signatures or a WIF private key"
+// @Success 200 {object} models.PayoutSession
+// @Failure 400 invalid signature
+// @Failure 404 payout not exist
+// @router /payouts/:payoutId/signatures [post]
+func (this *MultisigController) Sign() {
+	payoutId := this.Ctx.Input.Param(":payoutId")
+	if _, err := models.GetPayout(payoutId); err != nil {
+		this.Failure(http.StatusNotFound, err)
+		return
+	}
+	var req models.SignaturesRequest
+	if err := json.Unmarshal(this.Ctx.Input.RequestBody, &req); err != nil {
+		this.Failure(http.StatusBadRequest, err)
+		return
+	}
+
+	var session *models.PayoutSession
+	var err error
+	if req.PrivateKey != "" {
+		session, err = models.SignPayout(payoutId, req.PrivateKey)
+	} else {
+		session, err = models.AddSignatures(payoutId, req.Signatures)
+	}
+	if err != nil {
+		this.Failure(http.StatusBadRequest, err)
+		return
+	}
+	this.Success(session)
+}
+
+// @Title DeletePayout
+// @Description delete a payout
+// @Param	payoutId		path 	string	true		"The payout id"
+// @Success 200 {string} delete success!
+// @router /payouts/:payoutId [delete]
+func (this *MultisigController) DeletePayout() {
+	models.DeletePayout(this.Ctx.Input.Param(":payoutId"))
+	this.Success("delete success!")
+}
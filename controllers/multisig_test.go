@@ -0,0 +1,265 @@
+package controllers_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/astaxie/beego"
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+
+	"github.com/jualy007/GoTF/blockchain/btc/multisig"
+	"github.com/jualy007/GoTF/controllers"
+	"github.com/jualy007/GoTF/models"
+)
+
+// newMultisigHandler serves the multisig routes.
+func newMultisigHandler() *beego.ControllerRegister {
+	beego.BConfig.CopyRequestBody = true
+	handler := beego.NewControllerRegister()
+	handler.Add("/v1/btc/multisig/keys", &controllers.MultisigController{}, "post:Keys")
+	handler.Add("/v1/btc/multisig/address", &controllers.MultisigController{}, "post:Address")
+	handler.Add("/v1/btc/multisig/payouts", &controllers.MultisigController{}, "post:CreatePayout")
+	handler.Add("/v1/btc/multisig/payouts/:payoutId", &controllers.MultisigController{}, "get:GetPayout;delete:DeletePayout")
+	handler.Add("/v1/btc/multisig/payouts/:payoutId/signatures", &controllers.MultisigController{}, "post:Sign")
+	return handler
+}
+
+// multisigKey returns the i-th deterministic private key of the tests.
+func multisigKey(i int) *btcec.PrivateKey {
+	seed := sha256.Sum256([]byte(fmt.Sprintf("multisig controller key %d", i)))
+	key, _ := btcec.PrivKeyFromBytes(btcec.S256(), seed[:])
+	return key
+}
+
+// multisigPayout returns the request of a 2-of-3 P2WSH payout of the first three keys, on mainnet.
+func multisigPayout(t *testing.T) models.PayoutRequest {
+	t.Helper()
+	publicKeys := make([]string, 3)
+	for i := range publicKeys {
+		publicKeys[i] = hex.EncodeToString(multisigKey(i).PubKey().SerializeCompressed())
+	}
+	address, err := multisig.GenerateAddress(2, 3, publicKeys, multisig.AddressOptions{Type: multisig.P2WSH})
+	if err != nil {
+		t.Fatal(err)
+	}
+	destination, err := btcutil.NewAddressWitnessPubKeyHash(make([]byte, 20), &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return models.PayoutRequest{
+		Type:   multisig.P2WSH,
+		Script: address.WitnessScript,
+		Inputs: []multisig.UTXO{
+			{TxHash: strings.Repeat("11", 32), Vout: 0, Amount: 100000},
+			{TxHash: strings.Repeat("22", 32), Vout: 3, Amount: 50000},
+		},
+		Outputs: []multisig.Payment{{Address: destination.EncodeAddress(), Amount: 120000}},
+		FeeRate: 2,
+	}
+}
+
+// signatures returns the partial signatures of the payout by the i-th key.
+func signatures(t *testing.T, req models.PayoutRequest, i int) []multisig.PartialSignature {
+	t.Helper()
+	params, err := req.Params()
+	if err != nil {
+		t.Fatal(err)
+	}
+	payout, err := multisig.NewPayout(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	partial, err := payout.Sign(multisigKey(i).Serialize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return partial
+}
+
+func marshal(t *testing.T, v interface{}) string {
+	t.Helper()
+	body, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestMultisigKeys(t *testing.T) {
+	handler := newMultisigHandler()
+
+	var keyPairs []multisig.KeyPair
+	if code := serve(t, handler, "POST", "/v1/btc/multisig/keys", `{"count": 3}`, &keyPairs); code != http.StatusOK {
+		t.Fatalf("POST keys: %v", code)
+	}
+	if len(keyPairs) != 3 || keyPairs[0].PrivateKey == "" || keyPairs[0].PublicKey == "" || keyPairs[0].Address == "" {
+		t.Errorf("POST keys = %+v", keyPairs)
+	}
+
+	for _, body := range []string{`{"count": 0}`, `{"count": 101}`, `{"count": "x"}`} {
+		if code := serve(t, handler, "POST", "/v1/btc/multisig/keys", body, nil); code != http.StatusBadRequest {
+			t.Errorf("POST keys %v: %v, want 400", body, code)
+		}
+	}
+}
+
+func TestMultisigAddress(t *testing.T) {
+	handler := newMultisigHandler()
+	publicKeys := make([]string, 3)
+	for i := range publicKeys {
+		publicKeys[i] = hex.EncodeToString(multisigKey(i).PubKey().SerializeCompressed())
+	}
+	req := models.AddressRequest{M: 2, N: 3, PublicKeys: publicKeys, Type: multisig.P2WSH, Sort: true, Network: "regtest"}
+
+	var address multisig.MultisigAddress
+	if code := serve(t, handler, "POST", "/v1/btc/multisig/address", marshal(t, req), &address); code != http.StatusOK {
+		t.Fatalf("POST address: %v", code)
+	}
+	want, err := multisig.GenerateAddress(2, 3, publicKeys, multisig.AddressOptions{Type: multisig.P2WSH, Sort: true, Net: &chaincfg.RegressionNetParams})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if address.Address != want.Address || !strings.HasPrefix(address.Address, "bcrt1q") || address.WitnessScript != want.WitnessScript {
+		t.Errorf("POST address = %+v, want %+v", address, want)
+	}
+
+	bad := []models.AddressRequest{
+		{M: 3, N: 2, PublicKeys: publicKeys[:2]},
+		{M: 1, N: 1, PublicKeys: []string{"zz"}},
+		{M: 1, N: 1, PublicKeys: publicKeys[:1], Type: "p2tr"},
+		{M: 1, N: 1, PublicKeys: publicKeys[:1], Network: "signet"},
+	}
+	for _, req := range bad {
+		if code := serve(t, handler, "POST", "/v1/btc/multisig/address", marshal(t, req), nil); code != http.StatusBadRequest {
+			t.Errorf("POST address %+v: %v, want 400", req, code)
+		}
+	}
+}
+
+func TestMultisigPayout(t *testing.T) {
+	handler := newMultisigHandler()
+	req := multisigPayout(t)
+
+	var created models.PayoutSession
+	if code := serve(t, handler, "POST", "/v1/btc/multisig/payouts", marshal(t, req), &created); code != http.StatusOK {
+		t.Fatalf("POST payout: %v", code)
+	}
+	url := "/v1/btc/multisig/payouts/" + created.Id
+	defer models.DeletePayout(created.Id)
+	if created.Required != 2 || created.UnsignedTransaction == "" || created.Fee <= 0 || created.ChangeIndex != 1 || created.Transaction != "" {
+		t.Errorf("POST payout = %+v", created)
+	}
+
+	// A private key signs every input
+	wif, err := btcutil.NewWIF(multisigKey(2), &chaincfg.MainNetParams, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var session models.PayoutSession
+	if code := serve(t, handler, "POST", url+"/signatures", marshal(t, models.SignaturesRequest{PrivateKey: wif.String()}), &session); code != http.StatusOK {
+		t.Fatalf("POST private key: %v", code)
+	}
+	if len(session.Signatures) != 2 || session.Transaction != "" {
+		t.Errorf("POST private key = %+v", session)
+	}
+
+	// Nothing is added for a signature of the other input, a malformed private key or malformed JSON
+	partial := signatures(t, req, 0)
+	swapped := partial[1]
+	swapped.Input = 0
+	for _, body := range []string{
+		marshal(t, models.SignaturesRequest{Signatures: []multisig.PartialSignature{partial[0], swapped}}),
+		marshal(t, models.SignaturesRequest{PrivateKey: wif.String()[1:]}),
+		`{"signatures": "x"}`,
+	} {
+		if code := serve(t, handler, "POST", url+"/signatures", body, nil); code != http.StatusBadRequest {
+			t.Errorf("POST invalid signatures %v: %v, want 400", body, code)
+		}
+	}
+	if code := serve(t, handler, "GET", url, "", &session); code != http.StatusOK || len(session.Signatures) != 2 {
+		t.Errorf("GET payout after invalid signatures: %v %+v", code, session)
+	}
+
+	// Signatures of a second key complete the payout
+	if code := serve(t, handler, "POST", url+"/signatures", marshal(t, models.SignaturesRequest{Signatures: partial}), &session); code != http.StatusOK {
+		t.Fatalf("POST signatures: %v", code)
+	}
+	if len(session.Signatures) != 4 || session.Transaction == "" {
+		t.Fatalf("POST signatures = %+v", session)
+	}
+	var got models.PayoutSession
+	if code := serve(t, handler, "GET", url, "", &got); code != http.StatusOK || got.Transaction != session.Transaction {
+		t.Errorf("GET payout: %v %+v", code, got)
+	}
+	if code := serve(t, handler, "POST", url+"/signatures", marshal(t, models.SignaturesRequest{Signatures: partial}), nil); code != http.StatusBadRequest {
+		t.Errorf("POST signatures of a signed payout: %v, want 400", code)
+	}
+
+	if code := serve(t, handler, "DELETE", url, "", nil); code != http.StatusOK {
+		t.Errorf("DELETE payout: %v", code)
+	}
+	if code := serve(t, handler, "GET", url, "", nil); code != http.StatusNotFound {
+		t.Errorf("GET deleted payout: %v, want 404", code)
+	}
+	if code := serve(t, handler, "POST", url+"/signatures", marshal(t, models.SignaturesRequest{Signatures: partial}), nil); code != http.StatusNotFound {
+		t.Errorf("POST signatures of a deleted payout: %v, want 404", code)
+	}
+
+	bad := multisigPayout(t)
+	bad.Outputs[0].Amount = 1000000
+	if code := serve(t, handler, "POST", "/v1/btc/multisig/payouts", marshal(t, bad), nil); code != http.StatusBadRequest {
+		t.Errorf("POST payout above its inputs: %v, want 400", code)
+	}
+	bad = multisigPayout(t)
+	bad.Script = "zz"
+	if code := serve(t, handler, "POST", "/v1/btc/multisig/payouts", marshal(t, bad), nil); code != http.StatusBadRequest {
+		t.Errorf("POST payout of a bad script: %v, want 400", code)
+	}
+}
+
+// TestMultisigPayoutConcurrentSignatures serves payouts while signatures are added, for the race detector.
+func TestMultisigPayoutConcurrentSignatures(t *testing.T) {
+	handler := newMultisigHandler()
+	req := multisigPayout(t)
+
+	var created models.PayoutSession
+	if code := serve(t, handler, "POST", "/v1/btc/multisig/payouts", marshal(t, req), &created); code != http.StatusOK {
+		t.Fatalf("POST payout: %v", code)
+	}
+	url := "/v1/btc/multisig/payouts/" + created.Id
+	defer models.DeletePayout(created.Id)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		body := marshal(t, models.SignaturesRequest{Signatures: signatures(t, req, i)})
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			serve(t, handler, "POST", url+"/signatures", body, nil)
+		}()
+		go func() {
+			defer wg.Done()
+			//Poll until the payout is signed so reads overlap every write
+			for j := 0; j < 1000; j++ {
+				var session models.PayoutSession
+				if serve(t, handler, "GET", url, "", &session); session.Transaction != "" {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	var session models.PayoutSession
+	if code := serve(t, handler, "GET", url, "", &session); code != http.StatusOK || session.Transaction == "" {
+		t.Errorf("GET payout: %v %+v", code, session)
+	}
+}
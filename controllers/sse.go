@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/astaxie/beego/context"
+)
+
+// keepAliveInterval is how often an idle event stream sends a comment to keep proxies from closing it.
+const keepAliveInterval = 15 * time.Second
+
+// EventStream writes Server-Sent Events, safe for concurrent use.
+type EventStream struct {
+	mu      sync.Mutex
+	resp    *context.Response
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// StartEventStream switches the response to a text/event-stream and keeps it alive until Close.
+func (this *BaseController) StartEventStream() *EventStream {
+	this.EnableRender = false
+	header := this.Ctx.ResponseWriter.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+	this.Ctx.ResponseWriter.WriteHeader(200)
+	this.Ctx.ResponseWriter.Flush()
+
+	s := &EventStream{resp: this.Ctx.ResponseWriter, done: make(chan struct{}), stopped: make(chan struct{})}
+	go s.keepAlive()
+	return s
+}
+
+// Send writes an event with data encoded as JSON.
+func (s *EventStream) Send(event string, data interface{}) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return s.write(fmt.Sprintf("event: %s\ndata: %s\n\n", event, b))
+}
+
+// Close stops the keep-alive comments, the response ends when the controller returns.
+func (s *EventStream) Close() {
+	close(s.done)
+	<-s.stopped
+}
+
+func (s *EventStream) keepAlive() {
+	defer close(s.stopped)
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if s.write(": keep-alive\n\n") != nil {
+				return
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *EventStream) write(msg string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.resp.Write([]byte(msg)); err != nil {
+		return err
+	}
+	s.resp.Flush()
+	return nil
+}
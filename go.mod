@@ -6,7 +6,6 @@ require (
 	github.com/astaxie/beego v1.12.1
 	github.com/btcsuite/btcd v0.20.1-beta
 	github.com/btcsuite/btcutil v1.0.2
-	github.com/emirpasic/gods v1.12.0
 	github.com/frankban/quicktest v1.7.2 // indirect
 	github.com/golang/protobuf v1.4.1 // indirect
 	github.com/kr/pretty v0.2.0 // indirect
@@ -5,6 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/astaxie/beego/orm"
+	"github.com/jualy007/GoTF/blockchain/btc/multisig"
+	"github.com/jualy007/GoTF/blockchain/lighting/probe"
+	"github.com/jualy007/GoTF/blockchain/lighting/topology"
 	"github.com/jualy007/GoTF/models"
 	"os"
 	"path"
@@ -31,23 +34,31 @@ func main() {
 
 	app.Flags = []cli.Flag{
 		&cli.StringFlag{
-			Name:     "configdir",
-			Usage:    "Configuration Files Directory",
-			Required: true,
+			Name:  "configdir",
+			Usage: "Configuration Files Directory (required to start the server)",
 		},
 		&cli.StringFlag{
-			Name:     "logdir",
-			Usage:    "Log Directory",
-			Required: true,
+			Name:  "logdir",
+			Usage: "Log Directory (required to start the server)",
 		},
 	}
 
+	app.Commands = []*cli.Command{
+		multisig.Command(),
+		topology.Command(),
+		probe.Command(),
+	}
+
 	app.Before = func(cctx *cli.Context) error {
-		fmt.Println("Before Start......")
+		fmt.Fprintln(os.Stderr, "Before Start......")
 		return nil
 	}
 
 	app.Action = func(cctx *cli.Context) error {
+		if cctx.String("configdir") == "" || cctx.String("logdir") == "" {
+			return cli.Exit("Required flags \"configdir, logdir\" not set", 1)
+		}
+
 		// Load Customer Beego Configurations
 		bconf := path.Join(cctx.String("configdir"), "beego.yaml")
 		err := beego.LoadAppConfig("yaml", bconf)
@@ -102,17 +113,30 @@ func main() {
 			beego.BConfig.WebConfig.DirectoryIndex = true
 			beego.BConfig.WebConfig.StaticDir["/swagger"] = "swagger"
 		}
+		defer models.LndPool().Close()
+		models.LndHealth().Start()
+		defer models.LndHealth().Stop()
+
+		// Claim and refund the pending swaps, also those of a previous run.
+		swapCtx, stopSwaps := context.WithCancel(context.Background())
+		defer stopSwaps()
+		if provider, err := models.SwapProvider(); err != nil {
+			logs.Warn("Swaps are not advanced: %v", err)
+		} else {
+			go provider.Run(swapCtx)
+		}
 		beego.Run()
 		return nil
 	}
 
 	app.After = func(cctx *cli.Context) error {
-		fmt.Println("Start End......")
+		fmt.Fprintln(os.Stderr, "Start End......")
 		return nil
 	}
 
 	err := app.Run(os.Args)
 	if err != nil {
 		fmt.Printf("Run Error!!! %s\n", err)
+		os.Exit(1)
 	}
 }
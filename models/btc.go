@@ -1 +1,73 @@
 package models
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/jualy007/GoTF/blockchain/btc"
+	"github.com/jualy007/GoTF/config"
+)
+
+var (
+	bitcoindMu sync.Mutex
+	bitcoind   *btc.Bitcoind
+)
+
+// DefaultFeeTargets are the confirmation targets, in blocks, of FeeEstimates.
+var DefaultFeeTargets = []int{2, 6, 12, 144}
+
+// Bitcoind returns the client of the bitcoin node configured in config.Cfg.Btc.
+func Bitcoind() (*btc.Bitcoind, error) {
+	bitcoindMu.Lock()
+	defer bitcoindMu.Unlock()
+
+	if bitcoind != nil {
+		return bitcoind, nil
+	}
+	if config.Cfg == nil || config.Cfg.Btc.Address == "" {
+		return nil, errors.New("No bitcoin node configured")
+	}
+	b, err := btc.NewFromConfig(config.Cfg.Btc)
+	if err != nil {
+		return nil, err
+	}
+	bitcoind = b
+	return bitcoind, nil
+}
+
+// SetBitcoind replaces the client returned by Bitcoind, nil connects to the configured node again.
+// Tests use it to serve fake nodes.
+func SetBitcoind(b *btc.Bitcoind) {
+	bitcoindMu.Lock()
+	defer bitcoindMu.Unlock()
+	bitcoind = b
+}
+
+// BlockByHeight returns the block at height in the best block chain.
+func BlockByHeight(b *btc.Bitcoind, height uint64) (btc.Block, error) {
+	hash, err := b.GetBlockHash(height)
+	if err != nil {
+		return btc.Block{}, err
+	}
+	return b.GetBlock(hash)
+}
+
+// FeeEstimates returns the smart fee estimate for each confirmation target.
+func FeeEstimates(b *btc.Bitcoind, targets []int) (map[int]btc.EstimateSmartFeeResult, error) {
+	estimates := make(map[int]btc.EstimateSmartFeeResult, len(targets))
+	for _, target := range targets {
+		estimate, err := b.EstimateSmartFee(target)
+		if err != nil {
+			return nil, err
+		}
+		estimates[target] = estimate
+	}
+	return estimates, nil
+}
+
+type SendRequest struct {
+	Address   string  `json:"address"`
+	Amount    float64 `json:"amount"`
+	Comment   string  `json:"comment"`
+	CommentTo string  `json:"commentTo"`
+}
@@ -1,28 +1,238 @@
 package models
 
 import (
-	"fmt"
-	"github.com/emirpasic/gods/lists/arraylist"
+	"context"
+	"expvar"
+	"sort"
+	"sync"
+	"time"
+
 	"github.com/jualy007/GoTF/blockchain/lighting"
 	"github.com/jualy007/GoTF/config"
+	"github.com/lightningnetwork/lnd/lnrpc"
 )
 
-var Livenode = arraylist.New()
+var (
+	lndPoolMu sync.Mutex
+	lndPool   *lighting.Pool
+
+	lndHealthMu sync.Mutex
+	lndHealth   *HealthMonitor
+)
+
+// Interval and per node timeout of the background health checks.
+const (
+	DefaultHealthInterval = 30 * time.Second
+	DefaultHealthTimeout  = 10 * time.Second
+)
 
 func init() {
-	Healthcheck()
+	expvar.Publish("lnd", expvar.Func(func() interface{} {
+		return LndHealth().Status()
+	}))
+}
+
+// LndPool returns the connection pool of the lnd nodes configured in config.Cfg.Lnds.
+func LndPool() *lighting.Pool {
+	lndPoolMu.Lock()
+	defer lndPoolMu.Unlock()
+
+	if lndPool == nil {
+		var nodes map[string]*config.LndInfo
+		if config.Cfg != nil {
+			nodes = config.Cfg.Lnds
+		}
+		lndPool = lighting.NewPool(nodes)
+	}
+	return lndPool
+}
+
+// SetLndPool replaces the pool returned by LndPool, closing the previous one, and resets LndHealth.
+// Tests use it to serve fake nodes.
+func SetLndPool(pool *lighting.Pool) {
+	lndHealthMu.Lock()
+	if lndHealth != nil {
+		lndHealth.Stop()
+		lndHealth = nil
+	}
+	lndHealthMu.Unlock()
+
+	lndPoolMu.Lock()
+	defer lndPoolMu.Unlock()
+	if lndPool != nil {
+		lndPool.Close()
+	}
+	lndPool = pool
+}
+
+// LndHealth returns the health monitor of the pooled lnd nodes. It is not started.
+func LndHealth() *HealthMonitor {
+	lndHealthMu.Lock()
+	defer lndHealthMu.Unlock()
+
+	if lndHealth == nil {
+		lndHealth = NewHealthMonitor(LndPool(), DefaultHealthInterval, DefaultHealthTimeout)
+	}
+	return lndHealth
+}
+
+// NodeHealth is the result of the latest health check of a lnd node.
+// A node is alive if its latest GetInfo call succeeded.
+type NodeHealth struct {
+	Name          string     `json:"name"`
+	Alive         bool       `json:"alive"`
+	Connection    string     `json:"connection"`
+	SyncedToChain bool       `json:"synced_to_chain"`
+	BlockHeight   uint32     `json:"block_height"`
+	NumPeers      uint32     `json:"num_peers"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastErrorAt   *time.Time `json:"last_error_at,omitempty"`
+	LastAliveAt   *time.Time `json:"last_alive_at,omitempty"`
+	CheckedAt     *time.Time `json:"checked_at,omitempty"`
+	Checks        int64      `json:"checks"`
+	Failures      int64      `json:"failures"`
+}
+
+// HealthMonitor periodically calls GetInfo on every node of a pool and records the results.
+type HealthMonitor struct {
+	pool     *lighting.Pool
+	interval time.Duration
+	timeout  time.Duration
+
+	mu    sync.RWMutex
+	nodes map[string]NodeHealth
+
+	runMu sync.Mutex
+	stop  chan struct{}
+	done  chan struct{}
 }
 
-func Healthcheck() {
-	Livenode.Clear()
+func NewHealthMonitor(pool *lighting.Pool, interval time.Duration, timeout time.Duration) *HealthMonitor {
+	m := &HealthMonitor{pool: pool, interval: interval, timeout: timeout, nodes: make(map[string]NodeHealth)}
+	for _, name := range pool.Names() {
+		m.nodes[name] = NodeHealth{Name: name}
+	}
+	return m
+}
+
+// Start checks every node immediately and then once per interval, until Stop is called.
+func (m *HealthMonitor) Start() {
+	m.runMu.Lock()
+	defer m.runMu.Unlock()
+	if m.stop != nil {
+		return
+	}
+	m.stop, m.done = make(chan struct{}), make(chan struct{})
+
+	go func(stop <-chan struct{}, done chan<- struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+		for {
+			m.Check()
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}(m.stop, m.done)
+}
+
+// Stop stops the background checks and waits for a running check to return.
+func (m *HealthMonitor) Stop() {
+	m.runMu.Lock()
+	defer m.runMu.Unlock()
+	if m.stop == nil {
+		return
+	}
+	close(m.stop)
+	<-m.done
+	m.stop, m.done = nil, nil
+}
+
+// Check checks every node concurrently and returns once all checks are recorded.
+func (m *HealthMonitor) Check() {
+	var wg sync.WaitGroup
+	for _, name := range m.pool.Names() {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			m.check(name)
+		}(name)
+	}
+	wg.Wait()
+}
 
-	for key, value := range config.Cfg.Lnds {
-		_, err := lighting.NewAdapter(value)
+func (m *HealthMonitor) check(name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	adapter, err := m.pool.Get(name)
+	if err == nil {
+		var info *lnrpc.GetInfoResponse
+		if info, err = adapter.GetInfo(ctx); err == nil {
+			m.record(name, func(h *NodeHealth) {
+				h.Alive = true
+				h.SyncedToChain = info.SyncedToChain
+				h.BlockHeight = info.BlockHeight
+				h.NumPeers = info.NumPeers
+				h.LastAliveAt = h.CheckedAt
+			})
+			return
+		}
+	}
+	m.record(name, func(h *NodeHealth) {
+		h.Alive = false
+		h.LastError = err.Error()
+		h.LastErrorAt = h.CheckedAt
+		h.Failures++
+	})
+}
+
+// record updates the health of the node with the result of a check.
+func (m *HealthMonitor) record(name string, update func(*NodeHealth)) {
+	state, _ := m.pool.State(name)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h := m.nodes[name]
+	h.Name = name
+	h.Connection = state.String()
+	now := time.Now()
+	h.CheckedAt = &now
+	h.Checks++
+	update(&h)
+	m.nodes[name] = h
+}
+
+// Node returns the health of the node, false if it is not configured.
+func (m *HealthMonitor) Node(name string) (NodeHealth, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	h, ok := m.nodes[name]
+	return h, ok
+}
+
+// Status returns the health of every node, sorted by name.
+func (m *HealthMonitor) Status() []NodeHealth {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	status := make([]NodeHealth, 0, len(m.nodes))
+	for _, h := range m.nodes {
+		status = append(status, h)
+	}
+	sort.Slice(status, func(i, j int) bool { return status[i].Name < status[j].Name })
+	return status
+}
 
-		if err != nil {
-			fmt.Println("Lnd Node %v is not alive", key)
-		} else {
-			Livenode.Add(key)
+// Alive returns the names of the nodes whose latest check succeeded, sorted.
+func (m *HealthMonitor) Alive() []string {
+	var alive []string
+	for _, h := range m.Status() {
+		if h.Alive {
+			alive = append(alive, h.Name)
 		}
 	}
+	return alive
 }
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/jualy007/GoTF/blockchain/lighting/lsp"
+	"github.com/jualy007/GoTF/config"
+)
+
+var (
+	swapProviderMu sync.Mutex
+	swapProvider   lsp.SwapProvider
+)
+
+// SwapProvider returns the swap provider configured in config.Cfg.Lsp, swapping the funds of its
+// lnd node of LndPool.
+func SwapProvider() (lsp.SwapProvider, error) {
+	swapProviderMu.Lock()
+	defer swapProviderMu.Unlock()
+
+	if swapProvider != nil {
+		return swapProvider, nil
+	}
+	if config.Cfg == nil || config.Cfg.Lsp.Lnd == "" {
+		return nil, errors.New("No swap provider configured")
+	}
+	adapter, err := LndPool().Get(config.Cfg.Lsp.Lnd)
+	if err != nil {
+		return nil, err
+	}
+	b, err := Bitcoind()
+	if err != nil {
+		return nil, err
+	}
+	p, err := lsp.New(config.Cfg.Lsp, adapter, b)
+	if err != nil {
+		return nil, err
+	}
+	swapProvider = p
+	return swapProvider, nil
+}
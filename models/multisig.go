@@ -0,0 +1,214 @@
+package models
+
+import (
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/jualy007/GoTF/blockchain/btc/multisig"
+	"github.com/jualy007/GoTF/config"
+)
+
+type KeysRequest struct {
+	Count int `json:"count"`
+}
+
+type AddressRequest struct {
+	M          int                 `json:"m"`
+	N          int                 `json:"n"`
+	PublicKeys []string            `json:"publicKeys"`
+	Type       multisig.ScriptType `json:"type"`
+	Sort       bool                `json:"sort"`
+	Network    string              `json:"network"`
+}
+
+type PayoutRequest struct {
+	Type          multisig.ScriptType `json:"type"`
+	Script        string              `json:"script"`
+	Inputs        []multisig.UTXO     `json:"inputs"`
+	Outputs       []multisig.Payment  `json:"outputs"`
+	FeeRate       int64               `json:"feeRate"`
+	ChangeAddress string              `json:"changeAddress"`
+	Network       string              `json:"network"`
+}
+
+// SignaturesRequest submits partial signatures, or a WIF private key to sign every input with.
+type SignaturesRequest struct {
+	Signatures []multisig.PartialSignature `json:"signatures"`
+	PrivateKey string                      `json:"privateKey"`
+}
+
+var (
+	payoutsMu sync.Mutex
+	Payouts   = make(map[string]*PayoutSession)
+)
+
+// PayoutSession is an unsigned multisig payout collecting partial signatures.
+// Transaction is set to the signed transaction once every input has M signatures.
+type PayoutSession struct {
+	Id                  string                      `json:"id"`
+	UnsignedTransaction string                      `json:"unsignedTransaction"`
+	Fee                 int64                       `json:"fee"`
+	ChangeIndex         int                         `json:"changeIndex"`
+	Required            int                         `json:"required"`
+	Signatures          []multisig.PartialSignature `json:"signatures"`
+	Transaction         string                      `json:"transaction,omitempty"`
+
+	payout *multisig.Payout
+}
+
+// copy returns a copy of the session the caller may serialize while signatures are added.
+func (s *PayoutSession) copy() *PayoutSession {
+	c := *s
+	c.Signatures = append([]multisig.PartialSignature{}, s.Signatures...)
+	return &c
+}
+
+// BtcNet returns the chain parameters for a network name, defaulting to
+// mainnet or testnet as configured for the bitcoind node.
+func BtcNet(network string) (*chaincfg.Params, error) {
+	if network == "" && config.Cfg != nil && !config.Cfg.Btc.Mainnet {
+		network = "testnet"
+	}
+	return multisig.ParseNet(network)
+}
+
+func (r *AddressRequest) Generate() (*multisig.MultisigAddress, error) {
+	scriptType, err := multisig.ParseScriptType(string(r.Type))
+	if err != nil {
+		return nil, err
+	}
+	net, err := BtcNet(r.Network)
+	if err != nil {
+		return nil, err
+	}
+	return multisig.GenerateAddress(r.M, r.N, r.PublicKeys, multisig.AddressOptions{Type: scriptType, Sort: r.Sort, Net: net})
+}
+
+func (r *PayoutRequest) Params() (multisig.PayoutParams, error) {
+	params := multisig.PayoutParams{Inputs: r.Inputs, Outputs: r.Outputs, FeeRate: r.FeeRate, ChangeAddress: r.ChangeAddress}
+	var err error
+	if params.Type, err = multisig.ParseScriptType(string(r.Type)); err != nil {
+		return params, err
+	}
+	if params.Net, err = BtcNet(r.Network); err != nil {
+		return params, err
+	}
+	if params.Script, err = hex.DecodeString(r.Script); err != nil {
+		return params, &multisig.RedeemScriptError{RedeemScript: r.Script, Err: err}
+	}
+	return params, nil
+}
+
+func AddPayout(payout *multisig.Payout) (*PayoutSession, error) {
+	unsigned, err := payout.UnsignedTransaction()
+	if err != nil {
+		return nil, err
+	}
+
+	payoutsMu.Lock()
+	defer payoutsMu.Unlock()
+	session := &PayoutSession{
+		Id:                  "payout" + strconv.FormatInt(time.Now().UnixNano(), 10),
+		UnsignedTransaction: unsigned,
+		Fee:                 payout.Fee,
+		ChangeIndex:         payout.ChangeIndex,
+		Required:            payout.Required(),
+		Signatures:          []multisig.PartialSignature{},
+		payout:              payout,
+	}
+	Payouts[session.Id] = session
+	return session.copy(), nil
+}
+
+// GetPayout returns a copy of the payout, later signatures are not added to it.
+func GetPayout(Id string) (*PayoutSession, error) {
+	payoutsMu.Lock()
+	defer payoutsMu.Unlock()
+	if v, ok := Payouts[Id]; ok {
+		return v.copy(), nil
+	}
+	return nil, errors.New("Payout Not Exist")
+}
+
+// SignPayout signs every input of the payout with a WIF private key and adds the signatures.
+func SignPayout(Id string, privateKeyWIF string) (*PayoutSession, error) {
+	session, err := GetPayout(Id)
+	if err != nil {
+		return nil, err
+	}
+	privateKey, err := multisig.DecodePrivateKey(privateKeyWIF)
+	if err != nil {
+		return nil, err
+	}
+	signatures, err := session.payout.Sign(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return AddSignatures(Id, signatures)
+}
+
+// AddSignatures verifies and adds partial signatures to the payout, replacing earlier signatures
+// of the same input by the same key. Nothing is added if any signature is invalid.
+// It returns a copy of the payout with the signatures added.
+func AddSignatures(Id string, signatures []multisig.PartialSignature) (*PayoutSession, error) {
+	current, err := GetPayout(Id)
+	if err != nil {
+		return nil, err
+	}
+	for i := range signatures {
+		signatures[i].PublicKey = strings.ToLower(signatures[i].PublicKey)
+		if err := current.payout.VerifySignature(signatures[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	payoutsMu.Lock()
+	defer payoutsMu.Unlock()
+	session, ok := Payouts[Id]
+	if !ok {
+		return nil, errors.New("Payout Not Exist")
+	}
+	if session.Transaction != "" {
+		return nil, errors.New("Payout Already Signed")
+	}
+	for _, s := range signatures {
+		replaced := false
+		for i, existing := range session.Signatures {
+			if existing.Input == s.Input && existing.PublicKey == s.PublicKey {
+				session.Signatures[i], replaced = s, true
+			}
+		}
+		if !replaced {
+			session.Signatures = append(session.Signatures, s)
+		}
+	}
+
+	signers := make(map[int]int)
+	for _, s := range session.Signatures {
+		signers[s.Input]++
+	}
+	for input := range session.payout.Inputs {
+		if signers[input] < session.Required {
+			return session.copy(), nil
+		}
+	}
+	tx, err := session.payout.Finalize(session.Signatures)
+	if err != nil {
+		return nil, err
+	}
+	if session.Transaction, err = multisig.SerializeTx(tx); err != nil {
+		return nil, err
+	}
+	return session.copy(), nil
+}
+
+func DeletePayout(Id string) {
+	payoutsMu.Lock()
+	defer payoutsMu.Unlock()
+	delete(Payouts, Id)
+}
@@ -8,6 +8,8 @@
 package routers
 
 import (
+	"expvar"
+
 	"github.com/astaxie/beego"
 	"github.com/jualy007/GoTF/controllers"
 )
@@ -51,9 +53,22 @@ func init() {
 		),
 		beego.NSNamespace("/lightning",
 			beego.NSInclude(
+				&controllers.LightningHealthController{},
+				&controllers.Bolt11Controller{},
 				&controllers.LightningController{},
 			),
 		),
+		beego.NSNamespace("/btc",
+			beego.NSInclude(
+				&controllers.BtcController{},
+			),
+			beego.NSNamespace("/multisig",
+				beego.NSInclude(
+					&controllers.MultisigController{},
+				),
+			),
+		),
+		beego.NSHandler("/debug/vars", expvar.Handler()),
 		beego.NSNamespace("/debug/pprof",
 			beego.NSInclude(
 				&controllers.ProfController{},